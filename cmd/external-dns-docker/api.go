@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/bkero/external-dns-docker/pkg/controller"
+	"github.com/bkero/external-dns-docker/pkg/endpoint"
+	"github.com/bkero/external-dns-docker/pkg/plan"
+	"github.com/bkero/external-dns-docker/pkg/provider"
+)
+
+// apiEndpoint is the JSON form of an endpoint.
+type apiEndpoint = plan.Record
+
+// apiPlan is the JSON form of a change set, the same document
+// --plan-output writes.
+type apiPlan struct {
+	plan.Document
+	SkippedZones []string `json:"skippedZones,omitempty"`
+}
+
+// apiRecords is the response of GET /api/v1/records.
+type apiRecords struct {
+	Records    []apiEndpoint     `json:"records"`
+	ZoneErrors map[string]string `json:"zoneErrors,omitempty"`
+}
+
+// apiStatus is the response of GET /api/v1/status.
+type apiStatus struct {
+	LastRun         *time.Time     `json:"lastRun,omitempty"`
+	DurationSeconds float64        `json:"durationSeconds,omitempty"`
+	Result          string         `json:"result,omitempty"`
+	Error           string         `json:"error,omitempty"`
+	Changes         map[string]int `json:"changes,omitempty"`
+	Ready           bool           `json:"ready"`
+	Leader          bool           `json:"leader"`
+	DeletesBlocked  bool           `json:"deletesBlocked"`
+	DegradedZones   []string       `json:"degradedZones,omitempty"`
+}
+
+// registerAPI adds the admin API under /api/v1/ to mux:
+//
+//	GET  /api/v1/desired    endpoints the sources want, with their origin
+//	GET  /api/v1/records    records the provider holds
+//	GET  /api/v1/plan       changes the next cycle would make
+//	POST /api/v1/reconcile  start a cycle now
+//	GET  /api/v1/status     result of the last cycle
+//
+// The read endpoints query Docker and the DNS server on every request; they
+// do not change controller state. Every endpoint requires adminToken (see
+// adminAuth), as they expose the zones' records and let callers start cycles.
+func registerAPI(mux *http.ServeMux, ctrl *controller.Controller, adminToken string, log *slog.Logger) {
+	handle := func(path, method string, h http.HandlerFunc) {
+		mux.HandleFunc(path, adminAuth(adminToken, allow(method, h)))
+	}
+	handle("/api/v1/desired", http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		snap, err := ctrl.Preview(r.Context())
+		if err != nil {
+			writeError(w, log, http.StatusBadGateway, err)
+			return
+		}
+		writeJSON(w, log, http.StatusOK, toAPIEndpoints(snap.Desired))
+	})
+	handle("/api/v1/records", http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		records, err := ctrl.Records(r.Context())
+		var zerrs *provider.ZoneErrors
+		if err != nil && !errors.As(err, &zerrs) {
+			writeError(w, log, http.StatusBadGateway, err)
+			return
+		}
+		resp := apiRecords{Records: toAPIEndpoints(records)}
+		for _, z := range zerrs.Zones() {
+			if resp.ZoneErrors == nil {
+				resp.ZoneErrors = make(map[string]string)
+			}
+			resp.ZoneErrors[z] = zerrs.Errors[z].Error()
+		}
+		writeJSON(w, log, http.StatusOK, resp)
+	})
+	handle("/api/v1/plan", http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		snap, err := ctrl.Preview(r.Context())
+		if err != nil {
+			writeError(w, log, http.StatusBadGateway, err)
+			return
+		}
+		writeJSON(w, log, http.StatusOK, apiPlan{Document: snap.Changes.Document(), SkippedZones: snap.SkippedZones})
+	})
+	handle("/api/v1/reconcile", http.MethodPost, func(w http.ResponseWriter, _ *http.Request) {
+		if !ctrl.IsLeader() {
+			writeError(w, log, http.StatusConflict, errors.New("not the leader; send the request to the leading replica"))
+			return
+		}
+		ctrl.Trigger()
+		writeJSON(w, log, http.StatusAccepted, map[string]string{"status": "reconcile triggered"})
+	})
+	handle("/api/v1/status", http.MethodGet, func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, log, http.StatusOK, statusOf(ctrl))
+	})
+}
+
+// allow wraps h so that requests with any other method get 405.
+func allow(method string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// statusOf summarises the controller's last cycle and current state.
+func statusOf(ctrl *controller.Controller) apiStatus {
+	s := apiStatus{
+		Ready:          ctrl.IsReady(),
+		Leader:         ctrl.IsLeader(),
+		DeletesBlocked: ctrl.DeletesBlocked(),
+		DegradedZones:  ctrl.DegradedZones(),
+	}
+	cycle, ok := ctrl.LastCycle()
+	if !ok {
+		return s
+	}
+	s.LastRun = &cycle.Start
+	s.DurationSeconds = cycle.Duration.Seconds()
+	s.Result = cycle.Result
+	if cycle.Err != nil {
+		s.Error = cycle.Err.Error()
+	}
+	s.Changes = map[string]int{"create": cycle.Create, "update": cycle.Update, "delete": cycle.Delete}
+	return s
+}
+
+// toAPIEndpoints converts eps to their JSON form, sorted by name and type so
+// that responses are stable.
+func toAPIEndpoints(eps []*endpoint.Endpoint) []apiEndpoint {
+	out := make([]apiEndpoint, 0, len(eps))
+	for _, ep := range eps {
+		out = append(out, plan.NewRecord(ep))
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].Type < out[j].Type
+	})
+	return out
+}
+
+// writeJSON writes v as the JSON response body with the given status.
+func writeJSON(w http.ResponseWriter, log *slog.Logger, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Warn("api: writing response failed", "err", err)
+	}
+}
+
+// writeError writes err as a JSON {"error": ...} body with the given status.
+func writeError(w http.ResponseWriter, log *slog.Logger, status int, err error) {
+	writeJSON(w, log, status, map[string]string{"error": err.Error()})
+}
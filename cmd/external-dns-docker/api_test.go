@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bkero/external-dns-docker/pkg/controller"
+	"github.com/bkero/external-dns-docker/pkg/endpoint"
+	"github.com/bkero/external-dns-docker/pkg/plan"
+	fake_provider "github.com/bkero/external-dns-docker/pkg/provider/fake"
+	fake_source "github.com/bkero/external-dns-docker/pkg/source/fake"
+)
+
+// testAdminToken is the admin token of the test API servers.
+const testAdminToken = "test-token"
+
+// newTestAPI returns a server for the admin API of a once-mode controller
+// whose source wants app.example.com and whose provider holds nothing.
+func newTestAPI(t *testing.T) (*httptest.Server, *controller.Controller) {
+	t.Helper()
+	app := endpoint.New("app.example.com", []string{"10.0.0.1"}, endpoint.RecordTypeA, 300,
+		map[string]string{endpoint.LabelSource: "container/web-1,container/web-2"})
+	ctrl := controller.New(fake_source.New([]*endpoint.Endpoint{app}), fake_provider.New(nil), slog.Default(), controller.Config{Once: true})
+	mux := http.NewServeMux()
+	registerAPI(mux, ctrl, testAdminToken, slog.Default())
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, ctrl
+}
+
+// do sends an admin API request carrying token.
+func do(t *testing.T, method, url, token string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	return resp
+}
+
+// getJSON fetches url, checks the status and decodes the body into v.
+func getJSON(t *testing.T, url string, wantStatus int, v any) {
+	t.Helper()
+	resp := do(t, http.MethodGet, url, testAdminToken)
+	defer resp.Body.Close()
+	if resp.StatusCode != wantStatus {
+		t.Fatalf("GET %s: status %d, want %d", url, resp.StatusCode, wantStatus)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("GET %s: Content-Type %q, want application/json", url, ct)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("GET %s: decoding body: %v", url, err)
+	}
+}
+
+func TestAPI_Desired_ListsSources(t *testing.T) {
+	srv, _ := newTestAPI(t)
+
+	var got []apiEndpoint
+	getJSON(t, srv.URL+"/api/v1/desired", http.StatusOK, &got)
+	if len(got) != 1 || got[0].Name != "app.example.com" {
+		t.Fatalf("desired = %+v, want app.example.com", got)
+	}
+	if len(got[0].Sources) != 2 || got[0].Sources[0] != "container/web-1" {
+		t.Errorf("sources = %v, want [container/web-1 container/web-2]", got[0].Sources)
+	}
+	if _, ok := got[0].Labels[endpoint.LabelSource]; ok {
+		t.Error("source label repeated under labels")
+	}
+}
+
+func TestAPI_Plan_ShowsPendingCreates(t *testing.T) {
+	srv, _ := newTestAPI(t)
+
+	var got apiPlan
+	getJSON(t, srv.URL+"/api/v1/plan", http.StatusOK, &got)
+	var names []string
+	for _, c := range got.Create {
+		if c.Type == endpoint.RecordTypeA {
+			names = append(names, c.Name)
+		}
+	}
+	if len(names) != 1 || names[0] != "app.example.com" {
+		t.Errorf("planned A creates = %v, want [app.example.com]", names)
+	}
+	if got.Update == nil || got.Delete == nil {
+		t.Error("empty update and delete lists should be [] rather than null")
+	}
+}
+
+func TestAPI_Records_AfterReconcile(t *testing.T) {
+	srv, ctrl := newTestAPI(t)
+
+	var got apiRecords
+	getJSON(t, srv.URL+"/api/v1/records", http.StatusOK, &got)
+	if len(got.Records) != 0 {
+		t.Fatalf("records before reconcile = %+v, want none", got.Records)
+	}
+
+	if err := runOnce(ctrl); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	getJSON(t, srv.URL+"/api/v1/records", http.StatusOK, &got)
+	found := false
+	for _, r := range got.Records {
+		found = found || (r.Name == "app.example.com" && r.Type == endpoint.RecordTypeA)
+	}
+	if !found {
+		t.Errorf("records = %+v, want an A record for app.example.com", got.Records)
+	}
+}
+
+func TestAPI_Status(t *testing.T) {
+	srv, ctrl := newTestAPI(t)
+
+	var got apiStatus
+	getJSON(t, srv.URL+"/api/v1/status", http.StatusOK, &got)
+	if got.LastRun != nil || got.Ready || !got.Leader {
+		t.Errorf("status before first cycle = %+v", got)
+	}
+
+	if err := runOnce(ctrl); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	got = apiStatus{}
+	getJSON(t, srv.URL+"/api/v1/status", http.StatusOK, &got)
+	if got.LastRun == nil || got.Result != "success" || !got.Ready || got.Changes["create"] == 0 {
+		t.Errorf("status after cycle = %+v", got)
+	}
+}
+
+func TestAPI_Reconcile_RequiresPost(t *testing.T) {
+	srv, _ := newTestAPI(t)
+
+	resp := do(t, http.MethodGet, srv.URL+"/api/v1/reconcile", testAdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != http.MethodPost {
+		t.Errorf("GET: status %d, Allow %q; want 405 POST", resp.StatusCode, resp.Header.Get("Allow"))
+	}
+
+	resp = do(t, http.MethodPost, srv.URL+"/api/v1/reconcile", testAdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("POST: status %d, want 202", resp.StatusCode)
+	}
+}
+
+func TestAPI_RequiresAdminToken(t *testing.T) {
+	srv, _ := newTestAPI(t)
+
+	for _, path := range []string{"/api/v1/desired", "/api/v1/records", "/api/v1/plan", "/api/v1/status"} {
+		resp := do(t, http.MethodGet, srv.URL+path, "")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("GET %s without a token: status %d, want 401", path, resp.StatusCode)
+		}
+	}
+	resp := do(t, http.MethodPost, srv.URL+"/api/v1/reconcile", "wrong")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("POST /api/v1/reconcile with a wrong token: status %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestAPI_NoAdminToken_Disabled(t *testing.T) {
+	ctrl := controller.New(fake_source.New(nil), fake_provider.New(nil), slog.Default(), controller.Config{Once: true})
+	mux := http.NewServeMux()
+	registerAPI(mux, ctrl, "", slog.Default())
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp := do(t, http.MethodGet, srv.URL+"/api/v1/records", "anything")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("status %d, want 403 without --admin-token-file", resp.StatusCode)
+	}
+}
+
+func TestAPI_UpstreamError_Returns502(t *testing.T) {
+	ctrl := controller.New(fake_source.New(nil), failingProvider{}, slog.Default(), controller.Config{})
+	mux := http.NewServeMux()
+	registerAPI(mux, ctrl, testAdminToken, slog.Default())
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	var got map[string]string
+	getJSON(t, srv.URL+"/api/v1/plan", http.StatusBadGateway, &got)
+	if got["error"] == "" {
+		t.Error("expected an error message")
+	}
+}
+
+// runOnce runs a single reconciliation cycle on ctrl, which must be in once
+// mode.
+func runOnce(ctrl *controller.Controller) error {
+	return ctrl.Run(context.Background())
+}
+
+// failingProvider is a provider.Provider whose every call fails.
+type failingProvider struct{}
+
+func (failingProvider) Records(context.Context) ([]*endpoint.Endpoint, error) {
+	return nil, errors.New("server unreachable")
+}
+
+func (failingProvider) ApplyChanges(context.Context, *plan.Changes) error {
+	return errors.New("server unreachable")
+}
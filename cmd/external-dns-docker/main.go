@@ -3,14 +3,19 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/subtle"
+	"encoding/base64"
 	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -19,10 +24,13 @@ import (
 	"time"
 
 	dockerclient "github.com/docker/docker/client"
+	"github.com/miekg/dns"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.yaml.in/yaml/v2"
 
 	"github.com/bkero/external-dns-docker/pkg/controller"
+	"github.com/bkero/external-dns-docker/pkg/endpoint"
+	"github.com/bkero/external-dns-docker/pkg/plan"
 	"github.com/bkero/external-dns-docker/pkg/provider"
 	"github.com/bkero/external-dns-docker/pkg/provider/rfc2136"
 	"github.com/bkero/external-dns-docker/pkg/source"
@@ -33,14 +41,33 @@ type preflightProvider interface {
 	Preflight(ctx context.Context) error
 }
 
+// leaseZoneProvider is satisfied by both *rfc2136.Provider and
+// *rfc2136.MultiProvider.
+type leaseZoneProvider interface {
+	LeaseZone(zone string) *rfc2136.Provider
+}
+
+// watchSource is satisfied by *source.DockerSource and *source.SwarmSource.
+type watchSource interface {
+	source.Source
+	Watch(ctx context.Context)
+	Close() error
+}
+
+// Endpoint source kinds accepted by --source.
+const (
+	sourceDocker = "docker"
+	sourceSwarm  = "swarm"
+)
+
 func main() {
 	// ---- RFC2136 provider flags (Mode 1: single-zone) ----
 	rfc2136Host := flag.String("rfc2136-host",
 		envOr("EXTERNAL_DNS_RFC2136_HOST", ""),
-		"RFC2136 DNS server host (single-zone mode)")
+		"RFC2136 DNS server host (single-zone mode); a comma-separated list is tried in order, each entry optionally host:port")
 	rfc2136Port := flag.Int("rfc2136-port",
-		envOrInt("EXTERNAL_DNS_RFC2136_PORT", 53),
-		"RFC2136 DNS server port")
+		envOrInt("EXTERNAL_DNS_RFC2136_PORT", 0),
+		"RFC2136 DNS server port (0 = 53, or 853 with --rfc2136-transport=tcp-tls)")
 	rfc2136Zone := flag.String("rfc2136-zone",
 		envOr("EXTERNAL_DNS_RFC2136_ZONE", ""),
 		"DNS zone to manage (single-zone mode)")
@@ -52,7 +79,23 @@ func main() {
 		"TSIG secret (base64-encoded); mutually exclusive with --rfc2136-tsig-secret-file")
 	rfc2136TSIGSecretFile := flag.String("rfc2136-tsig-secret-file",
 		envOr("EXTERNAL_DNS_RFC2136_TSIG_SECRET_FILE", ""),
-		"Path to file containing base64-encoded TSIG secret; mutually exclusive with --rfc2136-tsig-secret")
+		"Path to file containing base64-encoded TSIG secret, re-read when it changes; mutually exclusive with --rfc2136-tsig-secret")
+	rfc2136TSIGKeyFile := flag.String("rfc2136-tsig-keyfile",
+		envOr("EXTERNAL_DNS_RFC2136_TSIG_KEYFILE", ""),
+		"Path to a BIND key file (key \"name\" { algorithm ...; secret \"...\"; };) holding the TSIG key; "+
+			"--rfc2136-tsig-key picks one of several keys. Replaces --rfc2136-tsig-secret and --rfc2136-tsig-alg")
+	rfc2136TSIGFallbackKey := flag.String("rfc2136-tsig-fallback-key",
+		envOr("EXTERNAL_DNS_RFC2136_TSIG_FALLBACK_KEY", ""),
+		"Second TSIG key name, used when the server rejects the first (e.g. during key rotation)")
+	rfc2136TSIGFallbackSecretFile := flag.String("rfc2136-tsig-fallback-secret-file",
+		envOr("EXTERNAL_DNS_RFC2136_TSIG_FALLBACK_SECRET_FILE", ""),
+		"Path to file containing the base64-encoded secret of --rfc2136-tsig-fallback-key, re-read when it changes")
+	rfc2136TSIGFallbackAlg := flag.String("rfc2136-tsig-fallback-alg",
+		envOr("EXTERNAL_DNS_RFC2136_TSIG_FALLBACK_ALG", ""),
+		"TSIG algorithm of --rfc2136-tsig-fallback-key (default: --rfc2136-tsig-alg)")
+	rfc2136SIG0KeyFile := flag.String("rfc2136-sig0-key-file",
+		envOr("EXTERNAL_DNS_RFC2136_SIG0_KEY_FILE", ""),
+		"Path to a BIND K*.private key, with its .key file alongside, to sign with SIG(0) instead of TSIG")
 	rfc2136TSIGAlg := flag.String("rfc2136-tsig-alg",
 		envOr("EXTERNAL_DNS_RFC2136_TSIG_ALG", "hmac-sha256"),
 		"TSIG algorithm (e.g. hmac-sha256, hmac-sha512)")
@@ -62,6 +105,36 @@ func main() {
 	rfc2136Timeout := flag.Duration("rfc2136-timeout",
 		envOrDuration("EXTERNAL_DNS_RFC2136_TIMEOUT", 10*time.Second),
 		"Timeout for RFC2136 DNS operations (AXFR and UPDATE)")
+	rfc2136BatchSize := flag.Int("rfc2136-batch-size",
+		envOrInt("EXTERNAL_DNS_RFC2136_BATCH_SIZE", 0),
+		"Maximum records per UPDATE message; larger change sets are split (0 = limited only by the 64 KiB message size)")
+	rfc2136Prerequisites := flag.Bool("rfc2136-prerequisites",
+		envOrBool("EXTERNAL_DNS_RFC2136_PREREQUISITES", false),
+		"Make UPDATEs conditional on the records being unchanged since they were read; conflicts trigger a replan")
+	rfc2136Transport := flag.String("rfc2136-transport",
+		envOr("EXTERNAL_DNS_RFC2136_TRANSPORT", rfc2136.TransportTCP),
+		"DNS transport: tcp, udp (TCP for large messages, truncated answers and transfers) or tcp-tls (DNS over TLS)")
+	rfc2136TLSCAFile := flag.String("rfc2136-tls-ca-file",
+		envOr("EXTERNAL_DNS_RFC2136_TLS_CA_FILE", ""),
+		"PEM CA bundle for verifying the DNS server with --rfc2136-transport=tcp-tls (default: system roots)")
+	rfc2136TLSCertFile := flag.String("rfc2136-tls-cert-file",
+		envOr("EXTERNAL_DNS_RFC2136_TLS_CERT_FILE", ""),
+		"PEM client certificate for --rfc2136-transport=tcp-tls; requires --rfc2136-tls-key-file")
+	rfc2136TLSKeyFile := flag.String("rfc2136-tls-key-file",
+		envOr("EXTERNAL_DNS_RFC2136_TLS_KEY_FILE", ""),
+		"PEM client private key for --rfc2136-transport=tcp-tls; requires --rfc2136-tls-cert-file")
+	rfc2136TLSServerName := flag.String("rfc2136-tls-server-name",
+		envOr("EXTERNAL_DNS_RFC2136_TLS_SERVER_NAME", ""),
+		"Name to verify in the DNS server's certificate (default: the host it is dialled by)")
+	rfc2136Verify := flag.Bool("rfc2136-verify",
+		envOrBool("EXTERNAL_DNS_RFC2136_VERIFY", false),
+		"Query created and updated records back from the DNS server after each update and report any it does not serve")
+	rfc2136VerifySecondaries := flag.String("rfc2136-verify-secondaries",
+		envOr("EXTERNAL_DNS_RFC2136_VERIFY_SECONDARIES", ""),
+		"Comma-separated secondary servers polled with --rfc2136-verify until they serve the update, to measure propagation")
+	rfc2136VerifyTimeout := flag.Duration("rfc2136-verify-timeout",
+		envOrDuration("EXTERNAL_DNS_RFC2136_VERIFY_TIMEOUT", 5*time.Second),
+		"How long --rfc2136-verify-secondaries are polled before the update is reported unverified")
 
 	// ---- RFC2136 provider flags (Mode 3: YAML config file) ----
 	rfc2136ConfigFile := flag.String("rfc2136-config-file",
@@ -69,6 +142,9 @@ func main() {
 		"Path to YAML file defining multiple RFC2136 zones (mutually exclusive with single-zone flags)")
 
 	// ---- Docker source flags ----
+	sourceFlag := flag.String("source",
+		envOr("EXTERNAL_DNS_SOURCE", sourceDocker),
+		"Comma-separated endpoint sources: docker (container labels), swarm (service labels)")
 	dockerHost := flag.String("docker-host",
 		envOr("EXTERNAL_DNS_DOCKER_HOST", ""),
 		"Docker daemon address (e.g. unix:///var/run/docker.sock, tcp://host:2376)")
@@ -92,13 +168,73 @@ func main() {
 	once := flag.Bool("once",
 		envOrBool("EXTERNAL_DNS_ONCE", false),
 		"Run exactly one reconciliation cycle and exit")
+	detailedExitCode := flag.Bool("detailed-exit-code",
+		envOrBool("EXTERNAL_DNS_DETAILED_EXIT_CODE", false),
+		"With --once, exit 0 when in sync, 2 when changes were applied or planned, 3 when the delete safety threshold blocked them and 1 on errors")
 	dryRun := flag.Bool("dry-run",
 		envOrBool("EXTERNAL_DNS_DRY_RUN", false),
 		"Log planned DNS changes without applying them")
+	planOutput := flag.String("plan-output",
+		envOr("EXTERNAL_DNS_PLAN_OUTPUT", ""),
+		"With --once --dry-run, write the planned changes to this file; the extension selects the format: .json, .yaml/.yml or .nsupdate")
 	ownerID := flag.String("owner-id",
 		envOr("EXTERNAL_DNS_OWNER_ID", ""),
 		"Ownership identifier written to TXT records (default: external-dns-docker)")
 
+	policyFlag := flag.String("policy",
+		envOr("EXTERNAL_DNS_POLICY", string(plan.PolicySync)),
+		"Sync policy: sync (create, update, delete), upsert-only (never delete), create-only (never update or delete)")
+	domainFilter := flag.String("domain-filter",
+		envOr("EXTERNAL_DNS_DOMAIN_FILTER", ""),
+		"Comma-separated domains to manage; other names are ignored (e.g. apps.example.com, *.apps.example.com)")
+	excludeDomains := flag.String("exclude-domains",
+		envOr("EXTERNAL_DNS_EXCLUDE_DOMAINS", ""),
+		"Comma-separated domains never to manage, even inside --domain-filter")
+	regexDomainFilter := flag.String("regex-domain-filter",
+		envOr("EXTERNAL_DNS_REGEX_DOMAIN_FILTER", ""),
+		"Regular expression names must match to be managed")
+	regexDomainExclusion := flag.String("regex-domain-exclusion",
+		envOr("EXTERNAL_DNS_REGEX_DOMAIN_EXCLUSION", ""),
+		"Regular expression for names never to manage")
+	deleteGrace := flag.Duration("delete-grace",
+		envOrDuration("EXTERNAL_DNS_DELETE_GRACE", 0),
+		"Keep records of vanished containers this long before deleting them (0 = delete immediately)")
+	autoPTR := flag.Bool("auto-ptr",
+		envOrBool("EXTERNAL_DNS_AUTO_PTR", false),
+		"Create PTR records for every A/AAAA record whose reverse zone is managed (otherwise only for external-dns.io/ptr=true)")
+	maxDeletes := flag.Int("max-deletes",
+		envOrInt("EXTERNAL_DNS_MAX_DELETES", 0),
+		"Refuse to apply a change set deleting more than this many records (0 = disabled)")
+	maxDeletePercent := flag.Float64("max-delete-percent",
+		envOrFloat64("EXTERNAL_DNS_MAX_DELETE_PERCENT", 0),
+		"Refuse to apply a change set deleting more than this percentage of owned records (0 = disabled)")
+	overrideDeleteThreshold := flag.Bool("override-delete-threshold",
+		envOrBool("EXTERNAL_DNS_OVERRIDE_DELETE_THRESHOLD", false),
+		"Apply the first change set that exceeds --max-deletes or --max-delete-percent anyway")
+
+	// ---- Leader election flags ----
+	leaderElect := flag.Bool("leader-elect",
+		envOrBool("EXTERNAL_DNS_LEADER_ELECT", false),
+		"Elect a leader among replicas via a lease TXT record; only the leader applies changes")
+	leaseName := flag.String("leader-election-lease-name",
+		envOr("EXTERNAL_DNS_LEADER_ELECTION_LEASE_NAME", ""),
+		"DNS name of the lease TXT record (default: <owner-id>-leader.<lease zone>)")
+	leaseZone := flag.String("leader-election-zone",
+		envOr("EXTERNAL_DNS_LEADER_ELECTION_ZONE", ""),
+		"Zone holding the lease, reached via the first zone's servers and key (default: first zone); every renewal bumps its SOA serial, so a dedicated zone keeps managed zones from being re-transferred")
+	leaderID := flag.String("leader-election-id",
+		envOr("EXTERNAL_DNS_LEADER_ELECTION_ID", ""),
+		"Identity of this replica in the lease (default: hostname)")
+	leaseDuration := flag.Duration("leader-election-lease-duration",
+		envOrDuration("EXTERNAL_DNS_LEADER_ELECTION_LEASE_DURATION", 30*time.Second),
+		"How long followers wait for an unrenewed lease before taking over")
+	renewDeadline := flag.Duration("leader-election-renew-deadline",
+		envOrDuration("EXTERNAL_DNS_LEADER_ELECTION_RENEW_DEADLINE", 20*time.Second),
+		"How long the leader keeps leading while lease renewals fail")
+	retryPeriod := flag.Duration("leader-election-retry-period",
+		envOrDuration("EXTERNAL_DNS_LEADER_ELECTION_RETRY_PERIOD", 5*time.Second),
+		"Interval between lease acquire and renew attempts; each renewal is an UPDATE to the lease zone")
+
 	skipPreflight := flag.Bool("skip-preflight",
 		envOrBool("EXTERNAL_DNS_SKIP_PREFLIGHT", false),
 		"Skip the startup DNS connectivity and TSIG credential check")
@@ -117,6 +253,9 @@ func main() {
 	metricsPath := flag.String("metrics-path",
 		envOr("EXTERNAL_DNS_METRICS_PATH", "/metrics"),
 		"HTTP path for Prometheus metrics endpoint")
+	adminTokenFile := flag.String("admin-token-file",
+		envOr("EXTERNAL_DNS_ADMIN_TOKEN_FILE", ""),
+		"File holding the bearer token that enables the admin endpoints on the health port (unset = admin endpoints disabled)")
 
 	// ---- Shutdown flags ----
 	shutdownTimeout := flag.Duration("shutdown-timeout",
@@ -132,6 +271,47 @@ func main() {
 
 	log := newLogger(*logLevel)
 
+	policy, err := plan.ParsePolicy(*policyFlag)
+	if err != nil {
+		log.Error("invalid --policy", "err", err)
+		os.Exit(1)
+	}
+
+	domains, err := endpoint.NewDomainFilter(splitList(*domainFilter), splitList(*excludeDomains),
+		*regexDomainFilter, *regexDomainExclusion)
+	if err != nil {
+		log.Error("invalid domain filter", "err", err)
+		os.Exit(1)
+	}
+
+	if *maxDeletes < 0 || *maxDeletePercent < 0 || *maxDeletePercent > 100 {
+		log.Error("invalid delete threshold: --max-deletes must be >= 0 and --max-delete-percent between 0 and 100")
+		os.Exit(1)
+	}
+
+	if *detailedExitCode && !*once {
+		log.Error("--detailed-exit-code requires --once")
+		os.Exit(1)
+	}
+
+	var planFormat plan.Format
+	if *planOutput != "" {
+		if !*once || !*dryRun {
+			log.Error("--plan-output requires --once and --dry-run")
+			os.Exit(1)
+		}
+		if planFormat, err = planOutputFormat(*planOutput); err != nil {
+			log.Error("invalid --plan-output", "err", err)
+			os.Exit(1)
+		}
+	}
+
+	adminToken, err := readAdminToken(*adminTokenFile)
+	if err != nil {
+		log.Error("invalid --admin-token-file", "err", err)
+		os.Exit(1)
+	}
+
 	// ---- Mode detection and mutual-exclusivity ----
 	//
 	// Priority: Mode 3 (YAML file) > Mode 2 (env prefix) > Mode 1 (single-zone flags)
@@ -146,10 +326,11 @@ func main() {
 	}
 
 	var (
-		prov   provider.Provider
-		pfProv preflightProvider
-		mode   string // for startup log
-		zones  int    // for startup log (multi-zone only)
+		prov      provider.Provider
+		pfProv    preflightProvider
+		mode      string // for startup log
+		zones     int    // for startup log (multi-zone only)
+		firstZone string // default home of the leader-election lease
 	)
 
 	switch {
@@ -163,16 +344,23 @@ func main() {
 			log.Error("--rfc2136-config-file is mutually exclusive with EXTERNAL_DNS_RFC2136_ZONE_* env vars")
 			os.Exit(1)
 		}
-		configs, ferr := loadZoneConfigsFromFile(*rfc2136ConfigFile)
+		configs, discovery, ferr := loadZonesFile(*rfc2136ConfigFile)
 		if ferr != nil {
 			log.Error("failed to load zone config file", "path", *rfc2136ConfigFile, "err", ferr)
 			os.Exit(1)
 		}
 		mp := rfc2136.NewMulti(configs, log)
+		if discovery != nil {
+			mp.EnableDiscovery(*discovery)
+			log.Info("zone discovery enabled", "resolver", discovery.Resolver, "templates", len(discovery.Templates))
+		}
 		prov = mp
 		pfProv = mp
 		mode = "multi-zone (yaml-file)"
 		zones = len(configs)
+		if len(configs) > 0 {
+			firstZone = configs[0].Zone
+		}
 
 	case envModeActive:
 		// Mode 2: environment variable prefixes
@@ -185,6 +373,7 @@ func main() {
 		pfProv = mp
 		mode = "multi-zone (env-prefix)"
 		zones = len(envConfigs)
+		firstZone = envConfigs[0].Zone
 
 	case *rfc2136Host != "" && *rfc2136Zone != "":
 		// Mode 1: single-zone flags (original behaviour — fully backward compatible)
@@ -201,19 +390,91 @@ func main() {
 			}
 			tsigSecret = strings.TrimSpace(string(data))
 		}
+		auth := rfc2136.ZoneConfig{
+			TSIGKey:        *rfc2136TSIGKey,
+			TSIGSecret:     tsigSecret,
+			TSIGSecretFile: *rfc2136TSIGSecretFile,
+			TSIGAlg:        *rfc2136TSIGAlg,
+			TSIGKeyFile:    *rfc2136TSIGKeyFile,
+		}
+		if auth.TSIGKeyFile != "" && !explicitlySet(flag.CommandLine, "rfc2136-tsig-alg", "EXTERNAL_DNS_RFC2136_TSIG_ALG") {
+			// The key file names the algorithm; the flag's default would
+			// otherwise be taken for a conflicting choice. An algorithm
+			// given explicitly must match the file's.
+			auth.TSIGAlg = ""
+		}
+		if *rfc2136TSIGFallbackKey != "" || *rfc2136TSIGFallbackSecretFile != "" {
+			auth.TSIGKeys = []rfc2136.TSIGKey{{
+				Name:       *rfc2136TSIGFallbackKey,
+				SecretFile: *rfc2136TSIGFallbackSecretFile,
+				Alg:        *rfc2136TSIGFallbackAlg,
+			}}
+		}
+		if kerr := resolveTSIGKeyFile(&auth); kerr != nil {
+			log.Error("invalid TSIG key file configuration", "err", kerr)
+			os.Exit(1)
+		}
+		if kerr := resolveTSIGKeys(auth.TSIGKeys, auth.TSIGAlg); kerr != nil {
+			log.Error("invalid TSIG fallback key", "err", kerr)
+			os.Exit(1)
+		}
+		var sig0Key *rfc2136.SIG0Key
+		if *rfc2136SIG0KeyFile != "" {
+			if auth.TSIGKey != "" || auth.TSIGSecret != "" || len(auth.TSIGKeys) > 0 {
+				log.Error("--rfc2136-sig0-key-file and the TSIG flags are mutually exclusive")
+				os.Exit(1)
+			}
+			var kerr error
+			sig0Key, kerr = rfc2136.LoadSIG0Key(*rfc2136SIG0KeyFile)
+			if kerr != nil {
+				log.Error("failed to load SIG(0) key", "path", *rfc2136SIG0KeyFile, "err", kerr)
+				os.Exit(1)
+			}
+		}
+		transport := rfc2136.ZoneConfig{
+			Transport:     *rfc2136Transport,
+			TLSCAFile:     *rfc2136TLSCAFile,
+			TLSCertFile:   *rfc2136TLSCertFile,
+			TLSKeyFile:    *rfc2136TLSKeyFile,
+			TLSServerName: *rfc2136TLSServerName,
+		}
+		if terr := resolveTLS(&transport); terr != nil {
+			log.Error("invalid RFC2136 transport configuration", "err", terr)
+			os.Exit(1)
+		}
+		verify := rfc2136.ZoneConfig{
+			Verify:            *rfc2136Verify,
+			VerifySecondaries: splitHosts(*rfc2136VerifySecondaries),
+			VerifyTimeout:     *rfc2136VerifyTimeout,
+		}
+		if verr := checkVerify(verify); verr != nil {
+			log.Error("invalid RFC2136 verification configuration", "err", verr)
+			os.Exit(1)
+		}
 		sp := rfc2136.New(rfc2136.Config{
-			Host:          *rfc2136Host,
-			Port:          *rfc2136Port,
-			Zone:          *rfc2136Zone,
-			TSIGKeyName:   *rfc2136TSIGKey,
-			TSIGSecret:    tsigSecret,
-			TSIGSecretAlg: *rfc2136TSIGAlg,
-			MinTTL:        *rfc2136MinTTL,
-			Timeout:       *rfc2136Timeout,
+			Hosts:             splitHosts(*rfc2136Host),
+			Port:              *rfc2136Port,
+			Zone:              *rfc2136Zone,
+			TSIGKeyName:       auth.TSIGKey,
+			TSIGSecret:        auth.TSIGSecret,
+			TSIGSecretFile:    auth.TSIGSecretFile,
+			TSIGSecretAlg:     auth.TSIGAlg,
+			TSIGKeys:          auth.TSIGKeys,
+			SIG0Key:           sig0Key,
+			MinTTL:            *rfc2136MinTTL,
+			Timeout:           *rfc2136Timeout,
+			MaxUpdateRRs:      *rfc2136BatchSize,
+			Prerequisites:     *rfc2136Prerequisites,
+			Transport:         transport.Transport,
+			TLS:               transport.TLS,
+			Verify:            verify.Verify,
+			VerifySecondaries: verify.VerifySecondaries,
+			VerifyTimeout:     verify.VerifyTimeout,
 		}, log)
 		prov = sp
 		pfProv = sp
 		mode = "single-zone"
+		firstZone = *rfc2136Zone
 
 	default:
 		log.Error("no RFC2136 configuration provided; use --rfc2136-host/--rfc2136-zone, " +
@@ -231,16 +492,44 @@ func main() {
 			dockerclient.WithTLSClientConfig(*dockerTLSCA, *dockerTLSCert, *dockerTLSKey))
 	}
 
-	src, err := source.NewDockerSource(log, dockerOpts...)
+	kinds, err := parseSourceKinds(*sourceFlag)
 	if err != nil {
-		log.Error("failed to create Docker source", "err", err)
+		log.Error("invalid --source", "err", err)
 		os.Exit(1)
 	}
+	var (
+		watchers []watchSource
+		srcs     []source.Source
+	)
+	for _, kind := range kinds {
+		var (
+			ws   watchSource
+			serr error
+		)
+		switch kind {
+		case sourceDocker:
+			ws, serr = source.NewDockerSource(log, dockerOpts...)
+		case sourceSwarm:
+			ws, serr = source.NewSwarmSource(log, dockerOpts...)
+		}
+		if serr != nil {
+			log.Error("failed to create source", "source", kind, "err", serr)
+			os.Exit(1)
+		}
+		watchers = append(watchers, ws)
+		srcs = append(srcs, ws)
+	}
 	defer func() {
-		if cerr := src.Close(); cerr != nil {
-			log.Warn("error closing Docker client", "err", cerr)
+		for _, w := range watchers {
+			if cerr := w.Close(); cerr != nil {
+				log.Warn("error closing Docker client", "err", cerr)
+			}
 		}
 	}()
+	var src source.Source = srcs[0]
+	if len(srcs) > 1 {
+		src = source.NewMulti(log, srcs...)
+	}
 
 	// ---- Preflight DNS connectivity check ----
 	if !*skipPreflight {
@@ -253,15 +542,81 @@ func main() {
 		log.Info("DNS preflight check passed")
 	}
 
+	// ---- Leader election ----
+	var elector *controller.Elector
+	if *leaderElect {
+		if *once {
+			log.Error("--leader-elect cannot be combined with --once")
+			os.Exit(1)
+		}
+		store, ok := prov.(provider.LeaseStore)
+		if !ok {
+			log.Error("--leader-elect is not supported by this provider")
+			os.Exit(1)
+		}
+		if *leaseDuration <= *renewDeadline || *renewDeadline <= *retryPeriod || *retryPeriod <= 0 {
+			log.Error("invalid leader election timing: need lease-duration > renew-deadline > retry-period > 0")
+			os.Exit(1)
+		}
+		id := *leaderID
+		if id == "" {
+			id, _ = os.Hostname()
+		}
+		if id == "" || strings.ContainsAny(id, " \t") {
+			log.Error("invalid --leader-election-id: must be non-empty and contain no whitespace", "id", id)
+			os.Exit(1)
+		}
+		// The lease is read before any zone is discovered.
+		if firstZone == "" {
+			log.Error("--leader-elect needs a zone listed under zones to hold the lease")
+			os.Exit(1)
+		}
+		home := firstZone
+		if *leaseZone != "" {
+			lz, ok := prov.(leaseZoneProvider)
+			if !ok {
+				log.Error("--leader-election-zone is not supported by this provider")
+				os.Exit(1)
+			}
+			lp := lz.LeaseZone(*leaseZone)
+			if *leaseName != "" && !lp.ManagesName(*leaseName) {
+				log.Error("--leader-election-lease-name must lie inside --leader-election-zone",
+					"name", *leaseName, "zone", *leaseZone)
+				os.Exit(1)
+			}
+			store, home = lp, *leaseZone
+		}
+		name := *leaseName
+		if name == "" {
+			name = defaultLeaseName(*ownerID, home)
+		}
+		elector = controller.NewElector(store, log, controller.ElectionConfig{
+			LeaseName:     name,
+			Identity:      id,
+			LeaseDuration: *leaseDuration,
+			RenewDeadline: *renewDeadline,
+			RetryPeriod:   *retryPeriod,
+		})
+		log.Info("leader election enabled", "lease", name, "identity", id)
+	}
+
 	// ---- Build controller ----
 	ctrl := controller.New(src, prov, log, controller.Config{
-		Interval:         *interval,
-		DebounceDuration: *debounce,
-		BackoffBase:      *backoffBase,
-		BackoffMax:       *backoffMax,
-		DryRun:           *dryRun,
-		Once:             *once,
-		OwnerID:          *ownerID,
+		Interval:                *interval,
+		DebounceDuration:        *debounce,
+		BackoffBase:             *backoffBase,
+		BackoffMax:              *backoffMax,
+		DryRun:                  *dryRun,
+		Once:                    *once,
+		OwnerID:                 *ownerID,
+		Policy:                  policy,
+		DomainFilter:            domains,
+		DeleteGrace:             *deleteGrace,
+		AutoPTR:                 *autoPTR,
+		Elector:                 elector,
+		MaxDeletes:              *maxDeletes,
+		MaxDeletePercent:        *maxDeletePercent,
+		OverrideDeleteThreshold: *overrideDeleteThreshold,
 	})
 
 	// ---- Graceful shutdown ----
@@ -269,26 +624,30 @@ func main() {
 	defer stop()
 
 	// ---- Health check server ----
-	startHealthServer(ctx, *healthPort, *metricsPath, ctrl, log)
+	startHealthServer(ctx, *healthPort, *metricsPath, adminToken, ctrl, log)
 
 	// Start the Docker event watcher in the background (not needed for once mode).
 	var watchWg sync.WaitGroup
 	if !*once {
-		watchWg.Add(1)
-		go func() {
-			defer watchWg.Done()
-			src.Watch(ctx)
-		}()
+		for _, w := range watchers {
+			watchWg.Add(1)
+			go func(w watchSource) {
+				defer watchWg.Done()
+				w.Watch(ctx)
+			}(w)
+		}
 	}
 
 	// ---- Run ----
-	if zones > 0 {
+	if strings.HasPrefix(mode, "multi-zone") {
 		log.Info("starting external-dns-docker",
 			"mode", mode,
 			"zones", zones,
 			"interval", interval.String(),
 			"dry-run", *dryRun,
 			"once", *once,
+			"policy", string(policy),
+			"domain-filter", domains.String(),
 		)
 	} else {
 		log.Info("starting external-dns-docker",
@@ -298,14 +657,42 @@ func main() {
 			"interval", interval.String(),
 			"dry-run", *dryRun,
 			"once", *once,
+			"policy", string(policy),
+			"domain-filter", domains.String(),
 		)
 	}
 
-	if err := ctrl.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
-		log.Error("controller exited with error", "err", err)
+	runErr := ctrl.Run(ctx)
+	failed := runErr != nil && !errors.Is(runErr, context.Canceled)
+	if failed {
+		log.Error("controller exited with error", "err", runErr)
+	}
+
+	// A failed run still writes the changes it planned, so a run held back
+	// by the delete threshold can be reviewed.
+	if *planOutput != "" {
+		err := writePlan(ctrl, *planOutput, planFormat)
+		switch {
+		case err == nil:
+			log.Info("plan written", "path", *planOutput, "format", string(planFormat))
+		case failed && errors.Is(err, errNoPlan):
+		default:
+			log.Error("failed to write plan", "path", *planOutput, "err", err)
+			os.Exit(1)
+		}
+	}
+
+	if failed {
+		if *detailedExitCode {
+			os.Exit(onceExitCode(ctrl.LastCycle()))
+		}
 		os.Exit(1)
 	}
 
+	if *detailedExitCode {
+		os.Exit(onceExitCode(ctrl.LastCycle()))
+	}
+
 	// Wait for the Watch goroutine to exit, bounded by the shutdown timeout.
 	watchDone := make(chan struct{})
 	go func() {
@@ -320,10 +707,109 @@ func main() {
 	}
 }
 
+// Exit codes of a --once run with --detailed-exit-code.
+const (
+	exitInSync  = 0 // nothing to change
+	exitError   = 1
+	exitChanges = 2 // changes applied, or planned with --dry-run
+	exitBlocked = 3 // the delete safety threshold held the changes back
+)
+
+// onceExitCode returns the detailed exit code for cycle, the last cycle run;
+// ok is false if none ran.
+func onceExitCode(cycle controller.Cycle, ok bool) int {
+	switch {
+	case !ok:
+		return exitError
+	case cycle.Blocked():
+		return exitBlocked
+	case cycle.Err != nil:
+		return exitError
+	case cycle.Changed():
+		return exitChanges
+	default:
+		return exitInSync
+	}
+}
+
+// readAdminToken returns the admin bearer token stored in path, or "" when
+// path is empty.
+func readAdminToken(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return token, nil
+}
+
+// adminAuth guards an admin endpoint that changes controller behaviour or
+// exposes DNS data. The health port is usually reachable by anything that
+// scrapes metrics, so these endpoints answer 403 unless a token is
+// configured, and 401 unless the request carries it as
+// "Authorization: Bearer <token>".
+func adminAuth(token string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = fmt.Fprintln(w, "admin endpoints are disabled; start with --admin-token-file to enable them")
+			return
+		}
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="external-dns-docker"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = fmt.Fprintln(w, "missing or wrong admin token")
+			return
+		}
+		h(w, r)
+	}
+}
+
+// planOutputFormat returns the plan format selected by path's extension.
+func planOutputFormat(path string) (plan.Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return plan.FormatJSON, nil
+	case ".yaml", ".yml":
+		return plan.FormatYAML, nil
+	case ".nsupdate":
+		return plan.FormatNSUpdate, nil
+	default:
+		return "", fmt.Errorf("%s: unknown extension; use .json, .yaml, .yml or .nsupdate", path)
+	}
+}
+
+// errNoPlan is returned by writePlan when the last cycle failed before
+// planning, or no cycle ran.
+var errNoPlan = errors.New("no changes were planned")
+
+// writePlan writes the change set ctrl's last cycle planned to path in
+// format f, replacing any existing file.
+func writePlan(ctrl *controller.Controller, path string, f plan.Format) error {
+	cycle, ok := ctrl.LastCycle()
+	if !ok || cycle.Changes == nil {
+		return errNoPlan
+	}
+	var buf bytes.Buffer
+	if err := cycle.Changes.Write(&buf, f); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
 // startHealthServer starts an HTTP server exposing /healthz (liveness),
-// /readyz (readiness), and a Prometheus metrics endpoint on the given port.
+// /readyz (readiness), the admin API under /api/v1/ and a Prometheus metrics
+// endpoint on the given port. The admin endpoints require adminToken (see
+// adminAuth).
 // A port of 0 disables the server. The server shuts down when ctx is cancelled.
-func startHealthServer(ctx context.Context, port int, metricsPath string, ctrl *controller.Controller, log *slog.Logger) {
+func startHealthServer(ctx context.Context, port int, metricsPath, adminToken string, ctrl *controller.Controller, log *slog.Logger) {
 	if port == 0 {
 		return
 	}
@@ -333,14 +819,32 @@ func startHealthServer(ctx context.Context, port int, metricsPath string, ctrl *
 		_, _ = fmt.Fprintln(w, "ok")
 	})
 	mux.HandleFunc("/readyz", func(w http.ResponseWriter, _ *http.Request) {
-		if ctrl.IsReady() {
+		switch {
+		case ctrl.IsReady() && !ctrl.IsLeader():
+			w.WriteHeader(http.StatusOK)
+			_, _ = fmt.Fprintln(w, "standby")
+		case ctrl.IsReady() && len(ctrl.DegradedZones()) > 0:
+			w.WriteHeader(http.StatusOK)
+			_, _ = fmt.Fprintln(w, "degraded: "+strings.Join(ctrl.DegradedZones(), ", "))
+		case ctrl.IsReady():
 			w.WriteHeader(http.StatusOK)
 			_, _ = fmt.Fprintln(w, "ok")
-		} else {
+		default:
 			w.WriteHeader(http.StatusServiceUnavailable)
 			_, _ = fmt.Fprintln(w, "not ready")
 		}
 	})
+	mux.HandleFunc("/override-delete-threshold", adminAuth(adminToken, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		ctrl.OverrideDeleteThreshold()
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = fmt.Fprintln(w, "override armed for the next blocked change set")
+	}))
+	registerAPI(mux, ctrl, adminToken, log)
 	mux.Handle(metricsPath, promhttp.Handler())
 	srv := &http.Server{
 		Addr:    fmt.Sprintf(":%d", port),
@@ -362,6 +866,52 @@ func startHealthServer(ctx context.Context, port int, metricsPath string, ctrl *
 	}()
 }
 
+// defaultLeaseName returns the leader-election lease name used when
+// --leader-election-lease-name is unset: "<owner-id>-leader.<zone>".
+func defaultLeaseName(ownerID, zone string) string {
+	if ownerID == "" {
+		ownerID = plan.DefaultOwnerID
+	}
+	return ownerID + "-leader." + strings.TrimSuffix(zone, ".")
+}
+
+// splitList splits a comma-separated flag value, trimming whitespace and
+// dropping empty entries.
+func splitList(raw string) []string {
+	var out []string
+	for _, v := range strings.Split(raw, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// parseSourceKinds splits a comma-separated --source value into its source
+// kinds, rejecting unknown, duplicate, and empty entries.
+func parseSourceKinds(raw string) ([]string, error) {
+	var kinds []string
+	seen := make(map[string]bool)
+	for _, k := range strings.Split(raw, ",") {
+		k = strings.ToLower(strings.TrimSpace(k))
+		if k == "" {
+			continue
+		}
+		if k != sourceDocker && k != sourceSwarm {
+			return nil, fmt.Errorf("unknown source %q (want %s or %s)", k, sourceDocker, sourceSwarm)
+		}
+		if seen[k] {
+			return nil, fmt.Errorf("source %q listed more than once", k)
+		}
+		seen[k] = true
+		kinds = append(kinds, k)
+	}
+	if len(kinds) == 0 {
+		return nil, fmt.Errorf("at least one source is required")
+	}
+	return kinds, nil
+}
+
 // newLogger returns a JSON logger writing to stderr at the given level.
 func newLogger(level string) *slog.Logger {
 	var l slog.Level
@@ -426,6 +976,19 @@ func envOrBool(key string, fallback bool) bool {
 	return b
 }
 
+// envOrFloat64 returns the environment variable named key parsed as float64, or fallback.
+func envOrFloat64(key string, fallback float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return fallback
+	}
+	return f
+}
+
 // envOrDuration returns the environment variable named key parsed as
 // time.Duration, or fallback.
 func envOrDuration(key string, fallback time.Duration) time.Duration {
@@ -440,19 +1003,59 @@ func envOrDuration(key string, fallback time.Duration) time.Duration {
 	return d
 }
 
+// explicitlySet reports whether the flag called name in fs was given on the
+// command line or through the environment variable env, rather than left at
+// its default.
+func explicitlySet(fs *flag.FlagSet, name, env string) bool {
+	if os.Getenv(env) != "" {
+		return true
+	}
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
 // zoneFieldSetter maps an env var suffix to a setter function for ZoneConfig.
-// Longer suffixes must appear before shorter ones that are prefixes of them
-// (e.g. TSIG_SECRET_FILE before TSIG_SECRET).
+// Longer suffixes must appear before shorter ones that end them (e.g.
+// VERIFY_TIMEOUT before TIMEOUT) or are prefixes of them (e.g.
+// TSIG_SECRET_FILE before TSIG_SECRET).
 type zoneFieldSetter struct {
 	suffix string
 	set    func(zc *rfc2136.ZoneConfig, val string) error
 }
 
 var zoneFieldSetters = []zoneFieldSetter{
+	{"TSIG_FALLBACK_SECRET_FILE", setFallbackKey(func(k *rfc2136.TSIGKey, val string) { k.SecretFile = val })},
+	{"TSIG_FALLBACK_SECRET", setFallbackKey(func(k *rfc2136.TSIGKey, val string) { k.Secret = val })},
+	{"TSIG_FALLBACK_KEY", setFallbackKey(func(k *rfc2136.TSIGKey, val string) { k.Name = val })},
+	{"TSIG_FALLBACK_ALG", setFallbackKey(func(k *rfc2136.TSIGKey, val string) { k.Alg = val })},
 	{"TSIG_SECRET_FILE", func(zc *rfc2136.ZoneConfig, val string) error { zc.TSIGSecretFile = val; return nil }},
 	{"TSIG_SECRET", func(zc *rfc2136.ZoneConfig, val string) error { zc.TSIGSecret = val; return nil }},
+	{"TSIG_KEYFILE", func(zc *rfc2136.ZoneConfig, val string) error { zc.TSIGKeyFile = val; return nil }},
 	{"TSIG_KEY", func(zc *rfc2136.ZoneConfig, val string) error { zc.TSIGKey = val; return nil }},
+	{"SIG0_KEY_FILE", func(zc *rfc2136.ZoneConfig, val string) error { zc.SIG0KeyFile = val; return nil }},
 	{"TSIG_ALG", func(zc *rfc2136.ZoneConfig, val string) error { zc.TSIGAlg = val; return nil }},
+	{"VERIFY_SECONDARIES", func(zc *rfc2136.ZoneConfig, val string) error { zc.VerifySecondaries = splitHosts(val); return nil }},
+	{"VERIFY_TIMEOUT", func(zc *rfc2136.ZoneConfig, val string) error {
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return fmt.Errorf("invalid VERIFY_TIMEOUT %q: %w", val, err)
+		}
+		zc.VerifyTimeout = d
+		return nil
+	}},
+	{"VERIFY", func(zc *rfc2136.ZoneConfig, val string) error {
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("invalid VERIFY %q: %w", val, err)
+		}
+		zc.Verify = b
+		return nil
+	}},
 	{"MIN_TTL", func(zc *rfc2136.ZoneConfig, val string) error {
 		n, err := strconv.ParseInt(val, 10, 64)
 		if err != nil {
@@ -469,7 +1072,28 @@ var zoneFieldSetters = []zoneFieldSetter{
 		zc.Timeout = d
 		return nil
 	}},
-	{"HOST", func(zc *rfc2136.ZoneConfig, val string) error { zc.Host = val; return nil }},
+	{"BATCH_SIZE", func(zc *rfc2136.ZoneConfig, val string) error {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("invalid BATCH_SIZE %q: %w", val, err)
+		}
+		zc.MaxUpdateRRs = n
+		return nil
+	}},
+	{"PREREQUISITES", func(zc *rfc2136.ZoneConfig, val string) error {
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("invalid PREREQUISITES %q: %w", val, err)
+		}
+		zc.Prerequisites = b
+		return nil
+	}},
+	{"TRANSPORT", func(zc *rfc2136.ZoneConfig, val string) error { zc.Transport = val; return nil }},
+	{"TLS_CA_FILE", func(zc *rfc2136.ZoneConfig, val string) error { zc.TLSCAFile = val; return nil }},
+	{"TLS_CERT_FILE", func(zc *rfc2136.ZoneConfig, val string) error { zc.TLSCertFile = val; return nil }},
+	{"TLS_KEY_FILE", func(zc *rfc2136.ZoneConfig, val string) error { zc.TLSKeyFile = val; return nil }},
+	{"TLS_SERVER_NAME", func(zc *rfc2136.ZoneConfig, val string) error { zc.TLSServerName = val; return nil }},
+	{"HOST", func(zc *rfc2136.ZoneConfig, val string) error { setHosts(zc, splitHosts(val)); return nil }},
 	{"PORT", func(zc *rfc2136.ZoneConfig, val string) error {
 		n, err := strconv.Atoi(val)
 		if err != nil {
@@ -539,7 +1163,29 @@ func loadZoneConfigsFromEnv() ([]rfc2136.ZoneConfig, bool, error) {
 				return nil, true, fmt.Errorf("zone %s: reading TSIG_SECRET_FILE: %w", name, rerr)
 			}
 			zc.TSIGSecret = strings.TrimSpace(string(data))
-			zc.TSIGSecretFile = ""
+		}
+		if kerr := resolveTSIGKeyFile(zc); kerr != nil {
+			return nil, true, fmt.Errorf("zone %s: TSIG_KEYFILE: %w", name, kerr)
+		}
+		if kerr := resolveTSIGKeys(zc.TSIGKeys, zc.TSIGAlg); kerr != nil {
+			return nil, true, fmt.Errorf("zone %s: TSIG_FALLBACK: %w", name, kerr)
+		}
+		if zc.SIG0KeyFile != "" {
+			if zc.TSIGKey != "" || zc.TSIGSecret != "" || len(zc.TSIGKeys) > 0 {
+				return nil, true, fmt.Errorf("zone %s: SIG0_KEY_FILE and TSIG settings are mutually exclusive", name)
+			}
+			key, kerr := rfc2136.LoadSIG0Key(zc.SIG0KeyFile)
+			if kerr != nil {
+				return nil, true, fmt.Errorf("zone %s: %w", name, kerr)
+			}
+			zc.SIG0Key = key
+			zc.SIG0KeyFile = ""
+		}
+		if terr := resolveTLS(zc); terr != nil {
+			return nil, true, fmt.Errorf("zone %s: %w", name, terr)
+		}
+		if verr := checkVerify(*zc); verr != nil {
+			return nil, true, fmt.Errorf("zone %s: %w", name, verr)
 		}
 		result = append(result, *zc)
 	}
@@ -549,75 +1195,401 @@ func loadZoneConfigsFromEnv() ([]rfc2136.ZoneConfig, bool, error) {
 
 // yamlZonesFile is the top-level structure of the YAML zone config file.
 type yamlZonesFile struct {
-	Zones []yamlZoneEntry `yaml:"zones"`
+	Zones     []yamlZoneEntry `yaml:"zones"`
+	Discovery *yamlDiscovery  `yaml:"discovery"`
 }
 
 type yamlZoneEntry struct {
-	Host           string `yaml:"host"`
-	Port           int    `yaml:"port"`
-	Zone           string `yaml:"zone"`
-	TSIGKey        string `yaml:"tsig-key"`
-	TSIGSecret     string `yaml:"tsig-secret"`
-	TSIGSecretFile string `yaml:"tsig-secret-file"`
-	TSIGAlg        string `yaml:"tsig-alg"`
-	MinTTL         int64  `yaml:"min-ttl"`
-	Timeout        string `yaml:"timeout"` // e.g. "10s"; empty = use provider default
-}
-
-// loadZoneConfigsFromFile reads a YAML zone config file, resolves secret files,
-// validates required fields, and returns a slice of ZoneConfig.
-func loadZoneConfigsFromFile(path string) ([]rfc2136.ZoneConfig, error) {
+	Host              yamlHosts     `yaml:"host"`
+	Port              int           `yaml:"port"`
+	Zone              string        `yaml:"zone"`
+	TSIGKey           string        `yaml:"tsig-key"`
+	TSIGSecret        string        `yaml:"tsig-secret"`
+	TSIGSecretFile    string        `yaml:"tsig-secret-file"`
+	TSIGAlg           string        `yaml:"tsig-alg"`
+	TSIGKeyFile       string        `yaml:"tsig-keyfile"`
+	TSIGFallback      []yamlTSIGKey `yaml:"tsig-fallback-keys"`
+	SIG0KeyFile       string        `yaml:"sig0-key-file"`
+	MinTTL            int64         `yaml:"min-ttl"`
+	Timeout           string        `yaml:"timeout"` // e.g. "10s"; empty = use provider default
+	BatchSize         int           `yaml:"batch-size"`
+	Prerequisites     bool          `yaml:"prerequisites"`
+	Transport         string        `yaml:"transport"`
+	TLSCAFile         string        `yaml:"tls-ca-file"`
+	TLSCertFile       string        `yaml:"tls-cert-file"`
+	TLSKeyFile        string        `yaml:"tls-key-file"`
+	TLSServerName     string        `yaml:"tls-server-name"`
+	Verify            bool          `yaml:"verify"`
+	VerifySecondaries yamlHosts     `yaml:"verify-secondaries"`
+	VerifyTimeout     string        `yaml:"verify-timeout"` // e.g. "10s"; empty = use provider default
+}
+
+// yamlTSIGKey is an entry of a zone's tsig-fallback-keys list.
+type yamlTSIGKey struct {
+	Name       string `yaml:"name"`
+	Secret     string `yaml:"secret"`
+	SecretFile string `yaml:"secret-file"`
+	Alg        string `yaml:"alg"`
+}
+
+// yamlDiscovery is the optional discovery block of the YAML zone config
+// file. Templates use the zone entry fields; their zone is a suffix matched
+// against the zones found and their host is optional.
+type yamlDiscovery struct {
+	Resolver  string          `yaml:"resolver"` // empty = first nameserver in /etc/resolv.conf
+	Timeout   string          `yaml:"timeout"`
+	Templates []yamlZoneEntry `yaml:"templates"`
+}
+
+// loadZonesFile reads a YAML zone config file, resolves secret files,
+// validates required fields, and returns its zones and its zone discovery
+// settings, nil if it has no discovery block. The zones list may be empty
+// when discovery is configured.
+func loadZonesFile(path string) ([]rfc2136.ZoneConfig, *rfc2136.DiscoveryConfig, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("reading config file: %w", err)
+		return nil, nil, fmt.Errorf("reading config file: %w", err)
 	}
 
 	var raw yamlZonesFile
 	if err := yaml.Unmarshal(data, &raw); err != nil {
-		return nil, fmt.Errorf("parsing config file: %w", err)
+		return nil, nil, fmt.Errorf("parsing config file: %w", err)
+	}
+	if len(raw.Zones) == 0 && raw.Discovery == nil {
+		return nil, nil, errors.New("no zones defined and no discovery configured")
 	}
 
+	configs, err := raw.zoneConfigs()
+	if err != nil {
+		return nil, nil, err
+	}
+	discovery, err := raw.discoveryConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	return configs, discovery, nil
+}
+
+// zoneConfigs returns the ZoneConfigs of raw's zones list.
+func (raw *yamlZonesFile) zoneConfigs() ([]rfc2136.ZoneConfig, error) {
 	configs := make([]rfc2136.ZoneConfig, 0, len(raw.Zones))
 	for i, z := range raw.Zones {
-		if z.Host == "" {
+		if len(z.Host) == 0 {
 			return nil, fmt.Errorf("zone[%d]: host is required", i)
 		}
 		if z.Zone == "" {
 			return nil, fmt.Errorf("zone[%d]: zone is required", i)
 		}
-		if z.TSIGSecret != "" && z.TSIGSecretFile != "" {
-			return nil, fmt.Errorf("zone[%d]: tsig-secret and tsig-secret-file are mutually exclusive", i)
+		zc, err := z.zoneConfig()
+		if err != nil {
+			return nil, fmt.Errorf("zone[%d]: %w", i, err)
 		}
+		configs = append(configs, zc)
+	}
 
-		secret := z.TSIGSecret
-		if z.TSIGSecretFile != "" {
-			fileData, ferr := os.ReadFile(z.TSIGSecretFile)
-			if ferr != nil {
-				return nil, fmt.Errorf("zone[%d]: reading tsig-secret-file: %w", i, ferr)
-			}
-			secret = strings.TrimSpace(string(fileData))
+	return configs, nil
+}
+
+// discoveryConfig returns the zone discovery settings of raw, or nil if it
+// has no discovery block.
+func (raw *yamlZonesFile) discoveryConfig() (*rfc2136.DiscoveryConfig, error) {
+	d := raw.Discovery
+	if d == nil {
+		return nil, nil
+	}
+	if len(d.Templates) == 0 {
+		return nil, errors.New("discovery: at least one template is required")
+	}
+
+	cfg := &rfc2136.DiscoveryConfig{Resolver: d.Resolver}
+	if cfg.Resolver == "" {
+		rc, rerr := dns.ClientConfigFromFile("/etc/resolv.conf")
+		if rerr != nil {
+			return nil, fmt.Errorf("discovery: no resolver set: %w", rerr)
+		}
+		if len(rc.Servers) == 0 {
+			return nil, errors.New("discovery: no resolver set and none in /etc/resolv.conf")
 		}
+		cfg.Resolver = net.JoinHostPort(rc.Servers[0], rc.Port)
+	}
+	if d.Timeout != "" {
+		var err error
+		if cfg.Timeout, err = time.ParseDuration(d.Timeout); err != nil {
+			return nil, fmt.Errorf("discovery: invalid timeout %q: %w", d.Timeout, err)
+		}
+	}
+	for i, t := range d.Templates {
+		zc, terr := t.zoneConfig()
+		if terr != nil {
+			return nil, fmt.Errorf("discovery: template[%d]: %w", i, terr)
+		}
+		cfg.Templates = append(cfg.Templates, zc)
+	}
+	return cfg, nil
+}
 
-		var timeout time.Duration
-		if z.Timeout != "" {
-			var terr error
-			timeout, terr = time.ParseDuration(z.Timeout)
-			if terr != nil {
-				return nil, fmt.Errorf("zone[%d]: invalid timeout %q: %w", i, z.Timeout, terr)
-			}
+// zoneConfig converts z to a ZoneConfig, resolving its secret and key files
+// and TLS settings. Required fields are checked by the caller.
+func (z yamlZoneEntry) zoneConfig() (rfc2136.ZoneConfig, error) {
+	if z.TSIGSecret != "" && z.TSIGSecretFile != "" {
+		return rfc2136.ZoneConfig{}, errors.New("tsig-secret and tsig-secret-file are mutually exclusive")
+	}
+
+	secret := z.TSIGSecret
+	if z.TSIGSecretFile != "" {
+		fileData, ferr := os.ReadFile(z.TSIGSecretFile)
+		if ferr != nil {
+			return rfc2136.ZoneConfig{}, fmt.Errorf("reading tsig-secret-file: %w", ferr)
 		}
+		secret = strings.TrimSpace(string(fileData))
+	}
 
-		configs = append(configs, rfc2136.ZoneConfig{
-			Host:       z.Host,
-			Port:       z.Port,
-			Zone:       z.Zone,
-			TSIGKey:    z.TSIGKey,
-			TSIGSecret: secret,
-			TSIGAlg:    z.TSIGAlg,
-			MinTTL:     z.MinTTL,
-			Timeout:    timeout,
-		})
+	auth := rfc2136.ZoneConfig{
+		TSIGKey:        z.TSIGKey,
+		TSIGSecret:     secret,
+		TSIGSecretFile: z.TSIGSecretFile,
+		TSIGAlg:        z.TSIGAlg,
+		TSIGKeyFile:    z.TSIGKeyFile,
+		TSIGKeys:       make([]rfc2136.TSIGKey, 0, len(z.TSIGFallback)),
+	}
+	for _, k := range z.TSIGFallback {
+		auth.TSIGKeys = append(auth.TSIGKeys, rfc2136.TSIGKey(k))
+	}
+	if kerr := resolveTSIGKeyFile(&auth); kerr != nil {
+		return rfc2136.ZoneConfig{}, fmt.Errorf("tsig-keyfile: %w", kerr)
+	}
+	if kerr := resolveTSIGKeys(auth.TSIGKeys, auth.TSIGAlg); kerr != nil {
+		return rfc2136.ZoneConfig{}, fmt.Errorf("tsig-fallback-keys: %w", kerr)
 	}
 
-	return configs, nil
+	var sig0Key *rfc2136.SIG0Key
+	if z.SIG0KeyFile != "" {
+		if auth.TSIGKey != "" || auth.TSIGSecret != "" || len(auth.TSIGKeys) > 0 {
+			return rfc2136.ZoneConfig{}, errors.New("sig0-key-file and the tsig settings are mutually exclusive")
+		}
+		var kerr error
+		sig0Key, kerr = rfc2136.LoadSIG0Key(z.SIG0KeyFile)
+		if kerr != nil {
+			return rfc2136.ZoneConfig{}, kerr
+		}
+	}
+
+	var timeout time.Duration
+	if z.Timeout != "" {
+		var terr error
+		timeout, terr = time.ParseDuration(z.Timeout)
+		if terr != nil {
+			return rfc2136.ZoneConfig{}, fmt.Errorf("invalid timeout %q: %w", z.Timeout, terr)
+		}
+	}
+
+	var verifyTimeout time.Duration
+	if z.VerifyTimeout != "" {
+		var terr error
+		verifyTimeout, terr = time.ParseDuration(z.VerifyTimeout)
+		if terr != nil {
+			return rfc2136.ZoneConfig{}, fmt.Errorf("invalid verify-timeout %q: %w", z.VerifyTimeout, terr)
+		}
+	}
+
+	zc := rfc2136.ZoneConfig{
+		Port:              z.Port,
+		Zone:              z.Zone,
+		TSIGKey:           auth.TSIGKey,
+		TSIGSecret:        auth.TSIGSecret,
+		TSIGSecretFile:    auth.TSIGSecretFile,
+		TSIGAlg:           auth.TSIGAlg,
+		TSIGKeyFile:       auth.TSIGKeyFile,
+		TSIGKeys:          auth.TSIGKeys,
+		SIG0Key:           sig0Key,
+		MinTTL:            z.MinTTL,
+		Timeout:           timeout,
+		MaxUpdateRRs:      z.BatchSize,
+		Prerequisites:     z.Prerequisites,
+		Transport:         z.Transport,
+		TLSCAFile:         z.TLSCAFile,
+		TLSCertFile:       z.TLSCertFile,
+		TLSKeyFile:        z.TLSKeyFile,
+		TLSServerName:     z.TLSServerName,
+		Verify:            z.Verify,
+		VerifySecondaries: z.VerifySecondaries,
+		VerifyTimeout:     verifyTimeout,
+	}
+	setHosts(&zc, z.Host)
+	if terr := resolveTLS(&zc); terr != nil {
+		return rfc2136.ZoneConfig{}, terr
+	}
+	if verr := checkVerify(zc); verr != nil {
+		return rfc2136.ZoneConfig{}, verr
+	}
+	return zc, nil
+}
+
+// yamlHosts is the YAML host field: a single server, a comma-separated list,
+// or a YAML list of servers.
+type yamlHosts []string
+
+// UnmarshalYAML accepts either a string or a list of strings.
+func (h *yamlHosts) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var list []string
+	if err := unmarshal(&list); err == nil {
+		*h = list
+		return nil
+	}
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	*h = splitHosts(s)
+	return nil
+}
+
+// splitHosts parses a comma-separated server list, dropping empty entries.
+func splitHosts(s string) []string {
+	var hosts []string
+	for _, h := range strings.Split(s, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// resolveTLS validates zc.Transport and loads its TLS settings into zc.TLS.
+// TLS settings are rejected for the plain transports, where they would be
+// silently ignored.
+func resolveTLS(zc *rfc2136.ZoneConfig) error {
+	switch zc.Transport {
+	case "", rfc2136.TransportTCP, rfc2136.TransportUDP:
+		if zc.TLSCAFile != "" || zc.TLSCertFile != "" || zc.TLSKeyFile != "" || zc.TLSServerName != "" {
+			return fmt.Errorf("TLS settings require transport %s", rfc2136.TransportTLS)
+		}
+		return nil
+	case rfc2136.TransportTLS:
+		cfg, err := rfc2136.NewTLSConfig(zc.TLSCAFile, zc.TLSCertFile, zc.TLSKeyFile, zc.TLSServerName)
+		if err != nil {
+			return err
+		}
+		zc.TLS = cfg
+		return nil
+	default:
+		return fmt.Errorf("unknown transport %q (want %s, %s or %s)",
+			zc.Transport, rfc2136.TransportTCP, rfc2136.TransportUDP, rfc2136.TransportTLS)
+	}
+}
+
+// checkVerify rejects verification settings that would be silently ignored.
+func checkVerify(zc rfc2136.ZoneConfig) error {
+	if !zc.Verify && len(zc.VerifySecondaries) > 0 {
+		return errors.New("verify secondaries require verify to be enabled")
+	}
+	if zc.VerifyTimeout < 0 {
+		return fmt.Errorf("verify timeout %s is negative", zc.VerifyTimeout)
+	}
+	return nil
+}
+
+// setFallbackKey returns a zone field setter that applies set to the zone's
+// fallback TSIG key, adding the key on its first non-empty field.
+func setFallbackKey(set func(k *rfc2136.TSIGKey, val string)) func(*rfc2136.ZoneConfig, string) error {
+	return func(zc *rfc2136.ZoneConfig, val string) error {
+		if val == "" {
+			return nil
+		}
+		if len(zc.TSIGKeys) == 0 {
+			zc.TSIGKeys = []rfc2136.TSIGKey{{}}
+		}
+		set(&zc.TSIGKeys[0], val)
+		return nil
+	}
+}
+
+// resolveTSIGKeyFile loads zc's TSIG key from the BIND key file
+// TSIGKeyFile, if set: TSIGKey picks the key, by default the file's only
+// one, and the key's name, secret and algorithm are filled in. Fallback keys
+// with only a name are looked up in the file as well.
+func resolveTSIGKeyFile(zc *rfc2136.ZoneConfig) error {
+	if zc.TSIGKeyFile == "" {
+		return nil
+	}
+	if zc.TSIGSecret != "" || zc.TSIGSecretFile != "" {
+		return errors.New("a TSIG key file and a TSIG secret are mutually exclusive")
+	}
+	f, err := rfc2136.LoadTSIGKeyFile(zc.TSIGKeyFile)
+	if err != nil {
+		return err
+	}
+	key, err := f.Key(zc.TSIGKey)
+	if err != nil {
+		return err
+	}
+	if !sameTSIGAlg(zc.TSIGAlg, key.Alg) {
+		return fmt.Errorf("TSIG algorithm %s does not match %s of key %s in %s", zc.TSIGAlg, key.Alg, key.Name, zc.TSIGKeyFile)
+	}
+	zc.TSIGKey, zc.TSIGSecret, zc.TSIGAlg = key.Name, key.Secret, key.Alg
+
+	for i := range zc.TSIGKeys {
+		k := &zc.TSIGKeys[i]
+		if k.Name == "" || k.Secret != "" || k.SecretFile != "" {
+			continue
+		}
+		fk, err := f.Key(k.Name)
+		if err != nil {
+			return err
+		}
+		if !sameTSIGAlg(k.Alg, fk.Alg) {
+			return fmt.Errorf("TSIG algorithm %s does not match %s of key %s in %s", k.Alg, fk.Alg, fk.Name, zc.TSIGKeyFile)
+		}
+		*k = fk
+	}
+	return nil
+}
+
+// sameTSIGAlg reports whether the configured algorithm alg, if set, is the
+// key file's algorithm fileAlg.
+func sameTSIGAlg(alg, fileAlg string) bool {
+	norm := func(a string) string { return strings.TrimSuffix(strings.ToLower(a), ".") }
+	return alg == "" || norm(alg) == norm(fileAlg)
+}
+
+// resolveTSIGKeys validates keys and reads their secret files into Secret,
+// so unreadable files and missing or malformed secrets are reported at
+// startup; the provider re-reads them when they change. Keys without an
+// algorithm get defaultAlg.
+func resolveTSIGKeys(keys []rfc2136.TSIGKey, defaultAlg string) error {
+	for i := range keys {
+		k := &keys[i]
+		if k.Name == "" {
+			return fmt.Errorf("key %d: name is required", i+1)
+		}
+		if k.Secret != "" && k.SecretFile != "" {
+			return fmt.Errorf("key %s: secret and secret file are mutually exclusive", k.Name)
+		}
+		if k.SecretFile != "" {
+			data, err := os.ReadFile(k.SecretFile)
+			if err != nil {
+				return fmt.Errorf("key %s: reading secret file: %w", k.Name, err)
+			}
+			k.Secret = strings.TrimSpace(string(data))
+		}
+		if k.Secret == "" {
+			return fmt.Errorf("key %s: a secret or secret file is required", k.Name)
+		}
+		if _, err := base64.StdEncoding.DecodeString(k.Secret); err != nil {
+			return fmt.Errorf("key %s: secret is not valid base64: %w", k.Name, err)
+		}
+		if k.Alg == "" {
+			k.Alg = defaultAlg
+		}
+	}
+	return nil
+}
+
+// setHosts sets zc's failover server list, keeping Host as its first entry.
+func setHosts(zc *rfc2136.ZoneConfig, hosts []string) {
+	zc.Hosts = hosts
+	zc.Host = ""
+	if len(hosts) > 0 {
+		zc.Host = hosts[0]
+	}
 }
@@ -2,11 +2,28 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/miekg/dns"
+	"go.yaml.in/yaml/v2"
+
+	"github.com/bkero/external-dns-docker/pkg/controller"
+	"github.com/bkero/external-dns-docker/pkg/endpoint"
+	"github.com/bkero/external-dns-docker/pkg/plan"
+	"github.com/bkero/external-dns-docker/pkg/provider"
+	fake_provider "github.com/bkero/external-dns-docker/pkg/provider/fake"
+	fake_source "github.com/bkero/external-dns-docker/pkg/source/fake"
 )
 
 // ---- newLogger ----
@@ -43,6 +60,34 @@ func TestNewLogger_Levels(t *testing.T) {
 	}
 }
 
+// ---- parseSourceKinds ----
+
+func TestParseSourceKinds(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{"docker", []string{"docker"}, false},
+		{"swarm", []string{"swarm"}, false},
+		{"docker, Swarm", []string{"docker", "swarm"}, false},
+		{"swarm,,docker,", []string{"swarm", "docker"}, false},
+		{"", nil, true},
+		{"kubernetes", nil, true},
+		{"docker,docker", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseSourceKinds(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSourceKinds(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("parseSourceKinds(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
 // ---- envOr ----
 
 func TestEnvOr_Unset_ReturnsFallback(t *testing.T) {
@@ -105,6 +150,49 @@ func TestEnvOrInt64_Invalid_ReturnsFallback(t *testing.T) {
 	}
 }
 
+func TestSplitList(t *testing.T) {
+	got := splitList(" apps.example.com, ,*.svc.example.com ,")
+	want := []string{"apps.example.com", "*.svc.example.com"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("splitList() = %q, want %q", got, want)
+	}
+	if got := splitList(""); len(got) != 0 {
+		t.Errorf("splitList(\"\") = %q, want empty", got)
+	}
+}
+
+func TestDefaultLeaseName(t *testing.T) {
+	if got := defaultLeaseName("", "example.com."); got != "external-dns-docker-leader.example.com" {
+		t.Errorf("defaultLeaseName() = %q", got)
+	}
+	if got := defaultLeaseName("edge", "example.com"); got != "edge-leader.example.com" {
+		t.Errorf("defaultLeaseName() = %q", got)
+	}
+}
+
+// ---- envOrFloat64 ----
+
+func TestEnvOrFloat64_Unset_ReturnsFallback(t *testing.T) {
+	t.Setenv("TEST_ENV_FLOAT_UNSET", "")
+	if got := envOrFloat64("TEST_ENV_FLOAT_UNSET", 12.5); got != 12.5 {
+		t.Errorf("got %v, want 12.5", got)
+	}
+}
+
+func TestEnvOrFloat64_Valid_ReturnsParsed(t *testing.T) {
+	t.Setenv("TEST_ENV_FLOAT_VALID", "33.3")
+	if got := envOrFloat64("TEST_ENV_FLOAT_VALID", 0); got != 33.3 {
+		t.Errorf("got %v, want 33.3", got)
+	}
+}
+
+func TestEnvOrFloat64_Invalid_ReturnsFallback(t *testing.T) {
+	t.Setenv("TEST_ENV_FLOAT_INVALID", "bad")
+	if got := envOrFloat64("TEST_ENV_FLOAT_INVALID", 5); got != 5 {
+		t.Errorf("got %v, want 5 (fallback)", got)
+	}
+}
+
 // ---- envOrBool ----
 
 func TestEnvOrBool_Unset_ReturnsFallback(t *testing.T) {
@@ -158,6 +246,35 @@ func TestEnvOrDuration_Invalid_ReturnsFallback(t *testing.T) {
 	}
 }
 
+// ---- explicitlySet ----
+
+func TestExplicitlySet(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  string
+		want bool
+	}{
+		{"default", nil, "", false},
+		{"flag", []string{"--alg=hmac-sha512"}, "", true},
+		{"flag set to the default", []string{"--alg=hmac-sha256"}, "", true},
+		{"env", nil, "hmac-sha512", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_ENV_ALG", tt.env)
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.String("alg", "hmac-sha256", "")
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if got := explicitlySet(fs, "alg", "TEST_ENV_ALG"); got != tt.want {
+				t.Errorf("explicitlySet() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 // ---- loadZoneConfigsFromEnv ----
 
 // clearZoneEnv removes any leftover EXTERNAL_DNS_RFC2136_ZONE_* vars from the
@@ -302,8 +419,8 @@ func TestLoadZoneConfigsFromEnv_TSIGSecretFile_Resolved(t *testing.T) {
 	if configs[0].TSIGSecret != secret {
 		t.Errorf("TSIGSecret = %q, want %q", configs[0].TSIGSecret, secret)
 	}
-	if configs[0].TSIGSecretFile != "" {
-		t.Error("TSIGSecretFile should be cleared after resolution")
+	if configs[0].TSIGSecretFile != f.Name() {
+		t.Error("TSIGSecretFile should be kept so the provider can re-read it")
 	}
 }
 
@@ -322,7 +439,7 @@ func TestLoadZoneConfigsFromEnv_UnknownFieldSuffix_Ignored(t *testing.T) {
 	}
 }
 
-// ---- loadZoneConfigsFromFile ----
+// ---- loadZonesFile ----
 
 func writeYAML(t *testing.T, content string) string {
 	t.Helper()
@@ -333,7 +450,7 @@ func writeYAML(t *testing.T, content string) string {
 	return f
 }
 
-func TestLoadZoneConfigsFromFile_TwoZones(t *testing.T) {
+func TestLoadZonesFile_TwoZones(t *testing.T) {
 	path := writeYAML(t, `
 zones:
   - host: ns1.example.com
@@ -346,7 +463,7 @@ zones:
     tsig-key: bke-key
     tsig-secret: c2VjcmV0Mg==
 `)
-	configs, err := loadZoneConfigsFromFile(path)
+	configs, _, err := loadZonesFile(path)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -361,29 +478,29 @@ zones:
 	}
 }
 
-func TestLoadZoneConfigsFromFile_MissingHost_ReturnsError(t *testing.T) {
+func TestLoadZonesFile_MissingHost_ReturnsError(t *testing.T) {
 	path := writeYAML(t, `
 zones:
   - zone: example.com.
 `)
-	_, err := loadZoneConfigsFromFile(path)
+	_, _, err := loadZonesFile(path)
 	if err == nil {
 		t.Error("expected error for missing host, got nil")
 	}
 }
 
-func TestLoadZoneConfigsFromFile_MissingZone_ReturnsError(t *testing.T) {
+func TestLoadZonesFile_MissingZone_ReturnsError(t *testing.T) {
 	path := writeYAML(t, `
 zones:
   - host: ns1.example.com
 `)
-	_, err := loadZoneConfigsFromFile(path)
+	_, _, err := loadZonesFile(path)
 	if err == nil {
 		t.Error("expected error for missing zone, got nil")
 	}
 }
 
-func TestLoadZoneConfigsFromFile_BothSecretFields_ReturnsError(t *testing.T) {
+func TestLoadZonesFile_BothSecretFields_ReturnsError(t *testing.T) {
 	path := writeYAML(t, `
 zones:
   - host: ns1.example.com
@@ -391,13 +508,13 @@ zones:
     tsig-secret: c2VjcmV0
     tsig-secret-file: /run/secrets/tsig
 `)
-	_, err := loadZoneConfigsFromFile(path)
+	_, _, err := loadZonesFile(path)
 	if err == nil {
 		t.Error("expected error for both tsig-secret and tsig-secret-file, got nil")
 	}
 }
 
-func TestLoadZoneConfigsFromFile_TSIGSecretFile_Resolved(t *testing.T) {
+func TestLoadZonesFile_TSIGSecretFile_Resolved(t *testing.T) {
 	secret := "mybase64secret"
 	sf := filepath.Join(t.TempDir(), "tsig.secret")
 	if err := os.WriteFile(sf, []byte(secret+"\n"), 0o600); err != nil {
@@ -410,7 +527,7 @@ zones:
     tsig-key: k
     tsig-secret-file: `+sf+`
 `)
-	configs, err := loadZoneConfigsFromFile(path)
+	configs, _, err := loadZonesFile(path)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -422,27 +539,27 @@ zones:
 	}
 }
 
-func TestLoadZoneConfigsFromFile_InvalidTimeout_ReturnsError(t *testing.T) {
+func TestLoadZonesFile_InvalidTimeout_ReturnsError(t *testing.T) {
 	path := writeYAML(t, `
 zones:
   - host: ns1.example.com
     zone: example.com.
     timeout: "not-a-duration"
 `)
-	_, err := loadZoneConfigsFromFile(path)
+	_, _, err := loadZonesFile(path)
 	if err == nil {
 		t.Error("expected error for invalid timeout, got nil")
 	}
 }
 
-func TestLoadZoneConfigsFromFile_Timeout_Parsed(t *testing.T) {
+func TestLoadZonesFile_Timeout_Parsed(t *testing.T) {
 	path := writeYAML(t, `
 zones:
   - host: ns1.example.com
     zone: example.com.
     timeout: "5s"
 `)
-	configs, err := loadZoneConfigsFromFile(path)
+	configs, _, err := loadZonesFile(path)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -451,29 +568,71 @@ zones:
 	}
 }
 
-func TestLoadZoneConfigsFromFile_FileNotFound_ReturnsError(t *testing.T) {
-	_, err := loadZoneConfigsFromFile("/nonexistent/path/zones.yaml")
+func TestLoadZonesFile_UpdateOptions_Parsed(t *testing.T) {
+	path := writeYAML(t, `
+zones:
+  - host: ns1.example.com
+    zone: example.com.
+    batch-size: 50
+    prerequisites: true
+`)
+	configs, _, err := loadZonesFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if configs[0].MaxUpdateRRs != 50 {
+		t.Errorf("MaxUpdateRRs = %d, want 50", configs[0].MaxUpdateRRs)
+	}
+	if !configs[0].Prerequisites {
+		t.Error("Prerequisites = false, want true")
+	}
+}
+
+func TestLoadZonesFile_HostList_Parsed(t *testing.T) {
+	path := writeYAML(t, `
+zones:
+  - host: [ns1.example.com, "ns2.example.com:5353"]
+    zone: example.com.
+  - host: ns1.bke.ro, ns2.bke.ro
+    zone: bke.ro.
+`)
+	configs, _, err := loadZonesFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, want := range []string{"ns1.example.com ns2.example.com:5353", "ns1.bke.ro ns2.bke.ro"} {
+		if got := strings.Join(configs[i].Hosts, " "); got != want {
+			t.Errorf("configs[%d].Hosts = %q, want %q", i, got, want)
+		}
+	}
+	if configs[0].Host != "ns1.example.com" {
+		t.Errorf("configs[0].Host = %q, want the first server", configs[0].Host)
+	}
+}
+
+func TestLoadZonesFile_FileNotFound_ReturnsError(t *testing.T) {
+	_, _, err := loadZonesFile("/nonexistent/path/zones.yaml")
 	if err == nil {
 		t.Error("expected error for missing file, got nil")
 	}
 }
 
-func TestLoadZoneConfigsFromFile_TSIGSecretFile_NotFound_ReturnsError(t *testing.T) {
+func TestLoadZonesFile_TSIGSecretFile_NotFound_ReturnsError(t *testing.T) {
 	path := writeYAML(t, `
 zones:
   - host: ns1.example.com
     zone: example.com.
     tsig-secret-file: /nonexistent/tsig.secret
 `)
-	_, err := loadZoneConfigsFromFile(path)
+	_, _, err := loadZonesFile(path)
 	if err == nil {
 		t.Error("expected error for missing tsig-secret-file, got nil")
 	}
 }
 
-func TestLoadZoneConfigsFromFile_InvalidYAML_ReturnsError(t *testing.T) {
+func TestLoadZonesFile_InvalidYAML_ReturnsError(t *testing.T) {
 	path := writeYAML(t, `not: valid: yaml: [`)
-	_, err := loadZoneConfigsFromFile(path)
+	_, _, err := loadZonesFile(path)
 	if err == nil {
 		t.Error("expected error for invalid YAML, got nil")
 	}
@@ -502,3 +661,733 @@ func TestLoadZoneConfigsFromEnv_InvalidPort_ReturnsError(t *testing.T) {
 		t.Error("expected error for invalid PORT, got nil")
 	}
 }
+
+func TestLoadZoneConfigsFromEnv_BatchSize_Parsed(t *testing.T) {
+	clearZoneEnv(t)
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_HOST", "ns1.example.com")
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_ZONE", "example.com.")
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_BATCH_SIZE", "50")
+
+	configs, _, err := loadZoneConfigsFromEnv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if configs[0].MaxUpdateRRs != 50 {
+		t.Errorf("MaxUpdateRRs = %d, want 50", configs[0].MaxUpdateRRs)
+	}
+}
+
+func TestLoadZoneConfigsFromEnv_Prerequisites_Parsed(t *testing.T) {
+	clearZoneEnv(t)
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_HOST", "ns1.example.com")
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_ZONE", "example.com.")
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_PREREQUISITES", "true")
+
+	configs, _, err := loadZoneConfigsFromEnv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !configs[0].Prerequisites {
+		t.Error("Prerequisites = false, want true")
+	}
+}
+
+func TestLoadZoneConfigsFromEnv_HostList_Parsed(t *testing.T) {
+	clearZoneEnv(t)
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_HOST", "ns1.example.com, ns2.example.com:5353")
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_ZONE", "example.com.")
+
+	configs, _, err := loadZoneConfigsFromEnv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(configs[0].Hosts, " "); got != "ns1.example.com ns2.example.com:5353" {
+		t.Errorf("Hosts = %q, want both servers in order", got)
+	}
+}
+
+func TestLoadZonesFile_Transport_Parsed(t *testing.T) {
+	path := writeYAML(t, `
+zones:
+  - host: ns1.example.com
+    zone: example.com.
+    transport: tcp-tls
+    tls-server-name: ns1.example.com
+`)
+	configs, _, err := loadZonesFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if configs[0].Transport != "tcp-tls" {
+		t.Errorf("Transport = %q, want tcp-tls", configs[0].Transport)
+	}
+	if configs[0].TLS == nil || configs[0].TLS.ServerName != "ns1.example.com" {
+		t.Errorf("TLS = %+v, want server name ns1.example.com", configs[0].TLS)
+	}
+}
+
+func TestLoadZonesFile_UnknownTransport_ReturnsError(t *testing.T) {
+	path := writeYAML(t, `
+zones:
+  - host: ns1.example.com
+    zone: example.com.
+    transport: quic
+`)
+	if _, _, err := loadZonesFile(path); err == nil {
+		t.Error("expected error for unknown transport, got nil")
+	}
+}
+
+func TestLoadZonesFile_TLSSettingsWithoutTLS_ReturnsError(t *testing.T) {
+	path := writeYAML(t, `
+zones:
+  - host: ns1.example.com
+    zone: example.com.
+    tls-ca-file: /etc/dns/ca.pem
+`)
+	if _, _, err := loadZonesFile(path); err == nil {
+		t.Error("expected error for TLS settings with the tcp transport, got nil")
+	}
+}
+
+func TestLoadZoneConfigsFromEnv_TLSCAFileMissing_ReturnsError(t *testing.T) {
+	clearZoneEnv(t)
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_HOST", "ns1.example.com")
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_ZONE", "example.com.")
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_TRANSPORT", "tcp-tls")
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_TLS_CA_FILE", "/nonexistent/ca.pem")
+
+	if _, _, err := loadZoneConfigsFromEnv(); err == nil {
+		t.Error("expected error for missing TLS_CA_FILE, got nil")
+	}
+}
+
+// writeSIG0Key writes a BIND SIG(0) key pair for client.example.com. and
+// returns the path of its .private file.
+func writeSIG0Key(t *testing.T) string {
+	t.Helper()
+	key := &dns.KEY{DNSKEY: dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: "client.example.com.", Rrtype: dns.TypeKEY, Class: dns.ClassINET},
+		Flags:     512,
+		Protocol:  3,
+		Algorithm: dns.ED25519,
+	}}
+	priv, err := key.Generate(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := filepath.Join(t.TempDir(), "Kclient.example.com.+015+00001")
+	if err := os.WriteFile(base+".key", []byte(key.String()+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(base+".private", []byte(key.PrivateKeyString(priv)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return base + ".private"
+}
+
+func TestLoadZonesFile_SIG0KeyFile_Resolved(t *testing.T) {
+	path := writeYAML(t, `
+zones:
+  - host: ns1.example.com
+    zone: example.com.
+    sig0-key-file: `+writeSIG0Key(t)+`
+`)
+	configs, _, err := loadZonesFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if configs[0].SIG0Key == nil || configs[0].SIG0Key.Name() != "client.example.com." {
+		t.Errorf("SIG0Key = %v, want the key for client.example.com.", configs[0].SIG0Key)
+	}
+}
+
+func TestLoadZonesFile_SIG0AndTSIG_ReturnsError(t *testing.T) {
+	path := writeYAML(t, `
+zones:
+  - host: ns1.example.com
+    zone: example.com.
+    tsig-key: prod-key
+    tsig-secret: c2VjcmV0
+    sig0-key-file: `+writeSIG0Key(t)+`
+`)
+	if _, _, err := loadZonesFile(path); err == nil {
+		t.Error("expected error for SIG(0) and TSIG together, got nil")
+	}
+}
+
+func TestLoadZoneConfigsFromEnv_SIG0KeyFile_Resolved(t *testing.T) {
+	clearZoneEnv(t)
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_HOST", "ns1.example.com")
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_ZONE", "example.com.")
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_SIG0_KEY_FILE", writeSIG0Key(t))
+
+	configs, _, err := loadZoneConfigsFromEnv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if configs[0].SIG0Key == nil || configs[0].SIG0KeyFile != "" {
+		t.Errorf("SIG0Key = %v, SIG0KeyFile = %q; want the key loaded and the path cleared", configs[0].SIG0Key, configs[0].SIG0KeyFile)
+	}
+}
+
+// ---- TSIG fallback keys ----
+
+func writeSecretFile(t *testing.T, secret string) string {
+	t.Helper()
+	f := filepath.Join(t.TempDir(), "tsig")
+	if err := os.WriteFile(f, []byte(secret+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestLoadZoneConfigsFromEnv_TSIGFallback_Parsed(t *testing.T) {
+	clearZoneEnv(t)
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_HOST", "ns1.example.com")
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_ZONE", "example.com.")
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_TSIG_KEY", "old-key")
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_TSIG_ALG", "hmac-sha512")
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_TSIG_FALLBACK_KEY", "new-key")
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_TSIG_FALLBACK_SECRET_FILE", writeSecretFile(t, "bmV3"))
+
+	configs, _, err := loadZoneConfigsFromEnv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	keys := configs[0].TSIGKeys
+	if len(keys) != 1 || keys[0].Name != "new-key" || keys[0].Secret != "bmV3" || keys[0].Alg != "hmac-sha512" {
+		t.Errorf("TSIGKeys = %+v, want new-key with its secret read and the zone's algorithm", keys)
+	}
+	if configs[0].TSIGKey != "old-key" {
+		t.Errorf("TSIGKey = %q, want old-key", configs[0].TSIGKey)
+	}
+}
+
+func TestLoadZoneConfigsFromEnv_TSIGFallbackWithoutName_ReturnsError(t *testing.T) {
+	clearZoneEnv(t)
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_HOST", "ns1.example.com")
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_ZONE", "example.com.")
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_TSIG_FALLBACK_SECRET", "bmV3")
+
+	if _, _, err := loadZoneConfigsFromEnv(); err == nil {
+		t.Error("expected error for a fallback key without a name, got nil")
+	}
+}
+
+func TestLoadZonesFile_TSIGFallbackKeys_Parsed(t *testing.T) {
+	path := writeYAML(t, `
+zones:
+  - host: ns1.example.com
+    zone: example.com.
+    tsig-key: old-key
+    tsig-secret-file: `+writeSecretFile(t, "b2xk")+`
+    tsig-fallback-keys:
+      - name: new-key
+        secret-file: `+writeSecretFile(t, "bmV3")+`
+        alg: hmac-sha512
+      - name: spare-key
+        secret: c3BhcmU=
+`)
+	configs, _, err := loadZonesFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	zc := configs[0]
+	if zc.TSIGSecret != "b2xk" || zc.TSIGSecretFile == "" {
+		t.Errorf("TSIGSecret = %q, TSIGSecretFile = %q; want the secret read and the path kept", zc.TSIGSecret, zc.TSIGSecretFile)
+	}
+	if len(zc.TSIGKeys) != 2 || zc.TSIGKeys[0].Secret != "bmV3" || zc.TSIGKeys[0].Alg != "hmac-sha512" || zc.TSIGKeys[1].Name != "spare-key" {
+		t.Errorf("TSIGKeys = %+v, want new-key then spare-key", zc.TSIGKeys)
+	}
+}
+
+func TestLoadZonesFile_TSIGFallbackKeyInvalid_ReturnsError(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{"no secret", "      - name: new-key\n"},
+		{"secret not base64", "      - name: new-key\n        secret: not-base64!\n"},
+		{"secret file not base64", "      - name: new-key\n        secret-file: " + writeSecretFile(t, "not-base64!") + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeYAML(t, `
+zones:
+  - host: ns1.example.com
+    zone: example.com.
+    tsig-key: old-key
+    tsig-secret: b2xk
+    tsig-fallback-keys:
+`+tt.key)
+			if _, _, err := loadZonesFile(path); err == nil {
+				t.Error("expected error for an invalid fallback key, got nil")
+			}
+		})
+	}
+}
+
+func TestLoadZonesFile_TSIGFallbackAndSIG0_ReturnsError(t *testing.T) {
+	path := writeYAML(t, `
+zones:
+  - host: ns1.example.com
+    zone: example.com.
+    sig0-key-file: `+writeSIG0Key(t)+`
+    tsig-fallback-keys:
+      - name: new-key
+        secret: bmV3
+`)
+	if _, _, err := loadZonesFile(path); err == nil {
+		t.Error("expected error for SIG(0) with TSIG fallback keys, got nil")
+	}
+}
+
+// ---- TSIG key files ----
+
+// writeKeyFile writes a BIND key file defining old-key (hmac-sha512) and
+// new-key (hmac-sha256) and returns its path.
+func writeKeyFile(t *testing.T) string {
+	t.Helper()
+	f := filepath.Join(t.TempDir(), "tsig.key")
+	content := `key "old-key" {
+	algorithm hmac-sha512;
+	secret "b2xk";
+};
+key "new-key" {
+	algorithm hmac-sha256;
+	secret "bmV3";
+};
+`
+	if err := os.WriteFile(f, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestLoadZonesFile_TSIGKeyfile_Resolved(t *testing.T) {
+	path := writeYAML(t, `
+zones:
+  - host: ns1.example.com
+    zone: example.com.
+    tsig-keyfile: `+writeKeyFile(t)+`
+    tsig-key: new-key
+    tsig-fallback-keys:
+      - name: old-key
+`)
+	configs, _, err := loadZonesFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	zc := configs[0]
+	if zc.TSIGKey != "new-key" || zc.TSIGSecret != "bmV3" || zc.TSIGAlg != "hmac-sha256" {
+		t.Errorf("TSIG = %s/%s/%s, want new-key/bmV3/hmac-sha256 from the key file", zc.TSIGKey, zc.TSIGSecret, zc.TSIGAlg)
+	}
+	if len(zc.TSIGKeys) != 1 || zc.TSIGKeys[0].Secret != "b2xk" || zc.TSIGKeys[0].Alg != "hmac-sha512" {
+		t.Errorf("TSIGKeys = %+v, want old-key looked up in the key file", zc.TSIGKeys)
+	}
+}
+
+func TestLoadZonesFile_TSIGKeyfileAmbiguous_ReturnsError(t *testing.T) {
+	path := writeYAML(t, `
+zones:
+  - host: ns1.example.com
+    zone: example.com.
+    tsig-keyfile: `+writeKeyFile(t)+`
+`)
+	_, _, err := loadZonesFile(path)
+	if err == nil || !strings.Contains(err.Error(), "old-key, new-key") {
+		t.Errorf("error = %v, want the key names listed", err)
+	}
+}
+
+func TestLoadZonesFile_TSIGKeyfileAndSecret_ReturnsError(t *testing.T) {
+	path := writeYAML(t, `
+zones:
+  - host: ns1.example.com
+    zone: example.com.
+    tsig-keyfile: `+writeKeyFile(t)+`
+    tsig-key: new-key
+    tsig-secret: bmV3
+`)
+	if _, _, err := loadZonesFile(path); err == nil {
+		t.Error("expected error for tsig-keyfile with tsig-secret, got nil")
+	}
+}
+
+func TestLoadZonesFile_TSIGKeyfileAlgMismatch_ReturnsError(t *testing.T) {
+	path := writeYAML(t, `
+zones:
+  - host: ns1.example.com
+    zone: example.com.
+    tsig-keyfile: `+writeKeyFile(t)+`
+    tsig-key: old-key
+    tsig-alg: hmac-sha256
+`)
+	if _, _, err := loadZonesFile(path); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("error = %v, want an algorithm mismatch", err)
+	}
+}
+
+func TestLoadZoneConfigsFromEnv_TSIGKeyfile_Resolved(t *testing.T) {
+	clearZoneEnv(t)
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_HOST", "ns1.example.com")
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_ZONE", "example.com.")
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_TSIG_KEYFILE", writeKeyFile(t))
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_TSIG_KEY", "old-key")
+
+	configs, _, err := loadZoneConfigsFromEnv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if zc := configs[0]; zc.TSIGSecret != "b2xk" || zc.TSIGAlg != "hmac-sha512" {
+		t.Errorf("TSIGSecret = %q, TSIGAlg = %q; want old-key's from the key file", zc.TSIGSecret, zc.TSIGAlg)
+	}
+}
+
+func TestLoadZoneConfigsFromEnv_TSIGKeyfileUnknownKey_ReturnsError(t *testing.T) {
+	clearZoneEnv(t)
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_HOST", "ns1.example.com")
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_ZONE", "example.com.")
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_TSIG_KEYFILE", writeKeyFile(t))
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_TSIG_KEY", "other-key")
+
+	_, _, err := loadZoneConfigsFromEnv()
+	if err == nil || !strings.Contains(err.Error(), "zone TEST: TSIG_KEYFILE") {
+		t.Errorf("error = %v, want the zone and field named", err)
+	}
+}
+
+// ---- Update verification ----
+
+func TestLoadZonesFile_Verify_Parsed(t *testing.T) {
+	path := writeYAML(t, `
+zones:
+  - host: ns1.example.com
+    zone: example.com.
+    verify: true
+    verify-secondaries: [ns2.example.com, "192.0.2.3:5353"]
+    verify-timeout: 1m
+`)
+	configs, _, err := loadZonesFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	zc := configs[0]
+	if !zc.Verify || strings.Join(zc.VerifySecondaries, " ") != "ns2.example.com 192.0.2.3:5353" || zc.VerifyTimeout != time.Minute {
+		t.Errorf("Verify = %v, VerifySecondaries = %v, VerifyTimeout = %v", zc.Verify, zc.VerifySecondaries, zc.VerifyTimeout)
+	}
+}
+
+func TestLoadZonesFile_VerifySecondariesWithoutVerify_ReturnsError(t *testing.T) {
+	path := writeYAML(t, `
+zones:
+  - host: ns1.example.com
+    zone: example.com.
+    verify-secondaries: ns2.example.com
+`)
+	if _, _, err := loadZonesFile(path); err == nil {
+		t.Error("expected error for verify-secondaries without verify, got nil")
+	}
+}
+
+func TestLoadZoneConfigsFromEnv_Verify_Parsed(t *testing.T) {
+	clearZoneEnv(t)
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_HOST", "ns1.example.com")
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_ZONE", "example.com.")
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_VERIFY", "true")
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_VERIFY_SECONDARIES", "ns2.example.com, ns3.example.com")
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_VERIFY_TIMEOUT", "45s")
+
+	configs, _, err := loadZoneConfigsFromEnv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	zc := configs[0]
+	if !zc.Verify || len(zc.VerifySecondaries) != 2 || zc.VerifyTimeout != 45*time.Second {
+		t.Errorf("Verify = %v, VerifySecondaries = %v, VerifyTimeout = %v", zc.Verify, zc.VerifySecondaries, zc.VerifyTimeout)
+	}
+}
+
+func TestLoadZoneConfigsFromEnv_VerifyInvalid_ReturnsError(t *testing.T) {
+	clearZoneEnv(t)
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_HOST", "ns1.example.com")
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_ZONE", "example.com.")
+	t.Setenv("EXTERNAL_DNS_RFC2136_ZONE_TEST_VERIFY", "sometimes")
+
+	if _, _, err := loadZoneConfigsFromEnv(); err == nil {
+		t.Error("expected error for an invalid VERIFY value, got nil")
+	}
+}
+
+// ---- loadZonesFile: discovery ----
+
+func TestLoadZonesFile_DiscoveryTemplates_Parsed(t *testing.T) {
+	path := writeYAML(t, `
+zones:
+  - host: ns1.example.com
+    zone: example.com.
+discovery:
+  resolver: 192.0.2.53
+  timeout: 3s
+  templates:
+    - tsig-key: default-key
+      tsig-secret: c2VjcmV0
+    - zone: bke.ro
+      host: [ns1.bke.ro, ns2.bke.ro]
+      tsig-key: bke-key
+      tsig-secret: c2VjcmV0Mg==
+`)
+	_, cfg, err := loadZonesFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Resolver != "192.0.2.53" || cfg.Timeout != 3*time.Second {
+		t.Errorf("resolver = %q, timeout = %v; want 192.0.2.53, 3s", cfg.Resolver, cfg.Timeout)
+	}
+	if len(cfg.Templates) != 2 {
+		t.Fatalf("got %d templates, want 2", len(cfg.Templates))
+	}
+	if cfg.Templates[0].Zone != "" || cfg.Templates[0].Host != "" || cfg.Templates[0].TSIGKey != "default-key" {
+		t.Errorf("templates[0] = %+v, want a default template without host", cfg.Templates[0])
+	}
+	if cfg.Templates[1].Zone != "bke.ro" || len(cfg.Templates[1].Hosts) != 2 {
+		t.Errorf("templates[1] = %+v, want bke.ro with two hosts", cfg.Templates[1])
+	}
+}
+
+func TestLoadZonesFile_DiscoveryNoBlock_ReturnsNil(t *testing.T) {
+	path := writeYAML(t, `
+zones:
+  - host: ns1.example.com
+    zone: example.com.
+`)
+	_, cfg, err := loadZonesFile(path)
+	if err != nil || cfg != nil {
+		t.Errorf("loadZonesFile() discovery = %v, %v; want nil, nil", cfg, err)
+	}
+}
+
+func TestLoadZonesFile_DiscoveryNoTemplates_ReturnsError(t *testing.T) {
+	path := writeYAML(t, `
+discovery:
+  resolver: 192.0.2.53
+`)
+	if _, _, err := loadZonesFile(path); err == nil {
+		t.Error("expected error for discovery without templates, got nil")
+	}
+}
+
+func TestLoadZonesFile_DiscoveryInvalidTemplate_ReturnsError(t *testing.T) {
+	path := writeYAML(t, `
+discovery:
+  resolver: 192.0.2.53
+  templates:
+    - tsig-secret: c2VjcmV0
+      tsig-secret-file: /run/secrets/tsig
+`)
+	_, _, err := loadZonesFile(path)
+	if err == nil || !strings.Contains(err.Error(), "template[0]") {
+		t.Errorf("error = %v, want one naming template[0]", err)
+	}
+}
+
+func TestLoadZonesFile_NoZonesNoDiscovery_ReturnsError(t *testing.T) {
+	path := writeYAML(t, `
+zones: []
+`)
+	if _, _, err := loadZonesFile(path); err == nil {
+		t.Error("expected error for a file without zones or discovery, got nil")
+	}
+}
+
+func TestLoadZonesFile_DiscoveryOnly_Parsed(t *testing.T) {
+	path := writeYAML(t, `
+zones: []
+discovery:
+  resolver: 192.0.2.53
+  templates:
+    - tsig-key: default-key
+      tsig-secret: c2VjcmV0
+`)
+	configs, cfg, err := loadZonesFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(configs) != 0 || cfg == nil || len(cfg.Templates) != 1 {
+		t.Errorf("loadZonesFile() = %v, %+v; want no zones and one discovery template", configs, cfg)
+	}
+}
+
+// ---- plan output ----
+
+func TestPlanOutputFormat_ByExtension(t *testing.T) {
+	tests := []struct {
+		path string
+		want plan.Format
+	}{
+		{"plan.json", plan.FormatJSON},
+		{"out/plan.YAML", plan.FormatYAML},
+		{"plan.yml", plan.FormatYAML},
+		{"changes.nsupdate", plan.FormatNSUpdate},
+	}
+	for _, tt := range tests {
+		got, err := planOutputFormat(tt.path)
+		if err != nil || got != tt.want {
+			t.Errorf("planOutputFormat(%q) = %q, %v, want %q", tt.path, got, err, tt.want)
+		}
+	}
+	if _, err := planOutputFormat("plan.txt"); err == nil {
+		t.Error("expected an error for an unknown extension")
+	}
+}
+
+func TestWritePlan_DryRun_WritesPlannedChanges(t *testing.T) {
+	app := endpoint.New("app.example.com", []string{"10.0.0.1"}, endpoint.RecordTypeA, 300, nil)
+	prov := fake_provider.New(nil)
+	ctrl := controller.New(fake_source.New([]*endpoint.Endpoint{app}), prov, slog.Default(),
+		controller.Config{Once: true, DryRun: true})
+	if err := ctrl.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "plan.json")
+	if err := writePlan(ctrl, path, plan.FormatJSON); err != nil {
+		t.Fatalf("writePlan() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var d plan.Document
+	if err := json.Unmarshal(data, &d); err != nil {
+		t.Fatalf("plan file is not JSON: %v", err)
+	}
+	if len(d.Create) != 2 || d.Create[0].Name != "app.example.com" {
+		t.Errorf("planned creates = %+v, want app.example.com and its ownership record", d.Create)
+	}
+	if recs, _ := prov.Records(context.Background()); len(recs) != 0 {
+		t.Errorf("dry-run applied records: %v", recs)
+	}
+}
+
+func TestWritePlan_NoCycle_ReturnsError(t *testing.T) {
+	ctrl := controller.New(fake_source.New(nil), fake_provider.New(nil), slog.Default(), controller.Config{Once: true})
+	if err := writePlan(ctrl, filepath.Join(t.TempDir(), "plan.json"), plan.FormatJSON); !errors.Is(err, errNoPlan) {
+		t.Errorf("writePlan() before any cycle ran = %v, want errNoPlan", err)
+	}
+}
+
+func TestWritePlan_Blocked_WritesPlannedDeletes(t *testing.T) {
+	owned := []*endpoint.Endpoint{
+		endpoint.New("app.example.com", []string{"10.0.0.1"}, endpoint.RecordTypeA, 300, nil),
+		endpoint.New("a-external-dns-docker-owner.app.example.com",
+			[]string{"heritage=external-dns-docker,external-dns-docker/owner=external-dns-docker"}, endpoint.RecordTypeTXT, 300, nil),
+	}
+	prov := fake_provider.New(owned)
+	ctrl := controller.New(fake_source.New(nil), prov, slog.Default(),
+		controller.Config{Once: true, MaxDeletePercent: 50})
+	if err := ctrl.Run(context.Background()); !errors.Is(err, controller.ErrDeleteThresholdExceeded) {
+		t.Fatalf("Run() error = %v, want ErrDeleteThresholdExceeded", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "plan.yaml")
+	if err := writePlan(ctrl, path, plan.FormatYAML); err != nil {
+		t.Fatalf("writePlan() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var d plan.Document
+	if err := yaml.Unmarshal(data, &d); err != nil {
+		t.Fatalf("plan file is not YAML: %v", err)
+	}
+	if len(d.Delete) != 2 || d.Delete[0].Name != "app.example.com" {
+		t.Errorf("planned deletes = %+v, want app.example.com and its ownership record", d.Delete)
+	}
+	if recs, _ := prov.Records(context.Background()); len(recs) != 2 {
+		t.Errorf("blocked run changed records: %v", recs)
+	}
+}
+
+// ---- detailed exit codes ----
+
+func TestOnceExitCode(t *testing.T) {
+	partial := provider.NewZoneErrors(func(string) string { return "b.example." })
+	partial.Add("app.b.example", errors.New("REFUSED"))
+	tests := []struct {
+		name  string
+		cycle controller.Cycle
+		ran   bool
+		want  int
+	}{
+		{"no cycle", controller.Cycle{}, false, exitError},
+		{"in sync", controller.Cycle{Result: "success"}, true, exitInSync},
+		{"changes", controller.Cycle{Result: "success", Create: 2}, true, exitChanges},
+		// An unverified apply is applied; the controller returns nil for it.
+		{"unverified apply", controller.Cycle{Result: "success", Update: 1}, true, exitChanges},
+		{"blocked", controller.Cycle{Result: "error", Delete: 4,
+			Err: fmt.Errorf("%w: 4 of 4 owned records would be deleted", controller.ErrDeleteThresholdExceeded)}, true, exitBlocked},
+		{"error", controller.Cycle{Result: "error", Err: errors.New("docker unavailable")}, true, exitError},
+		{"partial apply", controller.Cycle{Result: "partial", Create: 2,
+			Err: fmt.Errorf("apply changes: %w", partial)}, true, exitError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := onceExitCode(tt.cycle, tt.ran); got != tt.want {
+				t.Errorf("onceExitCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// ---- admin endpoints ----
+
+func TestAdminAuth(t *testing.T) {
+	tests := []struct {
+		name, token, header string
+		want                int
+	}{
+		{"disabled", "", "Bearer s3cret", http.StatusForbidden},
+		{"no header", "s3cret", "", http.StatusUnauthorized},
+		{"wrong token", "s3cret", "Bearer guess", http.StatusUnauthorized},
+		{"basic auth", "s3cret", "Basic czNjcmV0", http.StatusUnauthorized},
+		{"token", "s3cret", "Bearer s3cret", http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := adminAuth(tt.token, func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+			})
+			req := httptest.NewRequest(http.MethodPost, "/override-delete-threshold", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadAdminToken(t *testing.T) {
+	if tok, err := readAdminToken(""); tok != "" || err != nil {
+		t.Errorf("readAdminToken(\"\") = %q, %v; want admin endpoints disabled", tok, err)
+	}
+	path := filepath.Join(t.TempDir(), "admin-token")
+	if err := os.WriteFile(path, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if tok, err := readAdminToken(path); tok != "s3cret" || err != nil {
+		t.Errorf("readAdminToken() = %q, %v; want s3cret", tok, err)
+	}
+	if err := os.WriteFile(path, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readAdminToken(path); err == nil {
+		t.Error("expected an error for an empty token file")
+	}
+}
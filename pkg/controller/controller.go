@@ -3,8 +3,11 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -12,6 +15,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 
+	"github.com/bkero/external-dns-docker/pkg/endpoint"
 	"github.com/bkero/external-dns-docker/pkg/plan"
 	"github.com/bkero/external-dns-docker/pkg/provider"
 	"github.com/bkero/external-dns-docker/pkg/source"
@@ -39,8 +43,43 @@ var (
 		Name: "external_dns_docker_dns_operations_total",
 		Help: "Total number of DNS operations by type and result.",
 	}, []string{"op", "result"})
+
+	policySuppressedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "external_dns_docker_policy_suppressed_total",
+		Help: "Total number of DNS operations withheld by the sync policy, by type.",
+	}, []string{"op"})
+
+	endpointsFiltered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "external_dns_docker_endpoints_filtered_total",
+		Help: "Total number of endpoints dropped by the domain filter, by origin (source or provider).",
+	}, []string{"origin"})
+
+	deletesBlocked = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "external_dns_docker_deletes_blocked",
+		Help: "1 while the last change set is blocked by the delete safety threshold, 0 otherwise.",
+	})
+
+	updateConflictsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "external_dns_docker_update_conflicts_total",
+		Help: "Total number of change sets rejected because the records changed after they were read.",
+	})
+
+	unverifiedAppliesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "external_dns_docker_unverified_applies_total",
+		Help: "Total number of applied change sets that some servers did not serve yet when verified.",
+	})
 )
 
+// maxReplans bounds how often one cycle re-reads the records and plans again
+// after the provider reports a conflict, before the cycle fails and backs off.
+const maxReplans = 3
+
+// ErrDeleteThresholdExceeded is returned by a reconciliation cycle whose
+// change set deletes more records than Config.MaxDeletes or
+// Config.MaxDeletePercent allow. Nothing is applied until an operator
+// overrides the threshold.
+var ErrDeleteThresholdExceeded = errors.New("delete safety threshold exceeded")
+
 // Config holds controller tuning parameters.
 type Config struct {
 	// Interval is the periodic reconciliation interval. Default: 60s.
@@ -60,6 +99,32 @@ type Config struct {
 	// OwnerID is the ownership identifier written to TXT records.
 	// Uses plan.DefaultOwnerID if empty.
 	OwnerID string
+	// Policy limits which kinds of changes are applied. Default: plan.PolicySync.
+	Policy plan.Policy
+	// MaxDeletes is the largest number of records a single cycle may delete.
+	// Ownership TXT records are not counted. 0 disables the limit.
+	MaxDeletes int
+	// MaxDeletePercent is the largest share, in percent, of the currently
+	// owned records a single cycle may delete. 0 disables the limit.
+	MaxDeletePercent float64
+	// DomainFilter restricts the names this instance manages. Source endpoints
+	// and provider records outside it are dropped before planning, so they
+	// are never created, updated or deleted. Nil allows every name.
+	DomainFilter *endpoint.DomainFilter
+	// DeleteGrace keeps records whose source disappeared for this long before
+	// deleting them, so a restarting container keeps its records. An
+	// endpoint.LabelDeleteGrace label overrides it per record. 0 disables.
+	DeleteGrace time.Duration
+	// AutoPTR creates a reverse PTR record for every A and AAAA endpoint, not only
+	// those labelled with endpoint.LabelPTR. PTRs are only created in reverse
+	// zones the provider manages (see provider.ZoneMatcher).
+	AutoPTR bool
+	// Elector, if set, restricts reconciliation to the replica holding the
+	// leader-election lease; the others stand by. Nil means always lead.
+	Elector *Elector
+	// OverrideDeleteThreshold lets the first change set that exceeds the
+	// delete threshold through, as if OverrideDeleteThreshold had been called.
+	OverrideDeleteThreshold bool
 }
 
 // applyDefaults fills in zero-value fields with sensible defaults.
@@ -76,6 +141,9 @@ func (c *Config) applyDefaults() {
 	if c.BackoffMax <= 0 {
 		c.BackoffMax = 5 * time.Minute
 	}
+	if c.Policy == "" {
+		c.Policy = plan.PolicySync
+	}
 }
 
 // Controller orchestrates periodic and event-driven DNS reconciliation.
@@ -85,13 +153,59 @@ type Controller struct {
 	plan     *plan.Plan
 	log      *slog.Logger
 	cfg      Config
+	graves   *tombstones
 	ready    atomic.Bool // set true after first successful reconcile
+	blocked  atomic.Bool // set while the delete threshold blocks the change set
+	override atomic.Bool // one-shot approval for a change set over the delete threshold
+
+	trigger chan struct{} // requests an immediate cycle from Run
+
+	mu        sync.Mutex // guards the fields below and graves
+	degraded  []string   // zones skipped by the last cycle because they failed
+	lastCycle Cycle
 }
 
-// IsReady reports whether at least one reconciliation cycle has completed successfully.
-// Used by the health server to gate the readiness endpoint.
+// IsReady reports whether at least one reconciliation cycle has completed
+// successfully and the delete safety threshold is not currently blocking
+// changes. Used by the health server to gate the readiness endpoint.
 func (c *Controller) IsReady() bool {
-	return c.ready.Load()
+	return c.ready.Load() && !c.blocked.Load()
+}
+
+// DeletesBlocked reports whether the delete safety threshold blocked the
+// last planned change set.
+func (c *Controller) DeletesBlocked() bool {
+	return c.blocked.Load()
+}
+
+// DegradedZones returns the zones the last reconciliation cycle had to skip
+// because the provider could not read or update them, or nil when every zone
+// was healthy. Other zones keep reconciling while some are degraded.
+func (c *Controller) DegradedZones() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.degraded
+}
+
+// setDegraded records the zones skipped by the current cycle.
+func (c *Controller) setDegraded(zones []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.degraded = zones
+}
+
+// IsLeader reports whether this replica reconciles: true without leader
+// election, otherwise only while it holds the lease.
+func (c *Controller) IsLeader() bool {
+	return c.cfg.Elector.IsLeader()
+}
+
+// OverrideDeleteThreshold approves the next change set that exceeds the
+// delete safety threshold. The approval is consumed by that change set;
+// later cycles are checked against the threshold again.
+func (c *Controller) OverrideDeleteThreshold() {
+	c.log.Warn("delete safety threshold overridden for the next blocked change set")
+	c.override.Store(true)
 }
 
 // backoffDuration returns the backoff duration for the nth consecutive failure.
@@ -111,31 +225,54 @@ func (c *Controller) backoffDuration(consecutiveErrors int) time.Duration {
 	return d
 }
 
+// nextInterval returns the delay until the next periodic reconciliation:
+// cfg.Interval, shortened so that a pending delete runs when its grace
+// period expires.
+func (c *Controller) nextInterval() time.Duration {
+	d := c.cfg.Interval
+	c.mu.Lock()
+	next, ok := c.graves.nextExpiry()
+	c.mu.Unlock()
+	if ok {
+		if until := time.Until(next); until < d {
+			d = max(until, 0)
+		}
+	}
+	return d
+}
+
 // New returns a Controller wired with the given source, provider, and config.
 func New(src source.Source, prov provider.Provider, log *slog.Logger, cfg Config) *Controller {
 	cfg.applyDefaults()
 	if log == nil {
 		log = slog.Default()
 	}
-	return &Controller{
+	c := &Controller{
 		source:   src,
 		provider: prov,
-		plan:     plan.New(cfg.OwnerID),
+		plan:     plan.NewWithPolicy(cfg.OwnerID, cfg.Policy),
 		log:      log,
 		cfg:      cfg,
+		graves:   newTombstones(cfg.DeleteGrace),
+		trigger:  make(chan struct{}, 1),
 	}
+	c.override.Store(cfg.OverrideDeleteThreshold)
+	return c
 }
 
 // Run starts the reconciliation loop. It blocks until ctx is cancelled.
-// When cfg.Once is true it runs a single cycle and returns immediately.
+// When cfg.Once is true it runs a single cycle and returns immediately;
+// LastCycle then summarises that cycle.
 func (c *Controller) Run(ctx context.Context) error {
 	if c.cfg.Once {
-		return c.reconcile(ctx)
+		if err := c.reconcile(ctx); err != nil {
+			return err
+		}
+		// A partial apply does not fail the cycle, but does fail the run.
+		cycle, _ := c.LastCycle()
+		return cycle.Err
 	}
 
-	// reconcileCh is signalled by the debounce timer after Docker events.
-	reconcileCh := make(chan struct{}, 1)
-
 	var (
 		mu            sync.Mutex
 		debounceTimer *time.Timer
@@ -148,14 +285,24 @@ func (c *Controller) Run(ctx context.Context) error {
 		if debounceTimer != nil {
 			debounceTimer.Stop()
 		}
-		debounceTimer = time.AfterFunc(c.cfg.DebounceDuration, func() {
-			select {
-			case reconcileCh <- struct{}{}:
-			default:
-			}
-		})
+		debounceTimer = time.AfterFunc(c.cfg.DebounceDuration, c.Trigger)
 	})
 
+	// Reconcile as soon as this replica becomes leader. On shutdown, wait for
+	// the elector to release the lease.
+	if c.cfg.Elector != nil {
+		electorDone := make(chan struct{})
+		defer func() { <-electorDone }()
+		go func() {
+			defer close(electorDone)
+			c.cfg.Elector.Run(ctx, func(leading bool) {
+				if leading {
+					c.Trigger()
+				}
+			})
+		}()
+	}
+
 	// Timer-based scheduler: fires immediately for the first reconcile, then
 	// resets to cfg.Interval on success or a computed backoff on failure.
 	nextTimer := time.NewTimer(0)
@@ -174,7 +321,7 @@ func (c *Controller) Run(ctx context.Context) error {
 			nextTimer.Reset(b)
 		} else {
 			consecutiveErrors = 0
-			nextTimer.Reset(c.cfg.Interval)
+			nextTimer.Reset(c.nextInterval())
 		}
 	}
 
@@ -184,8 +331,9 @@ func (c *Controller) Run(ctx context.Context) error {
 			return ctx.Err()
 		case <-nextTimer.C:
 			doReconcile()
-		case <-reconcileCh:
-			// Docker event: reconcile immediately, cancelling any pending tick.
+		case <-c.trigger:
+			// Docker event or explicit trigger: reconcile immediately,
+			// cancelling any pending tick.
 			nextTimer.Stop()
 			doReconcile()
 		}
@@ -195,30 +343,61 @@ func (c *Controller) Run(ctx context.Context) error {
 // reconcile executes one full fetch → diff → apply cycle.
 func (c *Controller) reconcile(ctx context.Context) (retErr error) {
 	start := time.Now()
+	cycle := Cycle{Start: start, Result: "success"}
 	defer func() {
-		reconciliationDuration.Observe(time.Since(start).Seconds())
+		cycle.Duration = time.Since(start)
+		reconciliationDuration.Observe(cycle.Duration.Seconds())
 		if retErr == nil {
 			reconciliationsTotal.WithLabelValues("success").Inc()
 			c.ready.Store(true)
 		} else {
 			reconciliationsTotal.WithLabelValues("error").Inc()
+			cycle.Result, cycle.Err = "error", retErr
 		}
+		c.setLastCycle(cycle)
 	}()
 
-	desired, err := c.source.Endpoints(ctx)
-	if err != nil {
-		return fmt.Errorf("fetch desired endpoints: %w", err)
+	if !c.IsLeader() {
+		c.log.Debug("reconcile: standing by, another replica is leader")
+		cycle.Result = "standby"
+		return nil
 	}
 
-	current, err := c.provider.Records(ctx)
-	if err != nil {
-		return fmt.Errorf("fetch current records: %w", err)
+	// A conflict means the records changed between reading and applying, so
+	// the plan is stale: read them again and replan rather than back off.
+	for replans := 0; ; replans++ {
+		err := c.planAndApply(ctx, &cycle)
+		var conflict *provider.ConflictError
+		if !errors.As(err, &conflict) || replans == maxReplans {
+			return err
+		}
+		updateConflictsTotal.Inc()
+		c.log.Warn("reconcile: records changed since they were read, replanning",
+			"names", conflict.Names, "reason", conflict.Reason, "replan", replans+1)
 	}
+}
 
-	changes := c.plan.Calculate(desired, current)
+// planAndApply reads the desired and current records, plans the changes and
+// applies them, recording the planned counts in cycle.
+func (c *Controller) planAndApply(ctx context.Context, cycle *Cycle) error {
+	snap, err := c.observe(ctx, false)
+	if err != nil {
+		return err
+	}
+	changes := snap.Changes
+	cycle.Changes = changes
+	cycle.Create, cycle.Update, cycle.Delete = len(changes.Create), len(changes.UpdateOld), len(changes.Delete)
+	if changes.Suppressed != nil {
+		c.reportSuppressed(changes.Suppressed)
+	}
 
 	// Update the records-managed gauge to reflect current desired state.
-	recordsManaged.Set(float64(len(desired)))
+	recordsManaged.Set(float64(len(snap.Desired)))
+
+	overridden, err := c.checkDeleteThreshold(changes, snap.Current)
+	if err != nil {
+		return err
+	}
 
 	if changes.IsEmpty() {
 		c.log.Debug("reconcile: no changes")
@@ -233,41 +412,404 @@ func (c *Controller) reconcile(ctx context.Context) (retErr error) {
 
 	if c.cfg.DryRun {
 		c.log.Info("reconcile: dry-run enabled, skipping apply")
-		logChanges(c.log, changes)
+		logChanges(c.log, "dry-run", changes)
 		return nil
 	}
 
-	if err := c.provider.ApplyChanges(ctx, changes); err != nil {
-		dnsOperationsTotal.WithLabelValues("create", "error").Add(float64(len(changes.Create)))
-		dnsOperationsTotal.WithLabelValues("update", "error").Add(float64(len(changes.UpdateNew)))
-		dnsOperationsTotal.WithLabelValues("delete", "error").Add(float64(len(changes.Delete)))
-		return fmt.Errorf("apply changes: %w", err)
+	if !c.IsLeader() {
+		c.log.Warn("reconcile: lost leadership while planning, not applying changes")
+		cycle.Result = "standby"
+		return nil
 	}
 
-	dnsOperationsTotal.WithLabelValues("create", "success").Add(float64(len(changes.Create)))
-	dnsOperationsTotal.WithLabelValues("update", "success").Add(float64(len(changes.UpdateNew)))
-	dnsOperationsTotal.WithLabelValues("delete", "success").Add(float64(len(changes.Delete)))
+	// The override is spent only on a change set that is applied, not on
+	// a dry run.
+	if overridden {
+		c.override.Store(false)
+		c.log.Warn("reconcile: applying change set over the delete safety threshold on operator override")
+	}
+	err = c.provider.ApplyChanges(ctx, changes)
+	var (
+		conflict   *provider.ConflictError
+		applyErrs  *provider.ZoneErrors
+		partial    *provider.PartialApplyError
+		unverified *provider.VerifyError
+		failed     func(name string) bool
+	)
+	switch {
+	case errors.As(err, &applyErrs):
+		// Like zones that cannot be read, the failed zones are marked
+		// degraded and retried next cycle.
+		c.setDegraded(uniqueSorted(append(snap.SkippedZones, applyErrs.Zones()...)))
+		failed = applyErrs.Contains
+	case errors.As(err, &partial):
+		failed = partial.Contains
+	}
+	switch {
+	case failed != nil:
+		// Only the failed zones' or batches' operations failed; the rest
+		// were applied, so the others are not backed off. A conflict is
+		// replanned by reconcile, and a cycle none of whose operations were
+		// applied fails as a whole.
+		applied := countOperations(changes, failed)
+		if applied == 0 || errors.As(err, &conflict) {
+			return fmt.Errorf("apply changes: %w", err)
+		}
+		cycle.Result, cycle.Err = "partial", fmt.Errorf("apply changes: %w", err)
+		c.log.Error("reconcile: some changes failed", "zones", applyErrs.Zones(), "err", err)
+		return nil
+	case errors.As(err, &unverified):
+		// The changes were applied; some servers just do not serve them
+		// yet. That is no reason to back off: the next cycle reads the
+		// records again and replans anything still missing.
+		countOperations(changes, func(string) bool { return false })
+		unverifiedAppliesTotal.Inc()
+		c.log.Warn("reconcile: changes applied but not served by every server yet",
+			"mismatches", len(unverified.Mismatches), "err", err)
+		return nil
+	case err != nil:
+		countOperations(changes, func(string) bool { return true })
+		return fmt.Errorf("apply changes: %w", err)
+	}
+	countOperations(changes, func(string) bool { return false })
 
 	c.log.Info("reconcile: changes applied")
 	return nil
 }
 
-// logChanges logs the planned changes at INFO level for dry-run inspection.
-func logChanges(log *slog.Logger, changes *plan.Changes) {
+// observe fetches the desired and current records and calculates the
+// changes between them. A reconciliation cycle also advances the
+// delete-grace state and records filter metrics and degraded zones; a
+// preview leaves all state and metrics untouched, so it can run alongside
+// the loop.
+func (c *Controller) observe(ctx context.Context, preview bool) (*Snapshot, error) {
+	log := c.log
+	if preview {
+		log = slog.New(slog.DiscardHandler)
+	}
+
+	desired, err := c.source.Endpoints(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetch desired endpoints: %w", err)
+	}
+
+	var droppedDesired, droppedCurrent int
+	if c.cfg.DomainFilter.IsConfigured() {
+		desired, droppedDesired = c.filterDesired(log, desired)
+	}
+
+	// Zones found for new names are read below along with the rest, so
+	// their records are planned this cycle. Discovery queries the network
+	// and adds zones to the provider, so previews leave it to the cycles:
+	// until then, names in undiscovered zones preview as plain creates.
+	if zd, ok := c.provider.(provider.ZoneDiscoverer); ok && !preview {
+		names := make([]string, 0, len(desired))
+		for _, ep := range desired {
+			names = append(names, ep.DNSName)
+		}
+		zd.DiscoverZones(ctx, names)
+	}
+
+	// A multi-zone provider reports zones it could not read as ZoneErrors
+	// next to the records of the healthy zones. Those zones are left out of
+	// this cycle entirely: with no current records, anything planned for them
+	// would be wrong.
+	current, err := c.provider.Records(ctx)
+	var failedZones *provider.ZoneErrors
+	if errors.As(err, &failedZones) {
+		log.Warn("reconcile: skipping unavailable zones", "zones", failedZones.Zones(), "err", err)
+	} else if err != nil {
+		return nil, fmt.Errorf("fetch current records: %w", err)
+	}
+
+	if c.cfg.DomainFilter.IsConfigured() {
+		current, droppedCurrent = filterCurrent(c.cfg.DomainFilter, current)
+	}
+
+	c.mu.Lock()
+	graves := c.graves
+	if preview {
+		graves = graves.clone()
+	}
+	desired = graves.retain(log, desired)
+	c.mu.Unlock()
+
+	if ptrs := c.ptrEndpoints(desired); len(ptrs) > 0 {
+		if c.cfg.DomainFilter.IsConfigured() {
+			var dropped int
+			ptrs, dropped = c.filterDesired(log, ptrs)
+			droppedDesired += dropped
+		}
+		desired = append(desired, ptrs...)
+	}
+
+	if failedZones != nil {
+		desired = skipZones(desired, failedZones)
+	}
+
+	if !preview {
+		endpointsFiltered.WithLabelValues("source").Add(float64(droppedDesired))
+		endpointsFiltered.WithLabelValues("provider").Add(float64(droppedCurrent))
+		tombstonesPending.Set(float64(graves.len()))
+		c.setDegraded(failedZones.Zones())
+	}
+
+	return &Snapshot{
+		Desired:      desired,
+		Current:      current,
+		SkippedZones: failedZones.Zones(),
+		Changes:      c.plan.Calculate(desired, current),
+	}, nil
+}
+
+// countOperations adds the operations in changes to the DNS operations
+// metric, as errors for names failed reports true for and successes
+// otherwise. It returns the number of successes.
+func countOperations(changes *plan.Changes, failed func(name string) bool) int {
+	succeeded := 0
+	count := func(op string, eps []*endpoint.Endpoint) {
+		for _, ep := range eps {
+			result := "success"
+			if failed(ep.DNSName) {
+				result = "error"
+			} else {
+				succeeded++
+			}
+			dnsOperationsTotal.WithLabelValues(op, result).Inc()
+		}
+	}
+	count("create", changes.Create)
+	count("update", changes.UpdateNew)
+	count("delete", changes.Delete)
+	return succeeded
+}
+
+// skipZones drops desired endpoints in the failed zones, so nothing is
+// planned for them this cycle.
+func skipZones(desired []*endpoint.Endpoint, failed *provider.ZoneErrors) []*endpoint.Endpoint {
+	out := make([]*endpoint.Endpoint, 0, len(desired))
+	for _, ep := range desired {
+		if !failed.Contains(ep.DNSName) {
+			out = append(out, ep)
+		}
+	}
+	return out
+}
+
+// filterDesired drops source endpoints outside the domain filter, logging
+// each at WARN: a container claimed a name this instance may not manage. It
+// returns the kept endpoints and the number dropped.
+func (c *Controller) filterDesired(log *slog.Logger, desired []*endpoint.Endpoint) ([]*endpoint.Endpoint, int) {
+	out := make([]*endpoint.Endpoint, 0, len(desired))
+	for _, ep := range desired {
+		if c.cfg.DomainFilter.Match(ep.DNSName) {
+			out = append(out, ep)
+			continue
+		}
+		log.Warn("endpoint outside domain filter, skipping",
+			"name", ep.DNSName, "type", ep.RecordType, "filter", c.cfg.DomainFilter.String())
+	}
+	return out, len(desired) - len(out)
+}
+
+// filterCurrent drops provider records outside the domain filter. Ownership
+// TXT records are matched by the name they own. Out-of-scope records are
+// expected in a shared zone, so they are only counted, not logged. It
+// returns the kept records and the number dropped.
+func filterCurrent(f *endpoint.DomainFilter, current []*endpoint.Endpoint) ([]*endpoint.Endpoint, int) {
+	out := make([]*endpoint.Endpoint, 0, len(current))
+	for _, ep := range current {
+		if f.Match(plan.ManagedName(ep)) {
+			out = append(out, ep)
+		}
+	}
+	return out, len(current) - len(out)
+}
+
+// ptrEndpoints returns the reverse PTR endpoints for the A and AAAA records in
+// desired that request one, either via endpoint.LabelPTR or Config.AutoPTR. PTRs
+// whose reverse name lies outside the provider's zones are skipped; providers
+// that do not implement provider.ZoneMatcher get none. Since PTRs are part of
+// the desired state, the plan creates, updates and deletes them (and their
+// ownership records) together with the forward records.
+//
+// Several forward names sharing an address produce one PTR RRset listing all
+// of them. The result is sorted by name.
+func (c *Controller) ptrEndpoints(desired []*endpoint.Endpoint) []*endpoint.Endpoint {
+	zm, ok := c.provider.(provider.ZoneMatcher)
+	if !ok {
+		return nil
+	}
+
+	type reverse struct {
+		targets []string
+		ttl     int64
+	}
+	byName := make(map[string]*reverse)
+	for _, ep := range desired {
+		if ep.RecordType != endpoint.RecordTypeA && ep.RecordType != endpoint.RecordTypeAAAA {
+			continue
+		}
+		if !c.cfg.AutoPTR && ep.Labels[endpoint.LabelPTR] != "true" {
+			continue
+		}
+		for _, ip := range ep.Targets {
+			name, ok := endpoint.ReverseName(ip)
+			if !ok {
+				continue
+			}
+			if !zm.ManagesName(name) {
+				c.log.Debug("no managed reverse zone for address, skipping PTR",
+					"name", ep.DNSName, "address", ip)
+				continue
+			}
+			r, seen := byName[name]
+			if !seen {
+				r = &reverse{ttl: ep.TTL}
+				byName[name] = r
+			}
+			r.targets = append(r.targets, strings.TrimSuffix(ep.DNSName, "."))
+			if ep.TTL < r.ttl {
+				r.ttl = ep.TTL
+			}
+		}
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := make([]*endpoint.Endpoint, 0, len(names))
+	for _, name := range names {
+		r := byName[name]
+		targets := uniqueSorted(r.targets)
+		if len(targets) > 1 {
+			c.log.Debug("several names share an address, publishing a PTR for each",
+				"reverse", name, "targets", targets)
+		}
+		out = append(out, endpoint.New(name, targets, endpoint.RecordTypePTR, r.ttl, nil))
+	}
+	return out
+}
+
+// uniqueSorted returns the distinct values of s in sorted order.
+func uniqueSorted(s []string) []string {
+	seen := make(map[string]bool, len(s))
+	out := make([]string, 0, len(s))
+	for _, v := range s {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// checkDeleteThreshold returns ErrDeleteThresholdExceeded when changes
+// deletes more records than the configured limits allow and no override is
+// pending. The blocked change set is logged in full and reflected in the
+// deletes_blocked metric and readiness until a cycle passes the check. It
+// reports whether the change set passes only because of the override, which
+// the caller consumes once it applies the change set.
+func (c *Controller) checkDeleteThreshold(changes *plan.Changes, current []*endpoint.Endpoint) (bool, error) {
+	deletes := 0
+	for _, ep := range changes.Delete {
+		if !plan.IsOwnershipRecord(ep) {
+			deletes++
+		}
+	}
+	owned := c.plan.OwnedCount(current)
+
+	exceeded := c.cfg.MaxDeletes > 0 && deletes > c.cfg.MaxDeletes
+	if c.cfg.MaxDeletePercent > 0 && owned > 0 &&
+		float64(deletes)*100/float64(owned) > c.cfg.MaxDeletePercent {
+		exceeded = true
+	}
+
+	overridden := exceeded && c.override.Load()
+	if overridden {
+		c.log.Warn("reconcile: delete safety threshold exceeded, proceeding on operator override",
+			"deletes", deletes, "owned", owned)
+		exceeded = false
+	}
+	if !exceeded {
+		c.blocked.Store(false)
+		deletesBlocked.Set(0)
+		return overridden, nil
+	}
+
+	c.blocked.Store(true)
+	deletesBlocked.Set(1)
+	c.log.Error("reconcile: delete safety threshold exceeded, refusing to apply changes",
+		"deletes", deletes,
+		"owned", owned,
+		"max_deletes", c.cfg.MaxDeletes,
+		"max_delete_percent", c.cfg.MaxDeletePercent,
+	)
+	logChanges(c.log, "blocked", changes)
+	return false, fmt.Errorf("%w: %d of %d owned records would be deleted", ErrDeleteThresholdExceeded, deletes, owned)
+}
+
+// logChanges logs the planned changes at INFO level, each message prefixed
+// with reason (e.g. "dry-run"), for inspection without applying them. They
+// are logged in the change set's order, which Calculate makes stable.
+func logChanges(log *slog.Logger, reason string, changes *plan.Changes) {
 	for _, ep := range changes.Create {
-		log.Info("dry-run: would create",
+		log.Info(reason+": would create",
 			"name", ep.DNSName, "type", ep.RecordType, "targets", ep.Targets)
 	}
 	for i, old := range changes.UpdateOld {
 		if i < len(changes.UpdateNew) {
-			log.Info("dry-run: would update",
+			log.Info(reason+": would update",
 				"name", old.DNSName, "type", old.RecordType,
 				"old_targets", old.Targets, "new_targets", changes.UpdateNew[i].Targets,
 			)
 		}
 	}
 	for _, ep := range changes.Delete {
-		log.Info("dry-run: would delete",
+		log.Info(reason+": would delete",
 			"name", ep.DNSName, "type", ep.RecordType, "targets", ep.Targets)
 	}
 }
+
+// reportSuppressed counts and logs the operations the sync policy withheld.
+// Like the delete threshold, it counts records, not their ownership TXT
+// records, which are withheld along with them.
+func (c *Controller) reportSuppressed(s *plan.Changes) {
+	updates, deletes := 0, 0
+	for _, ep := range s.UpdateNew {
+		if !plan.IsOwnershipRecord(ep) {
+			updates++
+		}
+	}
+	for _, ep := range s.Delete {
+		if !plan.IsOwnershipRecord(ep) {
+			deletes++
+		}
+	}
+	policySuppressedTotal.WithLabelValues("update").Add(float64(updates))
+	policySuppressedTotal.WithLabelValues("delete").Add(float64(deletes))
+
+	c.log.Info("reconcile: changes suppressed by policy",
+		"policy", string(c.cfg.Policy),
+		"update", updates,
+		"delete", deletes,
+	)
+	for i, old := range s.UpdateOld {
+		if i < len(s.UpdateNew) && !plan.IsOwnershipRecord(old) {
+			c.log.Info("policy: suppressed update",
+				"name", old.DNSName, "type", old.RecordType,
+				"old_targets", old.Targets, "new_targets", s.UpdateNew[i].Targets,
+			)
+		}
+	}
+	for _, ep := range s.Delete {
+		if !plan.IsOwnershipRecord(ep) {
+			c.log.Info("policy: suppressed delete",
+				"name", ep.DNSName, "type", ep.RecordType, "targets", ep.Targets)
+		}
+	}
+}
@@ -3,7 +3,9 @@ package controller
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,6 +13,7 @@ import (
 
 	"github.com/bkero/external-dns-docker/pkg/endpoint"
 	"github.com/bkero/external-dns-docker/pkg/plan"
+	"github.com/bkero/external-dns-docker/pkg/provider"
 	fake_provider "github.com/bkero/external-dns-docker/pkg/provider/fake"
 	fake_source "github.com/bkero/external-dns-docker/pkg/source/fake"
 )
@@ -21,11 +24,12 @@ func ep(name, target string) *endpoint.Endpoint {
 	return endpoint.New(name, []string{target}, endpoint.RecordTypeA, 300, nil)
 }
 
-// ownerTXT returns the ownership sidecar TXT endpoint for the given DNS name
-// using the default owner ID. Mirrors what plan.ownershipTXTFor produces.
+// ownerTXT returns the ownership sidecar TXT endpoint for the A record at the
+// given DNS name using the default owner ID. Mirrors what plan.ownershipTXTFor
+// produces.
 func ownerTXT(name string) *endpoint.Endpoint {
 	return endpoint.New(
-		"external-dns-docker-owner."+name,
+		"a-external-dns-docker-owner."+name,
 		[]string{"heritage=external-dns-docker,external-dns-docker/owner=external-dns-docker"},
 		endpoint.RecordTypeTXT,
 		300,
@@ -133,6 +137,9 @@ func TestApplyDefaults_FillsZeroValues(t *testing.T) {
 	if cfg.BackoffMax != 5*time.Minute {
 		t.Errorf("BackoffMax = %v, want 5m", cfg.BackoffMax)
 	}
+	if cfg.Policy != plan.PolicySync {
+		t.Errorf("Policy = %q, want %q", cfg.Policy, plan.PolicySync)
+	}
 }
 
 func TestApplyDefaults_PreservesNonZero(t *testing.T) {
@@ -347,6 +354,915 @@ func TestRun_DryRun_LogsAllChangeTypes(t *testing.T) {
 	}
 }
 
+// --- Policy ---
+
+func TestRun_UpsertOnly_SuppressesDeleteAndCountsMetric(t *testing.T) {
+	before := testutil.ToFloat64(policySuppressedTotal.WithLabelValues("delete"))
+
+	src := fake_source.New(nil)
+	prov := fake_provider.New([]*endpoint.Endpoint{
+		ep("del.example.com", "4.4.4.4"),
+		ownerTXT("del.example.com"),
+	})
+	c := New(src, prov, slog.Default(), Config{Once: true, Policy: plan.PolicyUpsertOnly})
+
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if len(prov.History()) != 0 {
+		t.Errorf("expected 0 apply calls when only deletes are planned, got %d", len(prov.History()))
+	}
+	// The ownership TXT record is withheld with its record but not counted.
+	after := testutil.ToFloat64(policySuppressedTotal.WithLabelValues("delete"))
+	if after-before != 1 {
+		t.Errorf("policy_suppressed_total{op=delete} delta = %v, want 1", after-before)
+	}
+}
+
+func TestRun_CreateOnly_AppliesCreatesOnly(t *testing.T) {
+	before := testutil.ToFloat64(policySuppressedTotal.WithLabelValues("update"))
+
+	src := fake_source.New([]*endpoint.Endpoint{
+		ep("new.example.com", "1.1.1.1"),
+		ep("upd.example.com", "9.9.9.9"),
+	})
+	prov := fake_provider.New([]*endpoint.Endpoint{
+		ep("upd.example.com", "1.2.3.4"),
+		ownerTXT("upd.example.com"),
+	})
+	c := New(src, prov, slog.Default(), Config{Once: true, Policy: plan.PolicyCreateOnly})
+
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	hist := prov.History()
+	if len(hist) != 1 {
+		t.Fatalf("expected 1 apply call, got %d", len(hist))
+	}
+	if len(hist[0].UpdateNew) != 0 || len(hist[0].Delete) != 0 {
+		t.Errorf("expected creates only, got %+v", hist[0])
+	}
+	after := testutil.ToFloat64(policySuppressedTotal.WithLabelValues("update"))
+	if after-before != 1 {
+		t.Errorf("policy_suppressed_total{op=update} delta = %v, want 1", after-before)
+	}
+}
+
+// --- Delete safety threshold ---
+
+// ownedRecords returns n owned A records named host0..host<n-1>.example.com.
+func ownedRecords(n int) []*endpoint.Endpoint {
+	var eps []*endpoint.Endpoint
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("host%d.example.com", i)
+		eps = append(eps, ep(name, "10.0.0.1"), ownerTXT(name))
+	}
+	return eps
+}
+
+func TestRun_MaxDeletes_BlocksApply(t *testing.T) {
+	src := fake_source.New(nil)
+	prov := fake_provider.New(ownedRecords(3))
+	c := New(src, prov, slog.Default(), Config{Once: true, MaxDeletes: 2})
+
+	err := c.Run(context.Background())
+	if !errors.Is(err, ErrDeleteThresholdExceeded) {
+		t.Fatalf("Run error = %v, want ErrDeleteThresholdExceeded", err)
+	}
+	if len(prov.History()) != 0 {
+		t.Errorf("expected 0 apply calls while blocked, got %d", len(prov.History()))
+	}
+	if got := testutil.ToFloat64(deletesBlocked); got != 1 {
+		t.Errorf("deletes_blocked = %v, want 1", got)
+	}
+}
+
+func TestRun_MaxDeletes_WithinLimit_Applies(t *testing.T) {
+	src := fake_source.New(nil)
+	prov := fake_provider.New(ownedRecords(2))
+	c := New(src, prov, slog.Default(), Config{Once: true, MaxDeletes: 2})
+
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if len(prov.History()) != 1 {
+		t.Errorf("expected 1 apply call, got %d", len(prov.History()))
+	}
+	if got := testutil.ToFloat64(deletesBlocked); got != 0 {
+		t.Errorf("deletes_blocked = %v, want 0", got)
+	}
+}
+
+func TestRun_MaxDeletePercent_BlocksApply(t *testing.T) {
+	// 4 owned records, 2 still desired: deleting 2 of 4 is 50%.
+	src := fake_source.New([]*endpoint.Endpoint{
+		ep("host0.example.com", "10.0.0.1"),
+		ep("host1.example.com", "10.0.0.1"),
+	})
+	prov := fake_provider.New(ownedRecords(4))
+	c := New(src, prov, slog.Default(), Config{Once: true, MaxDeletePercent: 40})
+
+	if err := c.Run(context.Background()); !errors.Is(err, ErrDeleteThresholdExceeded) {
+		t.Fatalf("Run error = %v, want ErrDeleteThresholdExceeded", err)
+	}
+
+	c = New(src, prov, slog.Default(), Config{Once: true, MaxDeletePercent: 50})
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run error at 50%% limit: %v", err)
+	}
+}
+
+func TestReconcile_DeleteThreshold_FailsReadinessUntilCleared(t *testing.T) {
+	src := fake_source.New(nil)
+	prov := fake_provider.New(ownedRecords(3))
+	c := New(src, prov, slog.Default(), Config{MaxDeletes: 1})
+	c.ready.Store(true)
+
+	if err := c.reconcile(context.Background()); !errors.Is(err, ErrDeleteThresholdExceeded) {
+		t.Fatalf("reconcile error = %v, want ErrDeleteThresholdExceeded", err)
+	}
+	if c.IsReady() {
+		t.Error("IsReady() = true while deletes are blocked")
+	}
+
+	// The containers come back: nothing to delete, the block clears.
+	src.SetEndpoints([]*endpoint.Endpoint{
+		ep("host0.example.com", "10.0.0.1"),
+		ep("host1.example.com", "10.0.0.1"),
+		ep("host2.example.com", "10.0.0.1"),
+	})
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	if !c.IsReady() {
+		t.Error("IsReady() = false after the block cleared")
+	}
+}
+
+func TestReconcile_OverrideDeleteThreshold_IsOneShot(t *testing.T) {
+	src := fake_source.New(nil)
+	prov := fake_provider.New(ownedRecords(3))
+	c := New(src, prov, slog.Default(), Config{MaxDeletes: 1})
+
+	if err := c.reconcile(context.Background()); !errors.Is(err, ErrDeleteThresholdExceeded) {
+		t.Fatalf("reconcile error = %v, want ErrDeleteThresholdExceeded", err)
+	}
+
+	c.OverrideDeleteThreshold()
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error after override: %v", err)
+	}
+	if len(prov.History()) != 1 {
+		t.Fatalf("expected 1 apply call after override, got %d", len(prov.History()))
+	}
+
+	// The override was consumed: the next over-threshold change set blocks again.
+	prov2 := fake_provider.New(ownedRecords(3))
+	c.provider = prov2
+	if err := c.reconcile(context.Background()); !errors.Is(err, ErrDeleteThresholdExceeded) {
+		t.Errorf("reconcile error = %v, want ErrDeleteThresholdExceeded", err)
+	}
+}
+
+func TestReconcile_OverrideDeleteThreshold_KeptThroughDryRunAndPreview(t *testing.T) {
+	src := fake_source.New(nil)
+	prov := fake_provider.New(ownedRecords(3))
+	c := New(src, prov, slog.Default(), Config{MaxDeletes: 1, DryRun: true})
+	c.OverrideDeleteThreshold()
+
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("dry-run reconcile error: %v", err)
+	}
+	if _, err := c.Preview(context.Background()); err != nil {
+		t.Fatalf("Preview error: %v", err)
+	}
+	if len(prov.History()) != 0 {
+		t.Fatalf("dry run applied %d change sets", len(prov.History()))
+	}
+
+	// Nothing was applied, so the override still holds for the real cycle.
+	c.cfg.DryRun = false
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error after dry run: %v", err)
+	}
+	if len(prov.History()) != 1 {
+		t.Fatalf("expected 1 apply call, got %d", len(prov.History()))
+	}
+	if c.override.Load() {
+		t.Error("override still armed after the change set was applied")
+	}
+}
+
+func TestRun_OverrideDeleteThresholdConfig_AppliesOnce(t *testing.T) {
+	src := fake_source.New(nil)
+	prov := fake_provider.New(ownedRecords(3))
+	c := New(src, prov, slog.Default(), Config{Once: true, MaxDeletes: 1, OverrideDeleteThreshold: true})
+
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if len(prov.History()) != 1 {
+		t.Errorf("expected 1 apply call, got %d", len(prov.History()))
+	}
+}
+
+// --- Domain filter ---
+
+func TestRun_DomainFilter_DropsOutOfScopeDesired(t *testing.T) {
+	before := testutil.ToFloat64(endpointsFiltered.WithLabelValues("source"))
+	f, err := endpoint.NewDomainFilter([]string{"*.apps.example.com"}, nil, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := fake_source.New([]*endpoint.Endpoint{
+		ep("web.apps.example.com", "1.1.1.1"),
+		ep("www.example.com", "2.2.2.2"),
+	})
+	prov := fake_provider.New(nil)
+	c := New(src, prov, slog.Default(), Config{Once: true, DomainFilter: f})
+
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	hist := prov.History()
+	if len(hist) != 1 {
+		t.Fatalf("expected 1 apply call, got %d", len(hist))
+	}
+	for _, e := range hist[0].Create {
+		if !f.Match(plan.ManagedName(e)) {
+			t.Errorf("created out-of-scope record %s", e.DNSName)
+		}
+	}
+	if got := testutil.ToFloat64(endpointsFiltered.WithLabelValues("source")) - before; got != 1 {
+		t.Errorf("endpoints_filtered_total{origin=source} delta = %v, want 1", got)
+	}
+}
+
+func TestRun_DomainFilter_LeavesOutOfScopeCurrentAlone(t *testing.T) {
+	// An owned record outside the filter is not desired, yet must not be
+	// deleted: it is out of scope for this instance.
+	f, err := endpoint.NewDomainFilter([]string{"apps.example.com"}, nil, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := fake_source.New([]*endpoint.Endpoint{ep("web.apps.example.com", "1.1.1.1")})
+	prov := fake_provider.New([]*endpoint.Endpoint{
+		ep("web.apps.example.com", "1.1.1.1"),
+		ownerTXT("web.apps.example.com"),
+		ep("legacy.example.com", "2.2.2.2"),
+		ownerTXT("legacy.example.com"),
+	})
+	c := New(src, prov, slog.Default(), Config{Once: true, DomainFilter: f})
+
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if len(prov.History()) != 0 {
+		t.Errorf("expected no changes, got %+v", prov.History())
+	}
+}
+
+// --- Zone discovery ---
+
+// discoveringProvider is a fake provider that records the names it is asked
+// to discover zones for and whether Records had been called by then.
+type discoveringProvider struct {
+	*fake_provider.Provider
+	names         []string
+	recordsCalled bool
+	readFirst     bool
+}
+
+func (p *discoveringProvider) Records(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	p.recordsCalled = true
+	return p.Provider.Records(ctx)
+}
+
+func (p *discoveringProvider) DiscoverZones(_ context.Context, names []string) {
+	p.readFirst = p.recordsCalled
+	p.names = append(p.names, names...)
+}
+
+func TestReconcile_DiscoversZonesForFilteredNamesBeforeReading(t *testing.T) {
+	f, err := endpoint.NewDomainFilter([]string{"apps.example.com"}, nil, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := fake_source.New([]*endpoint.Endpoint{
+		ep("web.apps.example.com", "1.1.1.1"),
+		ep("www.example.com", "2.2.2.2"),
+	})
+	prov := &discoveringProvider{Provider: fake_provider.New(nil)}
+	c := New(src, prov, slog.Default(), Config{DomainFilter: f})
+
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	if strings.Join(prov.names, " ") != "web.apps.example.com" {
+		t.Errorf("names discovered = %v, want only the in-scope name", prov.names)
+	}
+	if prov.readFirst {
+		t.Error("zones discovered after the current records were read")
+	}
+}
+
+// --- Reverse PTR records ---
+
+func ptrWanted(name, target string) *endpoint.Endpoint {
+	e := ep(name, target)
+	e.Labels[endpoint.LabelPTR] = "true"
+	return e
+}
+
+// findRecord returns the stored endpoint with the given name and type, or nil.
+func findRecord(t *testing.T, prov *fake_provider.Provider, name, recordType string) *endpoint.Endpoint {
+	t.Helper()
+	recs, err := prov.Records(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range recs {
+		if r.DNSName == name && r.RecordType == recordType {
+			return r
+		}
+	}
+	return nil
+}
+
+func TestReconcile_PTR_CreatedForLabelledRecordsOnly(t *testing.T) {
+	src := fake_source.New([]*endpoint.Endpoint{
+		ptrWanted("app.example.com", "192.0.2.5"),
+		ep("db.example.com", "192.0.2.6"),
+	})
+	prov := fake_provider.New(nil)
+	prov.SetZones("example.com", "2.0.192.in-addr.arpa")
+	c := New(src, prov, slog.Default(), Config{})
+
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	ptr := findRecord(t, prov, "5.2.0.192.in-addr.arpa", endpoint.RecordTypePTR)
+	if ptr == nil || len(ptr.Targets) != 1 || ptr.Targets[0] != "app.example.com" {
+		t.Fatalf("PTR = %v, want 5.2.0.192.in-addr.arpa → app.example.com", ptr)
+	}
+	if findRecord(t, prov, "ptr-external-dns-docker-owner.5.2.0.192.in-addr.arpa", endpoint.RecordTypeTXT) == nil {
+		t.Error("PTR ownership TXT not created")
+	}
+	if findRecord(t, prov, "6.2.0.192.in-addr.arpa", endpoint.RecordTypePTR) != nil {
+		t.Error("PTR created for a record without the ptr label")
+	}
+}
+
+func TestReconcile_AutoPTR_OnlyInManagedReverseZones(t *testing.T) {
+	src := fake_source.New([]*endpoint.Endpoint{
+		ep("app.example.com", "192.0.2.5"),
+		ep("ext.example.com", "198.51.100.7"),
+	})
+	prov := fake_provider.New(nil)
+	prov.SetZones("example.com", "2.0.192.in-addr.arpa")
+	c := New(src, prov, slog.Default(), Config{AutoPTR: true})
+
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	if findRecord(t, prov, "5.2.0.192.in-addr.arpa", endpoint.RecordTypePTR) == nil {
+		t.Error("expected PTR in the managed reverse zone")
+	}
+	if findRecord(t, prov, "7.100.51.198.in-addr.arpa", endpoint.RecordTypePTR) != nil {
+		t.Error("PTR created in an unmanaged reverse zone")
+	}
+}
+
+func TestReconcile_PTR_FollowsForwardRecord(t *testing.T) {
+	src := fake_source.New([]*endpoint.Endpoint{ptrWanted("app.example.com", "192.0.2.5")})
+	prov := fake_provider.New(nil)
+	prov.SetZones("example.com", "2.0.192.in-addr.arpa")
+	c := New(src, prov, slog.Default(), Config{})
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+
+	// The address changes: the old PTR goes, a new one appears.
+	src.SetEndpoints([]*endpoint.Endpoint{ptrWanted("app.example.com", "192.0.2.9")})
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	if findRecord(t, prov, "5.2.0.192.in-addr.arpa", endpoint.RecordTypePTR) != nil {
+		t.Error("stale PTR for the old address not deleted")
+	}
+	if findRecord(t, prov, "9.2.0.192.in-addr.arpa", endpoint.RecordTypePTR) == nil {
+		t.Error("PTR for the new address not created")
+	}
+
+	// The container goes away: its PTR is deleted with the forward record.
+	src.SetEndpoints(nil)
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	if findRecord(t, prov, "9.2.0.192.in-addr.arpa", endpoint.RecordTypePTR) != nil {
+		t.Error("PTR not deleted with its forward record")
+	}
+}
+
+func TestPTREndpoints_SharedAddressMergedAndSorted(t *testing.T) {
+	prov := fake_provider.New(nil)
+	c := New(fake_source.New(nil), prov, slog.Default(), Config{AutoPTR: true})
+
+	ptrs := c.ptrEndpoints([]*endpoint.Endpoint{
+		ep("b.example.com", "192.0.2.5"),
+		ep("a.example.com", "192.0.2.5"),
+		ep("c.example.com", "192.0.2.1"),
+	})
+	if len(ptrs) != 2 {
+		t.Fatalf("got %d PTRs, want 2", len(ptrs))
+	}
+	if ptrs[0].DNSName != "1.2.0.192.in-addr.arpa" || ptrs[1].DNSName != "5.2.0.192.in-addr.arpa" {
+		t.Errorf("PTR names = %s, %s; want sorted", ptrs[0].DNSName, ptrs[1].DNSName)
+	}
+	if got := ptrs[1].Targets; len(got) != 2 || got[0] != "a.example.com" || got[1] != "b.example.com" {
+		t.Errorf("shared PTR targets = %v, want [a.example.com b.example.com]", got)
+	}
+}
+
+// --- Delete grace ---
+
+// graceClock makes c's delete-grace tracking read the time from *now.
+func graceClock(c *Controller, now *time.Time) {
+	c.graves.now = func() time.Time { return *now }
+}
+
+func TestReconcile_DeleteGrace_RetainsUntilExpiry(t *testing.T) {
+	src := fake_source.New([]*endpoint.Endpoint{ep("app.example.com", "10.0.0.1")})
+	prov := fake_provider.New(nil)
+	c := New(src, prov, slog.Default(), Config{DeleteGrace: time.Minute})
+	now := time.Unix(1_000_000, 0)
+	graceClock(c, &now)
+
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+
+	src.SetEndpoints(nil)
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	if findRecord(t, prov, "app.example.com", endpoint.RecordTypeA) == nil {
+		t.Fatal("record deleted inside its grace period")
+	}
+	if got := testutil.ToFloat64(tombstonesPending); got != 1 {
+		t.Errorf("tombstones_pending = %v, want 1", got)
+	}
+	if next, ok := c.graves.nextExpiry(); !ok || !next.Equal(now.Add(time.Minute)) {
+		t.Errorf("nextExpiry() = %v, %v; want %v", next, ok, now.Add(time.Minute))
+	}
+
+	now = now.Add(30 * time.Second)
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	if findRecord(t, prov, "app.example.com", endpoint.RecordTypeA) == nil {
+		t.Fatal("record deleted inside its grace period")
+	}
+
+	now = now.Add(30 * time.Second)
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	if findRecord(t, prov, "app.example.com", endpoint.RecordTypeA) != nil {
+		t.Error("record not deleted after its grace period expired")
+	}
+	if got := testutil.ToFloat64(tombstonesPending); got != 0 {
+		t.Errorf("tombstones_pending = %v, want 0", got)
+	}
+}
+
+func TestReconcile_DeleteGrace_ReappearanceCancelsDelete(t *testing.T) {
+	src := fake_source.New([]*endpoint.Endpoint{ep("app.example.com", "10.0.0.1")})
+	prov := fake_provider.New(nil)
+	c := New(src, prov, slog.Default(), Config{DeleteGrace: time.Minute})
+	now := time.Unix(1_000_000, 0)
+	graceClock(c, &now)
+
+	for _, eps := range [][]*endpoint.Endpoint{
+		{ep("app.example.com", "10.0.0.1")},
+		nil,
+		{ep("app.example.com", "10.0.0.1")},
+	} {
+		src.SetEndpoints(eps)
+		if err := c.reconcile(context.Background()); err != nil {
+			t.Fatalf("reconcile error: %v", err)
+		}
+	}
+	if _, ok := c.graves.nextExpiry(); ok {
+		t.Error("tombstone still pending after the record reappeared")
+	}
+
+	// Past the original expiry the record must still exist.
+	now = now.Add(2 * time.Minute)
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	if findRecord(t, prov, "app.example.com", endpoint.RecordTypeA) == nil {
+		t.Error("record deleted after it reappeared")
+	}
+	for _, h := range prov.History() {
+		if len(h.Delete) > 0 {
+			t.Errorf("unexpected delete during restart: %v", h.Delete)
+		}
+	}
+}
+
+func TestReconcile_DeleteGrace_ReplicaLeavingRRset_TargetRetained(t *testing.T) {
+	replicas := func(targets ...string) []*endpoint.Endpoint {
+		return []*endpoint.Endpoint{endpoint.New("app.example.com", targets, endpoint.RecordTypeA, 300, nil)}
+	}
+	src := fake_source.New(replicas("10.0.0.1", "10.0.0.2"))
+	prov := fake_provider.New(nil)
+	c := New(src, prov, slog.Default(), Config{DeleteGrace: time.Minute})
+	now := time.Unix(1_000_000, 0)
+	graceClock(c, &now)
+
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+
+	src.SetEndpoints(replicas("10.0.0.1"))
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	if rec := findRecord(t, prov, "app.example.com", endpoint.RecordTypeA); rec == nil || len(rec.Targets) != 2 {
+		t.Fatalf("record = %v, want both targets inside the grace period", rec)
+	}
+	if got := testutil.ToFloat64(tombstonesPending); got != 1 {
+		t.Errorf("tombstones_pending = %v, want 1", got)
+	}
+
+	now = now.Add(time.Minute)
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	rec := findRecord(t, prov, "app.example.com", endpoint.RecordTypeA)
+	if rec == nil || len(rec.Targets) != 1 || rec.Targets[0] != "10.0.0.1" {
+		t.Errorf("record = %v, want only 10.0.0.1 after the grace period", rec)
+	}
+}
+
+func TestReconcile_DeleteGrace_CNAMEChanged_ReplacedAtOnce(t *testing.T) {
+	cname := func(target string) []*endpoint.Endpoint {
+		return []*endpoint.Endpoint{endpoint.New("www.example.com", []string{target}, endpoint.RecordTypeCNAME, 300, nil)}
+	}
+	src := fake_source.New(cname("old.example.com"))
+	prov := fake_provider.New(nil)
+	c := New(src, prov, slog.Default(), Config{DeleteGrace: time.Minute})
+
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	src.SetEndpoints(cname("new.example.com"))
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	rec := findRecord(t, prov, "www.example.com", endpoint.RecordTypeCNAME)
+	if rec == nil || len(rec.Targets) != 1 || rec.Targets[0] != "new.example.com" {
+		t.Errorf("record = %v, want only new.example.com", rec)
+	}
+}
+
+func TestReconcile_DeleteGrace_LabelOverridesDefault(t *testing.T) {
+	labelled := ep("app.example.com", "10.0.0.1")
+	labelled.Labels[endpoint.LabelDeleteGrace] = "5m0s"
+	immediate := ep("db.example.com", "10.0.0.2")
+	immediate.Labels[endpoint.LabelDeleteGrace] = "0s"
+
+	src := fake_source.New([]*endpoint.Endpoint{labelled, immediate, ep("web.example.com", "10.0.0.3")})
+	prov := fake_provider.New(nil)
+	c := New(src, prov, slog.Default(), Config{DeleteGrace: time.Minute})
+	now := time.Unix(1_000_000, 0)
+	graceClock(c, &now)
+
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	src.SetEndpoints(nil)
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	if findRecord(t, prov, "db.example.com", endpoint.RecordTypeA) != nil {
+		t.Error("record with a zero delete-grace label was retained")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	if findRecord(t, prov, "web.example.com", endpoint.RecordTypeA) != nil {
+		t.Error("record without a label outlived the default grace period")
+	}
+	if findRecord(t, prov, "app.example.com", endpoint.RecordTypeA) == nil {
+		t.Error("labelled record deleted before its own grace period expired")
+	}
+}
+
+func TestReconcile_DeleteGraceDisabled_DeletesImmediately(t *testing.T) {
+	src := fake_source.New([]*endpoint.Endpoint{ep("app.example.com", "10.0.0.1")})
+	prov := fake_provider.New(nil)
+	c := New(src, prov, slog.Default(), Config{})
+
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	src.SetEndpoints(nil)
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	if findRecord(t, prov, "app.example.com", endpoint.RecordTypeA) != nil {
+		t.Error("record retained with delete grace disabled")
+	}
+}
+
+func TestNextInterval_ShortenedByPendingTombstone(t *testing.T) {
+	c := New(fake_source.New(nil), fake_provider.New(nil), slog.Default(), Config{Interval: time.Hour})
+	if got := c.nextInterval(); got != time.Hour {
+		t.Errorf("nextInterval() = %v, want 1h with nothing pending", got)
+	}
+	c.graves.pending["app.example.com|A|10.0.0.1"] = tombstone{
+		ep:      ep("app.example.com", "10.0.0.1"),
+		expires: time.Now().Add(10 * time.Second),
+	}
+	if got := c.nextInterval(); got > 10*time.Second || got <= 0 {
+		t.Errorf("nextInterval() = %v, want at most 10s", got)
+	}
+}
+
+// --- Zone failure isolation ---
+
+// zoneDownProvider wraps a fake provider serving example.com and bke.ro and
+// reports bke.ro as failed while down is set, like rfc2136.MultiProvider.
+// While allDown is set both zones fail, and like MultiProvider it returns a
+// plain error.
+type zoneDownProvider struct {
+	*fake_provider.Provider
+	down    bool
+	allDown bool
+	reads   int
+}
+
+func zoneOfTest(name string) string {
+	if strings.HasSuffix(name, "bke.ro") {
+		return "bke.ro."
+	}
+	return "example.com."
+}
+
+func (p *zoneDownProvider) Records(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	p.reads++
+	recs, _ := p.Provider.Records(ctx)
+	if p.allDown {
+		return nil, errors.New("all zones failed: zone bke.ro.: connection refused; zone example.com.: connection refused")
+	}
+	if !p.down {
+		return recs, nil
+	}
+	zerrs := provider.NewZoneErrors(zoneOfTest)
+	zerrs.Add("bke.ro.", errors.New("connection refused"))
+	var healthy []*endpoint.Endpoint
+	for _, r := range recs {
+		if !zerrs.Contains(r.DNSName) {
+			healthy = append(healthy, r)
+		}
+	}
+	return healthy, zerrs
+}
+
+func TestReconcile_FailedZone_SkippedWhileOthersReconcile(t *testing.T) {
+	// api.bke.ro exists and is owned; with bke.ro down it must not be
+	// deleted, and new.bke.ro must not be created.
+	prov := &zoneDownProvider{Provider: fake_provider.New(nil)}
+	src := fake_source.New([]*endpoint.Endpoint{
+		ep("api.bke.ro", "10.0.0.2"),
+	})
+	c := New(src, prov, slog.Default(), Config{})
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+
+	prov.down = true
+	src.SetEndpoints([]*endpoint.Endpoint{
+		ep("app.example.com", "10.0.0.1"),
+		ep("new.bke.ro", "10.0.0.3"),
+	})
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+
+	if findRecord(t, prov.Provider, "app.example.com", endpoint.RecordTypeA) == nil {
+		t.Error("healthy zone not reconciled")
+	}
+	if findRecord(t, prov.Provider, "new.bke.ro", endpoint.RecordTypeA) != nil {
+		t.Error("record created in a failed zone")
+	}
+	if findRecord(t, prov.Provider, "api.bke.ro", endpoint.RecordTypeA) == nil {
+		t.Error("record deleted in a failed zone")
+	}
+	if got := c.DegradedZones(); len(got) != 1 || got[0] != "bke.ro." {
+		t.Errorf("DegradedZones() = %v, want [bke.ro.]", got)
+	}
+	if !c.IsReady() {
+		t.Error("controller should stay ready while degraded")
+	}
+
+	// The zone recovers: it is reconciled again and the degraded state clears.
+	prov.down = false
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	if findRecord(t, prov.Provider, "new.bke.ro", endpoint.RecordTypeA) == nil {
+		t.Error("recovered zone not reconciled")
+	}
+	if got := c.DegradedZones(); got != nil {
+		t.Errorf("DegradedZones() = %v after recovery, want nil", got)
+	}
+}
+
+func TestRun_EveryZoneFailed_FailsAndBacksOff(t *testing.T) {
+	prov := &zoneDownProvider{Provider: fake_provider.New(nil), allDown: true}
+	src := fake_source.New([]*endpoint.Endpoint{ep("app.example.com", "10.0.0.1")})
+	c := New(src, prov, slog.Default(), Config{
+		Interval:         5 * time.Millisecond,
+		DebounceDuration: time.Hour,
+		BackoffBase:      time.Hour,
+	})
+
+	if err := c.reconcile(context.Background()); err == nil {
+		t.Fatal("reconcile error = nil, want an error when every zone failed")
+	}
+	if c.IsReady() {
+		t.Error("IsReady() = true with every zone down, want false")
+	}
+	if cycle, _ := c.LastCycle(); cycle.Result != "error" {
+		t.Errorf("LastCycle().Result = %q, want error", cycle.Result)
+	}
+
+	// In the loop, the failed cycle backs off instead of running again at
+	// the interval.
+	prov.reads = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- c.Run(ctx) }()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-errCh
+	if prov.reads != 1 {
+		t.Errorf("provider read %d times, want 1 before backing off", prov.reads)
+	}
+}
+
+// partialApplyProvider fails ApplyChanges for bke.ro names only.
+type partialApplyProvider struct {
+	*fake_provider.Provider
+}
+
+func (p *partialApplyProvider) ApplyChanges(ctx context.Context, changes *plan.Changes) error {
+	_ = p.Provider.ApplyChanges(ctx, changes)
+	zerrs := provider.NewZoneErrors(zoneOfTest)
+	zerrs.Add("bke.ro.", errors.New("update refused"))
+	return zerrs
+}
+
+func TestReconcile_PartialApplyFailure_CountsPerZone(t *testing.T) {
+	prov := &partialApplyProvider{Provider: fake_provider.New(nil)}
+	src := fake_source.New([]*endpoint.Endpoint{
+		ep("app.example.com", "10.0.0.1"),
+		ep("api.bke.ro", "10.0.0.2"),
+	})
+	c := New(src, prov, slog.Default(), Config{})
+
+	okBefore := testutil.ToFloat64(dnsOperationsTotal.WithLabelValues("create", "success"))
+	errBefore := testutil.ToFloat64(dnsOperationsTotal.WithLabelValues("create", "error"))
+	// The zones that applied are not backed off for the one that failed.
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error = %v, want nil for a partially failed apply", err)
+	}
+	if cycle, _ := c.LastCycle(); cycle.Result != "partial" || cycle.Err == nil {
+		t.Errorf("LastCycle = %+v, want a partial cycle carrying the zone errors", cycle)
+	}
+	if !c.IsReady() {
+		t.Error("controller not ready after a partially failed apply")
+	}
+	// Each name has an A record and an ownership TXT.
+	if got := testutil.ToFloat64(dnsOperationsTotal.WithLabelValues("create", "success")) - okBefore; got != 2 {
+		t.Errorf("successful creates = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(dnsOperationsTotal.WithLabelValues("create", "error")) - errBefore; got != 2 {
+		t.Errorf("failed creates = %v, want 2", got)
+	}
+	if got := c.DegradedZones(); len(got) != 1 || got[0] != "bke.ro." {
+		t.Errorf("DegradedZones() = %v, want [bke.ro.]", got)
+	}
+}
+
+func TestReconcile_ApplyFailedInEveryZone_ReturnsError(t *testing.T) {
+	prov := &partialApplyProvider{Provider: fake_provider.New(nil)}
+	c := New(fake_source.New([]*endpoint.Endpoint{ep("api.bke.ro", "10.0.0.2")}), prov, slog.Default(), Config{})
+
+	if err := c.reconcile(context.Background()); err == nil {
+		t.Error("expected error when no zone applied its changes")
+	}
+}
+
+func TestRun_OnceMode_PartialApplyFailure_ReturnsError(t *testing.T) {
+	prov := &partialApplyProvider{Provider: fake_provider.New(nil)}
+	src := fake_source.New([]*endpoint.Endpoint{
+		ep("app.example.com", "10.0.0.1"),
+		ep("api.bke.ro", "10.0.0.2"),
+	})
+	c := New(src, prov, slog.Default(), Config{Once: true})
+
+	var zerrs *provider.ZoneErrors
+	if err := c.Run(context.Background()); !errors.As(err, &zerrs) {
+		t.Errorf("Run error = %v, want the zone errors", err)
+	}
+}
+
+// partialBatchProvider applies every change except those to bke.ro names,
+// reporting them as a failed UPDATE batch like a single-zone
+// rfc2136.Provider.
+type partialBatchProvider struct {
+	*fake_provider.Provider
+}
+
+func (p *partialBatchProvider) ApplyChanges(ctx context.Context, changes *plan.Changes) error {
+	_ = p.Provider.ApplyChanges(ctx, changes)
+	return &provider.PartialApplyError{
+		Failed: []string{"api.bke.ro.", "a-external-dns-docker-owner.api.bke.ro."},
+		Err:    errors.New("update batch 2/2 (api.bke.ro): rcode SERVFAIL (2)"),
+	}
+}
+
+func TestReconcile_PartialBatchFailure_CountsCommittedBatches(t *testing.T) {
+	prov := &partialBatchProvider{Provider: fake_provider.New(nil)}
+	src := fake_source.New([]*endpoint.Endpoint{
+		ep("app.example.com", "10.0.0.1"),
+		ep("api.bke.ro", "10.0.0.2"),
+	})
+	c := New(src, prov, slog.Default(), Config{})
+
+	okBefore := testutil.ToFloat64(dnsOperationsTotal.WithLabelValues("create", "success"))
+	errBefore := testutil.ToFloat64(dnsOperationsTotal.WithLabelValues("create", "error"))
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error = %v, want nil when some batches were committed", err)
+	}
+	if cycle, _ := c.LastCycle(); cycle.Result != "partial" || cycle.Err == nil {
+		t.Errorf("LastCycle = %+v, want a partial cycle", cycle)
+	}
+	if got := testutil.ToFloat64(dnsOperationsTotal.WithLabelValues("create", "success")) - okBefore; got != 2 {
+		t.Errorf("successful creates = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(dnsOperationsTotal.WithLabelValues("create", "error")) - errBefore; got != 2 {
+		t.Errorf("failed creates = %v, want 2", got)
+	}
+	if got := c.DegradedZones(); got != nil {
+		t.Errorf("DegradedZones() = %v, want nil for a single-zone provider", got)
+	}
+}
+
+// unverifiedApplyProvider applies every change but reports that a
+// secondary does not serve them yet.
+type unverifiedApplyProvider struct {
+	*fake_provider.Provider
+}
+
+func (p *unverifiedApplyProvider) ApplyChanges(ctx context.Context, changes *plan.Changes) error {
+	_ = p.Provider.ApplyChanges(ctx, changes)
+	return &provider.VerifyError{Mismatches: []provider.Mismatch{
+		{Server: "192.0.2.2:53", Name: "app.example.com.", Type: "A", Missing: []string{"10.0.0.1"}},
+	}}
+}
+
+func TestReconcile_UnverifiedApply_CountsSuccessWithoutError(t *testing.T) {
+	prov := &unverifiedApplyProvider{Provider: fake_provider.New(nil)}
+	c := New(fake_source.New([]*endpoint.Endpoint{ep("app.example.com", "10.0.0.1")}), prov, slog.Default(), Config{})
+
+	okBefore := testutil.ToFloat64(dnsOperationsTotal.WithLabelValues("create", "success"))
+	errBefore := testutil.ToFloat64(dnsOperationsTotal.WithLabelValues("create", "error"))
+	unverifiedBefore := testutil.ToFloat64(unverifiedAppliesTotal)
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error = %v, want nil for applied but unverified changes", err)
+	}
+	if got := testutil.ToFloat64(dnsOperationsTotal.WithLabelValues("create", "success")) - okBefore; got != 2 {
+		t.Errorf("successful creates = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(dnsOperationsTotal.WithLabelValues("create", "error")) - errBefore; got != 0 {
+		t.Errorf("failed creates = %v, want 0", got)
+	}
+	if got := testutil.ToFloat64(unverifiedAppliesTotal) - unverifiedBefore; got != 1 {
+		t.Errorf("unverified_applies_total increased by %v, want 1", got)
+	}
+	if cycle, _ := c.LastCycle(); cycle.Result != "success" || !cycle.Changed() {
+		t.Errorf("LastCycle = %+v, want a successful cycle with changes", cycle)
+	}
+}
+
 // --- Loop mode ---
 
 func TestRun_ContextCancellation_ReturnsContextCanceled(t *testing.T) {
@@ -539,3 +1455,55 @@ func TestRun_EventReconcileError_LogsAndContinues(t *testing.T) {
 		t.Errorf("expected context.Canceled, got %v", err)
 	}
 }
+
+// conflictProvider rejects the first conflicts ApplyChanges calls with a
+// provider.ConflictError, as a backend does when records were edited
+// concurrently, and applies the rest.
+type conflictProvider struct {
+	*fake_provider.Provider
+	conflicts int
+	calls     int
+}
+
+func (p *conflictProvider) ApplyChanges(ctx context.Context, changes *plan.Changes) error {
+	p.calls++
+	if p.calls <= p.conflicts {
+		return &provider.ConflictError{Names: []string{"app.example.com"}, Reason: "NXRRSET"}
+	}
+	return p.Provider.ApplyChanges(ctx, changes)
+}
+
+func TestReconcile_Conflict_Replans(t *testing.T) {
+	prov := &conflictProvider{Provider: fake_provider.New(nil), conflicts: 1}
+	src := fake_source.New([]*endpoint.Endpoint{ep("app.example.com", "10.0.0.1")})
+	c := New(src, prov, slog.Default(), Config{})
+	before := testutil.ToFloat64(updateConflictsTotal)
+
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v, want the replan to succeed", err)
+	}
+	if prov.calls != 2 {
+		t.Errorf("ApplyChanges called %d times, want 2", prov.calls)
+	}
+	if findRecord(t, prov.Provider, "app.example.com", endpoint.RecordTypeA) == nil {
+		t.Error("record not created after replanning")
+	}
+	if got := testutil.ToFloat64(updateConflictsTotal) - before; got != 1 {
+		t.Errorf("update_conflicts_total grew by %v, want 1", got)
+	}
+}
+
+func TestReconcile_Conflict_GivesUpAfterMaxReplans(t *testing.T) {
+	prov := &conflictProvider{Provider: fake_provider.New(nil), conflicts: 100}
+	src := fake_source.New([]*endpoint.Endpoint{ep("app.example.com", "10.0.0.1")})
+	c := New(src, prov, slog.Default(), Config{})
+
+	err := c.reconcile(context.Background())
+	var conflict *provider.ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("reconcile error = %v, want a ConflictError", err)
+	}
+	if prov.calls != maxReplans+1 {
+		t.Errorf("ApplyChanges called %d times, want %d", prov.calls, maxReplans+1)
+	}
+}
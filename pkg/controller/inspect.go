@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bkero/external-dns-docker/pkg/endpoint"
+	"github.com/bkero/external-dns-docker/pkg/plan"
+)
+
+// Snapshot is the controller's view of one reconciliation: what the source
+// wants, what the provider holds, and the changes between them.
+type Snapshot struct {
+	// Desired holds the source endpoints after domain filtering, delete-grace
+	// retention and PTR synthesis.
+	Desired []*endpoint.Endpoint
+	// Current holds the provider records after domain filtering.
+	Current []*endpoint.Endpoint
+	// SkippedZones lists zones left out because the provider could not read
+	// them.
+	SkippedZones []string
+	// Changes is the plan that a cycle would apply.
+	Changes *plan.Changes
+}
+
+// Cycle summarises a finished reconciliation cycle.
+type Cycle struct {
+	Start    time.Time
+	Duration time.Duration
+	// Result is "success", "error", "partial" (some changes failed, in
+	// zones that are then degraded or in some UPDATE batches, while the rest
+	// were applied; Err holds the failures) or "standby" (a follower skipped
+	// the cycle).
+	Result string
+	Err    error
+	// Create, Update and Delete count the planned operations.
+	Create, Update, Delete int
+	// Changes is the planned change set; nil when the cycle failed before
+	// planning.
+	Changes *plan.Changes
+}
+
+// Changed reports whether the cycle planned any operations. Unless it failed
+// or ran in dry-run mode, they were applied.
+func (c Cycle) Changed() bool {
+	return c.Create+c.Update+c.Delete > 0
+}
+
+// Blocked reports whether the delete safety threshold stopped the cycle
+// from applying its changes.
+func (c Cycle) Blocked() bool {
+	return errors.Is(c.Err, ErrDeleteThresholdExceeded)
+}
+
+// Preview computes the changes the next cycle would make without applying
+// them. It changes no controller state or metrics, so it is safe to call
+// while Run is active.
+func (c *Controller) Preview(ctx context.Context) (*Snapshot, error) {
+	return c.observe(ctx, true)
+}
+
+// Records returns the provider's current records, unfiltered. A multi-zone
+// provider may return records together with a *provider.ZoneErrors.
+func (c *Controller) Records(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	return c.provider.Records(ctx)
+}
+
+// Trigger asks Run to start a cycle now. It does not block; a request made
+// while another is pending is merged with it.
+func (c *Controller) Trigger() {
+	select {
+	case c.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// LastCycle returns the summary of the most recent cycle, and false if none
+// has finished yet.
+func (c *Controller) LastCycle() (Cycle, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastCycle, !c.lastCycle.Start.IsZero()
+}
+
+// setLastCycle records the summary of a finished cycle.
+func (c *Controller) setLastCycle(cycle Cycle) {
+	c.mu.Lock()
+	c.lastCycle = cycle
+	c.mu.Unlock()
+}
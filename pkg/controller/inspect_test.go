@@ -0,0 +1,174 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/bkero/external-dns-docker/pkg/endpoint"
+	fake_provider "github.com/bkero/external-dns-docker/pkg/provider/fake"
+	fake_source "github.com/bkero/external-dns-docker/pkg/source/fake"
+)
+
+func TestPreview_ReturnsPlanWithoutApplying(t *testing.T) {
+	src := fake_source.New([]*endpoint.Endpoint{ep("app.example.com", "10.0.0.1")})
+	prov := fake_provider.New(nil)
+	c := New(src, prov, slog.Default(), Config{})
+
+	snap, err := c.Preview(context.Background())
+	if err != nil {
+		t.Fatalf("Preview error: %v", err)
+	}
+	if len(snap.Desired) != 1 || snap.Desired[0].DNSName != "app.example.com" {
+		t.Errorf("Desired = %v, want app.example.com", snap.Desired)
+	}
+	if len(snap.Changes.Create) == 0 {
+		t.Error("expected planned creates")
+	}
+	if len(prov.History()) != 0 {
+		t.Error("Preview applied changes")
+	}
+	if c.IsReady() {
+		t.Error("Preview marked the controller ready")
+	}
+	if _, ok := c.LastCycle(); ok {
+		t.Error("Preview recorded a cycle")
+	}
+}
+
+func TestPreview_DeleteGrace_LeavesTombstonesUntouched(t *testing.T) {
+	src := fake_source.New([]*endpoint.Endpoint{ep("app.example.com", "10.0.0.1")})
+	prov := fake_provider.New(nil)
+	c := New(src, prov, slog.Default(), Config{DeleteGrace: time.Minute})
+	now := time.Unix(1_000_000, 0)
+	graceClock(c, &now)
+
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	src.SetEndpoints(nil)
+
+	snap, err := c.Preview(context.Background())
+	if err != nil {
+		t.Fatalf("Preview error: %v", err)
+	}
+	if len(snap.Desired) != 1 {
+		t.Errorf("preview Desired = %d endpoints, want the retained record", len(snap.Desired))
+	}
+	if c.graves.len() != 0 {
+		t.Errorf("Preview started %d tombstones", c.graves.len())
+	}
+}
+
+func TestPreview_ZoneDiscovery_LeavesProviderZonesUntouched(t *testing.T) {
+	src := fake_source.New([]*endpoint.Endpoint{ep("app.new.example.com", "10.0.0.1")})
+	prov := &discoveringProvider{Provider: fake_provider.New(nil)}
+	c := New(src, prov, slog.Default(), Config{})
+
+	snap, err := c.Preview(context.Background())
+	if err != nil {
+		t.Fatalf("Preview error: %v", err)
+	}
+	if len(prov.names) != 0 {
+		t.Errorf("Preview asked the provider to discover zones for %v", prov.names)
+	}
+	if len(snap.Changes.Create) == 0 {
+		t.Error("expected the new name previewed as a create")
+	}
+
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	if len(prov.names) != 1 {
+		t.Errorf("names discovered by the cycle = %v, want app.new.example.com", prov.names)
+	}
+}
+
+func TestTrigger_StartsCycle(t *testing.T) {
+	src := fake_source.New([]*endpoint.Endpoint{ep("app.example.com", "10.0.0.1")})
+	prov := fake_provider.New(nil)
+	c := New(src, prov, slog.Default(), Config{Interval: time.Hour})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- c.Run(ctx) }()
+
+	waitFor(t, func() bool { return len(prov.History()) == 1 })
+	src.SetEndpoints([]*endpoint.Endpoint{ep("app.example.com", "10.0.0.2")})
+	c.Trigger()
+	waitFor(t, func() bool { return len(prov.History()) == 2 })
+
+	cancel()
+	<-errCh
+}
+
+func TestLastCycle_RecordsResult(t *testing.T) {
+	src := fake_source.New([]*endpoint.Endpoint{ep("app.example.com", "10.0.0.1")})
+	c := New(src, fake_provider.New(nil), slog.Default(), Config{})
+
+	if _, ok := c.LastCycle(); ok {
+		t.Fatal("LastCycle reported a cycle before any ran")
+	}
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	cycle, ok := c.LastCycle()
+	if !ok || cycle.Result != "success" || cycle.Err != nil {
+		t.Errorf("LastCycle = %+v, %v; want success", cycle, ok)
+	}
+	if cycle.Create == 0 {
+		t.Error("LastCycle did not count creates")
+	}
+
+	c.source = &errSource{err: errors.New("docker unavailable")}
+	_ = c.reconcile(context.Background())
+	cycle, _ = c.LastCycle()
+	if cycle.Result != "error" || cycle.Err == nil {
+		t.Errorf("LastCycle = %+v, want error", cycle)
+	}
+}
+
+// waitFor polls cond until it holds, failing the test after a second.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 1s")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestLastCycle_OnceSummary(t *testing.T) {
+	tests := []struct {
+		name                     string
+		desired                  []*endpoint.Endpoint
+		current                  []*endpoint.Endpoint
+		cfg                      Config
+		wantChanged, wantBlocked bool
+	}{
+		{"in sync", []*endpoint.Endpoint{ep("host0.example.com", "10.0.0.1")}, ownedRecords(1), Config{}, false, false},
+		{"changes applied", []*endpoint.Endpoint{ep("app.example.com", "10.0.0.1")}, nil, Config{}, true, false},
+		{"changes planned", []*endpoint.Endpoint{ep("app.example.com", "10.0.0.1")}, nil, Config{DryRun: true}, true, false},
+		{"blocked", nil, ownedRecords(3), Config{MaxDeletes: 2}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Once = true
+			c := New(fake_source.New(tt.desired), fake_provider.New(tt.current), slog.Default(), tt.cfg)
+			_ = c.Run(context.Background())
+
+			cycle, ok := c.LastCycle()
+			if !ok {
+				t.Fatal("no cycle recorded")
+			}
+			if cycle.Changed() != tt.wantChanged || cycle.Blocked() != tt.wantBlocked {
+				t.Errorf("Changed() = %v, Blocked() = %v; want %v, %v (cycle %+v)",
+					cycle.Changed(), cycle.Blocked(), tt.wantChanged, tt.wantBlocked, cycle)
+			}
+		})
+	}
+}
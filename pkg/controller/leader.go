@@ -0,0 +1,182 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/bkero/external-dns-docker/pkg/provider"
+)
+
+var (
+	isLeader = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "external_dns_docker_leader",
+		Help: "1 while this instance holds the leader-election lease, 0 while on standby.",
+	})
+
+	leaderTransitionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "external_dns_docker_leader_transitions_total",
+		Help: "Total number of times this instance acquired or lost leadership, by event.",
+	}, []string{"event"})
+)
+
+// ElectionConfig holds leader-election tuning parameters.
+type ElectionConfig struct {
+	// LeaseName is the DNS name of the TXT record holding the lease. It must
+	// lie inside a managed zone.
+	LeaseName string
+	// Identity names this replica in the lease. It must be unique among
+	// replicas and contain no whitespace.
+	Identity string
+	// LeaseDuration is how long followers wait, after last seeing the lease
+	// change, before taking it over. Default: 30s.
+	LeaseDuration time.Duration
+	// RenewDeadline is how long the leader keeps leading while renewals fail
+	// before stepping down. It must be shorter than LeaseDuration so the
+	// leader stops before a follower can take over. Default: 20s.
+	RenewDeadline time.Duration
+	// RetryPeriod is the interval between acquire and renew attempts. Each
+	// renewal is an UPDATE to the zone holding the lease. Default: 5s.
+	RetryPeriod time.Duration
+}
+
+// applyDefaults fills in zero-value fields with sensible defaults.
+func (c *ElectionConfig) applyDefaults() {
+	if c.LeaseDuration <= 0 {
+		c.LeaseDuration = 30 * time.Second
+	}
+	if c.RenewDeadline <= 0 {
+		c.RenewDeadline = 20 * time.Second
+	}
+	if c.RetryPeriod <= 0 {
+		c.RetryPeriod = 5 * time.Second
+	}
+}
+
+// Elector elects a single leader among replicas sharing a lease record in
+// the DNS backend. Leases are swapped with compare-and-swap semantics (see
+// provider.LeaseStore), so two replicas can never both acquire the same
+// lease.
+//
+// Followers judge expiry by their own clock: a lease is expired once it has
+// not changed for its duration since the follower last saw it change, so
+// clock skew between replicas does not matter.
+type Elector struct {
+	store  provider.LeaseStore
+	log    *slog.Logger
+	cfg    ElectionConfig
+	leader atomic.Bool
+	now    func() time.Time
+
+	// Only touched by the Run goroutine.
+	observed   *provider.Lease // last lease seen in the store
+	observedAt time.Time       // when observed was first seen
+	renewedAt  time.Time       // last successful acquire or renew by us
+}
+
+// NewElector returns an Elector that keeps its lease in store.
+func NewElector(store provider.LeaseStore, log *slog.Logger, cfg ElectionConfig) *Elector {
+	cfg.applyDefaults()
+	if log == nil {
+		log = slog.Default()
+	}
+	return &Elector{store: store, log: log, cfg: cfg, now: time.Now}
+}
+
+// IsLeader reports whether this replica currently holds the lease. A nil
+// Elector always leads, so a single replica needs no election.
+func (e *Elector) IsLeader() bool {
+	return e == nil || e.leader.Load()
+}
+
+// Run tries to acquire the lease and then keeps renewing it every
+// RetryPeriod until ctx is cancelled, calling onChange whenever leadership
+// is gained or lost. On cancellation a held lease is released so a follower
+// can take over at once.
+func (e *Elector) Run(ctx context.Context, onChange func(leading bool)) {
+	ticker := time.NewTicker(e.cfg.RetryPeriod)
+	defer ticker.Stop()
+	for {
+		e.setLeader(e.tryAcquireOrRenew(ctx), onChange)
+		select {
+		case <-ctx.Done():
+			e.release()
+			e.setLeader(false, onChange)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// tryAcquireOrRenew makes one attempt to acquire or renew the lease and
+// reports whether this replica leads afterwards.
+func (e *Elector) tryAcquireOrRenew(ctx context.Context) bool {
+	now := e.now()
+	cur, err := e.store.GetLease(ctx, e.cfg.LeaseName)
+	if err != nil {
+		return e.keepOnError(now, "read", err)
+	}
+	if !cur.Equal(e.observed) {
+		e.observed, e.observedAt = cur, now
+	}
+	if cur != nil && cur.Holder != e.cfg.Identity && now.Sub(e.observedAt) < cur.Duration {
+		return false
+	}
+
+	next := &provider.Lease{Holder: e.cfg.Identity, Renewed: now, Duration: e.cfg.LeaseDuration}
+	if err := e.store.SwapLease(ctx, e.cfg.LeaseName, cur, next); err != nil {
+		if errors.Is(err, provider.ErrLeaseConflict) {
+			e.log.Debug("leader election: lease taken by another replica", "lease", e.cfg.LeaseName)
+			return false
+		}
+		return e.keepOnError(now, "update", err)
+	}
+	e.observed, e.observedAt, e.renewedAt = next, now, now
+	return true
+}
+
+// keepOnError reports whether a leader may keep leading after a failed
+// attempt: only while its last renewal is younger than RenewDeadline.
+func (e *Elector) keepOnError(now time.Time, op string, err error) bool {
+	e.log.Warn("leader election: lease "+op+" failed", "lease", e.cfg.LeaseName, "err", err)
+	return e.leader.Load() && now.Sub(e.renewedAt) < e.cfg.RenewDeadline
+}
+
+// release removes the lease if this replica still holds it.
+func (e *Elector) release() {
+	if !e.leader.Load() || e.observed == nil || e.observed.Holder != e.cfg.Identity {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), e.cfg.RetryPeriod)
+	defer cancel()
+	if err := e.store.SwapLease(ctx, e.cfg.LeaseName, e.observed, nil); err != nil {
+		e.log.Warn("leader election: releasing lease failed", "lease", e.cfg.LeaseName, "err", err)
+		return
+	}
+	e.log.Info("leader election: lease released", "lease", e.cfg.LeaseName)
+}
+
+// setLeader records the leadership state, logging, counting and reporting
+// transitions.
+func (e *Elector) setLeader(leading bool, onChange func(bool)) {
+	if e.leader.Swap(leading) == leading {
+		return
+	}
+	if leading {
+		isLeader.Set(1)
+		leaderTransitionsTotal.WithLabelValues("acquired").Inc()
+		e.log.Info("leader election: became leader", "identity", e.cfg.Identity, "lease", e.cfg.LeaseName)
+	} else {
+		isLeader.Set(0)
+		leaderTransitionsTotal.WithLabelValues("lost").Inc()
+		e.log.Warn("leader election: lost leadership, standing by", "identity", e.cfg.Identity, "lease", e.cfg.LeaseName)
+	}
+	if onChange != nil {
+		onChange(leading)
+	}
+}
@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/bkero/external-dns-docker/pkg/endpoint"
+	"github.com/bkero/external-dns-docker/pkg/provider"
+	fake_provider "github.com/bkero/external-dns-docker/pkg/provider/fake"
+	fake_source "github.com/bkero/external-dns-docker/pkg/source/fake"
+)
+
+// memLeaseStore is an in-memory provider.LeaseStore shared by test electors.
+type memLeaseStore struct {
+	mu    sync.Mutex
+	lease *provider.Lease
+	err   error // returned by every call when set
+}
+
+func (s *memLeaseStore) GetLease(_ context.Context, _ string) (*provider.Lease, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lease, s.err
+}
+
+func (s *memLeaseStore) SwapLease(_ context.Context, _ string, old, next *provider.Lease) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if !s.lease.Equal(old) {
+		return provider.ErrLeaseConflict
+	}
+	s.lease = next
+	return nil
+}
+
+// newTestElector returns an Elector on store whose clock reads *now.
+func newTestElector(store provider.LeaseStore, id string, now *time.Time) *Elector {
+	e := NewElector(store, slog.Default(), ElectionConfig{
+		LeaseName:     "leader.example.com",
+		Identity:      id,
+		LeaseDuration: 15 * time.Second,
+		RenewDeadline: 10 * time.Second,
+		RetryPeriod:   2 * time.Second,
+	})
+	e.now = func() time.Time { return *now }
+	return e
+}
+
+// step runs one election round for e, as Run would.
+func step(e *Elector) {
+	e.setLeader(e.tryAcquireOrRenew(context.Background()), nil)
+}
+
+func TestElector_OnlyOneReplicaLeads(t *testing.T) {
+	store := &memLeaseStore{}
+	now := time.Unix(1_000_000, 0)
+	a := newTestElector(store, "a", &now)
+	b := newTestElector(store, "b", &now)
+
+	step(a)
+	step(b)
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("leaders: a=%v b=%v, want only a", a.IsLeader(), b.IsLeader())
+	}
+
+	// a keeps renewing; b never sees the lease go stale.
+	for range 20 {
+		now = now.Add(2 * time.Second)
+		step(a)
+		step(b)
+	}
+	if !a.IsLeader() || b.IsLeader() {
+		t.Errorf("leaders after renewals: a=%v b=%v, want only a", a.IsLeader(), b.IsLeader())
+	}
+}
+
+func TestElector_FollowerTakesOverStaleLease(t *testing.T) {
+	store := &memLeaseStore{}
+	now := time.Unix(1_000_000, 0)
+	a := newTestElector(store, "a", &now)
+	b := newTestElector(store, "b", &now)
+
+	step(a)
+	step(b)
+
+	// a stops renewing. b takes over once the lease has been unchanged for
+	// its full duration by b's own clock.
+	now = now.Add(14 * time.Second)
+	step(b)
+	if b.IsLeader() {
+		t.Fatal("b took over before the lease expired")
+	}
+	now = now.Add(time.Second)
+	step(b)
+	if !b.IsLeader() {
+		t.Fatal("b did not take over the expired lease")
+	}
+	if store.lease.Holder != "b" {
+		t.Errorf("lease holder = %q, want b", store.lease.Holder)
+	}
+
+	// a notices on its next attempt and stands by.
+	step(a)
+	if a.IsLeader() {
+		t.Error("a still leads after b took over")
+	}
+}
+
+func TestElector_StepsDownAfterRenewDeadline(t *testing.T) {
+	store := &memLeaseStore{}
+	now := time.Unix(1_000_000, 0)
+	a := newTestElector(store, "a", &now)
+	step(a)
+
+	store.err = errors.New("server unreachable")
+	now = now.Add(8 * time.Second)
+	step(a)
+	if !a.IsLeader() {
+		t.Fatal("leader stepped down before its renew deadline")
+	}
+	now = now.Add(2 * time.Second)
+	step(a)
+	if a.IsLeader() {
+		t.Error("leader kept leading past its renew deadline")
+	}
+}
+
+func TestElector_RunReleasesLeaseOnShutdown(t *testing.T) {
+	store := &memLeaseStore{}
+	e := NewElector(store, slog.Default(), ElectionConfig{LeaseName: "leader.example.com", Identity: "a"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := make(chan bool, 2)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.Run(ctx, func(leading bool) { changes <- leading })
+	}()
+
+	if got := <-changes; !got {
+		t.Fatal("expected to become leader")
+	}
+	if testutil.ToFloat64(isLeader) != 1 {
+		t.Error("leader gauge not set")
+	}
+	cancel()
+	<-done
+	if got := <-changes; got {
+		t.Error("expected to lose leadership on shutdown")
+	}
+	if store.lease != nil {
+		t.Errorf("lease not released: %v", store.lease)
+	}
+	if testutil.ToFloat64(isLeader) != 0 {
+		t.Error("leader gauge not cleared")
+	}
+}
+
+func TestReconcile_Follower_DoesNotApply(t *testing.T) {
+	store := &memLeaseStore{lease: &provider.Lease{
+		Holder: "other", Renewed: time.Now(), Duration: time.Hour,
+	}}
+	now := time.Now()
+	e := newTestElector(store, "me", &now)
+	step(e)
+
+	src := fake_source.New([]*endpoint.Endpoint{ep("app.example.com", "10.0.0.1")})
+	prov := fake_provider.New(nil)
+	c := New(src, prov, slog.Default(), Config{Elector: e})
+
+	if err := c.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	if len(prov.History()) != 0 {
+		t.Error("follower applied changes")
+	}
+	if !c.IsReady() {
+		t.Error("standby follower should be ready")
+	}
+	if c.IsLeader() {
+		t.Error("IsLeader() = true for a follower")
+	}
+}
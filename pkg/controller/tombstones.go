@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/bkero/external-dns-docker/pkg/endpoint"
+)
+
+var tombstonesPending = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "external_dns_docker_tombstones_pending",
+	Help: "Number of record targets kept during their delete grace period after their source disappeared.",
+})
+
+// tombstone is a record target whose source disappeared, kept until
+// expires. ep is the record it belonged to, narrowed to that target.
+type tombstone struct {
+	ep      *endpoint.Endpoint
+	expires time.Time
+}
+
+// tombstones holds records back from deletion for a grace period after they
+// vanish from the source, so that a container restart (die, then start a few
+// seconds later) does not delete and re-create its records. Grace is tracked
+// per target, so a replica leaving a merged RRset keeps its target for the
+// period too. State is kept in memory only; records missing when the daemon
+// starts are not retained.
+type tombstones struct {
+	grace   time.Duration                 // default grace; 0 disables unless labelled
+	seen    map[string]*endpoint.Endpoint // records desired in the previous cycle, by epKey
+	pending map[string]tombstone          // retained targets by targetKey
+	now     func() time.Time
+}
+
+func newTombstones(grace time.Duration) *tombstones {
+	return &tombstones{
+		grace:   grace,
+		seen:    make(map[string]*endpoint.Endpoint),
+		pending: make(map[string]tombstone),
+		now:     time.Now,
+	}
+}
+
+// retain returns desired plus every record target that disappeared from it
+// less than its grace period ago: targets of an RRset still desired are
+// added to it, the others come back as their own records. A target's grace
+// period is its record's endpoint.LabelDeleteGrace label if set, the default
+// otherwise. A CNAME whose target changed is replaced at once. Targets that
+// reappear are dropped from the pending set; expired ones are left out so
+// the plan deletes them.
+func (t *tombstones) retain(log *slog.Logger, desired []*endpoint.Endpoint) []*endpoint.Endpoint {
+	now := t.now()
+	wanted := make(map[string]bool, len(desired))
+	byKey := make(map[string]int, len(desired))
+	for i, ep := range desired {
+		byKey[epKey(ep)] = i
+		for _, target := range ep.Targets {
+			wanted[targetKey(ep, target)] = true
+		}
+	}
+
+	for key, ts := range t.pending {
+		if wanted[key] {
+			log.Info("record reappeared within its delete grace period, keeping",
+				"name", ts.ep.DNSName, "type", ts.ep.RecordType, "target", ts.ep.Targets[0])
+			delete(t.pending, key)
+		}
+	}
+
+	for key, ep := range t.seen {
+		if _, ok := byKey[key]; ok && ep.RecordType == endpoint.RecordTypeCNAME {
+			continue
+		}
+		for _, target := range ep.Targets {
+			tk := targetKey(ep, target)
+			if wanted[tk] {
+				continue
+			}
+			if _, ok := t.pending[tk]; ok {
+				continue
+			}
+			grace := t.graceFor(log, ep)
+			if grace <= 0 {
+				continue
+			}
+			log.Info("record source disappeared, delaying delete",
+				"name", ep.DNSName, "type", ep.RecordType, "target", target, "grace", grace.String())
+			narrowed := *ep
+			narrowed.Targets = []string{target}
+			t.pending[tk] = tombstone{ep: &narrowed, expires: now.Add(grace)}
+		}
+	}
+
+	keys := make([]string, 0, len(t.pending))
+	for key := range t.pending {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	out := append([]*endpoint.Endpoint(nil), desired...)
+	for _, key := range keys {
+		ts := t.pending[key]
+		if !now.Before(ts.expires) {
+			log.Info("delete grace period expired",
+				"name", ts.ep.DNSName, "type", ts.ep.RecordType, "target", ts.ep.Targets[0])
+			delete(t.pending, key)
+			continue
+		}
+		// Add the target to its record, copying the record first so the
+		// source's endpoint is left alone.
+		if i, ok := byKey[epKey(ts.ep)]; ok {
+			merged := *out[i]
+			merged.Targets = append(append([]string(nil), out[i].Targets...), ts.ep.Targets[0])
+			out[i] = &merged
+			continue
+		}
+		byKey[epKey(ts.ep)] = len(out)
+		out = append(out, ts.ep)
+	}
+
+	t.seen = make(map[string]*endpoint.Endpoint, len(out))
+	for _, ep := range out {
+		t.seen[epKey(ep)] = ep
+	}
+	return out
+}
+
+// len returns the number of pending tombstones.
+func (t *tombstones) len() int {
+	return len(t.pending)
+}
+
+// clone returns a copy of t that can be advanced without affecting t.
+func (t *tombstones) clone() *tombstones {
+	cp := &tombstones{
+		grace:   t.grace,
+		seen:    make(map[string]*endpoint.Endpoint, len(t.seen)),
+		pending: make(map[string]tombstone, len(t.pending)),
+		now:     t.now,
+	}
+	for k, v := range t.seen {
+		cp.seen[k] = v
+	}
+	for k, v := range t.pending {
+		cp.pending[k] = v
+	}
+	return cp
+}
+
+// nextExpiry returns the earliest expiry among pending tombstones, or false
+// when none are pending.
+func (t *tombstones) nextExpiry() (time.Time, bool) {
+	var next time.Time
+	for _, ts := range t.pending {
+		if next.IsZero() || ts.expires.Before(next) {
+			next = ts.expires
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// graceFor returns the grace period for ep: its delete-grace label if valid,
+// the default otherwise.
+func (t *tombstones) graceFor(log *slog.Logger, ep *endpoint.Endpoint) time.Duration {
+	raw, ok := ep.Labels[endpoint.LabelDeleteGrace]
+	if !ok {
+		return t.grace
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		log.Warn("invalid delete grace on endpoint, using default",
+			"name", ep.DNSName, "delete_grace", raw)
+		return t.grace
+	}
+	return d
+}
+
+// epKey returns the name and type key the plan uses to identify an RRset.
+func epKey(ep *endpoint.Endpoint) string {
+	return ep.DNSName + "|" + ep.RecordType
+}
+
+// targetKey returns the key of one target of ep's RRset.
+func targetKey(ep *endpoint.Endpoint, target string) string {
+	return epKey(ep) + "|" + target
+}
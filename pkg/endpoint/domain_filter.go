@@ -0,0 +1,128 @@
+package endpoint
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DomainFilter decides which DNS names are in scope for this instance.
+// A name is in scope when it matches at least one include domain (if any are
+// configured) and the include regex (if set), and matches neither an exclude
+// domain nor the exclude regex.
+//
+// Domains match on label boundaries, case-insensitively: "example.com"
+// matches "example.com" and "app.example.com" but not "myexample.com". A
+// leading "*." or "." restricts a domain to its subdomains, so
+// "*.apps.example.com" does not match "apps.example.com" itself.
+//
+// The zero value and a nil *DomainFilter match every name.
+type DomainFilter struct {
+	include      []string
+	exclude      []string
+	regex        *regexp.Regexp
+	regexExclude *regexp.Regexp
+}
+
+// NewDomainFilter returns a DomainFilter from include and exclude domain lists
+// and optional include and exclude regular expressions (empty = unset).
+// Empty list entries are ignored. An invalid regex is an error.
+func NewDomainFilter(include, exclude []string, regex, regexExclude string) (*DomainFilter, error) {
+	f := &DomainFilter{
+		include: normaliseDomains(include),
+		exclude: normaliseDomains(exclude),
+	}
+	var err error
+	if regex != "" {
+		if f.regex, err = regexp.Compile(regex); err != nil {
+			return nil, fmt.Errorf("invalid domain filter regex %q: %w", regex, err)
+		}
+	}
+	if regexExclude != "" {
+		if f.regexExclude, err = regexp.Compile(regexExclude); err != nil {
+			return nil, fmt.Errorf("invalid domain exclusion regex %q: %w", regexExclude, err)
+		}
+	}
+	return f, nil
+}
+
+// IsConfigured reports whether the filter restricts any names.
+func (f *DomainFilter) IsConfigured() bool {
+	return f != nil &&
+		(len(f.include) > 0 || len(f.exclude) > 0 || f.regex != nil || f.regexExclude != nil)
+}
+
+// Match reports whether name is in scope.
+func (f *DomainFilter) Match(name string) bool {
+	if !f.IsConfigured() {
+		return true
+	}
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+
+	if len(f.include) > 0 && !matchesAnyDomain(name, f.include) {
+		return false
+	}
+	if f.regex != nil && !f.regex.MatchString(name) {
+		return false
+	}
+	if matchesAnyDomain(name, f.exclude) {
+		return false
+	}
+	if f.regexExclude != nil && f.regexExclude.MatchString(name) {
+		return false
+	}
+	return true
+}
+
+// String returns a summary of the filter for logging.
+func (f *DomainFilter) String() string {
+	if !f.IsConfigured() {
+		return "none"
+	}
+	var parts []string
+	if len(f.include) > 0 {
+		parts = append(parts, "include="+strings.Join(f.include, ","))
+	}
+	if len(f.exclude) > 0 {
+		parts = append(parts, "exclude="+strings.Join(f.exclude, ","))
+	}
+	if f.regex != nil {
+		parts = append(parts, "regex="+f.regex.String())
+	}
+	if f.regexExclude != nil {
+		parts = append(parts, "regex-exclude="+f.regexExclude.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// normaliseDomains lower-cases domains, strips trailing dots and rewrites a
+// leading "*." to ".", dropping empty entries.
+func normaliseDomains(domains []string) []string {
+	var out []string
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(d), "."))
+		d = strings.TrimPrefix(d, "*")
+		if d == "" || d == "." {
+			continue
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+// matchesAnyDomain reports whether name equals or is a subdomain of one of
+// domains. Domains starting with "." match subdomains only.
+func matchesAnyDomain(name string, domains []string) bool {
+	for _, d := range domains {
+		if strings.HasPrefix(d, ".") {
+			if strings.HasSuffix(name, d) {
+				return true
+			}
+			continue
+		}
+		if name == d || strings.HasSuffix(name, "."+d) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package endpoint
+
+import "testing"
+
+func TestDomainFilter_Match(t *testing.T) {
+	cases := []struct {
+		name         string
+		include      []string
+		exclude      []string
+		regex        string
+		regexExclude string
+		host         string
+		want         bool
+	}{
+		{"unconfigured matches all", nil, nil, "", "", "anything.example.org", true},
+		{"include apex", []string{"example.com"}, nil, "", "", "example.com", true},
+		{"include subdomain", []string{"example.com"}, nil, "", "", "app.example.com", true},
+		{"include label boundary", []string{"example.com"}, nil, "", "", "myexample.com", false},
+		{"include case and trailing dot", []string{"Example.COM."}, nil, "", "", "APP.example.com.", true},
+		{"wildcard excludes apex", []string{"*.apps.example.com"}, nil, "", "", "apps.example.com", false},
+		{"wildcard matches subdomain", []string{"*.apps.example.com"}, nil, "", "", "web.apps.example.com", true},
+		{"outside include", []string{"apps.example.com"}, nil, "", "", "db.example.com", false},
+		{"exclude wins", []string{"example.com"}, []string{"internal.example.com"}, "", "", "db.internal.example.com", false},
+		{"exclude only", nil, []string{"internal.example.com"}, "", "", "app.example.com", true},
+		{"regex match", nil, nil, `^[a-z]+\.apps\.example\.com$`, "", "web.apps.example.com", true},
+		{"regex miss", nil, nil, `^[a-z]+\.apps\.example\.com$`, "", "web1.apps.example.com", false},
+		{"regex and include both required", []string{"example.org"}, nil, `apps`, "", "web.apps.example.com", false},
+		{"regex exclusion", []string{"example.com"}, nil, "", `^tmp-`, "tmp-1.example.com", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := NewDomainFilter(tc.include, tc.exclude, tc.regex, tc.regexExclude)
+			if err != nil {
+				t.Fatalf("NewDomainFilter() error = %v", err)
+			}
+			if got := f.Match(tc.host); got != tc.want {
+				t.Errorf("Match(%q) = %v, want %v", tc.host, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDomainFilter_InvalidRegex_ReturnsError(t *testing.T) {
+	if _, err := NewDomainFilter(nil, nil, "(", ""); err == nil {
+		t.Error("expected error for invalid include regex")
+	}
+	if _, err := NewDomainFilter(nil, nil, "", "["); err == nil {
+		t.Error("expected error for invalid exclude regex")
+	}
+}
+
+func TestDomainFilter_NilAndEmpty_MatchAll(t *testing.T) {
+	var nilFilter *DomainFilter
+	if !nilFilter.Match("app.example.com") || nilFilter.IsConfigured() {
+		t.Error("nil filter should match everything and be unconfigured")
+	}
+	f, _ := NewDomainFilter([]string{"", " "}, nil, "", "")
+	if f.IsConfigured() {
+		t.Error("filter with only empty entries should be unconfigured")
+	}
+}
@@ -13,18 +13,39 @@ const (
 	RecordTypeAAAA  = "AAAA"
 	RecordTypeCNAME = "CNAME"
 	RecordTypeTXT   = "TXT"
+	RecordTypeMX    = "MX"
+	RecordTypeSRV   = "SRV"
+	RecordTypeCAA   = "CAA"
+	RecordTypeNS    = "NS"
+	RecordTypePTR   = "PTR"
 
 	// DefaultTTL is the TTL applied when none is specified.
 	DefaultTTL = int64(300)
+
+	// LabelPTR is the Labels key that, set to "true" on an A or AAAA
+	// endpoint, requests a matching PTR record in the reverse zone.
+	LabelPTR = "ptr"
+
+	// LabelDeleteGrace is the Labels key holding a time.Duration string: how
+	// long the record is kept after its source disappears before it is deleted.
+	LabelDeleteGrace = "delete-grace"
+
+	// LabelSource is the Labels key naming the objects that declared the
+	// endpoint, e.g. "container/web-1" or "service/api". Endpoints merged
+	// from several objects list them all, sorted and comma-separated.
+	LabelSource = "source"
 )
 
 // Endpoint represents a desired DNS record.
 type Endpoint struct {
 	// DNSName is the fully-qualified DNS name (e.g. "app.example.com").
 	DNSName string
-	// Targets is the list of values the record points to (IPs or hostnames).
+	// Targets is the list of values the record points to: IPs or hostnames,
+	// or for MX, SRV and CAA records the presentation format parsed by
+	// ParseMXTarget, ParseSRVTarget and ParseCAATarget.
 	Targets []string
-	// RecordType is the DNS record type: A, AAAA, CNAME, or TXT.
+	// RecordType is the DNS record type: A, AAAA, CNAME, TXT, MX, SRV, CAA,
+	// NS or PTR.
 	RecordType string
 	// TTL is the time-to-live in seconds.
 	TTL int64
@@ -66,3 +87,26 @@ func InferRecordType(target string) string {
 	}
 	return RecordTypeAAAA
 }
+
+// ReverseName returns the in-addr.arpa or ip6.arpa name for ip, without a
+// trailing dot (e.g. "10.0.0.1" → "1.0.0.10.in-addr.arpa"). Returns false
+// when ip is not a valid IP address.
+func ReverseName(ip string) (string, bool) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return "", false
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", v4[3], v4[2], v4[1], v4[0]), true
+	}
+	const hex = "0123456789abcdef"
+	var b strings.Builder
+	for i := len(parsed) - 1; i >= 0; i-- {
+		b.WriteByte(hex[parsed[i]&0x0f])
+		b.WriteByte('.')
+		b.WriteByte(hex[parsed[i]>>4])
+		b.WriteByte('.')
+	}
+	b.WriteString("ip6.arpa")
+	return b.String(), true
+}
@@ -114,3 +114,21 @@ func TestRecordTypeScenarios(t *testing.T) {
 		}
 	})
 }
+
+func TestReverseName(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want string
+		ok   bool
+	}{
+		{"192.0.2.5", "5.2.0.192.in-addr.arpa", true},
+		{"2001:db8::567:89ab", "b.a.9.8.7.6.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa", true},
+		{"not-an-ip", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := ReverseName(tt.ip)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ReverseName(%q) = %q, %v; want %q, %v", tt.ip, got, ok, tt.want, tt.ok)
+		}
+	}
+}
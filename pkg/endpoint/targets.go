@@ -0,0 +1,158 @@
+package endpoint
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MXTarget is the structured form of an MX record target, written as
+// "<preference> <host>", e.g. "10 mail.example.com".
+type MXTarget struct {
+	Preference uint16
+	Host       string
+}
+
+// ParseMXTarget parses an MX target in "<preference> <host>" form.
+func ParseMXTarget(s string) (MXTarget, error) {
+	f := strings.Fields(s)
+	if len(f) != 2 {
+		return MXTarget{}, fmt.Errorf("MX target %q: want \"<preference> <host>\"", s)
+	}
+	pref, err := parseUint16(f[0])
+	if err != nil {
+		return MXTarget{}, fmt.Errorf("MX target %q: preference: %w", s, err)
+	}
+	return MXTarget{Preference: pref, Host: canonicalHost(f[1])}, nil
+}
+
+// String returns the target in "<preference> <host>" form.
+func (t MXTarget) String() string {
+	return fmt.Sprintf("%d %s", t.Preference, t.Host)
+}
+
+// SRVTarget is the structured form of an SRV record target, written as
+// "<priority> <weight> <port> <target>", e.g. "10 5 5060 sip.example.com".
+type SRVTarget struct {
+	Priority uint16
+	Weight   uint16
+	Port     uint16
+	Target   string
+}
+
+// ParseSRVTarget parses an SRV target in "<priority> <weight> <port> <target>"
+// form (RFC 2782).
+func ParseSRVTarget(s string) (SRVTarget, error) {
+	f := strings.Fields(s)
+	if len(f) != 4 {
+		return SRVTarget{}, fmt.Errorf("SRV target %q: want \"<priority> <weight> <port> <target>\"", s)
+	}
+	var nums [3]uint16
+	for i, name := range []string{"priority", "weight", "port"} {
+		n, err := parseUint16(f[i])
+		if err != nil {
+			return SRVTarget{}, fmt.Errorf("SRV target %q: %s: %w", s, name, err)
+		}
+		nums[i] = n
+	}
+	return SRVTarget{Priority: nums[0], Weight: nums[1], Port: nums[2], Target: canonicalHost(f[3])}, nil
+}
+
+// String returns the target in "<priority> <weight> <port> <target>" form.
+func (t SRVTarget) String() string {
+	return fmt.Sprintf("%d %d %d %s", t.Priority, t.Weight, t.Port, t.Target)
+}
+
+// CAATarget is the structured form of a CAA record target, written as
+// "<flags> <tag> <value>", e.g. `0 issue "letsencrypt.org"`. The value may be
+// quoted and may contain spaces.
+type CAATarget struct {
+	Flag  uint8
+	Tag   string
+	Value string
+}
+
+// ParseCAATarget parses a CAA target in "<flags> <tag> <value>" form
+// (RFC 8659). Tags are case-insensitive and returned lower-cased.
+func ParseCAATarget(s string) (CAATarget, error) {
+	f := strings.Fields(s)
+	if len(f) < 3 {
+		return CAATarget{}, fmt.Errorf("CAA target %q: want \"<flags> <tag> <value>\"", s)
+	}
+	flag, err := strconv.ParseUint(f[0], 10, 8)
+	if err != nil {
+		return CAATarget{}, fmt.Errorf("CAA target %q: flags: %w", s, err)
+	}
+	tag := strings.ToLower(f[1])
+	for _, r := range tag {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return CAATarget{}, fmt.Errorf("CAA target %q: tag must be alphanumeric", s)
+		}
+	}
+	// The value is everything after the tag, with one pair of quotes removed.
+	rest := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s), f[0]))
+	rest = strings.TrimSpace(strings.TrimPrefix(rest, f[1]))
+	if len(rest) >= 2 && strings.HasPrefix(rest, `"`) && strings.HasSuffix(rest, `"`) {
+		rest = rest[1 : len(rest)-1]
+	}
+	return CAATarget{Flag: uint8(flag), Tag: tag, Value: rest}, nil
+}
+
+// String returns the target in `<flags> <tag> "<value>"` form.
+func (t CAATarget) String() string {
+	return fmt.Sprintf("%d %s %q", t.Flag, t.Tag, t.Value)
+}
+
+// NormaliseTarget returns the canonical form of target for recordType, so
+// that equivalent spellings (trailing dots, letter case, quoting, extra
+// whitespace) compare equal. Targets of A, AAAA, CNAME and TXT records are
+// returned unchanged. An error is returned when target cannot be parsed.
+func NormaliseTarget(recordType, target string) (string, error) {
+	switch recordType {
+	case RecordTypeMX:
+		t, err := ParseMXTarget(target)
+		if err != nil {
+			return "", err
+		}
+		return t.String(), nil
+	case RecordTypeSRV:
+		t, err := ParseSRVTarget(target)
+		if err != nil {
+			return "", err
+		}
+		return t.String(), nil
+	case RecordTypeCAA:
+		t, err := ParseCAATarget(target)
+		if err != nil {
+			return "", err
+		}
+		return t.String(), nil
+	case RecordTypeNS, RecordTypePTR:
+		if strings.TrimSpace(target) == "" || len(strings.Fields(target)) != 1 {
+			return "", fmt.Errorf("%s target %q: want a single hostname", recordType, target)
+		}
+		return canonicalHost(target), nil
+	default:
+		return target, nil
+	}
+}
+
+// IsStructuredType reports whether recordType is one whose targets are
+// validated and normalised by NormaliseTarget.
+func IsStructuredType(recordType string) bool {
+	switch recordType {
+	case RecordTypeMX, RecordTypeSRV, RecordTypeCAA, RecordTypeNS, RecordTypePTR:
+		return true
+	}
+	return false
+}
+
+// canonicalHost lower-cases a hostname and removes its trailing dot.
+func canonicalHost(s string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(s), "."))
+}
+
+func parseUint16(s string) (uint16, error) {
+	n, err := strconv.ParseUint(s, 10, 16)
+	return uint16(n), err
+}
@@ -0,0 +1,98 @@
+package endpoint
+
+import "testing"
+
+func TestParseMXTarget(t *testing.T) {
+	got, err := ParseMXTarget("10 Mail.Example.com.")
+	if err != nil {
+		t.Fatalf("ParseMXTarget() error = %v", err)
+	}
+	if got != (MXTarget{Preference: 10, Host: "mail.example.com"}) {
+		t.Errorf("ParseMXTarget() = %+v", got)
+	}
+	if got.String() != "10 mail.example.com" {
+		t.Errorf("String() = %q", got.String())
+	}
+	for _, bad := range []string{"", "mail.example.com", "x mail.example.com", "70000 mail.example.com", "10 a b"} {
+		if _, err := ParseMXTarget(bad); err == nil {
+			t.Errorf("ParseMXTarget(%q) expected error", bad)
+		}
+	}
+}
+
+func TestParseSRVTarget(t *testing.T) {
+	got, err := ParseSRVTarget(" 10  5 5060 sip.example.com. ")
+	if err != nil {
+		t.Fatalf("ParseSRVTarget() error = %v", err)
+	}
+	want := SRVTarget{Priority: 10, Weight: 5, Port: 5060, Target: "sip.example.com"}
+	if got != want {
+		t.Errorf("ParseSRVTarget() = %+v, want %+v", got, want)
+	}
+	if got.String() != "10 5 5060 sip.example.com" {
+		t.Errorf("String() = %q", got.String())
+	}
+	for _, bad := range []string{"10 5 sip.example.com", "10 5 70000 sip.example.com", "a 5 80 sip.example.com"} {
+		if _, err := ParseSRVTarget(bad); err == nil {
+			t.Errorf("ParseSRVTarget(%q) expected error", bad)
+		}
+	}
+}
+
+func TestParseCAATarget(t *testing.T) {
+	tests := []struct {
+		in   string
+		want CAATarget
+		str  string
+	}{
+		{"0 issue letsencrypt.org", CAATarget{0, "issue", "letsencrypt.org"}, `0 issue "letsencrypt.org"`},
+		{`0 ISSUE "letsencrypt.org"`, CAATarget{0, "issue", "letsencrypt.org"}, `0 issue "letsencrypt.org"`},
+		{`128 iodef "mailto:security@example.com"`, CAATarget{128, "iodef", "mailto:security@example.com"}, `128 iodef "mailto:security@example.com"`},
+		{`0 issue "ca.example.net; account=230123"`, CAATarget{0, "issue", "ca.example.net; account=230123"}, `0 issue "ca.example.net; account=230123"`},
+	}
+	for _, tt := range tests {
+		got, err := ParseCAATarget(tt.in)
+		if err != nil {
+			t.Errorf("ParseCAATarget(%q) error = %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseCAATarget(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+		if got.String() != tt.str {
+			t.Errorf("String() = %q, want %q", got.String(), tt.str)
+		}
+	}
+	for _, bad := range []string{"0 issue", "256 issue ca.example.net", "0 is-sue ca.example.net"} {
+		if _, err := ParseCAATarget(bad); err == nil {
+			t.Errorf("ParseCAATarget(%q) expected error", bad)
+		}
+	}
+}
+
+func TestNormaliseTarget(t *testing.T) {
+	tests := []struct {
+		rt, in, want string
+	}{
+		{RecordTypeA, "10.0.0.1", "10.0.0.1"},
+		{RecordTypeCNAME, "Backend.example.com", "Backend.example.com"},
+		{RecordTypeMX, "10  MAIL.example.com.", "10 mail.example.com"},
+		{RecordTypeSRV, "0 0 443 web.example.com.", "0 0 443 web.example.com"},
+		{RecordTypeCAA, "0 issue letsencrypt.org", `0 issue "letsencrypt.org"`},
+		{RecordTypeNS, "NS1.example.com.", "ns1.example.com"},
+		{RecordTypePTR, "host.example.com.", "host.example.com"},
+	}
+	for _, tt := range tests {
+		got, err := NormaliseTarget(tt.rt, tt.in)
+		if err != nil {
+			t.Errorf("NormaliseTarget(%s, %q) error = %v", tt.rt, tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("NormaliseTarget(%s, %q) = %q, want %q", tt.rt, tt.in, got, tt.want)
+		}
+	}
+	if _, err := NormaliseTarget(RecordTypePTR, "a b"); err == nil {
+		t.Error("NormaliseTarget(PTR, \"a b\") expected error")
+	}
+}
@@ -1,7 +1,12 @@
 // Package plan holds the diff engine and the Changes type it produces.
 package plan
 
-import "github.com/bkero/external-dns-docker/pkg/endpoint"
+import (
+	"sort"
+	"strings"
+
+	"github.com/bkero/external-dns-docker/pkg/endpoint"
+)
 
 // Changes holds the sets of DNS record operations to apply in a single
 // reconciliation cycle.
@@ -15,12 +20,71 @@ type Changes struct {
 	UpdateNew []*endpoint.Endpoint
 	// Delete contains endpoints that should be deleted.
 	Delete []*endpoint.Endpoint
+
+	// Suppressed holds the operations the plan's policy withheld from this
+	// change set, for reporting only; providers never apply them. Nil when
+	// nothing was suppressed.
+	Suppressed *Changes
 }
 
-// IsEmpty reports whether the change set has no operations.
+// IsEmpty reports whether the change set has no operations. Suppressed
+// operations are not counted.
 func (c *Changes) IsEmpty() bool {
 	return len(c.Create) == 0 &&
 		len(c.UpdateOld) == 0 &&
 		len(c.UpdateNew) == 0 &&
 		len(c.Delete) == 0
 }
+
+// Sort orders each operation list by the name the records manage, then by
+// record type, with every ownership TXT record right after the record set it
+// owns. UpdateOld and UpdateNew stay parallel. Suppressed is sorted too.
+// Calculate returns sorted changes, so identical states always produce
+// identical plans.
+func (c *Changes) Sort() {
+	sort.SliceStable(c.Create, func(i, j int) bool { return endpointLess(c.Create[i], c.Create[j]) })
+	sort.SliceStable(c.Delete, func(i, j int) bool { return endpointLess(c.Delete[i], c.Delete[j]) })
+	if len(c.UpdateOld) == len(c.UpdateNew) {
+		sort.Stable(updatePairs{c.UpdateOld, c.UpdateNew})
+	}
+	if c.Suppressed != nil {
+		c.Suppressed.Sort()
+	}
+}
+
+// updatePairs sorts parallel UpdateOld and UpdateNew slices by the old
+// records.
+type updatePairs struct {
+	old, new []*endpoint.Endpoint
+}
+
+func (u updatePairs) Len() int           { return len(u.old) }
+func (u updatePairs) Less(i, j int) bool { return endpointLess(u.old[i], u.old[j]) }
+func (u updatePairs) Swap(i, j int) {
+	u.old[i], u.old[j] = u.old[j], u.old[i]
+	u.new[i], u.new[j] = u.new[j], u.new[i]
+}
+
+// endpointLess reports whether a sorts before b in a sorted change set.
+func endpointLess(a, b *endpoint.Endpoint) bool {
+	ka, kb := sortKey(a), sortKey(b)
+	for i := range ka {
+		if ka[i] != kb[i] {
+			return ka[i] < kb[i]
+		}
+	}
+	return false
+}
+
+// sortKey returns the fields ep is ordered by: the managed name, the record
+// type it holds or owns, whether it is an ownership record, then its own
+// name and targets to break ties.
+func sortKey(ep *endpoint.Endpoint) [5]string {
+	name, recordType, owner := ep.DNSName, ep.RecordType, "0"
+	if ep.RecordType == endpoint.RecordTypeTXT {
+		if n, t, ok := parseOwnershipName(ep.DNSName); ok {
+			name, recordType, owner = n, t, "1"
+		}
+	}
+	return [5]string{strings.ToLower(name), recordType, owner, ep.DNSName, strings.Join(ep.Targets, "\n")}
+}
@@ -1,6 +1,7 @@
 package plan
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/bkero/external-dns-docker/pkg/endpoint"
@@ -43,3 +44,45 @@ func TestChanges_IsEmpty_Delete(t *testing.T) {
 		t.Error("Changes with Delete entries should not be empty")
 	}
 }
+
+func TestChanges_Sort_OwnershipRecordFollowsItsRecordSet(t *testing.T) {
+	c := &Changes{Create: []*endpoint.Endpoint{
+		ep("b.example.com", "2.2.2.2", endpoint.RecordTypeA),
+		ep("a-"+ownerPrefix+"b.example.com", ownershipValue(DefaultOwnerID), endpoint.RecordTypeTXT),
+		ep("a.example.com", "a.example.net", endpoint.RecordTypeCNAME),
+		ep("a.example.com", "1.1.1.1", endpoint.RecordTypeA),
+	}}
+	c.Sort()
+
+	var got []string
+	for _, e := range c.Create {
+		got = append(got, e.DNSName+" "+e.RecordType)
+	}
+	want := []string{
+		"a.example.com A",
+		"a.example.com CNAME",
+		"b.example.com A",
+		"a-" + ownerPrefix + "b.example.com TXT",
+	}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("sorted = %v, want %v", got, want)
+	}
+}
+
+func TestChanges_Sort_KeepsUpdatesParallel(t *testing.T) {
+	c := &Changes{
+		UpdateOld: []*endpoint.Endpoint{ep("z.example.com", "1.1.1.1", endpoint.RecordTypeA), ep("a.example.com", "3.3.3.3", endpoint.RecordTypeA)},
+		UpdateNew: []*endpoint.Endpoint{ep("z.example.com", "2.2.2.2", endpoint.RecordTypeA), ep("a.example.com", "4.4.4.4", endpoint.RecordTypeA)},
+		Suppressed: &Changes{Delete: []*endpoint.Endpoint{
+			ep("z.example.com", "1.1.1.1", endpoint.RecordTypeA), ep("a.example.com", "1.1.1.1", endpoint.RecordTypeA),
+		}},
+	}
+	c.Sort()
+
+	if c.UpdateOld[0].DNSName != "a.example.com" || c.UpdateNew[0].Targets[0] != "4.4.4.4" {
+		t.Errorf("first update = %v → %v, want a.example.com 3.3.3.3 → 4.4.4.4", c.UpdateOld[0], c.UpdateNew[0])
+	}
+	if c.Suppressed.Delete[0].DNSName != "a.example.com" {
+		t.Errorf("suppressed deletes not sorted: %v", c.Suppressed.Delete)
+	}
+}
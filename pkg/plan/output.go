@@ -0,0 +1,224 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"go.yaml.in/yaml/v2"
+
+	"github.com/bkero/external-dns-docker/pkg/endpoint"
+)
+
+// Format is a rendering of a change set.
+type Format string
+
+// Supported formats.
+const (
+	// FormatJSON renders the change set as a JSON Document.
+	FormatJSON Format = "json"
+	// FormatYAML renders the change set as a YAML Document.
+	FormatYAML Format = "yaml"
+	// FormatNSUpdate renders the change set as an nsupdate(1) script.
+	FormatNSUpdate Format = "nsupdate"
+)
+
+// Record is the serialized form of an endpoint.
+type Record struct {
+	Name    string            `json:"name" yaml:"name"`
+	Type    string            `json:"type" yaml:"type"`
+	Targets []string          `json:"targets" yaml:"targets"`
+	TTL     int64             `json:"ttl" yaml:"ttl"`
+	Sources []string          `json:"sources,omitempty" yaml:"sources,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+}
+
+// RecordUpdate is one record update: the record before and after.
+type RecordUpdate struct {
+	Old Record `json:"old" yaml:"old"`
+	New Record `json:"new" yaml:"new"`
+}
+
+// Document is the serialized form of a change set. Empty operation lists are
+// kept, so they render as [] rather than being left out.
+type Document struct {
+	Create     []Record       `json:"create" yaml:"create"`
+	Update     []RecordUpdate `json:"update" yaml:"update"`
+	Delete     []Record       `json:"delete" yaml:"delete"`
+	Suppressed *Document      `json:"suppressed,omitempty" yaml:"suppressed,omitempty"`
+}
+
+// NewRecord returns the serialized form of ep, with its targets sorted.
+// endpoint.LabelSource is reported as Sources rather than as a label.
+func NewRecord(ep *endpoint.Endpoint) Record {
+	r := Record{
+		Name:    ep.DNSName,
+		Type:    ep.RecordType,
+		Targets: sortedCopy(ep.Targets),
+		TTL:     ep.TTL,
+	}
+	for k, v := range ep.Labels {
+		if k == endpoint.LabelSource {
+			r.Sources = strings.Split(v, ",")
+			continue
+		}
+		if r.Labels == nil {
+			r.Labels = make(map[string]string)
+		}
+		r.Labels[k] = v
+	}
+	return r
+}
+
+// Document returns the serialized form of c, in c's order. Suppressed is
+// included when it holds any operations.
+func (c *Changes) Document() Document {
+	d := Document{
+		Create: records(c.Create),
+		Update: make([]RecordUpdate, 0, len(c.UpdateOld)),
+		Delete: records(c.Delete),
+	}
+	for i, old := range c.UpdateOld {
+		if i < len(c.UpdateNew) {
+			d.Update = append(d.Update, RecordUpdate{Old: NewRecord(old), New: NewRecord(c.UpdateNew[i])})
+		}
+	}
+	if c.Suppressed != nil && !c.Suppressed.IsEmpty() {
+		s := c.Suppressed.Document()
+		d.Suppressed = &s
+	}
+	return d
+}
+
+// records returns the serialized form of eps.
+func records(eps []*endpoint.Endpoint) []Record {
+	out := make([]Record, 0, len(eps))
+	for _, ep := range eps {
+		out = append(out, NewRecord(ep))
+	}
+	return out
+}
+
+// Write renders c to w in format f. Sort c first for output that is stable
+// across runs; Calculate already does.
+func (c *Changes) Write(w io.Writer, f Format) error {
+	switch f {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(c.Document())
+	case FormatYAML:
+		out, err := yaml.Marshal(c.Document())
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	case FormatNSUpdate:
+		return c.writeNSUpdate(w)
+	default:
+		return fmt.Errorf("unknown plan format %q (want json, yaml or nsupdate)", f)
+	}
+}
+
+// writeNSUpdate writes c as an nsupdate(1) script. The operations for each
+// managed name, its ownership records included, are sent as one update, as
+// the rfc2136 provider sends them: deletions, then updates, then creations.
+// The script sets no server, zone or key; nsupdate finds the primary from
+// the zone's SOA record, and the key is given on its command line (-k).
+// Suppressed operations are only counted in the header comment.
+func (c *Changes) writeNSUpdate(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "; external-dns-docker plan: %d create, %d update, %d delete\n",
+		len(c.Create), len(c.UpdateOld), len(c.Delete))
+	if c.Suppressed != nil && !c.Suppressed.IsEmpty() {
+		fmt.Fprintf(&b, "; not included, suppressed by policy: %d update, %d delete\n",
+			len(c.Suppressed.UpdateOld), len(c.Suppressed.Delete))
+	}
+
+	groups := make(map[string][]string)
+	add := func(ep *endpoint.Endpoint, line func(target string) string) {
+		name := strings.ToLower(strings.TrimSuffix(ManagedName(ep), "."))
+		for _, t := range sortedCopy(ep.Targets) {
+			groups[name] = append(groups[name], line(t))
+		}
+	}
+	remove := func(ep *endpoint.Endpoint) {
+		add(ep, func(t string) string {
+			return fmt.Sprintf("update delete %s %s %s", fqdn(ep.DNSName), ep.RecordType, nsupdateData(ep.RecordType, t))
+		})
+	}
+	insert := func(ep *endpoint.Endpoint) {
+		add(ep, func(t string) string {
+			return fmt.Sprintf("update add %s %d %s %s", fqdn(ep.DNSName), ep.TTL, ep.RecordType, nsupdateData(ep.RecordType, t))
+		})
+	}
+	for _, ep := range c.Delete {
+		remove(ep)
+	}
+	for i, old := range c.UpdateOld {
+		if i < len(c.UpdateNew) {
+			remove(old)
+			insert(c.UpdateNew[i])
+		}
+	}
+	for _, ep := range c.Create {
+		insert(ep)
+	}
+
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// nsupdate sends on a blank line, so groups are separated by comments.
+	for _, name := range names {
+		fmt.Fprintf(&b, "; %s\n", name)
+		for _, line := range groups[name] {
+			b.WriteString(line + "\n")
+		}
+		b.WriteString("send\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// nsupdateData returns target as record data for an nsupdate script:
+// hostnames made fully qualified and TXT and CAA values quoted. Targets that
+// fail to parse are written verbatim.
+func nsupdateData(recordType, target string) string {
+	switch recordType {
+	case endpoint.RecordTypeTXT:
+		return quoteTXT(target)
+	case endpoint.RecordTypeCNAME, endpoint.RecordTypeNS, endpoint.RecordTypePTR:
+		return fqdn(strings.TrimSpace(target))
+	case endpoint.RecordTypeMX:
+		if t, err := endpoint.ParseMXTarget(target); err == nil {
+			return fmt.Sprintf("%d %s", t.Preference, fqdn(t.Host))
+		}
+	case endpoint.RecordTypeSRV:
+		if t, err := endpoint.ParseSRVTarget(target); err == nil {
+			return fmt.Sprintf("%d %d %d %s", t.Priority, t.Weight, t.Port, fqdn(t.Target))
+		}
+	case endpoint.RecordTypeCAA:
+		if t, err := endpoint.ParseCAATarget(target); err == nil {
+			return fmt.Sprintf("%d %s %s", t.Flag, t.Tag, quoteTXT(t.Value))
+		}
+	}
+	return target
+}
+
+// quoteTXT returns s as a quoted DNS character string.
+func quoteTXT(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// fqdn returns name with a trailing dot.
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
@@ -0,0 +1,112 @@
+package plan
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.yaml.in/yaml/v2"
+
+	"github.com/bkero/external-dns-docker/pkg/endpoint"
+)
+
+// reviewChanges returns a change set touching two names, with a suppressed
+// delete.
+func reviewChanges() *Changes {
+	web := endpoint.New("web.example.com", []string{"10.0.0.2", "10.0.0.1"}, endpoint.RecordTypeA, 300,
+		map[string]string{endpoint.LabelSource: "container/web-1,container/web-2"})
+	c := &Changes{
+		Create:    []*endpoint.Endpoint{web, ownerTXT("web.example.com")},
+		UpdateOld: []*endpoint.Endpoint{ep("mail.example.com", "10 mx1.example.com", endpoint.RecordTypeMX)},
+		UpdateNew: []*endpoint.Endpoint{ep("mail.example.com", "10 mx2.example.com.", endpoint.RecordTypeMX)},
+		Suppressed: &Changes{
+			Delete: []*endpoint.Endpoint{a("old.example.com", "10.0.0.9")},
+		},
+	}
+	c.Sort()
+	return c
+}
+
+func TestChanges_Write_JSONRoundTrips(t *testing.T) {
+	var buf bytes.Buffer
+	if err := reviewChanges().Write(&buf, FormatJSON); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	var d Document
+	if err := json.Unmarshal(buf.Bytes(), &d); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, buf.String())
+	}
+	if len(d.Create) != 2 || d.Create[0].Name != "web.example.com" || d.Create[0].Targets[0] != "10.0.0.1" {
+		t.Errorf("create = %+v, want web.example.com first with sorted targets", d.Create)
+	}
+	if len(d.Create[0].Sources) != 2 || d.Create[0].Labels != nil {
+		t.Errorf("web.example.com sources = %v labels = %v, want two sources and no labels", d.Create[0].Sources, d.Create[0].Labels)
+	}
+	if len(d.Update) != 1 || d.Update[0].New.Targets[0] != "10 mx2.example.com." {
+		t.Errorf("update = %+v, want the MX update", d.Update)
+	}
+	if d.Delete == nil || len(d.Delete) != 0 {
+		t.Errorf("delete = %#v, want an empty list", d.Delete)
+	}
+	if d.Suppressed == nil || len(d.Suppressed.Delete) != 1 {
+		t.Errorf("suppressed = %+v, want the withheld delete", d.Suppressed)
+	}
+}
+
+func TestChanges_Write_YAMLMatchesDocument(t *testing.T) {
+	var buf bytes.Buffer
+	if err := reviewChanges().Write(&buf, FormatYAML); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	var d Document
+	if err := yaml.Unmarshal(buf.Bytes(), &d); err != nil {
+		t.Fatalf("output is not YAML: %v\n%s", err, buf.String())
+	}
+	want := reviewChanges().Document()
+	if d.Create[1].Name != want.Create[1].Name || d.Update[0].Old.Targets[0] != want.Update[0].Old.Targets[0] {
+		t.Errorf("YAML document = %+v, want %+v", d, want)
+	}
+	if !strings.Contains(buf.String(), "delete: []") {
+		t.Errorf("empty delete list missing from output:\n%s", buf.String())
+	}
+}
+
+func TestChanges_Write_NSUpdateScript(t *testing.T) {
+	var buf bytes.Buffer
+	if err := reviewChanges().Write(&buf, FormatNSUpdate); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	want := `; external-dns-docker plan: 2 create, 1 update, 0 delete
+; not included, suppressed by policy: 0 update, 1 delete
+; mail.example.com
+update delete mail.example.com. MX 10 mx1.example.com.
+update add mail.example.com. 300 MX 10 mx2.example.com.
+send
+; web.example.com
+update add web.example.com. 300 A 10.0.0.1
+update add web.example.com. 300 A 10.0.0.2
+update add a-external-dns-docker-owner.web.example.com. 300 TXT "heritage=external-dns-docker,external-dns-docker/owner=external-dns-docker"
+send
+`
+	if buf.String() != want {
+		t.Errorf("script =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestChanges_Write_NSUpdateQuotesTXT(t *testing.T) {
+	c := &Changes{Delete: []*endpoint.Endpoint{ep("t.example.com", `say "hi" \o/`, endpoint.RecordTypeTXT)}}
+	var buf bytes.Buffer
+	if err := c.Write(&buf, FormatNSUpdate); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if want := `update delete t.example.com. TXT "say \"hi\" \\o/"`; !strings.Contains(buf.String(), want) {
+		t.Errorf("script =\n%s\nwant a line %s", buf.String(), want)
+	}
+}
+
+func TestChanges_Write_UnknownFormat_ReturnsError(t *testing.T) {
+	if err := (&Changes{}).Write(&bytes.Buffer{}, "toml"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
@@ -10,7 +10,13 @@ import (
 
 const (
 	// ownerPrefix is prepended to a managed record's DNS name to form the
-	// companion ownership TXT record name.
+	// companion ownership TXT record name. Current records prefix it further
+	// with the lower-cased record type and a hyphen, so that each RRset at a
+	// name is owned independently:
+	// e.g. app.example.com A → a-external-dns-docker-owner.app.example.com
+	//
+	// Records written by earlier versions use the bare prefix and own every
+	// record type at the name (legacy format):
 	// e.g. app.example.com → external-dns-docker-owner.app.example.com
 	ownerPrefix = "external-dns-docker-owner."
 
@@ -26,34 +32,86 @@ func ownershipValue(ownerID string) string {
 	return fmt.Sprintf("heritage=external-dns-docker,external-dns-docker/owner=%s", ownerID)
 }
 
-// ownershipName returns the DNS name of the ownership TXT record for a managed name.
-func ownershipName(dnsName string) string {
+// ownershipName returns the DNS name of the ownership TXT record for the
+// recordType RRset at dnsName.
+func ownershipName(dnsName, recordType string) string {
+	return strings.ToLower(recordType) + "-" + ownerPrefix + dnsName
+}
+
+// legacyOwnershipName returns the DNS name of a legacy, type-less ownership
+// TXT record for dnsName.
+func legacyOwnershipName(dnsName string) string {
 	return ownerPrefix + dnsName
 }
 
+// parseOwnershipName splits an ownership TXT record name into the managed DNS
+// name and record type it covers. recordType is empty for the legacy format.
+// ok is false when name is not an ownership record name.
+func parseOwnershipName(name string) (dnsName, recordType string, ok bool) {
+	if strings.HasPrefix(name, ownerPrefix) {
+		return strings.TrimPrefix(name, ownerPrefix), "", true
+	}
+	i := strings.Index(name, "-"+ownerPrefix)
+	if i <= 0 || strings.Contains(name[:i], ".") {
+		return "", "", false
+	}
+	return name[i+1+len(ownerPrefix):], strings.ToUpper(name[:i]), true
+}
+
 // Plan calculates DNS changes between a desired and current state, enforcing
 // ownership so that only records this daemon manages are ever modified.
 type Plan struct {
 	ownerID string
+	policy  Policy
 }
 
-// New returns a Plan with the given owner ID (use DefaultOwnerID if empty).
+// New returns a Plan with the given owner ID (use DefaultOwnerID if empty)
+// and PolicySync.
 func New(ownerID string) *Plan {
+	return NewWithPolicy(ownerID, PolicySync)
+}
+
+// NewWithPolicy returns a Plan with the given owner ID (use DefaultOwnerID if
+// empty) that only produces the kinds of changes policy allows. An empty
+// policy selects PolicySync.
+func NewWithPolicy(ownerID string, policy Policy) *Plan {
 	if ownerID == "" {
 		ownerID = DefaultOwnerID
 	}
-	return &Plan{ownerID: ownerID}
+	if policy == "" {
+		policy = PolicySync
+	}
+	return &Plan{ownerID: ownerID, policy: policy}
+}
+
+// ownership is the set of records this plan's owner ID owns in the current
+// state, split by ownership record format.
+type ownership struct {
+	typed  map[string]bool // epKey → owned via a per-type TXT record
+	legacy map[string]bool // DNS name → owned (all types) via a legacy TXT record
+}
+
+// owns reports whether ep's RRset is owned in either format.
+func (o ownership) owns(ep *endpoint.Endpoint) bool {
+	return o.typed[epKey(ep)] || o.legacy[ep.DNSName]
 }
 
 // Calculate diffs desired endpoints (from the source) against current endpoints
 // (from the provider) and returns the minimal set of Changes needed to converge
-// the DNS state. Ownership TXT companion records are created and deleted
-// alongside their managed records.
+// the DNS state. Ownership TXT companion records, one per name and record
+// type, are created and deleted alongside their managed records.
 //
 // Records present in current that have no matching ownership TXT record are
-// never modified or deleted.
+// never modified or deleted. Names still owned through a legacy type-less TXT
+// record are migrated: every surviving record at the name receives its own
+// per-type TXT record and the legacy record is deleted in the same change set.
+// Under policies that never delete, the legacy record is kept and left out of
+// Changes.Suppressed, so it is not reported every cycle.
+//
+// Operations the plan's policy forbids are moved to Changes.Suppressed. The
+// returned changes are sorted (see Changes.Sort).
 func (p *Plan) Calculate(desired, current []*endpoint.Endpoint) *Changes {
-	// Step 1: build the owned-name set from current ownership TXT records.
+	// Step 1: build the owned set from current ownership TXT records.
 	owned := p.buildOwnedSet(current)
 
 	// Step 2: index current non-ownership records by (DNSName, RecordType).
@@ -70,10 +128,12 @@ func (p *Plan) Calculate(desired, current []*endpoint.Endpoint) *Changes {
 		if !exists {
 			// New record: create it and its ownership TXT companion.
 			changes.Create = append(changes.Create, want)
-			changes.Create = append(changes.Create, p.ownershipTXTFor(want.DNSName))
+			if !owned.typed[key] {
+				changes.Create = append(changes.Create, p.ownershipTXTFor(want.DNSName, want.RecordType))
+			}
 			continue
 		}
-		if !owned[want.DNSName] {
+		if !owned.owns(have) {
 			// Record exists but is not owned by us — leave it alone.
 			continue
 		}
@@ -90,44 +150,120 @@ func (p *Plan) Calculate(desired, current []*endpoint.Endpoint) *Changes {
 		if _, wanted := desiredIdx[key]; wanted {
 			continue
 		}
-		if !owned[have.DNSName] {
+		if !owned.owns(have) {
 			// Not owned by us — never delete.
 			continue
 		}
 		changes.Delete = append(changes.Delete, have)
-		changes.Delete = append(changes.Delete, p.ownershipTXTFor(have.DNSName))
+		if owned.typed[key] {
+			changes.Delete = append(changes.Delete, p.ownershipTXTFor(have.DNSName, have.RecordType))
+		}
+	}
+
+	// Step 6: migrate legacy ownership — give every surviving record at a
+	// legacy-owned name its own per-type TXT, then drop the legacy TXT.
+	var retired []*endpoint.Endpoint
+	for name := range owned.legacy {
+		for key, have := range currentIdx {
+			if have.DNSName != name || owned.typed[key] {
+				continue
+			}
+			if _, wanted := desiredIdx[key]; !wanted {
+				continue // deleted in step 5
+			}
+			changes.Create = append(changes.Create, p.ownershipTXTFor(have.DNSName, have.RecordType))
+		}
+		retired = append(retired, p.legacyOwnershipTXTFor(name))
+	}
+
+	allowed, suppressed := p.policy.apply(changes)
+	allowed.Suppressed = suppressed
+	if p.policy.deletes() {
+		allowed.Delete = append(allowed.Delete, retired...)
+	}
+	allowed.Sort()
+	return allowed
+}
+
+// OwnedCount returns the number of RRsets in current that this plan's owner
+// ID owns, not counting the ownership TXT records themselves.
+func (p *Plan) OwnedCount(current []*endpoint.Endpoint) int {
+	owned := p.buildOwnedSet(current)
+	n := 0
+	for _, ep := range indexEndpoints(filterOwnershipTXTs(current)) {
+		if owned.owns(ep) {
+			n++
+		}
 	}
+	return n
+}
 
-	return changes
+// IsOwnershipRecord reports whether ep is an ownership TXT record, in either
+// format, rather than a managed record.
+func IsOwnershipRecord(ep *endpoint.Endpoint) bool {
+	if ep.RecordType != endpoint.RecordTypeTXT {
+		return false
+	}
+	_, _, ok := parseOwnershipName(ep.DNSName)
+	return ok
 }
 
-// buildOwnedSet returns a set of DNS names whose ownership TXT records match
-// this plan's owner ID.
-func (p *Plan) buildOwnedSet(current []*endpoint.Endpoint) map[string]bool {
+// ManagedName returns the DNS name ep concerns: for an ownership TXT record,
+// the name of the record it owns; for any other record, its own name.
+func ManagedName(ep *endpoint.Endpoint) string {
+	if ep.RecordType == endpoint.RecordTypeTXT {
+		if name, _, ok := parseOwnershipName(ep.DNSName); ok {
+			return name
+		}
+	}
+	return ep.DNSName
+}
+
+// buildOwnedSet returns the records whose ownership TXT records, in either
+// format, match this plan's owner ID.
+func (p *Plan) buildOwnedSet(current []*endpoint.Endpoint) ownership {
 	want := ownershipValue(p.ownerID)
-	owned := make(map[string]bool)
+	owned := ownership{typed: make(map[string]bool), legacy: make(map[string]bool)}
 	for _, ep := range current {
 		if ep.RecordType != endpoint.RecordTypeTXT {
 			continue
 		}
-		if !strings.HasPrefix(ep.DNSName, ownerPrefix) {
+		managedName, recordType, ok := parseOwnershipName(ep.DNSName)
+		if !ok {
 			continue
 		}
-		managedName := strings.TrimPrefix(ep.DNSName, ownerPrefix)
 		for _, v := range ep.Targets {
-			if v == want {
-				owned[managedName] = true
-				break
+			if v != want {
+				continue
+			}
+			if recordType == "" {
+				owned.legacy[managedName] = true
+			} else {
+				owned.typed[managedName+"|"+recordType] = true
 			}
+			break
 		}
 	}
 	return owned
 }
 
-// ownershipTXTFor returns the ownership TXT endpoint companion for dnsName.
-func (p *Plan) ownershipTXTFor(dnsName string) *endpoint.Endpoint {
+// ownershipTXTFor returns the ownership TXT endpoint companion for the
+// recordType RRset at dnsName.
+func (p *Plan) ownershipTXTFor(dnsName, recordType string) *endpoint.Endpoint {
+	return endpoint.New(
+		ownershipName(dnsName, recordType),
+		[]string{ownershipValue(p.ownerID)},
+		endpoint.RecordTypeTXT,
+		ownershipTTL,
+		nil,
+	)
+}
+
+// legacyOwnershipTXTFor returns the legacy, type-less ownership TXT endpoint
+// for dnsName, as written by earlier versions.
+func (p *Plan) legacyOwnershipTXTFor(dnsName string) *endpoint.Endpoint {
 	return endpoint.New(
-		ownershipName(dnsName),
+		legacyOwnershipName(dnsName),
 		[]string{ownershipValue(p.ownerID)},
 		endpoint.RecordTypeTXT,
 		ownershipTTL,
@@ -139,7 +275,7 @@ func (p *Plan) ownershipTXTFor(dnsName string) *endpoint.Endpoint {
 func filterOwnershipTXTs(eps []*endpoint.Endpoint) []*endpoint.Endpoint {
 	out := make([]*endpoint.Endpoint, 0, len(eps))
 	for _, ep := range eps {
-		if ep.RecordType == endpoint.RecordTypeTXT && strings.HasPrefix(ep.DNSName, ownerPrefix) {
+		if IsOwnershipRecord(ep) {
 			continue
 		}
 		out = append(out, ep)
@@ -148,11 +284,23 @@ func filterOwnershipTXTs(eps []*endpoint.Endpoint) []*endpoint.Endpoint {
 }
 
 // indexEndpoints builds a map from "DNSName|RecordType" to Endpoint.
-// If duplicate keys exist the last one wins (undefined provider behaviour).
+// Endpoints sharing a key are merged into one RRset carrying all of their
+// targets, keeping the TTL of the first: providers such as AXFR return one
+// endpoint per resource record, so a round-robin RRset arrives as several.
+// The input endpoints are never modified.
 func indexEndpoints(eps []*endpoint.Endpoint) map[string]*endpoint.Endpoint {
 	idx := make(map[string]*endpoint.Endpoint, len(eps))
 	for _, ep := range eps {
-		idx[epKey(ep)] = ep
+		k := epKey(ep)
+		prev, ok := idx[k]
+		if !ok {
+			idx[k] = ep
+			continue
+		}
+		targets := make([]string, 0, len(prev.Targets)+len(ep.Targets))
+		targets = append(targets, prev.Targets...)
+		targets = append(targets, ep.Targets...)
+		idx[k] = endpoint.New(prev.DNSName, targets, prev.RecordType, prev.TTL, prev.Labels)
 	}
 	return idx
 }
@@ -164,6 +312,8 @@ func epKey(ep *endpoint.Endpoint) string {
 
 // endpointsEqual returns true when two endpoints have the same targets and TTL.
 // DNSName and RecordType are assumed to already match (they are the map key).
+// Targets are compared in canonical form (see endpoint.NormaliseTarget), so
+// e.g. "10 mail.example.com." equals "10 mail.example.com" for MX records.
 func endpointsEqual(a, b *endpoint.Endpoint) bool {
 	if a.TTL != b.TTL {
 		return false
@@ -171,8 +321,8 @@ func endpointsEqual(a, b *endpoint.Endpoint) bool {
 	if len(a.Targets) != len(b.Targets) {
 		return false
 	}
-	as := sortedCopy(a.Targets)
-	bs := sortedCopy(b.Targets)
+	as := sortedCopy(normaliseTargets(a.RecordType, a.Targets))
+	bs := sortedCopy(normaliseTargets(b.RecordType, b.Targets))
 	for i := range as {
 		if as[i] != bs[i] {
 			return false
@@ -181,6 +331,22 @@ func endpointsEqual(a, b *endpoint.Endpoint) bool {
 	return true
 }
 
+// normaliseTargets returns targets in canonical form for recordType. Targets
+// that fail to parse are kept verbatim.
+func normaliseTargets(recordType string, targets []string) []string {
+	if !endpoint.IsStructuredType(recordType) {
+		return targets
+	}
+	out := make([]string, len(targets))
+	for i, t := range targets {
+		if n, err := endpoint.NormaliseTarget(recordType, t); err == nil {
+			t = n
+		}
+		out[i] = t
+	}
+	return out
+}
+
 func sortedCopy(s []string) []string {
 	c := make([]string, len(s))
 	copy(c, s)
@@ -1,6 +1,7 @@
 package plan
 
 import (
+	"reflect"
 	"sort"
 	"testing"
 
@@ -17,20 +18,26 @@ func aTTL(name, target string, ttl int64) *endpoint.Endpoint {
 	return endpoint.New(name, []string{target}, endpoint.RecordTypeA, ttl, nil)
 }
 
+// ownerTXT returns the per-type ownership TXT for the A record at name.
 func ownerTXT(name string) *endpoint.Endpoint {
+	return ownerTXTID(name, DefaultOwnerID)
+}
+
+func ownerTXTID(name, ownerID string) *endpoint.Endpoint {
 	return endpoint.New(
-		ownerPrefix+name,
-		[]string{ownershipValue(DefaultOwnerID)},
+		ownershipName(name, endpoint.RecordTypeA),
+		[]string{ownershipValue(ownerID)},
 		endpoint.RecordTypeTXT,
 		ownershipTTL,
 		nil,
 	)
 }
 
-func ownerTXTID(name, ownerID string) *endpoint.Endpoint {
+// legacyOwnerTXT returns a type-less ownership TXT as written by earlier versions.
+func legacyOwnerTXT(name string) *endpoint.Endpoint {
 	return endpoint.New(
 		ownerPrefix+name,
-		[]string{ownershipValue(ownerID)},
+		[]string{ownershipValue(DefaultOwnerID)},
 		endpoint.RecordTypeTXT,
 		ownershipTTL,
 		nil,
@@ -60,12 +67,13 @@ func TestCalculate_NewRecord_ProducesCreate(t *testing.T) {
 	if len(changes.Create) != 2 {
 		t.Fatalf("Create len = %d, want 2 (record + ownership TXT)", len(changes.Create))
 	}
+	// Sort alphabetically: "a-external-dns-docker-owner.*" < "app.*"
 	names := sortedNames(changes.Create)
-	if names[0] != "app.example.com" {
-		t.Errorf("Create[0].DNSName = %q, want app.example.com", names[0])
+	if names[0] != "a-"+ownerPrefix+"app.example.com" {
+		t.Errorf("Create[0].DNSName = %q, want %s", names[0], "a-"+ownerPrefix+"app.example.com")
 	}
-	if names[1] != ownerPrefix+"app.example.com" {
-		t.Errorf("Create[1].DNSName = %q, want %s", names[1], ownerPrefix+"app.example.com")
+	if names[1] != "app.example.com" {
+		t.Errorf("Create[1].DNSName = %q, want app.example.com", names[1])
 	}
 	if len(changes.Delete) != 0 || len(changes.UpdateOld) != 0 {
 		t.Errorf("unexpected deletes or updates: %+v", changes)
@@ -105,10 +113,10 @@ func TestCalculate_OwnedMissingRecord_ProducesDelete(t *testing.T) {
 	if len(changes.Delete) != 2 {
 		t.Fatalf("Delete len = %d, want 2 (record + ownership TXT)", len(changes.Delete))
 	}
-	// Sort alphabetically: "external-dns-docker-owner.*" ('e') < "old.*" ('o')
+	// Sort alphabetically: "a-external-dns-docker-owner.*" ('a') < "old.*" ('o')
 	names := sortedNames(changes.Delete)
-	if names[0] != ownerPrefix+"old.example.com" {
-		t.Errorf("Delete[0] = %q, want %s", names[0], ownerPrefix+"old.example.com")
+	if names[0] != "a-"+ownerPrefix+"old.example.com" {
+		t.Errorf("Delete[0] = %q, want %s", names[0], "a-"+ownerPrefix+"old.example.com")
 	}
 	if names[1] != "old.example.com" {
 		t.Errorf("Delete[1] = %q, want old.example.com", names[1])
@@ -298,13 +306,199 @@ func TestCalculate_MultipleTargets_NotEqualWhenDifferent(t *testing.T) {
 	}
 }
 
+func TestCalculate_StructuredTargets_ComparedCanonically(t *testing.T) {
+	// The provider reports "10 Mail.example.com." style values; labels give
+	// "10 mail.example.com". They are the same record.
+	mx := func(target string) *endpoint.Endpoint {
+		return endpoint.New("example.com", []string{target}, endpoint.RecordTypeMX, 300, nil)
+	}
+	mxOwner := endpoint.New(ownershipName("example.com", endpoint.RecordTypeMX),
+		[]string{ownershipValue(DefaultOwnerID)}, endpoint.RecordTypeTXT, ownershipTTL, nil)
+
+	changes := plan().Calculate(
+		[]*endpoint.Endpoint{mx("10 mail.example.com")},
+		[]*endpoint.Endpoint{mx("10  Mail.example.com."), mxOwner},
+	)
+	if !changes.IsEmpty() {
+		t.Errorf("expected no changes, got %+v", changes)
+	}
+
+	changes = plan().Calculate(
+		[]*endpoint.Endpoint{mx("20 mail.example.com")},
+		[]*endpoint.Endpoint{mx("10 mail.example.com"), mxOwner},
+	)
+	if len(changes.UpdateNew) != 1 {
+		t.Errorf("expected 1 update for a changed preference, got %+v", changes)
+	}
+}
+
+func TestCalculate_CurrentRRsetSplitPerRecord_NoOp(t *testing.T) {
+	// AXFR yields one endpoint per RR; a matching round-robin desired record
+	// must not be seen as changed.
+	desired := []*endpoint.Endpoint{
+		endpoint.New("app.example.com", []string{"1.1.1.1", "2.2.2.2"}, endpoint.RecordTypeA, 300, nil),
+	}
+	current := []*endpoint.Endpoint{
+		a("app.example.com", "2.2.2.2"),
+		a("app.example.com", "1.1.1.1"),
+		ownerTXT("app.example.com"),
+	}
+	changes := plan().Calculate(desired, current)
+	if !changes.IsEmpty() {
+		t.Errorf("expected no changes, got %+v", changes)
+	}
+}
+
+func TestCalculate_CurrentRRsetSplitPerRecord_UpdateCarriesAllOldTargets(t *testing.T) {
+	desired := []*endpoint.Endpoint{a("app.example.com", "1.1.1.1")}
+	current := []*endpoint.Endpoint{
+		a("app.example.com", "1.1.1.1"),
+		a("app.example.com", "2.2.2.2"),
+		ownerTXT("app.example.com"),
+	}
+	changes := plan().Calculate(desired, current)
+	if len(changes.UpdateOld) != 1 {
+		t.Fatalf("UpdateOld = %d, want 1", len(changes.UpdateOld))
+	}
+	if got := changes.UpdateOld[0].Targets; len(got) != 2 {
+		t.Errorf("UpdateOld targets = %v, want both current RRs", got)
+	}
+}
+
+// --- Per-type ownership ---
+
+func TestCalculate_RemovingOneType_KeepsOtherOwned(t *testing.T) {
+	aaaa := endpoint.New("app.example.com", []string{"fd00::1"}, endpoint.RecordTypeAAAA, 300, nil)
+	current := []*endpoint.Endpoint{
+		a("app.example.com", "1.2.3.4"),
+		ownerTXT("app.example.com"),
+		aaaa,
+		New(DefaultOwnerID).ownershipTXTFor("app.example.com", endpoint.RecordTypeAAAA),
+	}
+	desired := []*endpoint.Endpoint{a("app.example.com", "1.2.3.4")}
+
+	changes := plan().Calculate(desired, current)
+
+	names := sortedNames(changes.Delete)
+	if len(names) != 2 || names[0] != "aaaa-"+ownerPrefix+"app.example.com" || names[1] != "app.example.com" {
+		t.Fatalf("Delete = %v, want the AAAA record and its ownership TXT only", names)
+	}
+
+	// The A record must still be owned on the next cycle.
+	after := []*endpoint.Endpoint{a("app.example.com", "1.2.3.4"), ownerTXT("app.example.com")}
+	next := plan().Calculate([]*endpoint.Endpoint{a("app.example.com", "5.6.7.8")}, after)
+	if len(next.UpdateOld) != 1 {
+		t.Errorf("A record no longer owned after AAAA removal: %+v", next)
+	}
+}
+
+func TestCalculate_LegacyOwnership_MigratedInPlace(t *testing.T) {
+	aaaa := endpoint.New("app.example.com", []string{"fd00::1"}, endpoint.RecordTypeAAAA, 300, nil)
+	current := []*endpoint.Endpoint{
+		a("app.example.com", "1.2.3.4"),
+		aaaa,
+		legacyOwnerTXT("app.example.com"),
+	}
+	// Keep the A record, drop the AAAA record.
+	desired := []*endpoint.Endpoint{a("app.example.com", "1.2.3.4")}
+
+	changes := plan().Calculate(desired, current)
+
+	if got := sortedNames(changes.Create); len(got) != 1 || got[0] != "a-"+ownerPrefix+"app.example.com" {
+		t.Errorf("Create = %v, want only the per-type TXT for the surviving A record", got)
+	}
+	got := sortedNames(changes.Delete)
+	want := []string{"app.example.com", ownerPrefix + "app.example.com"}
+	sort.Strings(want)
+	if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Delete = %v, want %v (AAAA record + legacy TXT)", got, want)
+	}
+	if len(changes.UpdateOld) != 0 {
+		t.Errorf("unexpected updates: %v", changes.UpdateOld)
+	}
+}
+
+func TestCalculate_LegacyOwnership_UpdatesStillAllowed(t *testing.T) {
+	current := []*endpoint.Endpoint{a("app.example.com", "1.2.3.4"), legacyOwnerTXT("app.example.com")}
+	desired := []*endpoint.Endpoint{a("app.example.com", "5.6.7.8")}
+
+	changes := plan().Calculate(desired, current)
+
+	if len(changes.UpdateOld) != 1 {
+		t.Errorf("UpdateOld len = %d, want 1 (legacy-owned record is owned)", len(changes.UpdateOld))
+	}
+}
+
+func TestCalculate_LegacyOwnership_NoDeletePolicy_KeptUnreported(t *testing.T) {
+	current := []*endpoint.Endpoint{a("app.example.com", "1.2.3.4"), legacyOwnerTXT("app.example.com")}
+	desired := []*endpoint.Endpoint{a("app.example.com", "1.2.3.4")}
+
+	for _, policy := range []Policy{PolicyUpsertOnly, PolicyCreateOnly} {
+		t.Run(string(policy), func(t *testing.T) {
+			changes := NewWithPolicy(DefaultOwnerID, policy).Calculate(desired, current)
+
+			if got := sortedNames(changes.Create); len(got) != 1 || got[0] != "a-"+ownerPrefix+"app.example.com" {
+				t.Errorf("Create = %v, want the per-type TXT for the A record", got)
+			}
+			if len(changes.Delete) != 0 {
+				t.Errorf("Delete = %v, want the legacy TXT kept", sortedNames(changes.Delete))
+			}
+			if changes.Suppressed != nil {
+				t.Errorf("Suppressed = %+v, want the legacy TXT not reported", changes.Suppressed)
+			}
+		})
+	}
+}
+
+func TestCalculate_LegacyOwnershipOtherOwner_Ignored(t *testing.T) {
+	legacy := legacyOwnerTXT("app.example.com")
+	legacy.Targets = []string{ownershipValue("someone-else")}
+	current := []*endpoint.Endpoint{a("app.example.com", "1.2.3.4"), legacy}
+
+	changes := plan().Calculate(nil, current)
+
+	if !changes.IsEmpty() {
+		t.Errorf("expected no changes for another owner's legacy record, got %+v", changes)
+	}
+}
+
 // --- Helper unit tests ---
 
 func TestOwnershipName(t *testing.T) {
-	got := ownershipName("app.example.com")
-	want := ownerPrefix + "app.example.com"
-	if got != want {
-		t.Errorf("ownershipName = %q, want %q", got, want)
+	tests := []struct {
+		recordType string
+		want       string
+	}{
+		{"A", "a-" + ownerPrefix + "app.example.com"},
+		{"AAAA", "aaaa-" + ownerPrefix + "app.example.com"},
+		{"CNAME", "cname-" + ownerPrefix + "app.example.com"},
+	}
+	for _, tt := range tests {
+		if got := ownershipName("app.example.com", tt.recordType); got != tt.want {
+			t.Errorf("ownershipName(%s) = %q, want %q", tt.recordType, got, tt.want)
+		}
+	}
+}
+
+func TestParseOwnershipName(t *testing.T) {
+	tests := []struct {
+		input      string
+		wantName   string
+		wantType   string
+		wantParsed bool
+	}{
+		{"aaaa-" + ownerPrefix + "app.example.com", "app.example.com", "AAAA", true},
+		{ownerPrefix + "app.example.com", "app.example.com", "", true},
+		{ownerPrefix + "a-app.example.com", "a-app.example.com", "", true}, // legacy, not typed
+		{"app.example.com", "", "", false},
+		{"x.a-" + ownerPrefix + "app.example.com", "", "", false},
+	}
+	for _, tt := range tests {
+		name, rt, ok := parseOwnershipName(tt.input)
+		if name != tt.wantName || rt != tt.wantType || ok != tt.wantParsed {
+			t.Errorf("parseOwnershipName(%q) = (%q, %q, %v), want (%q, %q, %v)",
+				tt.input, name, rt, ok, tt.wantName, tt.wantType, tt.wantParsed)
+		}
 	}
 }
 
@@ -347,11 +541,51 @@ func TestBuildOwnedSet_NonOwnerPrefixTXT_Ignored(t *testing.T) {
 		a("app.example.com", "1.2.3.4"),
 	}
 	owned := p.buildOwnedSet(current)
-	if owned["app.example.com"] {
+	if owned.owns(a("app.example.com", "1.2.3.4")) {
 		t.Error("app.example.com should not be owned (TXT lacks ownerPrefix)")
 	}
 }
 
+func TestOwnedCount(t *testing.T) {
+	current := []*endpoint.Endpoint{
+		a("owned.example.com", "1.1.1.1"),
+		ownerTXT("owned.example.com"),
+		a("legacy.example.com", "2.2.2.2"),
+		legacyOwnerTXT("legacy.example.com"),
+		a("manual.example.com", "3.3.3.3"),
+		a("other.example.com", "4.4.4.4"),
+		ownerTXTID("other.example.com", "someone-else"),
+	}
+	if got := plan().OwnedCount(current); got != 2 {
+		t.Errorf("OwnedCount() = %d, want 2", got)
+	}
+}
+
+func TestIsOwnershipRecord(t *testing.T) {
+	if !IsOwnershipRecord(ownerTXT("app.example.com")) {
+		t.Error("per-type ownership TXT not recognised")
+	}
+	if !IsOwnershipRecord(legacyOwnerTXT("app.example.com")) {
+		t.Error("legacy ownership TXT not recognised")
+	}
+	if IsOwnershipRecord(a("a-external-dns-docker-owner.app.example.com", "1.1.1.1")) {
+		t.Error("A record reported as an ownership record")
+	}
+}
+
+func TestManagedName(t *testing.T) {
+	cases := map[*endpoint.Endpoint]string{
+		ownerTXT("app.example.com"):       "app.example.com",
+		legacyOwnerTXT("app.example.com"): "app.example.com",
+		a("app.example.com", "1.1.1.1"):   "app.example.com",
+	}
+	for ep, want := range cases {
+		if got := ManagedName(ep); got != want {
+			t.Errorf("ManagedName(%s) = %q, want %q", ep.DNSName, got, want)
+		}
+	}
+}
+
 func TestEndpointsEqual_DifferentLengths_NotEqual(t *testing.T) {
 	ep1 := endpoint.New("app.example.com", []string{"1.1.1.1", "2.2.2.2"}, endpoint.RecordTypeA, 300, nil)
 	ep2 := endpoint.New("app.example.com", []string{"1.1.1.1"}, endpoint.RecordTypeA, 300, nil)
@@ -360,3 +594,21 @@ func TestEndpointsEqual_DifferentLengths_NotEqual(t *testing.T) {
 		t.Error("endpoints with different target counts should not be equal")
 	}
 }
+
+func TestCalculate_SameState_SameOrder(t *testing.T) {
+	var desired, current []*endpoint.Endpoint
+	for _, name := range []string{"d", "b", "e", "a", "c", "f", "h", "g"} {
+		desired = append(desired, a(name+".new.example.com", "10.0.0.1"))
+		current = append(current, a(name+".old.example.com", "10.0.0.2"), ownerTXT(name+".old.example.com"))
+	}
+
+	first := plan().Calculate(desired, current).Document()
+	for range 20 {
+		if got := plan().Calculate(desired, current).Document(); !reflect.DeepEqual(got, first) {
+			t.Fatalf("plans of the same state differ:\n%+v\n%+v", got, first)
+		}
+	}
+	if first.Create[0].Name != "a.new.example.com" || first.Create[1].Name != "a-"+ownerPrefix+"a.new.example.com" {
+		t.Errorf("first creates = %+v, want a.new.example.com then its ownership record", first.Create[:2])
+	}
+}
@@ -0,0 +1,64 @@
+package plan
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Policy controls which kinds of changes a Plan may produce.
+type Policy string
+
+// Supported policies.
+const (
+	// PolicySync creates, updates, and deletes records (full synchronisation).
+	PolicySync Policy = "sync"
+	// PolicyUpsertOnly creates and updates records but never deletes them.
+	PolicyUpsertOnly Policy = "upsert-only"
+	// PolicyCreateOnly creates records but never updates or deletes them.
+	PolicyCreateOnly Policy = "create-only"
+)
+
+// ParsePolicy returns the Policy named by s (case-insensitive).
+// An empty string selects PolicySync.
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(strings.ToLower(strings.TrimSpace(s))); p {
+	case "":
+		return PolicySync, nil
+	case PolicySync, PolicyUpsertOnly, PolicyCreateOnly:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown policy %q (want %s, %s or %s)",
+			s, PolicySync, PolicyUpsertOnly, PolicyCreateOnly)
+	}
+}
+
+// apply splits changes into the operations the policy allows and those it
+// suppresses. The suppressed set is nil when nothing was suppressed.
+func (p Policy) apply(changes *Changes) (allowed, suppressed *Changes) {
+	allowed = &Changes{Create: changes.Create}
+	suppressed = &Changes{}
+
+	if p == PolicyCreateOnly {
+		suppressed.UpdateOld = changes.UpdateOld
+		suppressed.UpdateNew = changes.UpdateNew
+	} else {
+		allowed.UpdateOld = changes.UpdateOld
+		allowed.UpdateNew = changes.UpdateNew
+	}
+
+	if p.deletes() {
+		allowed.Delete = changes.Delete
+	} else {
+		suppressed.Delete = changes.Delete
+	}
+
+	if suppressed.IsEmpty() {
+		return allowed, nil
+	}
+	return allowed, suppressed
+}
+
+// deletes reports whether the policy lets changes delete records.
+func (p Policy) deletes() bool {
+	return p != PolicyUpsertOnly && p != PolicyCreateOnly
+}
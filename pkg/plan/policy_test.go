@@ -0,0 +1,110 @@
+package plan
+
+import (
+	"testing"
+
+	"github.com/bkero/external-dns-docker/pkg/endpoint"
+)
+
+func TestParsePolicy(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    Policy
+		wantErr bool
+	}{
+		{"", PolicySync, false},
+		{"sync", PolicySync, false},
+		{"Upsert-Only", PolicyUpsertOnly, false},
+		{" create-only ", PolicyCreateOnly, false},
+		{"delete-only", "", true},
+	}
+	for _, tc := range cases {
+		got, err := ParsePolicy(tc.in)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("ParsePolicy(%q) error = %v, wantErr %v", tc.in, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("ParsePolicy(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+// policyScenario returns desired and current sets that produce one create
+// (new.example.com), one update (upd.example.com) and one delete
+// (del.example.com) under PolicySync.
+func policyScenario() (desired, current []*endpoint.Endpoint) {
+	desired = []*endpoint.Endpoint{
+		a("new.example.com", "1.1.1.1"),
+		a("upd.example.com", "9.9.9.9"),
+	}
+	current = []*endpoint.Endpoint{
+		a("upd.example.com", "1.2.3.4"),
+		ownerTXT("upd.example.com"),
+		a("del.example.com", "4.4.4.4"),
+		ownerTXT("del.example.com"),
+	}
+	return desired, current
+}
+
+func TestCalculate_PolicySync_NothingSuppressed(t *testing.T) {
+	desired, current := policyScenario()
+	changes := NewWithPolicy(DefaultOwnerID, PolicySync).Calculate(desired, current)
+
+	if changes.Suppressed != nil {
+		t.Errorf("Suppressed = %+v, want nil", changes.Suppressed)
+	}
+	if len(changes.UpdateNew) != 1 || len(changes.Delete) != 2 {
+		t.Errorf("update/delete = %d/%d, want 1/2", len(changes.UpdateNew), len(changes.Delete))
+	}
+}
+
+func TestCalculate_PolicyUpsertOnly_SuppressesDeletes(t *testing.T) {
+	desired, current := policyScenario()
+	changes := NewWithPolicy(DefaultOwnerID, PolicyUpsertOnly).Calculate(desired, current)
+
+	if len(changes.Delete) != 0 {
+		t.Errorf("Delete = %v, want none", sortedNames(changes.Delete))
+	}
+	if len(changes.Create) != 2 || len(changes.UpdateNew) != 1 {
+		t.Errorf("create/update = %d/%d, want 2/1", len(changes.Create), len(changes.UpdateNew))
+	}
+	if changes.Suppressed == nil || len(changes.Suppressed.Delete) != 2 {
+		t.Fatalf("Suppressed = %+v, want the record and its ownership TXT deletes", changes.Suppressed)
+	}
+	if len(changes.Suppressed.Create) != 0 || len(changes.Suppressed.UpdateNew) != 0 {
+		t.Errorf("Suppressed should only hold deletes, got %+v", changes.Suppressed)
+	}
+}
+
+func TestCalculate_PolicyCreateOnly_SuppressesUpdatesAndDeletes(t *testing.T) {
+	desired, current := policyScenario()
+	changes := NewWithPolicy(DefaultOwnerID, PolicyCreateOnly).Calculate(desired, current)
+
+	if len(changes.UpdateNew) != 0 || len(changes.UpdateOld) != 0 || len(changes.Delete) != 0 {
+		t.Errorf("expected only creates, got %+v", changes)
+	}
+	if got := sortedNames(changes.Create); len(got) != 2 || got[1] != "new.example.com" {
+		t.Errorf("Create = %v, want new.example.com and its ownership TXT", got)
+	}
+	s := changes.Suppressed
+	if s == nil || len(s.UpdateOld) != 1 || len(s.UpdateNew) != 1 || len(s.Delete) != 2 {
+		t.Fatalf("Suppressed = %+v, want 1 update and 2 deletes", s)
+	}
+}
+
+func TestCalculate_PolicyUpsertOnly_NoDeletes_NothingSuppressed(t *testing.T) {
+	changes := NewWithPolicy(DefaultOwnerID, PolicyUpsertOnly).Calculate(
+		[]*endpoint.Endpoint{a("app.example.com", "1.2.3.4")}, nil)
+
+	if changes.Suppressed != nil {
+		t.Errorf("Suppressed = %+v, want nil", changes.Suppressed)
+	}
+}
+
+func TestChanges_IsEmpty_IgnoresSuppressed(t *testing.T) {
+	c := &Changes{Suppressed: &Changes{Delete: []*endpoint.Endpoint{a("x.example.com", "1.1.1.1")}}}
+	if !c.IsEmpty() {
+		t.Error("IsEmpty() = false, want true when only suppressed changes exist")
+	}
+}
@@ -0,0 +1,22 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConflictError is returned by ApplyChanges when the backend rejected an
+// update because the records it was planned against changed in the meantime,
+// e.g. were edited by hand. Callers should re-read the records and plan
+// again rather than retry the same change set.
+type ConflictError struct {
+	// Names lists the DNS names in the rejected update.
+	Names []string
+	// Reason describes the failed check, e.g. "YXRRSET".
+	Reason string
+}
+
+// Error names the failed check and the names affected.
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("records changed concurrently (%s): %s", e.Reason, strings.Join(e.Names, ", "))
+}
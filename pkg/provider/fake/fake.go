@@ -3,6 +3,7 @@ package fake
 
 import (
 	"context"
+	"strings"
 	"sync"
 
 	"github.com/bkero/external-dns-docker/pkg/endpoint"
@@ -23,6 +24,7 @@ type Provider struct {
 	mu      sync.Mutex
 	records map[string]*endpoint.Endpoint // keyed by DNSName+RecordType
 	history []ChangeRecord
+	zones   []string // managed zones; empty means every name is managed
 }
 
 // New returns a Provider pre-loaded with the given endpoints.
@@ -73,6 +75,32 @@ func (p *Provider) ApplyChanges(_ context.Context, changes *plan.Changes) error
 	return nil
 }
 
+// SetZones restricts the names ManagesName reports as managed to the given
+// zones and their subdomains.
+func (p *Provider) SetZones(zones ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.zones = zones
+}
+
+// ManagesName reports whether name falls inside one of the zones set with
+// SetZones. Every name is managed when no zones are set.
+func (p *Provider) ManagesName(name string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.zones) == 0 {
+		return true
+	}
+	name = strings.TrimSuffix(name, ".")
+	for _, z := range p.zones {
+		z = strings.TrimSuffix(z, ".")
+		if name == z || strings.HasSuffix(name, "."+z) {
+			return true
+		}
+	}
+	return false
+}
+
 // History returns all ApplyChanges calls made so far, oldest first.
 func (p *Provider) History() []ChangeRecord {
 	p.mu.Lock()
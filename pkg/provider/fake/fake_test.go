@@ -151,3 +151,20 @@ func TestRecordCount(t *testing.T) {
 		t.Errorf("RecordCount() = %d, want 2", p.RecordCount())
 	}
 }
+
+func TestManagesName(t *testing.T) {
+	p := New(nil)
+	if !p.ManagesName("anything.example.org") {
+		t.Error("expected every name to be managed when no zones are set")
+	}
+	p.SetZones("example.com.", "2.0.192.in-addr.arpa")
+	for name, want := range map[string]bool{
+		"app.example.com":        true,
+		"5.2.0.192.in-addr.arpa": true,
+		"5.3.0.192.in-addr.arpa": false,
+	} {
+		if got := p.ManagesName(name); got != want {
+			t.Errorf("ManagesName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrLeaseConflict is returned by LeaseStore.SwapLease when the stored lease
+// is not the one the caller expected, i.e. another replica changed it first.
+var ErrLeaseConflict = errors.New("lease changed concurrently")
+
+// Lease is a leader-election lease: Holder leads until Duration has passed
+// without the lease being renewed.
+type Lease struct {
+	Holder   string
+	Renewed  time.Time
+	Duration time.Duration
+}
+
+// String encodes the lease as stored, e.g.
+// "holder=node-1 renewed=2024-01-02T03:04:05.123Z duration=15s". Encoding a
+// lease returned by ParseLease reproduces its input exactly, so stores can
+// compare encoded values.
+func (l *Lease) String() string {
+	return fmt.Sprintf("holder=%s renewed=%s duration=%s",
+		l.Holder, l.Renewed.UTC().Format(time.RFC3339Nano), l.Duration)
+}
+
+// Equal reports whether l and o encode to the same lease. Two nil leases are
+// equal.
+func (l *Lease) Equal(o *Lease) bool {
+	if l == nil || o == nil {
+		return l == o
+	}
+	return l.String() == o.String()
+}
+
+// ParseLease decodes a lease produced by Lease.String.
+func ParseLease(s string) (*Lease, error) {
+	l := &Lease{}
+	var err error
+	seen := 0
+	for _, field := range strings.Fields(s) {
+		k, v, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid lease field %q", field)
+		}
+		switch k {
+		case "holder":
+			l.Holder = v
+		case "renewed":
+			if l.Renewed, err = time.Parse(time.RFC3339Nano, v); err != nil {
+				return nil, fmt.Errorf("invalid lease renew time %q: %w", v, err)
+			}
+		case "duration":
+			if l.Duration, err = time.ParseDuration(v); err != nil {
+				return nil, fmt.Errorf("invalid lease duration %q: %w", v, err)
+			}
+		default:
+			return nil, fmt.Errorf("unknown lease field %q", k)
+		}
+		seen++
+	}
+	if seen != 3 || l.Holder == "" || l.Duration <= 0 {
+		return nil, fmt.Errorf("incomplete lease %q", s)
+	}
+	return l, nil
+}
+
+// LeaseStore is implemented by providers that can hold a leader-election
+// lease in the DNS backend itself, so replicas need no external coordinator.
+type LeaseStore interface {
+	// GetLease returns the lease stored under name, or nil if there is none.
+	GetLease(ctx context.Context, name string) (*Lease, error)
+
+	// SwapLease atomically replaces the lease old with next under name. A nil
+	// old requires that no lease exists; a nil next removes the lease. It
+	// returns ErrLeaseConflict when the stored lease is not old.
+	SwapLease(ctx context.Context, name string, old, next *Lease) error
+}
@@ -0,0 +1,46 @@
+package provider
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLease_StringRoundTrip(t *testing.T) {
+	l := &Lease{
+		Holder:   "node-1",
+		Renewed:  time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC),
+		Duration: 15 * time.Second,
+	}
+	got, err := ParseLease(l.String())
+	if err != nil {
+		t.Fatalf("ParseLease() error = %v", err)
+	}
+	if !got.Equal(l) || got.String() != l.String() {
+		t.Errorf("round trip = %q, want %q", got, l)
+	}
+}
+
+func TestParseLease_Invalid(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"holder=a renewed=2024-01-02T03:04:05Z",
+		"holder=a renewed=yesterday duration=15s",
+		"holder=a renewed=2024-01-02T03:04:05Z duration=forever",
+		"holder=a renewed=2024-01-02T03:04:05Z duration=15s color=blue",
+		"holder renewed=2024-01-02T03:04:05Z duration=15s",
+	} {
+		if _, err := ParseLease(in); err == nil {
+			t.Errorf("ParseLease(%q) = nil error, want error", in)
+		}
+	}
+}
+
+func TestLease_Equal_Nil(t *testing.T) {
+	var a, b *Lease
+	if !a.Equal(b) {
+		t.Error("nil leases should be equal")
+	}
+	if a.Equal(&Lease{Holder: "x", Duration: time.Second}) {
+		t.Error("nil lease should not equal a non-nil lease")
+	}
+}
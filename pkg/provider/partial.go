@@ -0,0 +1,42 @@
+package provider
+
+import "strings"
+
+// PartialApplyError is returned by ApplyChanges when only some of the
+// changes were applied, e.g. when one of several UPDATE messages failed after
+// the others were committed. The names in Failed were not changed; every
+// other name in the change set was.
+type PartialApplyError struct {
+	// Failed lists the record names, including ownership record names,
+	// whose changes were not applied.
+	Failed []string
+	// Err is the failure of the unapplied changes.
+	Err error
+}
+
+// Error returns the failure of the unapplied changes.
+func (e *PartialApplyError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the failure of the unapplied changes.
+func (e *PartialApplyError) Unwrap() error {
+	return e.Err
+}
+
+// Contains reports whether the changes to name were not applied. Names are
+// compared case-insensitively, with or without a trailing dot.
+func (e *PartialApplyError) Contains(name string) bool {
+	name = normaliseName(name)
+	for _, f := range e.Failed {
+		if normaliseName(f) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// normaliseName lower-cases name and strips its trailing dot.
+func normaliseName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
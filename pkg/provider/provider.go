@@ -17,3 +17,19 @@ type Provider interface {
 	// operations to the DNS backend.
 	ApplyChanges(ctx context.Context, changes *plan.Changes) error
 }
+
+// ZoneMatcher is implemented by providers that can report whether a DNS name
+// lies inside one of the zones they manage.
+type ZoneMatcher interface {
+	// ManagesName reports whether name falls inside a managed zone.
+	ManagesName(name string) bool
+}
+
+// ZoneDiscoverer is implemented by providers that can start managing zones
+// they find at runtime, rather than only those configured up front.
+type ZoneDiscoverer interface {
+	// DiscoverZones looks for the zones enclosing names that lie outside
+	// the managed zones and adds those it can manage. Names it cannot place
+	// are left alone; the provider logs why.
+	DiscoverZones(ctx context.Context, names []string)
+}
@@ -0,0 +1,285 @@
+package rfc2136
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+
+	"github.com/bkero/external-dns-docker/pkg/endpoint"
+	"github.com/bkero/external-dns-docker/pkg/plan"
+	"github.com/bkero/external-dns-docker/pkg/provider"
+)
+
+// maxUpdateBytes bounds the records carried by one UPDATE message. It stays
+// below the 64 KiB DNS message limit to leave room for the header, zone
+// section and TSIG record.
+const maxUpdateBytes = 60 * 1024
+
+// updateGroup holds the operations on one managed name: its records and
+// their ownership TXT records. A group is never split across UPDATE
+// messages, so a record is never replaced or created without its owner.
+type updateGroup struct {
+	name   string
+	remove []dns.RR
+	insert []dns.RR
+
+	// Prerequisites (RFC2136 §2.4), set only when Config.Prerequisites is.
+	used   []dns.RR // RRsets that must exist with exactly these records
+	absent []dns.RR // one record per RRset that must not exist
+	unused []dns.RR // one record per name that must hold no records at all
+}
+
+// size returns the number of RRs the group adds to a message.
+func (g *updateGroup) size() int {
+	return len(g.remove) + len(g.insert) + len(g.used) + len(g.absent) + len(g.unused)
+}
+
+// bytes returns an upper bound on the group's wire size (uncompressed).
+func (g *updateGroup) bytes() int {
+	n := 0
+	for _, rrs := range [][]dns.RR{g.remove, g.insert, g.used, g.absent, g.unused} {
+		for _, rr := range rrs {
+			n += dns.Len(rr)
+		}
+	}
+	return n
+}
+
+// updateGroups converts changes to RRs grouped by managed name, in the order
+// the names first appear. Within a group, deletes and the removal half of
+// updates precede inserts, as in a single UPDATE. With Config.Prerequisites,
+// each update requires its old RRset to be unchanged and each create requires
+// its name to be free (see addCreatePrereqs). Endpoints that cannot be
+// converted are logged and skipped.
+func (p *Provider) updateGroups(changes *plan.Changes) []*updateGroup {
+	byName := make(map[string]*updateGroup)
+	var groups []*updateGroup
+	group := func(ep *endpoint.Endpoint) *updateGroup {
+		name := strings.ToLower(strings.TrimSuffix(plan.ManagedName(ep), "."))
+		g, ok := byName[name]
+		if !ok {
+			g = &updateGroup{name: name}
+			byName[name] = g
+			groups = append(groups, g)
+		}
+		return g
+	}
+
+	// Deletes: remove exact RR (RFC2136 §2.5.4).
+	for _, ep := range changes.Delete {
+		rrs, err := p.endpointToRRs(ep)
+		if err != nil {
+			p.log.Warn("skipping delete: cannot convert endpoint to RR",
+				"endpoint", ep.DNSName, "err", err)
+			continue
+		}
+		g := group(ep)
+		g.remove = append(g.remove, rrs...)
+	}
+
+	// Updates: remove old, insert new.
+	for i, old := range changes.UpdateOld {
+		rrs, err := p.endpointToRRs(old)
+		if err != nil {
+			p.log.Warn("skipping update (remove): cannot convert endpoint to RR",
+				"endpoint", old.DNSName, "err", err)
+			continue
+		}
+		g := group(old)
+		g.remove = append(g.remove, rrs...)
+		if p.cfg.Prerequisites {
+			for _, rr := range rrs {
+				// Msg.Used and Msg.Remove rewrite the header in place, so
+				// the prerequisite needs its own copy.
+				g.used = append(g.used, dns.Copy(rr))
+			}
+		}
+		if i < len(changes.UpdateNew) {
+			newRRs, err := p.endpointToRRs(changes.UpdateNew[i])
+			if err != nil {
+				p.log.Warn("skipping update (insert): cannot convert endpoint to RR",
+					"endpoint", changes.UpdateNew[i].DNSName, "err", err)
+				continue
+			}
+			g.insert = append(g.insert, newRRs...)
+		}
+	}
+
+	// Creates: insert new RRs.
+	for _, ep := range changes.Create {
+		rrs, err := p.endpointToRRs(ep)
+		if err != nil {
+			p.log.Warn("skipping create: cannot convert endpoint to RR",
+				"endpoint", ep.DNSName, "err", err)
+			continue
+		}
+		g := group(ep)
+		g.insert = append(g.insert, rrs...)
+		if p.cfg.Prerequisites && len(rrs) > 0 {
+			g.addCreatePrereqs(rrs[0])
+		}
+	}
+	return groups
+}
+
+// addCreatePrereqs adds the prerequisites for creating the RRset of rr. A
+// CNAME cannot share its name, so the name must not be in use at all. Other
+// types may share a name with records of other types, as an AAAA record does
+// with an A record, so for them only their own RRset and a CNAME, which would
+// make the server ignore the new record, must be absent. A prerequisite on
+// records the group removes first would always fail, so it is left to the
+// RRset check then. Creates are grouped last, so g.remove is complete.
+func (g *updateGroup) addCreatePrereqs(rr dns.RR) {
+	h := rr.Header()
+	if h.Rrtype == dns.TypeCNAME {
+		if g.removes(h.Name, dns.TypeANY) {
+			g.absent = append(g.absent, rr)
+		} else {
+			g.unused = append(g.unused, rr)
+		}
+		return
+	}
+	g.absent = append(g.absent, rr)
+	if !g.removes(h.Name, dns.TypeCNAME) {
+		g.absent = append(g.absent, &dns.ANY{Hdr: dns.RR_Header{Name: h.Name, Rrtype: dns.TypeCNAME}})
+	}
+}
+
+// removes reports whether g removes a record at name of type rrtype, or of
+// any type for dns.TypeANY.
+func (g *updateGroup) removes(name string, rrtype uint16) bool {
+	for _, rr := range g.remove {
+		h := rr.Header()
+		if strings.EqualFold(h.Name, name) && (rrtype == dns.TypeANY || h.Rrtype == rrtype) {
+			return true
+		}
+	}
+	return false
+}
+
+// batchGroups packs groups, in order, into batches of at most maxRRs records
+// (0 = no limit) and maxUpdateBytes. A group larger than the limits gets a
+// batch of its own.
+func batchGroups(groups []*updateGroup, maxRRs int) [][]*updateGroup {
+	var batches [][]*updateGroup
+	var cur []*updateGroup
+	rrs, size := 0, 0
+	for _, g := range groups {
+		if g.size() == 0 {
+			continue
+		}
+		full := (maxRRs > 0 && rrs+g.size() > maxRRs) || size+g.bytes() > maxUpdateBytes
+		if len(cur) > 0 && full {
+			batches = append(batches, cur)
+			cur, rrs, size = nil, 0, 0
+		}
+		cur = append(cur, g)
+		rrs += g.size()
+		size += g.bytes()
+	}
+	if len(cur) > 0 {
+		batches = append(batches, cur)
+	}
+	return batches
+}
+
+// sendUpdate sends one UPDATE message carrying the groups.
+func (p *Provider) sendUpdate(ctx context.Context, groups []*updateGroup) error {
+	m := new(dns.Msg)
+	m.SetUpdate(dns.Fqdn(p.cfg.Zone))
+	for _, g := range groups {
+		if len(g.used) > 0 {
+			m.Used(g.used)
+		}
+		if len(g.absent) > 0 {
+			m.RRsetNotUsed(g.absent)
+		}
+		if len(g.unused) > 0 {
+			m.NameNotUsed(g.unused)
+		}
+	}
+	for _, g := range groups {
+		if len(g.remove) > 0 {
+			m.Remove(g.remove)
+		}
+		if len(g.insert) > 0 {
+			m.Insert(g.insert)
+		}
+	}
+	if err := p.sign(m); err != nil {
+		return err
+	}
+
+	r, err := p.exchange(ctx, m)
+	if err != nil {
+		return fmt.Errorf("dns update exchange: %w", err)
+	}
+	switch r.Rcode {
+	case dns.RcodeSuccess:
+		return nil
+	case dns.RcodeYXRrset, dns.RcodeNXRrset, dns.RcodeYXDomain, dns.RcodeNameError:
+		if len(m.Answer) > 0 {
+			return &provider.ConflictError{Names: groupNames(groups), Reason: dns.RcodeToString[r.Rcode]}
+		}
+	}
+	return fmt.Errorf("dns update failed: rcode %s (%d)", dns.RcodeToString[r.Rcode], r.Rcode)
+}
+
+// sendBatches sends each batch as its own UPDATE. A failed batch does not
+// stop the rest, since batches touch disjoint names; every failure is
+// returned, naming the batch and the names it carried. When some batches
+// were committed, the failures are returned as a *provider.PartialApplyError
+// naming the records that were not changed.
+func (p *Provider) sendBatches(ctx context.Context, batches [][]*updateGroup) error {
+	if len(batches) == 1 {
+		return p.sendUpdate(ctx, batches[0])
+	}
+	var (
+		errs   []error
+		failed []string
+	)
+	for i, b := range batches {
+		if err := p.sendUpdate(ctx, b); err != nil {
+			names := groupNames(b)
+			p.log.Warn("update batch failed", "zone", p.cfg.Zone, "batch", i+1, "batches", len(batches), "names", names, "err", err)
+			errs = append(errs, fmt.Errorf("update batch %d/%d (%s): %w", i+1, len(batches), strings.Join(names, ", "), err))
+			failed = append(failed, ownerNames(b)...)
+			continue
+		}
+		p.log.Debug("update batch applied", "zone", p.cfg.Zone, "server", p.servers.current(), "batch", i+1, "batches", len(batches), "names", len(b))
+	}
+	if len(errs) == 0 || len(errs) == len(batches) {
+		return errors.Join(errs...)
+	}
+	return &provider.PartialApplyError{Failed: failed, Err: errors.Join(errs...)}
+}
+
+// ownerNames returns the distinct owner names of the records groups change,
+// ownership records included.
+func ownerNames(groups []*updateGroup) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, g := range groups {
+		for _, rrs := range [][]dns.RR{g.remove, g.insert} {
+			for _, rr := range rrs {
+				if n := rr.Header().Name; !seen[n] {
+					seen[n] = true
+					names = append(names, n)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// groupNames returns the names of groups.
+func groupNames(groups []*updateGroup) []string {
+	names := make([]string, len(groups))
+	for i, g := range groups {
+		names[i] = g.name
+	}
+	return names
+}
@@ -0,0 +1,339 @@
+package rfc2136
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/bkero/external-dns-docker/pkg/endpoint"
+	"github.com/bkero/external-dns-docker/pkg/plan"
+	"github.com/bkero/external-dns-docker/pkg/provider"
+)
+
+// recordingExchanger records every message sent and answers the i-th with
+// rcodes[i], or success when rcodes is shorter.
+type recordingExchanger struct {
+	sent   []*dns.Msg
+	rcodes []int
+}
+
+func (r *recordingExchanger) ExchangeContext(_ context.Context, m *dns.Msg, _ string) (*dns.Msg, time.Duration, error) {
+	resp := successResp()
+	if i := len(r.sent); i < len(r.rcodes) {
+		resp.Rcode = r.rcodes[i]
+	}
+	r.sent = append(r.sent, m)
+	return resp, 0, nil
+}
+
+// names returns the distinct owner names in m's update section.
+func names(m *dns.Msg) map[string]bool {
+	out := make(map[string]bool)
+	for _, rr := range m.Ns {
+		out[rr.Header().Name] = true
+	}
+	return out
+}
+
+func batchProvider(maxRRs int) (*Provider, *recordingExchanger) {
+	re := &recordingExchanger{}
+	p := newWithDeps(Config{Host: "ns1.example.com", Zone: "example.com", MaxUpdateRRs: maxRRs}, nil, nil, re)
+	return p, re
+}
+
+// createWithOwner returns an A record and its ownership TXT record.
+func createWithOwner(name, ip string) []*endpoint.Endpoint {
+	return []*endpoint.Endpoint{
+		endpoint.New(name, []string{ip}, endpoint.RecordTypeA, 300, nil),
+		endpoint.New("a-external-dns-docker-owner."+name, []string{"heritage=external-dns-docker"}, endpoint.RecordTypeTXT, 300, nil),
+	}
+}
+
+func TestApplyChanges_Batch_SplitsByRRLimit(t *testing.T) {
+	p, re := batchProvider(4)
+	var creates []*endpoint.Endpoint
+	for i := range 5 {
+		creates = append(creates, createWithOwner(fmt.Sprintf("app%d.example.com", i), "10.0.0.1")...)
+	}
+
+	if err := p.ApplyChanges(context.Background(), &plan.Changes{Create: creates}); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	if len(re.sent) != 3 {
+		t.Fatalf("sent %d UPDATEs, want 3 (2+2+1 names)", len(re.sent))
+	}
+	for i, m := range re.sent {
+		if len(m.Ns) > 4 {
+			t.Errorf("UPDATE %d carries %d RRs, want at most 4", i, len(m.Ns))
+		}
+	}
+}
+
+func TestApplyChanges_Batch_KeepsRecordWithOwnerAndUpdatePairs(t *testing.T) {
+	p, re := batchProvider(3)
+	changes := &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{endpoint.New("app.example.com", []string{"10.0.0.1"}, endpoint.RecordTypeA, 300, nil)},
+		UpdateNew: []*endpoint.Endpoint{endpoint.New("app.example.com", []string{"10.0.0.2"}, endpoint.RecordTypeA, 300, nil)},
+		Create:    createWithOwner("web.example.com", "10.0.0.3"),
+	}
+
+	if err := p.ApplyChanges(context.Background(), changes); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	if len(re.sent) != 2 {
+		t.Fatalf("sent %d UPDATEs, want 2", len(re.sent))
+	}
+	if n := names(re.sent[0]); len(n) != 1 || !n["app.example.com."] || len(re.sent[0].Ns) != 2 {
+		t.Errorf("first UPDATE = %v, want the remove+insert pair for app.example.com", re.sent[0].Ns)
+	}
+	n := names(re.sent[1])
+	if !n["web.example.com."] || !n["a-external-dns-docker-owner.web.example.com."] {
+		t.Errorf("second UPDATE names = %v, want web.example.com with its ownership TXT", n)
+	}
+}
+
+func TestApplyChanges_Batch_OversizedGroupSentAlone(t *testing.T) {
+	p, re := batchProvider(2)
+	ips := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}
+	changes := &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.New("small.example.com", []string{"10.0.0.9"}, endpoint.RecordTypeA, 300, nil),
+		endpoint.New("big.example.com", ips, endpoint.RecordTypeA, 300, nil),
+	}}
+
+	if err := p.ApplyChanges(context.Background(), changes); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	if len(re.sent) != 2 || len(re.sent[1].Ns) != 3 {
+		t.Errorf("UPDATEs = %d, want the 3-RR group on its own", len(re.sent))
+	}
+}
+
+func TestApplyChanges_Batch_SplitsBySize(t *testing.T) {
+	p, re := batchProvider(0)
+	var creates []*endpoint.Endpoint
+	for i := range 400 {
+		txt := endpoint.New(fmt.Sprintf("t%d.example.com", i), []string{strings.Repeat("x", 250)}, endpoint.RecordTypeTXT, 300, nil)
+		creates = append(creates, txt)
+	}
+
+	if err := p.ApplyChanges(context.Background(), &plan.Changes{Create: creates}); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	if len(re.sent) < 2 {
+		t.Fatalf("sent %d UPDATEs, want the ~110 KB change set split", len(re.sent))
+	}
+	total := 0
+	for i, m := range re.sent {
+		if l := m.Len(); l > dns.MaxMsgSize {
+			t.Errorf("UPDATE %d is %d bytes, over the DNS limit", i, l)
+		}
+		total += len(m.Ns)
+	}
+	if total != 400 {
+		t.Errorf("sent %d RRs in total, want 400", total)
+	}
+}
+
+func TestApplyChanges_Batch_FailureReportedPerBatch(t *testing.T) {
+	p, re := batchProvider(1)
+	re.rcodes = []int{dns.RcodeSuccess, dns.RcodeRefused, dns.RcodeSuccess}
+	changes := &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.New("a.example.com", []string{"10.0.0.1"}, endpoint.RecordTypeA, 300, nil),
+		endpoint.New("b.example.com", []string{"10.0.0.2"}, endpoint.RecordTypeA, 300, nil),
+		endpoint.New("c.example.com", []string{"10.0.0.3"}, endpoint.RecordTypeA, 300, nil),
+	}}
+
+	err := p.ApplyChanges(context.Background(), changes)
+	if err == nil {
+		t.Fatal("expected an error for the refused batch")
+	}
+	if len(re.sent) != 3 {
+		t.Errorf("sent %d UPDATEs, want all 3 despite the failure", len(re.sent))
+	}
+	if msg := err.Error(); !strings.Contains(msg, "batch 2/3") || !strings.Contains(msg, "b.example.com") {
+		t.Errorf("error = %q, want it to name batch 2/3 and b.example.com", msg)
+	}
+}
+
+func TestApplyChanges_Batch_SecondOfThreeFails_ReportsCommittedBatches(t *testing.T) {
+	p, re := batchProvider(2)
+	re.rcodes = []int{dns.RcodeSuccess, dns.RcodeServerFailure, dns.RcodeSuccess}
+	var creates []*endpoint.Endpoint
+	for _, name := range []string{"a.example.com", "b.example.com", "c.example.com"} {
+		creates = append(creates, createWithOwner(name, "10.0.0.1")...)
+	}
+
+	err := p.ApplyChanges(context.Background(), &plan.Changes{Create: creates})
+	var partial *provider.PartialApplyError
+	if !errors.As(err, &partial) {
+		t.Fatalf("error = %v, want *provider.PartialApplyError", err)
+	}
+	for name, want := range map[string]bool{
+		"a.example.com": false,
+		"a-external-dns-docker-owner.a.example.com": false,
+		"b.example.com": true,
+		"a-external-dns-docker-owner.b.example.com": true,
+		"c.example.com": false,
+	} {
+		if got := partial.Contains(name); got != want {
+			t.Errorf("Contains(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestApplyChanges_Batch_EveryBatchFails_NotPartial(t *testing.T) {
+	p, re := batchProvider(1)
+	re.rcodes = []int{dns.RcodeRefused, dns.RcodeRefused}
+	changes := &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.New("a.example.com", []string{"10.0.0.1"}, endpoint.RecordTypeA, 300, nil),
+		endpoint.New("b.example.com", []string{"10.0.0.2"}, endpoint.RecordTypeA, 300, nil),
+	}}
+
+	err := p.ApplyChanges(context.Background(), changes)
+	var partial *provider.PartialApplyError
+	if err == nil || errors.As(err, &partial) {
+		t.Errorf("error = %v, want a plain error when nothing was committed", err)
+	}
+}
+
+func TestApplyChanges_Prerequisites_Attached(t *testing.T) {
+	p, re := batchProvider(0)
+	p.cfg.Prerequisites = true
+	changes := &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{endpoint.New("app.example.com", []string{"10.0.0.1"}, endpoint.RecordTypeA, 300, nil)},
+		UpdateNew: []*endpoint.Endpoint{endpoint.New("app.example.com", []string{"10.0.0.2"}, endpoint.RecordTypeA, 300, nil)},
+		Create:    []*endpoint.Endpoint{endpoint.New("web.example.com", []string{"10.0.0.3", "10.0.0.4"}, endpoint.RecordTypeA, 300, nil)},
+	}
+
+	if err := p.ApplyChanges(context.Background(), changes); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	prereqs := re.sent[0].Answer
+	if len(prereqs) != 3 {
+		t.Fatalf("prerequisites = %v, want one for the update and two for the create", prereqs)
+	}
+	used, ok := prereqs[0].(*dns.A)
+	if !ok || used.A.String() != "10.0.0.1" || used.Hdr.Class != dns.ClassINET {
+		t.Errorf("update prerequisite = %v, want the old A record (RRset exists, value dependent)", prereqs[0])
+	}
+	if h := prereqs[1].Header(); h.Name != "web.example.com." || h.Rrtype != dns.TypeA || h.Class != dns.ClassNONE {
+		t.Errorf("create prerequisite = %v, want web.example.com A RRset does not exist", prereqs[1])
+	}
+	if h := prereqs[2].Header(); h.Name != "web.example.com." || h.Rrtype != dns.TypeCNAME || h.Class != dns.ClassNONE {
+		t.Errorf("create prerequisite = %v, want web.example.com CNAME RRset does not exist", prereqs[2])
+	}
+	// The removal of the old record must not be affected by the prerequisite.
+	if h := re.sent[0].Ns[0].Header(); h.Class != dns.ClassNONE {
+		t.Errorf("update section starts with %v, want the delete of the old record", re.sent[0].Ns[0])
+	}
+}
+
+func TestApplyChanges_Prerequisites_OtherTypeAtName_NotRequiredAbsent(t *testing.T) {
+	// An A record already exists at app.example.com; creating its AAAA
+	// record must not require the whole name to be unused.
+	p, re := batchProvider(0)
+	p.cfg.Prerequisites = true
+	err := p.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.New("app.example.com", []string{"2001:db8::1"}, endpoint.RecordTypeAAAA, 300, nil)},
+	})
+	if err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	for _, rr := range re.sent[0].Answer {
+		if h := rr.Header(); h.Rrtype == dns.TypeANY || h.Rrtype == dns.TypeA {
+			t.Errorf("prerequisite %v would reject the existing A record", rr)
+		}
+	}
+	if len(re.sent[0].Answer) != 2 {
+		t.Errorf("prerequisites = %v, want AAAA and CNAME RRsets absent", re.sent[0].Answer)
+	}
+}
+
+func TestApplyChanges_Prerequisites_CNAMECreate_NameNotUsed(t *testing.T) {
+	p, re := batchProvider(0)
+	p.cfg.Prerequisites = true
+	err := p.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.New("www.example.com", []string{"app.example.com"}, endpoint.RecordTypeCNAME, 300, nil)},
+	})
+	if err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	prereqs := re.sent[0].Answer
+	if len(prereqs) != 1 {
+		t.Fatalf("prerequisites = %v, want one", prereqs)
+	}
+	if h := prereqs[0].Header(); h.Name != "www.example.com." || h.Rrtype != dns.TypeANY || h.Class != dns.ClassNONE {
+		t.Errorf("prerequisite = %v, want www.example.com name not in use", prereqs[0])
+	}
+}
+
+func TestApplyChanges_Prerequisites_TypeSwitch_OnlyNewRRsetAbsent(t *testing.T) {
+	// Replacing an A record with a CNAME removes the A first, so the name
+	// is in use when the prerequisites are checked.
+	p, re := batchProvider(0)
+	p.cfg.Prerequisites = true
+	err := p.ApplyChanges(context.Background(), &plan.Changes{
+		Delete: []*endpoint.Endpoint{endpoint.New("www.example.com", []string{"10.0.0.1"}, endpoint.RecordTypeA, 300, nil)},
+		Create: []*endpoint.Endpoint{endpoint.New("www.example.com", []string{"app.example.com"}, endpoint.RecordTypeCNAME, 300, nil)},
+	})
+	if err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	prereqs := re.sent[0].Answer
+	if len(prereqs) != 1 || prereqs[0].Header().Rrtype != dns.TypeCNAME {
+		t.Errorf("prerequisites = %v, want only the CNAME RRset absent", prereqs)
+	}
+}
+
+func TestApplyChanges_Prerequisites_OffByDefault(t *testing.T) {
+	p, re := batchProvider(0)
+	err := p.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.New("web.example.com", []string{"10.0.0.3"}, endpoint.RecordTypeA, 300, nil)},
+	})
+	if err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	if len(re.sent[0].Answer) != 0 {
+		t.Errorf("prerequisites = %v, want none", re.sent[0].Answer)
+	}
+}
+
+func TestApplyChanges_Prerequisites_FailureIsConflict(t *testing.T) {
+	for _, rcode := range []int{dns.RcodeYXRrset, dns.RcodeNXRrset} {
+		p, re := batchProvider(0)
+		p.cfg.Prerequisites = true
+		re.rcodes = []int{rcode}
+
+		err := p.ApplyChanges(context.Background(), &plan.Changes{
+			Create: createWithOwner("web.example.com", "10.0.0.3"),
+		})
+		var conflict *provider.ConflictError
+		if !errors.As(err, &conflict) {
+			t.Fatalf("rcode %s: error = %v, want *provider.ConflictError", dns.RcodeToString[rcode], err)
+		}
+		if len(conflict.Names) != 1 || conflict.Names[0] != "web.example.com" {
+			t.Errorf("conflict names = %v, want [web.example.com]", conflict.Names)
+		}
+	}
+}
+
+func TestApplyChanges_Prerequisites_ConflictInOneBatch(t *testing.T) {
+	p, re := batchProvider(2)
+	p.cfg.Prerequisites = true
+	re.rcodes = []int{dns.RcodeSuccess, dns.RcodeYXRrset}
+	changes := &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.New("a.example.com", []string{"10.0.0.1"}, endpoint.RecordTypeA, 300, nil),
+		endpoint.New("b.example.com", []string{"10.0.0.2"}, endpoint.RecordTypeA, 300, nil),
+	}}
+
+	err := p.ApplyChanges(context.Background(), changes)
+	var conflict *provider.ConflictError
+	if !errors.As(err, &conflict) || conflict.Names[0] != "b.example.com" {
+		t.Errorf("error = %v, want a conflict for b.example.com", err)
+	}
+}
@@ -0,0 +1,281 @@
+package rfc2136
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Zone cache metrics, registered on the default registry.
+var (
+	zoneCacheTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "external_dns_docker_zone_cache_total",
+		Help: "Total number of zone reads, by result: hit (SOA serial unchanged) or miss (zone transferred).",
+	}, []string{"zone", "result"})
+
+	zoneTransfersTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "external_dns_docker_zone_transfers_total",
+		Help: "Total number of zone transfers, by type: ixfr (incremental) or axfr (full zone).",
+	}, []string{"zone", "type"})
+)
+
+// errIXFRUnusable reports an IXFR response that cannot be applied to the
+// cached zone, so a full transfer is needed.
+var errIXFRUnusable = errors.New("ixfr response not usable")
+
+// zoneCache is the zone content as of SOA serial. rrs holds every record
+// except the SOA, in transfer order.
+type zoneCache struct {
+	serial uint32
+	soa    *dns.SOA
+	rrs    []dns.RR
+}
+
+// zoneRRs returns the zone's records, reading them from the cache when the
+// zone's SOA serial is unchanged. Otherwise it fetches the changes since the
+// cached serial with IXFR (RFC 1995) and falls back to a full AXFR when
+// there is no cache yet or the IXFR fails. The caller must hold p.cacheMu.
+func (p *Provider) zoneRRs(ctx context.Context) ([]dns.RR, error) {
+	zone := dns.Fqdn(p.cfg.Zone)
+	if p.cache == nil {
+		zoneCacheTotal.WithLabelValues(zone, "miss").Inc()
+		return p.axfr(ctx)
+	}
+
+	serial, err := p.querySerial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if serial == p.cache.serial {
+		zoneCacheTotal.WithLabelValues(zone, "hit").Inc()
+		return p.cache.rrs, nil
+	}
+	zoneCacheTotal.WithLabelValues(zone, "miss").Inc()
+
+	rrs, err := p.ixfr(ctx)
+	if err == nil {
+		return rrs, nil
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	p.log.Debug("ixfr failed, falling back to axfr", "zone", zone, "from_serial", p.cache.serial, "err", err)
+	return p.axfr(ctx)
+}
+
+// querySerial returns the zone's current SOA serial.
+func (p *Provider) querySerial(ctx context.Context) (uint32, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(p.cfg.Zone), dns.TypeSOA)
+	if err := p.sign(m); err != nil {
+		return 0, err
+	}
+	r, err := p.exchange(ctx, m)
+	if err != nil {
+		return 0, fmt.Errorf("soa query %s: %w", p.cfg.Zone, err)
+	}
+	if r.Rcode != dns.RcodeSuccess {
+		return 0, fmt.Errorf("soa query %s failed: rcode %s (%d)", p.cfg.Zone, dns.RcodeToString[r.Rcode], r.Rcode)
+	}
+	for _, rr := range r.Answer {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa.Serial, nil
+		}
+	}
+	return 0, fmt.Errorf("soa query %s: no SOA in answer", p.cfg.Zone)
+}
+
+// axfr transfers the whole zone and replaces the cache with it.
+func (p *Provider) axfr(ctx context.Context) ([]dns.RR, error) {
+	m := new(dns.Msg)
+	m.SetAxfr(dns.Fqdn(p.cfg.Zone))
+	if err := p.sign(m); err != nil {
+		return nil, err
+	}
+	all, err := p.transfer(ctx, m)
+	if err != nil {
+		return nil, fmt.Errorf("axfr %s: %w", p.cfg.Zone, err)
+	}
+	zoneTransfersTotal.WithLabelValues(dns.Fqdn(p.cfg.Zone), "axfr").Inc()
+
+	c := &zoneCache{}
+	for _, rr := range all {
+		if soa, ok := rr.(*dns.SOA); ok {
+			if c.soa == nil {
+				c.soa, c.serial = soa, soa.Serial
+			}
+			continue
+		}
+		c.rrs = append(c.rrs, rr)
+	}
+	// Without a SOA there is no serial to compare against, so the result is
+	// not cached.
+	if c.soa != nil {
+		p.cache = c
+	}
+	return c.rrs, nil
+}
+
+// ixfr fetches the changes since the cached serial and applies them to the
+// cache. A server without the history answers with the full zone in AXFR
+// form (RFC 1995 §4), which replaces the cache instead.
+func (p *Provider) ixfr(ctx context.Context) ([]dns.RR, error) {
+	zone := dns.Fqdn(p.cfg.Zone)
+	m := new(dns.Msg)
+	m.SetIxfr(zone, p.cache.serial, p.cache.soa.Ns, p.cache.soa.Mbox)
+	if err := p.sign(m); err != nil {
+		return nil, err
+	}
+	all, err := p.transfer(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(all) == 0 {
+		return nil, errIXFRUnusable
+	}
+	newSOA, ok := all[0].(*dns.SOA)
+	if !ok {
+		return nil, errIXFRUnusable
+	}
+	if len(all) == 1 {
+		// The server has nothing newer than the cached serial.
+		if newSOA.Serial != p.cache.serial {
+			return nil, errIXFRUnusable
+		}
+		zoneTransfersTotal.WithLabelValues(zone, "ixfr").Inc()
+		return p.cache.rrs, nil
+	}
+	last, ok := all[len(all)-1].(*dns.SOA)
+	if !ok || last.Serial != newSOA.Serial {
+		return nil, errIXFRUnusable
+	}
+
+	body := all[1 : len(all)-1]
+	var first *dns.SOA
+	if len(body) > 0 {
+		first, _ = body[0].(*dns.SOA)
+	}
+	if first == nil {
+		// AXFR-form reply: the full zone between two copies of the SOA.
+		zoneTransfersTotal.WithLabelValues(zone, "axfr").Inc()
+		c := &zoneCache{serial: newSOA.Serial, soa: newSOA}
+		for _, rr := range body {
+			if _, isSOA := rr.(*dns.SOA); !isSOA {
+				c.rrs = append(c.rrs, rr)
+			}
+		}
+		p.cache = c
+		return c.rrs, nil
+	}
+	if first.Serial != p.cache.serial {
+		return nil, errIXFRUnusable
+	}
+
+	// Incremental reply: a sequence of differences, each the old SOA, the
+	// records it removed, the new SOA and the records it added.
+	rrs := slices.Clone(p.cache.rrs)
+	var removed []dns.RR
+	deleting := false
+	for _, rr := range body {
+		if _, isSOA := rr.(*dns.SOA); isSOA {
+			if deleting {
+				rrs = removeRRs(rrs, removed)
+				removed = nil
+			}
+			deleting = !deleting
+			continue
+		}
+		if deleting {
+			removed = append(removed, rr)
+		} else {
+			rrs = append(rrs, rr)
+		}
+	}
+	if deleting {
+		return nil, errIXFRUnusable
+	}
+	zoneTransfersTotal.WithLabelValues(zone, "ixfr").Inc()
+	p.cache = &zoneCache{serial: newSOA.Serial, soa: newSOA, rrs: rrs}
+	return rrs, nil
+}
+
+// transfer runs the AXFR or IXFR request m against the zone's servers in
+// failover order and returns every record received. A server that answers
+// with an error rcode is not skipped: the others would most likely refuse too.
+// A rejected TSIG key is retried with the zone's other keys, as in exchange.
+func (p *Provider) transfer(ctx context.Context, m *dns.Msg) ([]dns.RR, error) {
+	var all []dns.RR
+	err := p.keys.try(m, func() error {
+		return p.servers.try(ctx, func(addr string) error {
+			rrs, err := p.transferFrom(ctx, m, addr)
+			if errors.Is(err, dns.ErrAuth) {
+				return errTSIGRejected
+			}
+			var derr *dns.Error
+			if err != nil && !errors.As(err, &derr) {
+				return &unavailableError{err}
+			}
+			all = rrs
+			return err
+		})
+	})
+	return all, err
+}
+
+// transferFrom runs the transfer request m against addr. Errors the server
+// reported (bad rcode, malformed reply) are returned as *dns.Error.
+func (p *Provider) transferFrom(ctx context.Context, m *dns.Msg, addr string) ([]dns.RR, error) {
+	env, err := p.newTransferer().In(m, addr)
+	if err != nil {
+		return nil, err
+	}
+	var all []dns.RR
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case e, ok := <-env:
+			if !ok {
+				return all, nil
+			}
+			if e.Error != nil {
+				return nil, e.Error
+			}
+			all = append(all, e.RR...)
+		}
+	}
+}
+
+// removeRRs returns rrs without the records in removed, compared by name,
+// type, class and data but not TTL.
+func removeRRs(rrs, removed []dns.RR) []dns.RR {
+	if len(removed) == 0 {
+		return rrs
+	}
+	drop := make(map[string]bool, len(removed))
+	for _, rr := range removed {
+		drop[rrKey(rr)] = true
+	}
+	out := make([]dns.RR, 0, len(rrs))
+	for _, rr := range rrs {
+		if !drop[rrKey(rr)] {
+			out = append(out, rr)
+		}
+	}
+	return out
+}
+
+// rrKey identifies rr by everything except its TTL.
+func rrKey(rr dns.RR) string {
+	cp := dns.Copy(rr)
+	cp.Header().Ttl = 0
+	cp.Header().Name = strings.ToLower(cp.Header().Name)
+	return cp.String()
+}
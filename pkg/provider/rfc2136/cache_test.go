@@ -0,0 +1,235 @@
+package rfc2136
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// scriptedTransferer answers successive transfers with successive replies
+// and records each request.
+type scriptedTransferer struct {
+	replies [][]dns.RR
+	errs    []error
+	reqs    []*dns.Msg
+}
+
+func (s *scriptedTransferer) In(m *dns.Msg, _ string) (chan *dns.Envelope, error) {
+	i := len(s.reqs)
+	s.reqs = append(s.reqs, m)
+	if i < len(s.errs) && s.errs[i] != nil {
+		return nil, s.errs[i]
+	}
+	ch := make(chan *dns.Envelope, 1)
+	if i < len(s.replies) {
+		ch <- &dns.Envelope{RR: s.replies[i]}
+	}
+	close(ch)
+	return ch, nil
+}
+
+// qtype returns the question type of the i-th transfer request.
+func (s *scriptedTransferer) qtype(i int) uint16 {
+	return s.reqs[i].Question[0].Qtype
+}
+
+func soaRR(serial uint32) *dns.SOA {
+	return &dns.SOA{
+		Hdr:    dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 3600},
+		Ns:     "ns1.example.com.",
+		Mbox:   "hostmaster.example.com.",
+		Serial: serial,
+	}
+}
+
+func aRR(name, ip string) *dns.A {
+	return &dns.A{
+		Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300},
+		A:   net.ParseIP(ip),
+	}
+}
+
+// soaResp returns a SOA query answer carrying serial.
+func soaResp(serial uint32) *dns.Msg {
+	r := successResp()
+	r.Answer = []dns.RR{soaRR(serial)}
+	return r
+}
+
+// targets returns "name=target" for each endpoint Records returns.
+func targets(t *testing.T, p *Provider) map[string]bool {
+	t.Helper()
+	eps, err := p.Records(context.Background())
+	if err != nil {
+		t.Fatalf("Records() error = %v", err)
+	}
+	out := make(map[string]bool, len(eps))
+	for _, ep := range eps {
+		out[ep.DNSName+"="+ep.Targets[0]] = true
+	}
+	return out
+}
+
+func TestRecords_Cache_HitWhenSerialUnchanged(t *testing.T) {
+	st := &scriptedTransferer{replies: [][]dns.RR{
+		{soaRR(1), aRR("app.example.com.", "10.0.0.1"), soaRR(1)},
+	}}
+	me := &mockExchanger{resp: soaResp(1)}
+	p := testProvider(nil, me)
+	p.newTransferer = func() dnsTransferer { return st }
+	hits := testutil.ToFloat64(zoneCacheTotal.WithLabelValues("example.com.", "hit"))
+
+	targets(t, p)
+	got := targets(t, p)
+
+	if len(st.reqs) != 1 {
+		t.Errorf("made %d transfers, want 1", len(st.reqs))
+	}
+	if !got["app.example.com=10.0.0.1"] {
+		t.Errorf("cached records = %v, want app.example.com", got)
+	}
+	if me.sent.Question[0].Qtype != dns.TypeSOA {
+		t.Error("serial was not checked with a SOA query")
+	}
+	if d := testutil.ToFloat64(zoneCacheTotal.WithLabelValues("example.com.", "hit")) - hits; d != 1 {
+		t.Errorf("cache hits grew by %v, want 1", d)
+	}
+}
+
+func TestRecords_Cache_AppliesIXFRDelta(t *testing.T) {
+	st := &scriptedTransferer{replies: [][]dns.RR{
+		{soaRR(1), aRR("app.example.com.", "10.0.0.1"), aRR("db.example.com.", "10.0.0.5"), soaRR(1)},
+		{
+			soaRR(3),
+			soaRR(1), aRR("app.example.com.", "10.0.0.1"), soaRR(2), aRR("app.example.com.", "10.0.0.2"),
+			soaRR(2), soaRR(3), aRR("web.example.com.", "10.0.0.3"),
+			soaRR(3),
+		},
+	}}
+	me := &mockExchanger{resp: soaResp(1)}
+	p := testProvider(nil, me)
+	p.newTransferer = func() dnsTransferer { return st }
+
+	targets(t, p)
+	me.resp = soaResp(3)
+	got := targets(t, p)
+
+	if len(st.reqs) != 2 || st.qtype(1) != dns.TypeIXFR {
+		t.Fatalf("second transfer was not an IXFR")
+	}
+	if serial := st.reqs[1].Ns[0].(*dns.SOA).Serial; serial != 1 {
+		t.Errorf("IXFR asked from serial %d, want 1", serial)
+	}
+	want := []string{"app.example.com=10.0.0.2", "db.example.com=10.0.0.5", "web.example.com=10.0.0.3"}
+	if len(got) != len(want) {
+		t.Errorf("records = %v, want %v", got, want)
+	}
+	for _, w := range want {
+		if !got[w] {
+			t.Errorf("missing %s in %v", w, got)
+		}
+	}
+	if p.cache.serial != 3 {
+		t.Errorf("cached serial = %d, want 3", p.cache.serial)
+	}
+}
+
+func TestRecords_Cache_IXFRInAXFRFormReplacesCache(t *testing.T) {
+	st := &scriptedTransferer{replies: [][]dns.RR{
+		{soaRR(1), aRR("app.example.com.", "10.0.0.1"), soaRR(1)},
+		{soaRR(7), aRR("new.example.com.", "10.0.0.7"), soaRR(7)},
+	}}
+	me := &mockExchanger{resp: soaResp(1)}
+	p := testProvider(nil, me)
+	p.newTransferer = func() dnsTransferer { return st }
+
+	targets(t, p)
+	me.resp = soaResp(7)
+	got := targets(t, p)
+
+	if len(got) != 1 || !got["new.example.com=10.0.0.7"] {
+		t.Errorf("records = %v, want only new.example.com", got)
+	}
+	if len(st.reqs) != 2 {
+		t.Errorf("made %d transfers, want 2", len(st.reqs))
+	}
+}
+
+func TestRecords_Cache_IXFRFailureFallsBackToAXFR(t *testing.T) {
+	st := &scriptedTransferer{
+		replies: [][]dns.RR{
+			{soaRR(1), aRR("app.example.com.", "10.0.0.1"), soaRR(1)},
+			nil,
+			{soaRR(2), aRR("app.example.com.", "10.0.0.2"), soaRR(2)},
+		},
+		errs: []error{nil, errors.New("refused")},
+	}
+	me := &mockExchanger{resp: soaResp(1)}
+	p := testProvider(nil, me)
+	p.newTransferer = func() dnsTransferer { return st }
+
+	targets(t, p)
+	me.resp = soaResp(2)
+	got := targets(t, p)
+
+	if len(st.reqs) != 3 || st.qtype(1) != dns.TypeIXFR || st.qtype(2) != dns.TypeAXFR {
+		t.Fatalf("transfers = %d, want IXFR then AXFR fallback", len(st.reqs))
+	}
+	if !got["app.example.com=10.0.0.2"] || len(got) != 1 {
+		t.Errorf("records = %v, want app.example.com=10.0.0.2", got)
+	}
+}
+
+func TestRecords_Cache_IncompleteIXFRFallsBackToAXFR(t *testing.T) {
+	st := &scriptedTransferer{replies: [][]dns.RR{
+		{soaRR(1), aRR("app.example.com.", "10.0.0.1"), soaRR(1)},
+		// Ends while still listing deletions.
+		{soaRR(2), soaRR(1), aRR("app.example.com.", "10.0.0.1"), soaRR(2)},
+		{soaRR(2), soaRR(2)},
+	}}
+	me := &mockExchanger{resp: soaResp(1)}
+	p := testProvider(nil, me)
+	p.newTransferer = func() dnsTransferer { return st }
+
+	targets(t, p)
+	me.resp = soaResp(2)
+	got := targets(t, p)
+
+	if len(st.reqs) != 3 || st.qtype(2) != dns.TypeAXFR {
+		t.Fatalf("transfers = %d, want AXFR fallback", len(st.reqs))
+	}
+	if len(got) != 0 {
+		t.Errorf("records = %v, want none", got)
+	}
+}
+
+func TestRecords_Cache_SOAQueryError(t *testing.T) {
+	st := &scriptedTransferer{replies: [][]dns.RR{
+		{soaRR(1), aRR("app.example.com.", "10.0.0.1"), soaRR(1)},
+	}}
+	me := &mockExchanger{resp: soaResp(1)}
+	p := testProvider(nil, me)
+	p.newTransferer = func() dnsTransferer { return st }
+
+	targets(t, p)
+	me.err = errors.New("timeout")
+	if _, err := p.Records(context.Background()); err == nil {
+		t.Error("expected an error when the SOA query fails")
+	}
+}
+
+func TestRemoveRRs_IgnoresTTLAndNameCase(t *testing.T) {
+	keep := aRR("db.example.com.", "10.0.0.5")
+	rrs := []dns.RR{aRR("app.example.com.", "10.0.0.1"), keep}
+	gone := aRR("APP.example.com.", "10.0.0.1")
+	gone.Hdr.Ttl = 60
+
+	got := removeRRs(rrs, []dns.RR{gone})
+	if len(got) != 1 || got[0] != keep {
+		t.Errorf("removeRRs = %v, want only db.example.com", got)
+	}
+}
@@ -0,0 +1,190 @@
+package rfc2136
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// discoveryRetry is how long a name whose zone could not be found or added
+// waits before it is looked up again.
+const discoveryRetry = 5 * time.Minute
+
+// DiscoveryConfig enables automatic zone discovery on a MultiProvider.
+type DiscoveryConfig struct {
+	// Resolver is the "host" or "host:port" of the server asked for SOA
+	// records, typically a recursive resolver.
+	Resolver string
+	// Templates configure the zones found. The template whose Zone is the
+	// longest suffix of the zone found applies; a template with an empty
+	// Zone applies to every zone. Zones no template matches are not added.
+	// Templates without Host or Hosts send updates to the primary server
+	// named in the zone's SOA record.
+	Templates []ZoneConfig
+	Timeout   time.Duration // SOA query timeout; 0 uses defaultTimeout (10s)
+}
+
+// discoverer finds the zones enclosing names outside a MultiProvider's
+// zones.
+type discoverer struct {
+	resolver    string
+	templates   []ZoneConfig
+	exchanger   dnsExchanger
+	newProvider func(Config) *Provider
+	now         func() time.Time
+
+	mu    sync.Mutex           // serialises DiscoverZones
+	retry map[string]time.Time // name → when it may be looked up again
+}
+
+// EnableDiscovery makes m look up the zones of names it does not manage
+// (see DiscoverZones). It must be called before m is used.
+func (m *MultiProvider) EnableDiscovery(cfg DiscoveryConfig) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	m.discovery = &discoverer{
+		resolver:    serverAddrs(Config{Host: cfg.Resolver, Port: 53})[0],
+		templates:   cfg.Templates,
+		exchanger:   newExchanger(Config{Transport: TransportUDP, Timeout: cfg.Timeout}, nil),
+		newProvider: func(c Config) *Provider { return New(c, m.log) },
+		now:         time.Now,
+		retry:       make(map[string]time.Time),
+	}
+}
+
+// DiscoverZones implements provider.ZoneDiscoverer. For each name outside
+// the managed zones it finds the enclosing zone by walking up the name's
+// labels with SOA queries, then starts managing that zone with the settings
+// of the matching template, once a preflight check against it passes. Names
+// whose zone cannot be found or added are retried after discoveryRetry, as
+// long as they are still passed in. It does nothing unless EnableDiscovery
+// was called.
+func (m *MultiProvider) DiscoverZones(ctx context.Context, names []string) {
+	d := m.discovery
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	fqdns := make([]string, 0, len(names))
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = dns.Fqdn(strings.ToLower(name))
+		fqdns = append(fqdns, name)
+		wanted[name] = true
+	}
+	// Names no longer asked for need no retry.
+	for name := range d.retry {
+		if !wanted[name] {
+			delete(d.retry, name)
+		}
+	}
+
+	for _, name := range fqdns {
+		if m.zoneFor(name) != nil || d.now().Before(d.retry[name]) {
+			continue
+		}
+		if err := m.discover(ctx, name); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			m.log.Warn("zone discovery failed", "name", name, "retry", discoveryRetry, "err", err)
+			d.retry[name] = d.now().Add(discoveryRetry)
+			continue
+		}
+		delete(d.retry, name)
+	}
+}
+
+// discover finds and adds the zone enclosing name.
+func (m *MultiProvider) discover(ctx context.Context, name string) error {
+	d := m.discovery
+	zone, primary, err := d.lookupZone(ctx, name)
+	if err != nil {
+		return err
+	}
+	zc, ok := d.template(zone)
+	if !ok {
+		return fmt.Errorf("no discovery template matches zone %s", zone)
+	}
+	zc.Zone = zone
+	if zc.Host == "" && len(zc.Hosts) == 0 {
+		zc.Host = strings.TrimSuffix(primary, ".")
+	}
+	prov := d.newProvider(zc.config())
+	if err := prov.Preflight(ctx); err != nil {
+		return fmt.Errorf("zone %s: %w", zone, err)
+	}
+
+	m.mu.Lock()
+	// Copy on write: callers may still hold the slice entries returned.
+	zones := make([]zoneEntry, len(m.zones), len(m.zones)+1)
+	copy(zones, m.zones)
+	m.zones = append(zones, zoneEntry{zone: zone, prov: prov})
+	m.mu.Unlock()
+
+	m.log.Info("discovered zone", "zone", zone, "name", name, "servers", prov.servers.String())
+	return nil
+}
+
+// lookupZone returns the zone enclosing name and its primary server, as
+// named in the zone's SOA record. It queries each of name's ancestors for
+// their SOA in turn, stopping early when a negative answer carries the SOA
+// of an enclosing zone. Top-level domains are never returned.
+func (d *discoverer) lookupZone(ctx context.Context, name string) (zone, primary string, err error) {
+	for cand := name; dns.CountLabel(cand) >= 2; {
+		m := new(dns.Msg)
+		m.SetQuestion(cand, dns.TypeSOA)
+		r, _, err := d.exchanger.ExchangeContext(ctx, m, d.resolver)
+		if err != nil {
+			return "", "", fmt.Errorf("SOA query for %s to %s: %w", cand, d.resolver, err)
+		}
+		if r.Rcode != dns.RcodeSuccess && r.Rcode != dns.RcodeNameError {
+			return "", "", fmt.Errorf("SOA query for %s to %s: %s", cand, d.resolver, dns.RcodeToString[r.Rcode])
+		}
+		for _, rr := range r.Answer {
+			if soa, ok := rr.(*dns.SOA); ok && strings.EqualFold(soa.Hdr.Name, cand) {
+				return cand, soa.Ns, nil
+			}
+		}
+		for _, rr := range r.Ns {
+			soa, ok := rr.(*dns.SOA)
+			if !ok || !dns.IsSubDomain(soa.Hdr.Name, cand) {
+				continue
+			}
+			if dns.CountLabel(soa.Hdr.Name) < 2 {
+				return "", "", fmt.Errorf("%s has no enclosing zone below %s", name, soa.Hdr.Name)
+			}
+			return strings.ToLower(soa.Hdr.Name), soa.Ns, nil
+		}
+		off, _ := dns.NextLabel(cand, 0)
+		cand = cand[off:]
+	}
+	return "", "", errors.New("no enclosing zone found")
+}
+
+// template returns the template for zone: the one whose Zone is its longest
+// suffix, else the one with an empty Zone.
+func (d *discoverer) template(zone string) (ZoneConfig, bool) {
+	var best ZoneConfig
+	found, bestLen := false, -1
+	for _, t := range d.templates {
+		suffix := dns.Fqdn(t.Zone)
+		if t.Zone == "" {
+			suffix = ""
+		} else if !dns.IsSubDomain(suffix, zone) {
+			continue
+		}
+		if len(suffix) > bestLen {
+			best, found, bestLen = t, true, len(suffix)
+		}
+	}
+	return best, found
+}
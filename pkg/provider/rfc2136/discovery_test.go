@@ -0,0 +1,212 @@
+package rfc2136
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// resolverStub answers SOA queries for the zones it knows, mapping each zone
+// to its primary server. Names below a zone get NXDOMAIN, with the zone's
+// SOA in the authority section unless bare is set, as a recursive resolver
+// would answer.
+type resolverStub struct {
+	zones map[string]string
+	bare  bool
+	asked []string
+}
+
+func (r *resolverStub) ExchangeContext(_ context.Context, m *dns.Msg, _ string) (*dns.Msg, time.Duration, error) {
+	qname := m.Question[0].Name
+	r.asked = append(r.asked, qname)
+	resp := new(dns.Msg)
+	resp.SetReply(m)
+	soa := func(zone string) *dns.SOA {
+		return &dns.SOA{
+			Hdr: dns.RR_Header{Name: zone, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 3600},
+			Ns:  r.zones[zone],
+		}
+	}
+	if _, ok := r.zones[qname]; ok {
+		resp.Answer = []dns.RR{soa(qname)}
+		return resp, 0, nil
+	}
+	resp.Rcode = dns.RcodeNameError
+	for zone := range r.zones {
+		if !r.bare && dns.IsSubDomain(zone, qname) {
+			resp.Ns = []dns.RR{soa(zone)}
+		}
+	}
+	return resp, 0, nil
+}
+
+// discoveryProvider returns a MultiProvider managing example.com with
+// discovery enabled through rs. Zones found get a provider whose preflight
+// answers with preflightRcode; their configs are appended to built.
+func discoveryProvider(rs *resolverStub, preflightRcode int, templates ...ZoneConfig) (*MultiProvider, *[]Config, *time.Time) {
+	m := newMultiWithDeps([]ZoneConfig{{Host: "ns1.example.com", Port: 53, Zone: "example.com"}}, nil, nil)
+	m.EnableDiscovery(DiscoveryConfig{Resolver: "192.0.2.53", Templates: templates})
+	built := &[]Config{}
+	now := time.Unix(1_000_000, 0)
+	m.discovery.exchanger = rs
+	m.discovery.now = func() time.Time { return now }
+	m.discovery.newProvider = func(cfg Config) *Provider {
+		*built = append(*built, cfg)
+		resp := successResp()
+		resp.Rcode = preflightRcode
+		return newWithDeps(cfg, nil, nil, &mockExchanger{resp: resp})
+	}
+	return m, built, &now
+}
+
+func TestEnableDiscovery_ResolverDefaultPort(t *testing.T) {
+	m := newMultiWithDeps(nil, nil, nil)
+	m.EnableDiscovery(DiscoveryConfig{Resolver: "192.0.2.53"})
+	if m.discovery.resolver != "192.0.2.53:53" {
+		t.Errorf("resolver = %q, want 192.0.2.53:53", m.discovery.resolver)
+	}
+}
+
+func TestDiscoverZones_WalksUpToZone(t *testing.T) {
+	rs := &resolverStub{zones: map[string]string{"new.example.org.": "ns1.new.example.org."}, bare: true}
+	m, built, _ := discoveryProvider(rs, dns.RcodeSuccess, ZoneConfig{TSIGKey: "k", TSIGSecret: "s"})
+
+	m.DiscoverZones(context.Background(), []string{"app.example.com", "web.svc.new.example.org"})
+
+	if !m.ManagesName("web.svc.new.example.org") {
+		t.Fatal("new.example.org was not added")
+	}
+	want := []string{"web.svc.new.example.org.", "svc.new.example.org.", "new.example.org."}
+	if !slices.Equal(rs.asked, want) {
+		t.Errorf("SOA queries = %v, want %v (nothing for the managed name)", rs.asked, want)
+	}
+	cfg := (*built)[0]
+	if cfg.Zone != "new.example.org." || cfg.Host != "ns1.new.example.org" || cfg.TSIGKeyName != "k" {
+		t.Errorf("zone config = %+v, want new.example.org. on its SOA primary with the template key", cfg)
+	}
+}
+
+func TestDiscoverZones_AuthoritySOA_ShortCircuits(t *testing.T) {
+	rs := &resolverStub{zones: map[string]string{"new.example.org.": "ns1.new.example.org."}}
+	m, _, _ := discoveryProvider(rs, dns.RcodeSuccess, ZoneConfig{})
+
+	m.DiscoverZones(context.Background(), []string{"web.svc.new.example.org"})
+
+	if !m.ManagesName("web.svc.new.example.org") {
+		t.Fatal("new.example.org was not added")
+	}
+	if len(rs.asked) != 1 {
+		t.Errorf("SOA queries = %v, want one: the negative answer names the zone", rs.asked)
+	}
+}
+
+func TestDiscoverZones_SecondNameInZone_NoLookup(t *testing.T) {
+	rs := &resolverStub{zones: map[string]string{"new.example.org.": "ns1.new.example.org."}}
+	m, built, _ := discoveryProvider(rs, dns.RcodeSuccess, ZoneConfig{})
+
+	m.DiscoverZones(context.Background(), []string{"a.new.example.org", "b.new.example.org"})
+
+	if len(rs.asked) != 1 || len(*built) != 1 {
+		t.Errorf("SOA queries = %v, zones built = %d; want the zone found once", rs.asked, len(*built))
+	}
+}
+
+func TestDiscoverZones_LongestTemplateWins(t *testing.T) {
+	rs := &resolverStub{zones: map[string]string{"new.example.org.": "ns1.new.example.org."}}
+	m, built, _ := discoveryProvider(rs, dns.RcodeSuccess,
+		ZoneConfig{Host: "ns.default.net"},
+		ZoneConfig{Zone: "example.org", Host: "ns.example.org", Hosts: []string{"ns.example.org", "ns2.example.org"}},
+		ZoneConfig{Zone: "other.org", Host: "ns.other.org"},
+	)
+
+	m.DiscoverZones(context.Background(), []string{"app.new.example.org"})
+
+	if len(*built) != 1 {
+		t.Fatalf("zones built = %d, want 1", len(*built))
+	}
+	if got := (*built)[0].Hosts; strings.Join(got, " ") != "ns.example.org ns2.example.org" {
+		t.Errorf("hosts = %v, want the example.org template's servers", got)
+	}
+}
+
+func TestDiscoverZones_NoTemplate_RetriedLater(t *testing.T) {
+	rs := &resolverStub{zones: map[string]string{"new.example.org.": "ns1.new.example.org."}}
+	m, _, now := discoveryProvider(rs, dns.RcodeSuccess, ZoneConfig{Zone: "example.net"})
+
+	m.DiscoverZones(context.Background(), []string{"app.new.example.org"})
+	if m.ManagesName("app.new.example.org") {
+		t.Fatal("zone added without a matching template")
+	}
+
+	m.DiscoverZones(context.Background(), []string{"app.new.example.org"})
+	if len(rs.asked) != 1 {
+		t.Errorf("SOA queries = %v, want no new lookup before the retry interval", rs.asked)
+	}
+
+	*now = now.Add(discoveryRetry)
+	m.DiscoverZones(context.Background(), []string{"app.new.example.org"})
+	if len(rs.asked) != 2 {
+		t.Errorf("SOA queries = %v, want a new lookup after the retry interval", rs.asked)
+	}
+}
+
+func TestDiscoverZones_NameGone_RetryForgotten(t *testing.T) {
+	rs := &resolverStub{zones: map[string]string{"new.example.org.": "ns1.new.example.org."}}
+	m, _, _ := discoveryProvider(rs, dns.RcodeSuccess, ZoneConfig{Zone: "example.net"})
+
+	m.DiscoverZones(context.Background(), []string{"app.new.example.org", "web.new.example.org"})
+	if len(m.discovery.retry) != 2 {
+		t.Fatalf("retry = %v, want both names", m.discovery.retry)
+	}
+
+	m.DiscoverZones(context.Background(), []string{"web.new.example.org"})
+	if _, ok := m.discovery.retry["app.new.example.org."]; ok || len(m.discovery.retry) != 1 {
+		t.Errorf("retry = %v, want only web.new.example.org.", m.discovery.retry)
+	}
+}
+
+func TestDiscoverZones_PreflightFails_NotAdded(t *testing.T) {
+	rs := &resolverStub{zones: map[string]string{"new.example.org.": "ns1.new.example.org."}}
+	m, built, _ := discoveryProvider(rs, dns.RcodeNotAuth, ZoneConfig{})
+
+	m.DiscoverZones(context.Background(), []string{"app.new.example.org"})
+
+	if len(*built) != 1 || m.ManagesName("app.new.example.org") {
+		t.Error("zone added although its preflight failed")
+	}
+}
+
+func TestDiscoverZones_TopLevelOnly_NotAdded(t *testing.T) {
+	rs := &resolverStub{zones: map[string]string{"org.": "a0.org.afilias-nst.info."}}
+	m, built, _ := discoveryProvider(rs, dns.RcodeSuccess, ZoneConfig{})
+
+	m.DiscoverZones(context.Background(), []string{"app.unregistered.org"})
+
+	if len(*built) != 0 {
+		t.Errorf("zones built = %v, want none for a top-level domain", *built)
+	}
+}
+
+func TestDiscoverZones_ResolverError_NotAdded(t *testing.T) {
+	m, built, _ := discoveryProvider(nil, dns.RcodeSuccess, ZoneConfig{})
+	m.discovery.exchanger = &mockExchanger{err: errors.New("i/o timeout")}
+
+	m.DiscoverZones(context.Background(), []string{"app.new.example.org"})
+
+	if len(*built) != 0 {
+		t.Errorf("zones built = %v, want none when the resolver fails", *built)
+	}
+}
+
+func TestDiscoverZones_Disabled_NoOp(t *testing.T) {
+	m := newMultiWithDeps(twoZoneConfigs(), nil, nil)
+	m.DiscoverZones(context.Background(), []string{"app.new.example.org"})
+	if m.ManagesName("app.new.example.org") {
+		t.Error("zone added with discovery disabled")
+	}
+}
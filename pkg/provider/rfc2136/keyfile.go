@@ -0,0 +1,275 @@
+package rfc2136
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// TSIGKeyFile holds the keys of a BIND key file, the format written by
+// tsig-keygen and ddns-confgen and read by nsupdate -k and named.conf:
+//
+//	key "external-dns" {
+//		algorithm hmac-sha256;
+//		secret "base64...";
+//	};
+type TSIGKeyFile struct {
+	path string
+	keys []TSIGKey
+}
+
+// LoadTSIGKeyFile reads the key clauses of the BIND key file at path and
+// checks each has a supported algorithm and a base64 secret. Other
+// statements, such as server clauses, are skipped.
+func LoadTSIGKeyFile(path string) (*TSIGKeyFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("TSIG key file: %w", err)
+	}
+	keys, err := parseTSIGKeyFile(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("TSIG key file %s: %w", path, err)
+	}
+	return &TSIGKeyFile{path: path, keys: keys}, nil
+}
+
+// Names returns the names of the file's keys in file order.
+func (f *TSIGKeyFile) Names() []string {
+	names := make([]string, 0, len(f.keys))
+	for _, k := range f.keys {
+		names = append(names, k.Name)
+	}
+	return names
+}
+
+// Key returns the key called name; case and a trailing dot are ignored. An
+// empty name selects the file's only key.
+func (f *TSIGKeyFile) Key(name string) (TSIGKey, error) {
+	if name == "" {
+		if len(f.keys) > 1 {
+			return TSIGKey{}, fmt.Errorf("TSIG key file %s defines %d keys (%s); set the key name to choose one",
+				f.path, len(f.keys), strings.Join(f.Names(), ", "))
+		}
+		return f.keys[0], nil
+	}
+	for _, k := range f.keys {
+		if strings.EqualFold(dns.Fqdn(k.Name), dns.Fqdn(name)) {
+			return k, nil
+		}
+	}
+	return TSIGKey{}, fmt.Errorf("TSIG key file %s has no key %q (it defines %s)",
+		f.path, name, strings.Join(f.Names(), ", "))
+}
+
+// keyFileToken is a token of a BIND configuration file.
+type keyFileToken struct {
+	text   string
+	quoted bool // a quoted string, never punctuation
+	line   int
+}
+
+// punct reports whether t is the punctuation s.
+func (t keyFileToken) punct(s string) bool {
+	return !t.quoted && t.text == s
+}
+
+// keyFileTokens splits data into BIND configuration tokens: quoted strings,
+// the punctuation { } ; and words. Whitespace and #, // and /* */ comments
+// are dropped.
+func keyFileTokens(data string) ([]keyFileToken, error) {
+	var toks []keyFileToken
+	line := 1
+	for i := 0; i < len(data); {
+		rest := data[i:]
+		switch c := data[i]; {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#' || strings.HasPrefix(rest, "//"):
+			if end := strings.IndexByte(rest, '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(data)
+			}
+		case strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest, "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated comment", line)
+			}
+			line += strings.Count(rest[:end], "\n")
+			i += end + 2
+		case c == '"':
+			end := strings.IndexAny(rest[1:], "\"\n")
+			if end < 0 || rest[1+end] != '"' {
+				return nil, fmt.Errorf("line %d: unterminated string", line)
+			}
+			toks = append(toks, keyFileToken{text: rest[1 : 1+end], quoted: true, line: line})
+			i += end + 2
+		case c == '{' || c == '}' || c == ';':
+			toks = append(toks, keyFileToken{text: string(c), line: line})
+			i++
+		default:
+			end := strings.IndexAny(rest, " \t\r\n{};\"#")
+			if end < 0 {
+				end = len(rest)
+			}
+			toks = append(toks, keyFileToken{text: rest[:end], line: line})
+			i += end
+		}
+	}
+	return toks, nil
+}
+
+// keyFileParser reads statements from a token stream.
+type keyFileParser struct {
+	toks []keyFileToken
+	pos  int
+}
+
+// next returns the next token, or an error at the end of the file.
+func (p *keyFileParser) next() (keyFileToken, error) {
+	if p.pos == len(p.toks) {
+		line := 1
+		if len(p.toks) > 0 {
+			line = p.toks[len(p.toks)-1].line
+		}
+		return keyFileToken{}, fmt.Errorf("line %d: unexpected end of file", line)
+	}
+	t := p.toks[p.pos]
+	p.pos++
+	return t, nil
+}
+
+// expect consumes the punctuation s.
+func (p *keyFileParser) expect(s string) error {
+	t, err := p.next()
+	if err != nil {
+		return err
+	}
+	if !t.punct(s) {
+		return fmt.Errorf("line %d: expected %q, found %q", t.line, s, t.text)
+	}
+	return nil
+}
+
+// skip consumes the rest of a statement starting with first, including any
+// nested blocks, up to its closing semicolon.
+func (p *keyFileParser) skip(first keyFileToken) error {
+	depth := 0
+	for t := first; ; {
+		switch {
+		case t.punct("{"):
+			depth++
+		case t.punct("}"):
+			if depth == 0 {
+				return fmt.Errorf("line %d: unexpected \"}\"", t.line)
+			}
+			depth--
+		case t.punct(";") && depth == 0:
+			return nil
+		}
+		var err error
+		if t, err = p.next(); err != nil {
+			return err
+		}
+	}
+}
+
+// key parses a key clause after its "key" keyword on line.
+func (p *keyFileParser) key(line int) (TSIGKey, error) {
+	name, err := p.next()
+	if err != nil {
+		return TSIGKey{}, err
+	}
+	if name.text == "" || (!name.quoted && strings.ContainsAny(name.text, "{};")) {
+		return TSIGKey{}, fmt.Errorf("line %d: key clause without a name", line)
+	}
+	k := TSIGKey{Name: name.text}
+	if err := p.expect("{"); err != nil {
+		return TSIGKey{}, err
+	}
+	for {
+		opt, err := p.next()
+		if err != nil {
+			return TSIGKey{}, err
+		}
+		if opt.punct("}") {
+			break
+		}
+		val, err := p.next()
+		if err != nil {
+			return TSIGKey{}, err
+		}
+		if !val.quoted && strings.ContainsAny(val.text, "{};") {
+			return TSIGKey{}, fmt.Errorf("line %d: key %q: %s has no value", opt.line, k.Name, opt.text)
+		}
+		switch strings.ToLower(opt.text) {
+		case "algorithm":
+			k.Alg = strings.ToLower(val.text)
+		case "secret":
+			k.Secret = val.text
+		default:
+			return TSIGKey{}, fmt.Errorf("line %d: key %q: unknown option %q (want algorithm or secret)", opt.line, k.Name, opt.text)
+		}
+		if err := p.expect(";"); err != nil {
+			return TSIGKey{}, err
+		}
+	}
+	if err := p.expect(";"); err != nil {
+		return TSIGKey{}, err
+	}
+
+	switch {
+	case k.Alg == "":
+		return TSIGKey{}, fmt.Errorf("line %d: key %q has no algorithm", line, k.Name)
+	case tsigHash(normaliseTSIGAlg(k.Alg)) == nil:
+		return TSIGKey{}, fmt.Errorf("line %d: key %q: unsupported algorithm %q (want hmac-sha1, hmac-sha224, hmac-sha256, hmac-sha384 or hmac-sha512)",
+			line, k.Name, k.Alg)
+	case k.Secret == "":
+		return TSIGKey{}, fmt.Errorf("line %d: key %q has no secret", line, k.Name)
+	}
+	if _, err := base64.StdEncoding.DecodeString(k.Secret); err != nil {
+		return TSIGKey{}, fmt.Errorf("line %d: key %q: secret is not base64", line, k.Name)
+	}
+	return k, nil
+}
+
+// parseTSIGKeyFile returns the keys defined in data, a BIND key file.
+func parseTSIGKeyFile(data string) ([]TSIGKey, error) {
+	toks, err := keyFileTokens(data)
+	if err != nil {
+		return nil, err
+	}
+	p := &keyFileParser{toks: toks}
+	var keys []TSIGKey
+	seen := make(map[string]int) // FQDN → line of its key clause
+	for p.pos < len(p.toks) {
+		t, _ := p.next()
+		if t.quoted || !strings.EqualFold(t.text, "key") {
+			if err := p.skip(t); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		k, err := p.key(t.line)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.ToLower(dns.Fqdn(k.Name))
+		if prev, ok := seen[name]; ok {
+			return nil, fmt.Errorf("line %d: key %q is already defined on line %d", t.line, k.Name, prev)
+		}
+		seen[name] = t.line
+		keys = append(keys, k)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no key clauses found")
+	}
+	return keys, nil
+}
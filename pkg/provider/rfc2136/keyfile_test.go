@@ -0,0 +1,102 @@
+package rfc2136
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeKeyFile writes content to a BIND key file and returns its path.
+func writeKeyFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tsig.key")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadTSIGKeyFile_TSIGKeygenOutput(t *testing.T) {
+	path := writeKeyFile(t, `key "external-dns" {
+	algorithm hmac-sha256;
+	secret "`+newSecret+`";
+};
+`)
+	f, err := LoadTSIGKeyFile(path)
+	if err != nil {
+		t.Fatalf("LoadTSIGKeyFile() error = %v", err)
+	}
+	k, err := f.Key("")
+	if err != nil {
+		t.Fatalf("Key() error = %v", err)
+	}
+	if k.Name != "external-dns" || k.Alg != "hmac-sha256" || k.Secret != newSecret {
+		t.Errorf("key = %+v, want external-dns/hmac-sha256 with its secret", k)
+	}
+}
+
+func TestLoadTSIGKeyFile_MultipleKeys_SelectedByName(t *testing.T) {
+	path := writeKeyFile(t, `# rotated 2026-10
+key old-key { algorithm HMAC-SHA512; secret "`+oldSecret+`"; };
+/* the key
+   in use */
+key "new-key." {
+	algorithm hmac-sha256; // default
+	secret "`+newSecret+`";
+};
+server 192.0.2.1 { keys { new-key.; }; };
+`)
+	f, err := LoadTSIGKeyFile(path)
+	if err != nil {
+		t.Fatalf("LoadTSIGKeyFile() error = %v", err)
+	}
+	k, err := f.Key("NEW-KEY")
+	if err != nil {
+		t.Fatalf("Key() error = %v", err)
+	}
+	if k.Secret != newSecret {
+		t.Errorf("new-key secret = %q, want %q", k.Secret, newSecret)
+	}
+	if k, _ := f.Key("old-key"); k.Alg != "hmac-sha512" {
+		t.Errorf("old-key algorithm = %q, want hmac-sha512", k.Alg)
+	}
+	if _, err := f.Key(""); err == nil || !strings.Contains(err.Error(), "old-key, new-key.") {
+		t.Errorf("Key(\"\") error = %v, want the key names listed", err)
+	}
+	if _, err := f.Key("missing"); err == nil {
+		t.Error("expected an error for a key not in the file")
+	}
+}
+
+func TestLoadTSIGKeyFile_Invalid_ReturnsError(t *testing.T) {
+	tests := []struct {
+		name, content, want string
+	}{
+		{"no keys", "# empty\n", "no key clauses"},
+		{"no secret", `key k { algorithm hmac-sha256; };`, `line 1: key "k" has no secret`},
+		{"no algorithm", `key k { secret "` + newSecret + `"; };`, "has no algorithm"},
+		{"md5", `key k { algorithm hmac-md5; secret "` + newSecret + `"; };`, `unsupported algorithm "hmac-md5"`},
+		{"bad secret", `key k { algorithm hmac-sha256; secret "not base64!"; };`, "not base64"},
+		{"unknown option", "key k {\n algorithm hmac-sha256;\n keyfile \"x\";\n};", `line 3: key "k": unknown option "keyfile"`},
+		{"missing semicolon", `key k { algorithm hmac-sha256 secret "x"; };`, `expected ";"`},
+		{"truncated", "key k {\n algorithm hmac-sha256;\n", "line 2: unexpected end of file"},
+		{"unterminated string", `key k { secret "abc`, "unterminated string"},
+		{"duplicate", `key k { algorithm hmac-sha256; secret "` + newSecret + `"; };
+key K. { algorithm hmac-sha256; secret "` + oldSecret + `"; };`, "already defined on line 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadTSIGKeyFile(writeKeyFile(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadTSIGKeyFile() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadTSIGKeyFile_Missing_ReturnsError(t *testing.T) {
+	if _, err := LoadTSIGKeyFile("/nonexistent/tsig.key"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
@@ -2,6 +2,8 @@ package rfc2136
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
@@ -9,24 +11,59 @@ import (
 	"time"
 
 	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 
 	"github.com/bkero/external-dns-docker/pkg/endpoint"
 	"github.com/bkero/external-dns-docker/pkg/plan"
+	"github.com/bkero/external-dns-docker/pkg/provider"
+)
+
+// Per-zone health metrics, registered on the default registry.
+var (
+	zoneUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "external_dns_docker_zone_up",
+		Help: "1 if the last operation against the zone succeeded, 0 if it failed.",
+	}, []string{"zone"})
+
+	zoneErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "external_dns_docker_zone_errors_total",
+		Help: "Total number of failed operations per zone, by operation (records or apply).",
+	}, []string{"zone", "op"})
 )
 
 // ZoneConfig holds per-zone RFC2136 provider configuration.
 // TSIGSecretFile, if set, must be resolved to TSIGSecret by the caller before
-// passing to NewMulti.
+// passing to NewMulti; it is kept so the provider can re-read it when it
+// changes. TSIGKeyFile must be resolved to TSIGKey, TSIGSecret and TSIGAlg
+// (see LoadTSIGKeyFile), SIG0KeyFile to SIG0Key (see LoadSIG0Key) and the
+// TLS* settings to TLS (see NewTLSConfig).
 type ZoneConfig struct {
-	Host           string
-	Port           int
-	Zone           string
-	TSIGKey        string
-	TSIGSecret     string
-	TSIGSecretFile string
-	TSIGAlg        string
-	MinTTL         int64
-	Timeout        time.Duration
+	Host              string
+	Hosts             []string // servers in failover order; replaces Host when set
+	Port              int
+	Zone              string
+	TSIGKey           string
+	TSIGSecret        string
+	TSIGSecretFile    string
+	TSIGAlg           string
+	TSIGKeyFile       string
+	TSIGKeys          []TSIGKey // fallback keys, see Config.TSIGKeys
+	SIG0KeyFile       string
+	SIG0Key           *SIG0Key
+	MinTTL            int64
+	Timeout           time.Duration
+	MaxUpdateRRs      int
+	Prerequisites     bool
+	Transport         string
+	TLSCAFile         string
+	TLSCertFile       string
+	TLSKeyFile        string
+	TLSServerName     string
+	TLS               *tls.Config
+	Verify            bool
+	VerifySecondaries []string
+	VerifyTimeout     time.Duration
 }
 
 // zoneEntry pairs a normalised zone FQDN with its single-zone Provider.
@@ -37,46 +74,78 @@ type zoneEntry struct {
 
 // MultiProvider implements provider.Provider for multiple RFC2136-managed zones.
 type MultiProvider struct {
+	mu    sync.RWMutex // guards zones, which grows as zones are discovered
 	zones []zoneEntry
 	log   *slog.Logger
+
+	discovery *discoverer // nil unless EnableDiscovery was called
 }
 
 // NewMulti creates a MultiProvider from a slice of ZoneConfigs.
-// TSIGSecretFile in each config must already be resolved to TSIGSecret.
+// TSIGSecretFile in each config must already be resolved to TSIGSecret,
+// SIG0KeyFile to SIG0Key and the TLS* settings to TLS.
 func NewMulti(configs []ZoneConfig, log *slog.Logger) *MultiProvider {
 	if log == nil {
 		log = slog.Default()
 	}
 	entries := make([]zoneEntry, 0, len(configs))
 	for _, zc := range configs {
-		cfg := Config{
-			Host:          zc.Host,
-			Port:          zc.Port,
-			Zone:          zc.Zone,
-			TSIGKeyName:   zc.TSIGKey,
-			TSIGSecret:    zc.TSIGSecret,
-			TSIGSecretAlg: zc.TSIGAlg,
-			MinTTL:        zc.MinTTL,
-			Timeout:       zc.Timeout,
-		}
 		entries = append(entries, zoneEntry{
 			zone: dns.Fqdn(zc.Zone),
-			prov: New(cfg, log),
+			prov: New(zc.config(), log),
 		})
 	}
 	return &MultiProvider{zones: entries, log: log}
 }
 
+// config returns the single-zone Provider configuration for zc.
+func (zc ZoneConfig) config() Config {
+	return Config{
+		Host:              zc.Host,
+		Hosts:             zc.Hosts,
+		Port:              zc.Port,
+		Zone:              zc.Zone,
+		TSIGKeyName:       zc.TSIGKey,
+		TSIGSecret:        zc.TSIGSecret,
+		TSIGSecretFile:    zc.TSIGSecretFile,
+		TSIGSecretAlg:     zc.TSIGAlg,
+		TSIGKeys:          zc.TSIGKeys,
+		SIG0Key:           zc.SIG0Key,
+		MinTTL:            zc.MinTTL,
+		Timeout:           zc.Timeout,
+		MaxUpdateRRs:      zc.MaxUpdateRRs,
+		Prerequisites:     zc.Prerequisites,
+		Transport:         zc.Transport,
+		TLS:               zc.TLS,
+		Verify:            zc.Verify,
+		VerifySecondaries: zc.VerifySecondaries,
+		VerifyTimeout:     zc.VerifyTimeout,
+	}
+}
+
+// entries returns the zones managed right now. The slice is never modified
+// in place, so it stays valid while zones are being added.
+func (m *MultiProvider) entries() []zoneEntry {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.zones
+}
+
 // Records fans out to all sub-providers in parallel and merges the results.
-// Returns the first error encountered, if any.
+// A failing zone does not hide the others: their records are returned
+// together with a *provider.ZoneErrors naming the failed zones, so callers
+// can skip just those. Only when every zone fails is a plain error returned;
+// it does not wrap the ZoneErrors, so a total outage is not mistaken for a
+// partial one and the cycle fails.
 func (m *MultiProvider) Records(ctx context.Context) ([]*endpoint.Endpoint, error) {
 	type result struct {
 		eps []*endpoint.Endpoint
 		err error
 	}
-	results := make([]result, len(m.zones))
+	zones := m.entries()
+	results := make([]result, len(zones))
 	var wg sync.WaitGroup
-	for i, ze := range m.zones {
+	for i, ze := range zones {
 		wg.Add(1)
 		go func(idx int, z zoneEntry) {
 			defer wg.Done()
@@ -87,26 +156,42 @@ func (m *MultiProvider) Records(ctx context.Context) ([]*endpoint.Endpoint, erro
 	wg.Wait()
 
 	var all []*endpoint.Endpoint
-	for _, r := range results {
+	zerrs := provider.NewZoneErrors(m.zoneName)
+	for i, r := range results {
+		zone := zones[i].zone
 		if r.err != nil {
-			return nil, r.err
+			m.zoneFailed(zerrs, zone, "records", r.err)
+			continue
 		}
+		zoneUp.WithLabelValues(zone).Set(1)
 		all = append(all, r.eps...)
 	}
-	return all, nil
+	switch {
+	case zerrs.Len() == 0:
+		return all, nil
+	case zerrs.Len() == len(zones):
+		return nil, fmt.Errorf("all zones failed: %v", zerrs)
+	default:
+		return all, zerrs
+	}
 }
 
 // ApplyChanges splits the Changes set by zone using longest-suffix matching and
 // dispatches each subset to the matching sub-provider. Endpoints with no matching
 // zone are logged at WARN level and skipped. Zones with no changes are not called.
+// A failing zone does not stop the others; the failures are returned as a
+// *provider.ZoneErrors. Zones whose changes were applied but failed
+// verification are not failed zones: their mismatches are returned together
+// as a *provider.VerifyError when no zone failed, and logged otherwise.
 func (m *MultiProvider) ApplyChanges(ctx context.Context, changes *plan.Changes) error {
-	byZone := make(map[string]*plan.Changes, len(m.zones))
-	for _, ze := range m.zones {
+	zones := m.entries()
+	byZone := make(map[string]*plan.Changes, len(zones))
+	for _, ze := range zones {
 		byZone[ze.zone] = &plan.Changes{}
 	}
 
 	for _, ep := range changes.Create {
-		ze := m.zoneFor(ep.DNSName)
+		ze := findZone(zones, ep.DNSName)
 		if ze == nil {
 			m.log.Warn("no zone match for endpoint, skipping", "dnsName", ep.DNSName)
 			continue
@@ -114,7 +199,7 @@ func (m *MultiProvider) ApplyChanges(ctx context.Context, changes *plan.Changes)
 		byZone[ze.zone].Create = append(byZone[ze.zone].Create, ep)
 	}
 	for _, ep := range changes.Delete {
-		ze := m.zoneFor(ep.DNSName)
+		ze := findZone(zones, ep.DNSName)
 		if ze == nil {
 			m.log.Warn("no zone match for endpoint, skipping", "dnsName", ep.DNSName)
 			continue
@@ -122,7 +207,7 @@ func (m *MultiProvider) ApplyChanges(ctx context.Context, changes *plan.Changes)
 		byZone[ze.zone].Delete = append(byZone[ze.zone].Delete, ep)
 	}
 	for i, old := range changes.UpdateOld {
-		ze := m.zoneFor(old.DNSName)
+		ze := findZone(zones, old.DNSName)
 		if ze == nil {
 			m.log.Warn("no zone match for endpoint, skipping", "dnsName", old.DNSName)
 			continue
@@ -133,22 +218,50 @@ func (m *MultiProvider) ApplyChanges(ctx context.Context, changes *plan.Changes)
 		}
 	}
 
-	for _, ze := range m.zones {
+	zerrs := provider.NewZoneErrors(m.zoneName)
+	unverified := &provider.VerifyError{}
+	for _, ze := range zones {
 		zc := byZone[ze.zone]
 		if zc.IsEmpty() {
 			continue
 		}
 		if err := ze.prov.ApplyChanges(ctx, zc); err != nil {
-			return err
+			// The zone applied its changes; its servers just do not all
+			// serve them yet, so it is not failed.
+			var verr *provider.VerifyError
+			if !errors.As(err, &verr) {
+				m.zoneFailed(zerrs, ze.zone, "apply", err)
+				continue
+			}
+			unverified.Mismatches = append(unverified.Mismatches, verr.Mismatches...)
 		}
+		zoneUp.WithLabelValues(ze.zone).Set(1)
+	}
+	switch {
+	case zerrs.Len() > 0:
+		if len(unverified.Mismatches) > 0 {
+			m.log.Warn("applied changes not served yet", "err", unverified)
+		}
+		return zerrs
+	case len(unverified.Mismatches) > 0:
+		return unverified
 	}
 	return nil
 }
 
+// zoneFailed records a failed operation against zone in zerrs, the logs and
+// the per-zone metrics.
+func (m *MultiProvider) zoneFailed(zerrs *provider.ZoneErrors, zone, op string, err error) {
+	m.log.Warn("zone operation failed, skipping zone", "zone", zone, "op", op, "err", err)
+	zoneUp.WithLabelValues(zone).Set(0)
+	zoneErrorsTotal.WithLabelValues(zone, op).Inc()
+	zerrs.Add(zone, err)
+}
+
 // Preflight runs SOA preflight checks against all zones sequentially.
 // Returns the first error encountered.
 func (m *MultiProvider) Preflight(ctx context.Context) error {
-	for _, ze := range m.zones {
+	for _, ze := range m.entries() {
 		if err := ze.prov.Preflight(ctx); err != nil {
 			return fmt.Errorf("zone %s: %w", ze.zone, err)
 		}
@@ -156,15 +269,69 @@ func (m *MultiProvider) Preflight(ctx context.Context) error {
 	return nil
 }
 
+// ManagesName reports whether dnsName falls inside one of the managed zones.
+func (m *MultiProvider) ManagesName(dnsName string) bool {
+	return m.zoneFor(dnsName) != nil
+}
+
+// GetLease reads the lease at name from the zone that contains it.
+func (m *MultiProvider) GetLease(ctx context.Context, name string) (*provider.Lease, error) {
+	ze := m.zoneFor(name)
+	if ze == nil {
+		return nil, fmt.Errorf("lease name %s is outside the managed zones", name)
+	}
+	return ze.prov.GetLease(ctx, name)
+}
+
+// SwapLease replaces the lease at name in the zone that contains it.
+func (m *MultiProvider) SwapLease(ctx context.Context, name string, old, next *provider.Lease) error {
+	ze := m.zoneFor(name)
+	if ze == nil {
+		return fmt.Errorf("lease name %s is outside the managed zones", name)
+	}
+	return ze.prov.SwapLease(ctx, name, old, next)
+}
+
+// LeaseZone returns the Provider for a leader-election lease kept in zone:
+// the zone's own Provider if it is managed, otherwise one reaching the first
+// zone's servers with its credentials (see Provider.LeaseZone). It returns
+// nil when no zone is configured.
+func (m *MultiProvider) LeaseZone(zone string) *Provider {
+	zones := m.entries()
+	if len(zones) == 0 {
+		return nil
+	}
+	for _, ze := range zones {
+		if ze.zone == dns.Fqdn(zone) {
+			return ze.prov
+		}
+	}
+	return zones[0].prov.LeaseZone(zone)
+}
+
+// zoneName returns the zone FQDN that holds dnsName, or "" if none does.
+func (m *MultiProvider) zoneName(dnsName string) string {
+	if ze := m.zoneFor(dnsName); ze != nil {
+		return ze.zone
+	}
+	return ""
+}
+
 // zoneFor returns the zoneEntry whose zone FQDN is the longest suffix match
 // for dnsName. Returns nil if no zone matches.
 func (m *MultiProvider) zoneFor(dnsName string) *zoneEntry {
+	return findZone(m.entries(), dnsName)
+}
+
+// findZone returns the entry in zones whose zone FQDN is the longest suffix
+// match for dnsName, or nil if none matches.
+func findZone(zones []zoneEntry, dnsName string) *zoneEntry {
 	name := strings.TrimSuffix(dnsName, ".")
 
 	var best *zoneEntry
 	bestLen := 0
-	for i := range m.zones {
-		ze := &m.zones[i]
+	for i := range zones {
+		ze := &zones[i]
 		zoneWithoutDot := strings.TrimSuffix(ze.zone, ".")
 		if name == zoneWithoutDot || strings.HasSuffix(name, "."+zoneWithoutDot) {
 			if len(zoneWithoutDot) > bestLen {
@@ -2,15 +2,19 @@ package rfc2136
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 
 	"github.com/bkero/external-dns-docker/pkg/endpoint"
 	"github.com/bkero/external-dns-docker/pkg/plan"
+	"github.com/bkero/external-dns-docker/pkg/provider"
 )
 
 // --- helpers for multi-zone tests ---
@@ -115,6 +119,17 @@ func TestZoneFor_SecondZoneMatches(t *testing.T) {
 	}
 }
 
+func TestManagesName_ReverseZone(t *testing.T) {
+	configs := append(twoZoneConfigs(), ZoneConfig{Host: "ns1.example.com", Zone: "2.0.192.in-addr.arpa"})
+	m := newMultiWithDeps(configs, nil, nil)
+	if !m.ManagesName("5.2.0.192.in-addr.arpa") {
+		t.Error("expected reverse name in a managed reverse zone to match")
+	}
+	if m.ManagesName("5.3.0.192.in-addr.arpa") {
+		t.Error("expected reverse name outside managed zones not to match")
+	}
+}
+
 // --- Records tests ---
 
 func TestMultiRecords_MergesResultsFromTwoZones(t *testing.T) {
@@ -150,6 +165,70 @@ func TestMultiRecords_OneZoneError_ReturnsError(t *testing.T) {
 	}
 }
 
+// zoneMocks builds a two-zone MultiProvider (example.com, bke.ro) whose
+// zones each have their own transferrer and exchanger.
+func zoneMocks(t1, t2 dnsTransferer, e1, e2 dnsExchanger) *MultiProvider {
+	configs := twoZoneConfigs()
+	m := &MultiProvider{log: slog.Default()}
+	for i, deps := range []struct {
+		t dnsTransferer
+		e dnsExchanger
+	}{{t1, e1}, {t2, e2}} {
+		cfg := Config{Host: configs[i].Host, Zone: configs[i].Zone}
+		m.zones = append(m.zones, zoneEntry{zone: dns.Fqdn(configs[i].Zone), prov: newWithDeps(cfg, nil, deps.t, deps.e)})
+	}
+	return m
+}
+
+func TestMultiRecords_OneZoneError_ReturnsHealthyZonesAndZoneErrors(t *testing.T) {
+	rr := &dns.A{
+		Hdr: dns.RR_Header{Name: "app.example.com.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300},
+		A:   net.ParseIP("1.2.3.4"),
+	}
+	m := zoneMocks(
+		&mockTransferer{envelopes: []*dns.Envelope{{RR: []dns.RR{rr}}}},
+		&mockTransferer{err: fmt.Errorf("connection refused")},
+		nil, nil,
+	)
+
+	eps, err := m.Records(context.Background())
+	var zerrs *provider.ZoneErrors
+	if !errors.As(err, &zerrs) {
+		t.Fatalf("Records() error = %v, want *provider.ZoneErrors", err)
+	}
+	if got := zerrs.Zones(); len(got) != 1 || got[0] != "bke.ro." {
+		t.Errorf("failed zones = %v, want [bke.ro.]", got)
+	}
+	if !zerrs.Contains("api.bke.ro") || zerrs.Contains("app.example.com") {
+		t.Error("Contains() should report only names in bke.ro")
+	}
+	if len(eps) != 1 || eps[0].DNSName != "app.example.com" {
+		t.Errorf("records = %v, want the healthy zone's record", eps)
+	}
+	if got := testutil.ToFloat64(zoneUp.WithLabelValues("bke.ro.")); got != 0 {
+		t.Errorf("zone_up{bke.ro.} = %v, want 0", got)
+	}
+	if got := testutil.ToFloat64(zoneUp.WithLabelValues("example.com.")); got != 1 {
+		t.Errorf("zone_up{example.com.} = %v, want 1", got)
+	}
+}
+
+func TestMultiRecords_AllZonesFail_ReturnsPlainError(t *testing.T) {
+	mt := &mockTransferer{err: fmt.Errorf("axfr failure")}
+	m := newMultiWithDeps(twoZoneConfigs(), mt, nil)
+
+	eps, err := m.Records(context.Background())
+	var zerrs *provider.ZoneErrors
+	if err == nil || eps != nil {
+		t.Fatalf("Records() = %v, %v; want nil records and an error", eps, err)
+	}
+	// Callers skip the zones in a ZoneErrors and carry on, so a total
+	// outage must not be one.
+	if errors.As(err, &zerrs) {
+		t.Errorf("Records() error wraps ZoneErrors for %v, want a plain error", zerrs.Zones())
+	}
+}
+
 func TestMultiRecords_EmptyZones_ReturnsNil(t *testing.T) {
 	mt := &mockTransferer{envelopes: []*dns.Envelope{}}
 	m := newMultiWithDeps(twoZoneConfigs(), mt, nil)
@@ -345,6 +424,30 @@ func TestMultiApplyChanges_SubProviderError_ReturnsError(t *testing.T) {
 	}
 }
 
+func TestMultiApplyChanges_FailingZoneDoesNotStopOthers(t *testing.T) {
+	bad := &mockExchanger{err: fmt.Errorf("update timed out")}
+	good := &mockExchanger{resp: successResp()}
+	m := zoneMocks(nil, nil, bad, good)
+
+	before := testutil.ToFloat64(zoneErrorsTotal.WithLabelValues("example.com.", "apply"))
+	err := m.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.New("app.example.com", []string{"1.2.3.4"}, endpoint.RecordTypeA, 300, nil),
+			endpoint.New("api.bke.ro", []string{"5.6.7.8"}, endpoint.RecordTypeA, 300, nil),
+		},
+	})
+	var zerrs *provider.ZoneErrors
+	if !errors.As(err, &zerrs) || zerrs.Len() != 1 || !zerrs.Contains("app.example.com") {
+		t.Fatalf("ApplyChanges() error = %v, want ZoneErrors for example.com.", err)
+	}
+	if good.sent == nil {
+		t.Error("healthy zone was not updated after the other zone failed")
+	}
+	if got := testutil.ToFloat64(zoneErrorsTotal.WithLabelValues("example.com.", "apply")); got != before+1 {
+		t.Errorf("zone_errors_total{example.com.,apply} = %v, want %v", got, before+1)
+	}
+}
+
 // --- Preflight tests ---
 
 func TestMultiPreflight_AllSuccess(t *testing.T) {
@@ -378,6 +481,52 @@ func TestMultiPreflight_NonSuccessRcode_ReturnsError(t *testing.T) {
 	}
 }
 
+// --- Lease tests ---
+
+func TestMultiProvider_SwapLease_RoutesToZone(t *testing.T) {
+	me := &mockExchanger{resp: successResp()}
+	m := newMultiWithDeps(twoZoneConfigs(), nil, me)
+	next := &provider.Lease{Holder: "node-1", Renewed: time.Now(), Duration: 15 * time.Second}
+
+	if err := m.SwapLease(context.Background(), "leader.bke.ro", nil, next); err != nil {
+		t.Fatalf("SwapLease() error = %v", err)
+	}
+	if z := me.sent.Question[0].Name; z != "bke.ro." {
+		t.Errorf("update zone = %q, want bke.ro.", z)
+	}
+}
+
+func TestMultiProvider_Lease_OutsideZones_ReturnsError(t *testing.T) {
+	m := newMultiWithDeps(twoZoneConfigs(), nil, &mockExchanger{resp: successResp()})
+	if _, err := m.GetLease(context.Background(), "leader.other.tld"); err == nil {
+		t.Error("expected error for GetLease outside the managed zones")
+	}
+	if err := m.SwapLease(context.Background(), "leader.other.tld", nil, nil); err == nil {
+		t.Error("expected error for SwapLease outside the managed zones")
+	}
+}
+
+func TestMultiProvider_LeaseZone_ManagedOrFirstZoneServers(t *testing.T) {
+	m := NewMulti(twoZoneConfigs(), nil)
+
+	if got := m.LeaseZone("bke.ro"); got != m.zones[1].prov {
+		t.Error("LeaseZone(bke.ro) should return the managed zone's provider")
+	}
+	lp := m.LeaseZone("leases.example.net")
+	if lp == nil || lp.cfg.Zone != "leases.example.net" {
+		t.Fatalf("LeaseZone(leases.example.net) = %+v, want a provider for that zone", lp)
+	}
+	if lp.cfg.Host != "ns1.example.com" || lp.cfg.TSIGKeyName != "k1" {
+		t.Errorf("lease provider reaches %s with key %s, want the first zone's server and key", lp.cfg.Host, lp.cfg.TSIGKeyName)
+	}
+	if !lp.ManagesName("external-dns-docker-leader.leases.example.net") || lp.ManagesName("leader.example.com") {
+		t.Error("lease provider should manage only names in its zone")
+	}
+	if NewMulti(nil, nil).LeaseZone("leases.example.net") != nil {
+		t.Error("LeaseZone with no zones should be nil")
+	}
+}
+
 // --- NewMulti construction tests ---
 
 func TestNewMulti_NilLog_UsesDefault(t *testing.T) {
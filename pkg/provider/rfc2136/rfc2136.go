@@ -3,16 +3,20 @@ package rfc2136
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/miekg/dns"
 
 	"github.com/bkero/external-dns-docker/pkg/endpoint"
 	"github.com/bkero/external-dns-docker/pkg/plan"
+	"github.com/bkero/external-dns-docker/pkg/provider"
 )
 
 // dnsTransferer abstracts dns.Transfer.In for testability.
@@ -30,30 +34,77 @@ const defaultTimeout = 10 * time.Second
 
 // Config holds all RFC2136 provider configuration.
 type Config struct {
-	Host          string
-	Port          int
-	Zone          string
-	TSIGKeyName   string
-	TSIGSecret    string
-	TSIGSecretAlg string // e.g. "hmac-sha256" (trailing dot optional)
-	MinTTL        int64
-	Timeout       time.Duration // DNS operation timeout; 0 uses defaultTimeout (10s)
+	Host string
+	// Hosts lists the zone's DNS servers in failover order, each "host" or
+	// "host:port"; it replaces Host when set. A server that does not answer
+	// is skipped for a cool-off period in favour of the next one.
+	Hosts       []string
+	Port        int // 0 uses 53, or 853 for TransportTLS
+	Zone        string
+	TSIGKeyName string
+	TSIGSecret  string
+	// TSIGSecretFile, if set, holds TSIGSecret and is re-read whenever it
+	// changes (see TSIGKey.SecretFile).
+	TSIGSecretFile string
+	TSIGSecretAlg  string // e.g. "hmac-sha256" (trailing dot optional)
+	// TSIGKeys are further keys, tried in order when the server rejects the
+	// key in use (BADKEY or BADSIG), e.g. the old and new key while a key is
+	// being rotated. The key the server accepted last is used from then on.
+	TSIGKeys []TSIGKey
+	// SIG0Key signs messages with SIG(0) public-key authentication instead of
+	// TSIG (see LoadSIG0Key); the TSIG fields are then ignored.
+	SIG0Key      *SIG0Key
+	MinTTL       int64
+	Timeout      time.Duration // DNS operation timeout; 0 uses defaultTimeout (10s)
+	MaxUpdateRRs int           // records per UPDATE message; 0 = bounded only by message size
+	// Prerequisites makes each UPDATE conditional on the records being as
+	// last read, so concurrent edits are detected instead of overwritten.
+	Prerequisites bool
+	// Transport is TransportTCP (default), TransportUDP or TransportTLS.
+	Transport string
+	// TLS holds the client TLS settings for TransportTLS (see NewTLSConfig);
+	// nil verifies the server against the system roots.
+	TLS *tls.Config
+	// Verify makes ApplyChanges query the records it created or updated
+	// back from the server that accepted the update, reporting any it does
+	// not serve as a *provider.VerifyError.
+	Verify bool
+	// VerifySecondaries are further servers, each "host" or "host:port",
+	// polled over UDP after a verified update until they serve it too, to
+	// measure propagation. Used only with Verify.
+	VerifySecondaries []string
+	// VerifyTimeout bounds how long the secondaries are polled; 0 uses
+	// defaultVerifyTimeout (5s).
+	VerifyTimeout time.Duration
 }
 
 // Provider implements provider.Provider against an RFC2136-capable DNS server.
 type Provider struct {
 	cfg           Config
-	server        string // "host:port"
-	tsigAlg       string // normalised algorithm name (with trailing dot)
+	servers       *serverPool
+	keys          *tsigKeyring // nil without TSIG
 	log           *slog.Logger
 	newTransferer func() dnsTransferer // factory: creates a fresh transferrer per Records() call
 	exchanger     dnsExchanger
+	secondaries   dnsExchanger // unsigned UDP queries to Config.VerifySecondaries
+
+	cacheMu sync.Mutex // serialises zone reads and guards cache
+	cache   *zoneCache // zone content as of the last transfer; nil until the first
 }
 
 // New returns a configured RFC2136 Provider.
 func New(cfg Config, log *slog.Logger) *Provider {
+	if cfg.Transport == "" {
+		cfg.Transport = TransportTCP
+	}
+	if cfg.Transport == TransportTLS && cfg.TLS == nil {
+		cfg.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
 	if cfg.Port == 0 {
 		cfg.Port = 53
+		if cfg.Transport == TransportTLS {
+			cfg.Port = defaultTLSPort
+		}
 	}
 	if cfg.Timeout <= 0 {
 		cfg.Timeout = defaultTimeout
@@ -61,29 +112,23 @@ func New(cfg Config, log *slog.Logger) *Provider {
 	if log == nil {
 		log = slog.Default()
 	}
-	alg := normaliseTSIGAlg(cfg.TSIGSecretAlg)
-	server := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+	keys := newTSIGKeyring(cfg.Zone, tsigKeys(cfg), log)
 
-	tsigSecret := map[string]string{
-		dns.Fqdn(cfg.TSIGKeyName): cfg.TSIGSecret,
+	// Without a key, no TSIG provider: the transfer client would otherwise
+	// demand a signature on every reply.
+	var tsig dns.TsigProvider
+	if keys != nil {
+		tsig = keys
 	}
 
 	return &Provider{
-		cfg:     cfg,
-		server:  server,
-		tsigAlg: alg,
-		log:     log,
-		newTransferer: func() dnsTransferer {
-			return &dns.Transfer{
-				TsigSecret:  tsigSecret,
-				ReadTimeout: cfg.Timeout,
-			}
-		},
-		exchanger: &dns.Client{
-			Net:        "tcp",
-			TsigSecret: tsigSecret,
-			Timeout:    cfg.Timeout,
-		},
+		cfg:           cfg,
+		servers:       newServerPool(cfg.Zone, serverAddrs(cfg), log),
+		keys:          keys,
+		log:           log,
+		newTransferer: newTransferer(cfg, tsig),
+		exchanger:     newExchanger(cfg, tsig),
+		secondaries:   newExchanger(Config{Transport: TransportUDP, Timeout: cfg.Timeout}, nil),
 	}
 }
 
@@ -97,137 +142,202 @@ func newWithDeps(cfg Config, log *slog.Logger, t dnsTransferer, e dnsExchanger)
 	}
 	return &Provider{
 		cfg:           cfg,
-		server:        fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
-		tsigAlg:       normaliseTSIGAlg(cfg.TSIGSecretAlg),
+		servers:       newServerPool(cfg.Zone, serverAddrs(cfg), log),
+		keys:          newTSIGKeyring(cfg.Zone, tsigKeys(cfg), log),
 		log:           log,
 		newTransferer: func() dnsTransferer { return t },
 		exchanger:     e,
+		secondaries:   e,
 	}
 }
 
 // Preflight validates connectivity and TSIG credentials by sending a SOA query
-// to the configured DNS server. Returns an error if the server is unreachable
-// or responds with a non-success rcode (e.g. NOTAUTH on bad TSIG).
+// to each configured DNS server. Returns an error if no server passes, i.e.
+// each is unreachable or responds with a non-success rcode (e.g. NOTAUTH on
+// bad TSIG). Servers that fail while another passes are logged and put in
+// cool-off.
 func (p *Provider) Preflight(ctx context.Context) error {
+	var errs []error
+	for _, addr := range p.servers.addrs {
+		err := p.preflight(ctx, addr)
+		if err == nil {
+			p.servers.markUp(addr)
+			continue
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		p.servers.markDown(addr, err)
+		errs = append(errs, err)
+	}
+	if len(errs) == len(p.servers.addrs) {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// preflight sends the Preflight SOA query to addr.
+func (p *Provider) preflight(ctx context.Context, addr string) error {
 	m := new(dns.Msg)
 	m.SetQuestion(dns.Fqdn(p.cfg.Zone), dns.TypeSOA)
-	if p.cfg.TSIGKeyName != "" {
-		m.SetTsig(dns.Fqdn(p.cfg.TSIGKeyName), p.tsigAlg, 300, time.Now().Unix())
+	if err := p.sign(m); err != nil {
+		return err
+	}
+	var r *dns.Msg
+	err := p.keys.try(m, func() error {
+		var err error
+		r, _, err = p.exchanger.ExchangeContext(ctx, m, addr)
+		if r != nil && tsigRejected(r, err) {
+			return errTSIGRejected
+		}
+		return err
+	})
+	if errors.Is(err, errTSIGRejected) {
+		return fmt.Errorf("preflight SOA query to %s failed: %w — check the TSIG key names and secrets", addr, err)
+	}
+	if err != nil && isTLSError(err) {
+		return fmt.Errorf("preflight TLS handshake with %s failed: %w — check the CA file and TLS server name", addr, err)
 	}
-	r, _, err := p.exchanger.ExchangeContext(ctx, m, p.server)
 	if err != nil {
-		return fmt.Errorf("preflight SOA query to %s failed: %w", p.server, err)
+		return fmt.Errorf("preflight SOA query to %s failed: %w", addr, err)
 	}
 	if r.Rcode != dns.RcodeSuccess {
-		return fmt.Errorf("preflight SOA query failed: rcode %s (%d) — check --rfc2136-host and TSIG credentials",
-			dns.RcodeToString[r.Rcode], r.Rcode)
+		return fmt.Errorf("preflight SOA query to %s failed: rcode %s (%d) — check --rfc2136-host and TSIG credentials",
+			addr, dns.RcodeToString[r.Rcode], r.Rcode)
 	}
 	return nil
 }
 
-// Records fetches the current zone contents via AXFR and returns them as Endpoints.
+// Records returns the current zone contents as Endpoints. The zone is
+// cached: while its SOA serial is unchanged no transfer is made, and when it
+// has moved only the difference is fetched with IXFR (see zoneRRs).
 func (p *Provider) Records(ctx context.Context) ([]*endpoint.Endpoint, error) {
-	m := new(dns.Msg)
-	m.SetAxfr(dns.Fqdn(p.cfg.Zone))
-	if p.cfg.TSIGKeyName != "" {
-		m.SetTsig(dns.Fqdn(p.cfg.TSIGKeyName), p.tsigAlg, 300, time.Now().Unix())
-	}
-
-	env, err := p.newTransferer().In(m, p.server)
+	p.cacheMu.Lock()
+	rrs, err := p.zoneRRs(ctx)
+	p.cacheMu.Unlock()
 	if err != nil {
-		return nil, fmt.Errorf("axfr %s: %w", p.cfg.Zone, err)
+		return nil, err
 	}
 
 	var endpoints []*endpoint.Endpoint
-	for {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case e, ok := <-env:
-			if !ok {
-				return endpoints, nil
-			}
-			if e.Error != nil {
-				return nil, fmt.Errorf("axfr %s: %w", p.cfg.Zone, e.Error)
-			}
-			for _, rr := range e.RR {
-				ep := rrToEndpoint(rr)
-				if ep != nil {
-					endpoints = append(endpoints, ep)
-				}
-			}
+	for _, rr := range rrs {
+		if isApexNS(rr, p.cfg.Zone) {
+			continue // zone metadata, like the SOA
+		}
+		if ep := rrToEndpoint(rr); ep != nil {
+			endpoints = append(endpoints, ep)
 		}
 	}
+	return endpoints, nil
 }
 
-// ApplyChanges sends RFC2136 UPDATE messages to create, update, and delete records.
+// ApplyChanges sends RFC2136 UPDATE messages to create, update, and delete
+// records. Changes are grouped by name, each name's records and ownership
+// TXT records together, and the groups are packed into as few UPDATEs as
+// Config.MaxUpdateRRs and the DNS message size allow. Each UPDATE is applied
+// atomically by the server. With Config.Verify, the records created or
+// updated are then read back (see verify).
 func (p *Provider) ApplyChanges(ctx context.Context, changes *plan.Changes) error {
 	if changes.IsEmpty() {
 		return nil
 	}
+	groups := p.updateGroups(changes)
+	if err := p.sendBatches(ctx, batchGroups(groups, p.cfg.MaxUpdateRRs)); err != nil || !p.cfg.Verify {
+		return err
+	}
+	return p.verify(ctx, groups, time.Now())
+}
 
-	// Collect all RRs into a single UPDATE message for atomicity.
-	m := new(dns.Msg)
-	m.SetUpdate(dns.Fqdn(p.cfg.Zone))
+// ManagesName reports whether dnsName falls inside the provider's zone.
+func (p *Provider) ManagesName(dnsName string) bool {
+	name := strings.ToLower(strings.TrimSuffix(dnsName, "."))
+	zone := strings.ToLower(strings.TrimSuffix(p.cfg.Zone, "."))
+	return name == zone || strings.HasSuffix(name, "."+zone)
+}
 
-	// Deletes: remove exact RR (RFC2136 §2.5.4).
-	for _, ep := range changes.Delete {
-		rrs, err := p.endpointToRRs(ep)
-		if err != nil {
-			p.log.Warn("skipping delete: cannot convert endpoint to RR",
-				"endpoint", ep.DNSName, "err", err)
-			continue
-		}
-		m.Remove(rrs)
+// GetLease reads the leader-election lease stored as a TXT record at name,
+// querying the server directly so the answer is never cached.
+func (p *Provider) GetLease(ctx context.Context, name string) (*provider.Lease, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), dns.TypeTXT)
+	if err := p.sign(m); err != nil {
+		return nil, err
 	}
-
-	// Updates: remove old, insert new.
-	for i, old := range changes.UpdateOld {
-		rrs, err := p.endpointToRRs(old)
-		if err != nil {
-			p.log.Warn("skipping update (remove): cannot convert endpoint to RR",
-				"endpoint", old.DNSName, "err", err)
-			continue
-		}
-		m.Remove(rrs)
-		if i < len(changes.UpdateNew) {
-			newRRs, err := p.endpointToRRs(changes.UpdateNew[i])
-			if err != nil {
-				p.log.Warn("skipping update (insert): cannot convert endpoint to RR",
-					"endpoint", changes.UpdateNew[i].DNSName, "err", err)
-				continue
-			}
-			m.Insert(newRRs)
+	r, err := p.exchange(ctx, m)
+	if err != nil {
+		return nil, fmt.Errorf("lease query %s: %w", name, err)
+	}
+	if r.Rcode == dns.RcodeNameError {
+		return nil, nil
+	}
+	if r.Rcode != dns.RcodeSuccess {
+		return nil, fmt.Errorf("lease query %s failed: rcode %s (%d)", name, dns.RcodeToString[r.Rcode], r.Rcode)
+	}
+	for _, rr := range r.Answer {
+		if txt, ok := rr.(*dns.TXT); ok {
+			return provider.ParseLease(strings.Join(txt.Txt, ""))
 		}
 	}
+	return nil, nil
+}
 
-	// Creates: insert new RRs.
-	for _, ep := range changes.Create {
-		rrs, err := p.endpointToRRs(ep)
-		if err != nil {
-			p.log.Warn("skipping create: cannot convert endpoint to RR",
-				"endpoint", ep.DNSName, "err", err)
-			continue
+// LeaseZone returns a Provider for zone that reaches p's servers with p's
+// credentials, to keep the leader-election lease in a zone of its own: every
+// lease renewal bumps the SOA serial of the zone holding it, which would
+// otherwise invalidate the records cache of a managed zone and make its
+// secondaries transfer it on every renewal.
+func (p *Provider) LeaseZone(zone string) *Provider {
+	cfg := p.cfg
+	cfg.Zone = zone
+	return New(cfg, p.log)
+}
+
+// SwapLease replaces the lease TXT record at name in a single UPDATE whose
+// prerequisite (RFC2136 §2.4) is that the record still holds old, or does not
+// exist when old is nil. The server rejects the update if another replica got
+// there first, which is reported as provider.ErrLeaseConflict.
+func (p *Provider) SwapLease(ctx context.Context, name string, old, next *provider.Lease) error {
+	fqdn := dns.Fqdn(name)
+	leaseRR := func(l *provider.Lease) dns.RR {
+		ttl := p.effectiveTTL(max(int64(l.Duration.Seconds()), 1))
+		return &dns.TXT{
+			Hdr: dns.RR_Header{Name: fqdn, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: uint32(ttl)},
+			Txt: []string{l.String()},
 		}
-		m.Insert(rrs)
 	}
+	rrset := []dns.RR{&dns.TXT{Hdr: dns.RR_Header{Name: fqdn, Rrtype: dns.TypeTXT}}}
 
-	if p.cfg.TSIGKeyName != "" {
-		m.SetTsig(dns.Fqdn(p.cfg.TSIGKeyName), p.tsigAlg, 300, time.Now().Unix())
+	m := new(dns.Msg)
+	m.SetUpdate(dns.Fqdn(p.cfg.Zone))
+	if old == nil {
+		m.RRsetNotUsed(rrset)
+	} else {
+		m.Used([]dns.RR{leaseRR(old)})
+	}
+	m.RemoveRRset(rrset)
+	if next != nil {
+		m.Insert([]dns.RR{leaseRR(next)})
+	}
+	if err := p.sign(m); err != nil {
+		return err
 	}
 
-	r, _, err := p.exchanger.ExchangeContext(ctx, m, p.server)
+	r, err := p.exchange(ctx, m)
 	if err != nil {
-		return fmt.Errorf("dns update exchange: %w", err)
+		return fmt.Errorf("lease update %s: %w", name, err)
 	}
-	if r.Rcode != dns.RcodeSuccess {
-		return fmt.Errorf("dns update failed: rcode %s (%d)", dns.RcodeToString[r.Rcode], r.Rcode)
+	switch r.Rcode {
+	case dns.RcodeSuccess:
+		return nil
+	case dns.RcodeNXRrset, dns.RcodeYXRrset:
+		return provider.ErrLeaseConflict
+	default:
+		return fmt.Errorf("lease update %s failed: rcode %s (%d)", name, dns.RcodeToString[r.Rcode], r.Rcode)
 	}
-	return nil
 }
 
 // rrToEndpoint converts a miekg/dns RR to an Endpoint. Returns nil for
-// unsupported or zone-metadata record types (SOA, NS, TSIG, etc.).
+// unsupported or zone-metadata record types (SOA, TSIG, etc.).
 func rrToEndpoint(rr dns.RR) *endpoint.Endpoint {
 	hdr := rr.Header()
 	name := strings.TrimSuffix(hdr.Name, ".")
@@ -242,11 +352,31 @@ func rrToEndpoint(rr dns.RR) *endpoint.Endpoint {
 		return endpoint.New(name, []string{strings.TrimSuffix(v.Target, ".")}, endpoint.RecordTypeCNAME, ttl, nil)
 	case *dns.TXT:
 		return endpoint.New(name, v.Txt, endpoint.RecordTypeTXT, ttl, nil)
+	case *dns.MX:
+		t := endpoint.MXTarget{Preference: v.Preference, Host: strings.TrimSuffix(v.Mx, ".")}
+		return endpoint.New(name, []string{t.String()}, endpoint.RecordTypeMX, ttl, nil)
+	case *dns.SRV:
+		t := endpoint.SRVTarget{Priority: v.Priority, Weight: v.Weight, Port: v.Port, Target: strings.TrimSuffix(v.Target, ".")}
+		return endpoint.New(name, []string{t.String()}, endpoint.RecordTypeSRV, ttl, nil)
+	case *dns.CAA:
+		t := endpoint.CAATarget{Flag: v.Flag, Tag: v.Tag, Value: v.Value}
+		return endpoint.New(name, []string{t.String()}, endpoint.RecordTypeCAA, ttl, nil)
+	case *dns.NS:
+		return endpoint.New(name, []string{strings.TrimSuffix(v.Ns, ".")}, endpoint.RecordTypeNS, ttl, nil)
+	case *dns.PTR:
+		return endpoint.New(name, []string{strings.TrimSuffix(v.Ptr, ".")}, endpoint.RecordTypePTR, ttl, nil)
 	default:
 		return nil
 	}
 }
 
+// isApexNS reports whether rr is an NS record at the apex of zone. These
+// belong to the zone itself and are never reported as managed records.
+func isApexNS(rr dns.RR, zone string) bool {
+	hdr := rr.Header()
+	return hdr.Rrtype == dns.TypeNS && strings.EqualFold(hdr.Name, dns.Fqdn(zone))
+}
+
 // endpointToRRs converts an Endpoint to one or more miekg/dns RRs.
 func (p *Provider) endpointToRRs(ep *endpoint.Endpoint) ([]dns.RR, error) {
 	ttl := p.effectiveTTL(ep.TTL)
@@ -277,6 +407,28 @@ func (p *Provider) endpointToRRs(ep *endpoint.Endpoint) ([]dns.RR, error) {
 			rrs = append(rrs, &dns.CNAME{Hdr: hdr, Target: dns.Fqdn(target)})
 		case endpoint.RecordTypeTXT:
 			rrs = append(rrs, &dns.TXT{Hdr: hdr, Txt: []string{target}})
+		case endpoint.RecordTypeMX:
+			t, err := endpoint.ParseMXTarget(target)
+			if err != nil {
+				return nil, err
+			}
+			rrs = append(rrs, &dns.MX{Hdr: hdr, Preference: t.Preference, Mx: dns.Fqdn(t.Host)})
+		case endpoint.RecordTypeSRV:
+			t, err := endpoint.ParseSRVTarget(target)
+			if err != nil {
+				return nil, err
+			}
+			rrs = append(rrs, &dns.SRV{Hdr: hdr, Priority: t.Priority, Weight: t.Weight, Port: t.Port, Target: dns.Fqdn(t.Target)})
+		case endpoint.RecordTypeCAA:
+			t, err := endpoint.ParseCAATarget(target)
+			if err != nil {
+				return nil, err
+			}
+			rrs = append(rrs, &dns.CAA{Hdr: hdr, Flag: t.Flag, Tag: t.Tag, Value: t.Value})
+		case endpoint.RecordTypeNS:
+			rrs = append(rrs, &dns.NS{Hdr: hdr, Ns: dns.Fqdn(target)})
+		case endpoint.RecordTypePTR:
+			rrs = append(rrs, &dns.PTR{Hdr: hdr, Ptr: dns.Fqdn(target)})
 		default:
 			return nil, fmt.Errorf("unsupported record type %q", ep.RecordType)
 		}
@@ -303,6 +455,16 @@ func rrType(rt string) uint16 {
 		return dns.TypeCNAME
 	case endpoint.RecordTypeTXT:
 		return dns.TypeTXT
+	case endpoint.RecordTypeMX:
+		return dns.TypeMX
+	case endpoint.RecordTypeSRV:
+		return dns.TypeSRV
+	case endpoint.RecordTypeCAA:
+		return dns.TypeCAA
+	case endpoint.RecordTypeNS:
+		return dns.TypeNS
+	case endpoint.RecordTypePTR:
+		return dns.TypePTR
 	default:
 		return dns.TypeNone
 	}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/bkero/external-dns-docker/pkg/endpoint"
 	"github.com/bkero/external-dns-docker/pkg/plan"
+	"github.com/bkero/external-dns-docker/pkg/provider"
 )
 
 // --- Mock helpers ---
@@ -363,8 +364,8 @@ func TestNew_DefaultPort(t *testing.T) {
 	if p.cfg.Port != 53 {
 		t.Errorf("Port = %d, want 53 (default)", p.cfg.Port)
 	}
-	if p.server != "ns1.example.com:53" {
-		t.Errorf("server = %q, want ns1.example.com:53", p.server)
+	if got := p.servers.String(); got != "ns1.example.com:53" {
+		t.Errorf("servers = %q, want ns1.example.com:53", got)
 	}
 }
 
@@ -476,12 +477,53 @@ func TestEndpointToRRs_InvalidAAAAIP(t *testing.T) {
 
 func TestEndpointToRRs_UnsupportedType(t *testing.T) {
 	p := newWithDeps(Config{Host: "ns1", Zone: "example.com"}, nil, nil, nil)
-	_, err := p.endpointToRRs(endpoint.New("app.example.com", []string{"1.2.3.4"}, "MX", 300, nil))
+	_, err := p.endpointToRRs(endpoint.New("app.example.com", []string{"1.2.3.4"}, "HINFO", 300, nil))
 	if err == nil {
 		t.Error("expected error for unsupported record type, got nil")
 	}
 }
 
+func TestEndpointToRRs_StructuredTypes(t *testing.T) {
+	p := newWithDeps(Config{Host: "ns1", Zone: "example.com"}, nil, nil, nil)
+	tests := []struct {
+		name, rt, target, want string
+	}{
+		{"example.com", endpoint.RecordTypeMX, "10 mail.example.com",
+			"example.com.\t300\tIN\tMX\t10 mail.example.com."},
+		{"_sip._tcp.example.com", endpoint.RecordTypeSRV, "10 5 5060 sip.example.com",
+			"_sip._tcp.example.com.\t300\tIN\tSRV\t10 5 5060 sip.example.com."},
+		{"example.com", endpoint.RecordTypeCAA, `0 issue "letsencrypt.org"`,
+			"example.com.\t300\tIN\tCAA\t0 issue \"letsencrypt.org\""},
+		{"sub.example.com", endpoint.RecordTypeNS, "ns1.example.net",
+			"sub.example.com.\t300\tIN\tNS\tns1.example.net."},
+		{"5.2.0.192.in-addr.arpa", endpoint.RecordTypePTR, "app.example.com",
+			"5.2.0.192.in-addr.arpa.\t300\tIN\tPTR\tapp.example.com."},
+	}
+	for _, tt := range tests {
+		rrs, err := p.endpointToRRs(endpoint.New(tt.name, []string{tt.target}, tt.rt, 300, nil))
+		if err != nil {
+			t.Errorf("%s: endpointToRRs() error = %v", tt.rt, err)
+			continue
+		}
+		if len(rrs) != 1 || rrs[0].String() != tt.want {
+			t.Errorf("%s: got %v, want %q", tt.rt, rrs, tt.want)
+		}
+	}
+}
+
+func TestEndpointToRRs_InvalidStructuredTarget(t *testing.T) {
+	p := newWithDeps(Config{Host: "ns1", Zone: "example.com"}, nil, nil, nil)
+	for rt, target := range map[string]string{
+		endpoint.RecordTypeMX:  "mail.example.com",
+		endpoint.RecordTypeSRV: "10 5 sip.example.com",
+		endpoint.RecordTypeCAA: "0 issue",
+	} {
+		if _, err := p.endpointToRRs(endpoint.New("example.com", []string{target}, rt, 300, nil)); err == nil {
+			t.Errorf("%s %q: expected error, got nil", rt, target)
+		}
+	}
+}
+
 // --- ApplyChanges: invalid endpoint warning paths ---
 
 func TestApplyChanges_InvalidCreateEndpoint_Skipped(t *testing.T) {
@@ -557,6 +599,11 @@ func TestRRType(t *testing.T) {
 		{endpoint.RecordTypeAAAA, "AAAA"},
 		{endpoint.RecordTypeCNAME, "CNAME"},
 		{endpoint.RecordTypeTXT, "TXT"},
+		{endpoint.RecordTypeMX, "MX"},
+		{endpoint.RecordTypeSRV, "SRV"},
+		{endpoint.RecordTypeCAA, "CAA"},
+		{endpoint.RecordTypeNS, "NS"},
+		{endpoint.RecordTypePTR, "PTR"},
 	}
 	for _, tt := range tests {
 		got := rrType(tt.in)
@@ -567,8 +614,8 @@ func TestRRType(t *testing.T) {
 }
 
 func TestRRType_Unknown(t *testing.T) {
-	if got := rrType("MX"); got != dns.TypeNone {
-		t.Errorf("rrType(MX) = %d, want TypeNone (%d)", got, dns.TypeNone)
+	if got := rrType("HINFO"); got != dns.TypeNone {
+		t.Errorf("rrType(HINFO) = %d, want TypeNone (%d)", got, dns.TypeNone)
 	}
 }
 
@@ -595,6 +642,165 @@ func TestRRToEndpoint_TrailingDotStripped(t *testing.T) {
 	}
 }
 
+func TestRRToEndpoint_StructuredTypes_RoundTrip(t *testing.T) {
+	p := newWithDeps(Config{Host: "ns1", Zone: "example.com"}, nil, nil, nil)
+	for _, zone := range []string{
+		"example.com. 300 IN MX 10 Mail.Example.com.",
+		"_sip._tcp.example.com. 300 IN SRV 10 5 5060 sip.example.com.",
+		`example.com. 300 IN CAA 0 issue "letsencrypt.org; validationmethods=dns-01"`,
+		"sub.example.com. 300 IN NS ns1.example.net.",
+		"5.2.0.192.in-addr.arpa. 300 IN PTR app.example.com.",
+	} {
+		rr, err := dns.NewRR(zone)
+		if err != nil {
+			t.Fatalf("NewRR(%q): %v", zone, err)
+		}
+		ep := rrToEndpoint(rr)
+		if ep == nil {
+			t.Errorf("rrToEndpoint(%q) = nil", zone)
+			continue
+		}
+		back, err := p.endpointToRRs(ep)
+		if err != nil {
+			t.Errorf("endpointToRRs(%v) error = %v", ep, err)
+			continue
+		}
+		if !dns.IsDuplicate(rr, back[0]) {
+			t.Errorf("round trip of %q produced %q", zone, back[0].String())
+		}
+	}
+}
+
+func TestRecords_IgnoresApexNS(t *testing.T) {
+	apexNS, _ := dns.NewRR("example.com. 3600 IN NS ns1.example.com.")
+	delegation, _ := dns.NewRR("sub.example.com. 3600 IN NS ns1.example.net.")
+	mt := &mockTransferer{envelopes: []*dns.Envelope{{RR: []dns.RR{apexNS, delegation}}}}
+	eps, err := testProvider(mt, nil).Records(context.Background())
+	if err != nil {
+		t.Fatalf("Records() error = %v", err)
+	}
+	if len(eps) != 1 || eps[0].DNSName != "sub.example.com" {
+		t.Errorf("got %v, want only the sub.example.com delegation", eps)
+	}
+}
+
+func TestManagesName(t *testing.T) {
+	p := testProvider(nil, nil)
+	for name, want := range map[string]bool{
+		"example.com":      true,
+		"app.example.com.": true,
+		"APP.Example.com":  true,
+		"myexample.com":    false,
+		"example.org":      false,
+	} {
+		if got := p.ManagesName(name); got != want {
+			t.Errorf("ManagesName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+// --- Lease tests ---
+
+func TestGetLease_ParsesTXT(t *testing.T) {
+	want := &provider.Lease{Holder: "node-1", Renewed: time.Unix(1_700_000_000, 0), Duration: 15 * time.Second}
+	resp := successResp()
+	resp.Answer = []dns.RR{&dns.TXT{
+		Hdr: dns.RR_Header{Name: "leader.example.com.", Rrtype: dns.TypeTXT, Class: dns.ClassINET},
+		Txt: []string{want.String()},
+	}}
+	me := &mockExchanger{resp: resp}
+	p := testProvider(nil, me)
+
+	got, err := p.GetLease(context.Background(), "leader.example.com")
+	if err != nil {
+		t.Fatalf("GetLease() error = %v", err)
+	}
+	if !got.Equal(want) {
+		t.Errorf("GetLease() = %v, want %v", got, want)
+	}
+	if q := me.sent.Question[0]; q.Name != "leader.example.com." || q.Qtype != dns.TypeTXT {
+		t.Errorf("query = %v, want TXT leader.example.com.", q)
+	}
+}
+
+func TestGetLease_Missing_ReturnsNil(t *testing.T) {
+	resp := new(dns.Msg)
+	resp.Rcode = dns.RcodeNameError
+	p := testProvider(nil, &mockExchanger{resp: resp})
+
+	got, err := p.GetLease(context.Background(), "leader.example.com")
+	if err != nil || got != nil {
+		t.Errorf("GetLease() = %v, %v; want nil, nil", got, err)
+	}
+}
+
+func TestSwapLease_Acquire_RequiresAbsentRRset(t *testing.T) {
+	me := &mockExchanger{resp: successResp()}
+	p := testProvider(nil, me)
+	next := &provider.Lease{Holder: "node-1", Renewed: time.Unix(1_700_000_000, 0), Duration: 15 * time.Second}
+
+	if err := p.SwapLease(context.Background(), "leader.example.com", nil, next); err != nil {
+		t.Fatalf("SwapLease() error = %v", err)
+	}
+	if len(me.sent.Answer) != 1 {
+		t.Fatalf("got %d prerequisites, want 1", len(me.sent.Answer))
+	}
+	if h := me.sent.Answer[0].Header(); h.Class != dns.ClassNONE || h.Rrtype != dns.TypeTXT {
+		t.Errorf("prerequisite = %v, want TXT RRset does not exist", me.sent.Answer[0])
+	}
+	var inserted *dns.TXT
+	for _, rr := range me.sent.Ns {
+		if txt, ok := rr.(*dns.TXT); ok && txt.Hdr.Class == dns.ClassINET {
+			inserted = txt
+		}
+	}
+	if inserted == nil || inserted.Txt[0] != next.String() {
+		t.Errorf("inserted = %v, want lease %q", inserted, next)
+	}
+}
+
+func TestSwapLease_Renew_RequiresOldValue(t *testing.T) {
+	me := &mockExchanger{resp: successResp()}
+	p := testProvider(nil, me)
+	old := &provider.Lease{Holder: "node-1", Renewed: time.Unix(1_700_000_000, 0), Duration: 15 * time.Second}
+	next := &provider.Lease{Holder: "node-1", Renewed: time.Unix(1_700_000_002, 0), Duration: 15 * time.Second}
+
+	if err := p.SwapLease(context.Background(), "leader.example.com", old, next); err != nil {
+		t.Fatalf("SwapLease() error = %v", err)
+	}
+	prereq, ok := me.sent.Answer[0].(*dns.TXT)
+	if !ok || prereq.Txt[0] != old.String() || prereq.Hdr.Class != dns.ClassINET {
+		t.Errorf("prerequisite = %v, want value-dependent TXT %q", me.sent.Answer[0], old)
+	}
+}
+
+func TestSwapLease_PrerequisiteFailed_ReturnsConflict(t *testing.T) {
+	for _, rcode := range []int{dns.RcodeNXRrset, dns.RcodeYXRrset} {
+		resp := new(dns.Msg)
+		resp.Rcode = rcode
+		p := testProvider(nil, &mockExchanger{resp: resp})
+		next := &provider.Lease{Holder: "node-1", Renewed: time.Now(), Duration: 15 * time.Second}
+
+		err := p.SwapLease(context.Background(), "leader.example.com", nil, next)
+		if !errors.Is(err, provider.ErrLeaseConflict) {
+			t.Errorf("rcode %s: SwapLease() error = %v, want ErrLeaseConflict", dns.RcodeToString[rcode], err)
+		}
+	}
+}
+
+func TestSwapLease_Release_RemovesOnly(t *testing.T) {
+	me := &mockExchanger{resp: successResp()}
+	p := testProvider(nil, me)
+	old := &provider.Lease{Holder: "node-1", Renewed: time.Unix(1_700_000_000, 0), Duration: 15 * time.Second}
+
+	if err := p.SwapLease(context.Background(), "leader.example.com", old, nil); err != nil {
+		t.Fatalf("SwapLease() error = %v", err)
+	}
+	if len(me.sent.Ns) != 1 || me.sent.Ns[0].Header().Class != dns.ClassANY {
+		t.Errorf("update = %v, want a single RRset removal", me.sent.Ns)
+	}
+}
+
 // --- Preflight tests ---
 
 func TestPreflight_Success(t *testing.T) {
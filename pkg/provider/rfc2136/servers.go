@@ -0,0 +1,207 @@
+package rfc2136
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Per-server health metrics, registered on the default registry.
+var (
+	serverUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "external_dns_docker_zone_server_up",
+		Help: "1 if the last request to the DNS server got an answer, 0 if it failed and the server is cooling off.",
+	}, []string{"zone", "server"})
+
+	serverActive = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "external_dns_docker_zone_server_active",
+		Help: "1 for the DNS server that answered the zone's last request, 0 for the zone's other servers.",
+	}, []string{"zone", "server"})
+)
+
+// serverCooloff is how long a server that failed to answer is passed over
+// in favour of the zone's other servers.
+const serverCooloff = 30 * time.Second
+
+// unavailableError wraps an error meaning the server gave no answer at all,
+// so the request may be retried on the next server.
+type unavailableError struct{ err error }
+
+func (e *unavailableError) Error() string { return e.err.Error() }
+func (e *unavailableError) Unwrap() error { return e.err }
+
+// serverPool holds a zone's DNS servers in failover order and tracks their
+// health. Requests go to the first server that is not cooling off after a
+// failure, so traffic returns to the preferred server once it recovers.
+type serverPool struct {
+	zone    string // dns.Fqdn-normalised, used as the metric label
+	addrs   []string
+	cooloff time.Duration
+	now     func() time.Time
+	log     *slog.Logger
+
+	mu        sync.Mutex
+	downUntil map[string]time.Time
+	active    string // server that answered last; "" before the first answer
+}
+
+func newServerPool(zone string, addrs []string, log *slog.Logger) *serverPool {
+	return &serverPool{
+		zone:      dns.Fqdn(zone),
+		addrs:     addrs,
+		cooloff:   serverCooloff,
+		now:       time.Now,
+		log:       log,
+		downUntil: make(map[string]time.Time),
+	}
+}
+
+// serverAddrs returns "host:port" for each of cfg's servers: Hosts, or Host
+// when Hosts is empty. Entries without a port get cfg.Port.
+func serverAddrs(cfg Config) []string {
+	hosts := cfg.Hosts
+	if len(hosts) == 0 {
+		hosts = []string{cfg.Host}
+	}
+	addrs := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		if _, _, err := net.SplitHostPort(h); err == nil {
+			addrs = append(addrs, h)
+			continue
+		}
+		addrs = append(addrs, net.JoinHostPort(h, strconv.Itoa(cfg.Port)))
+	}
+	return addrs
+}
+
+// String lists the servers in failover order.
+func (sp *serverPool) String() string {
+	return strings.Join(sp.addrs, ", ")
+}
+
+// order returns the servers to try: those not cooling off in configured
+// order, then the rest, soonest back first. Servers that are cooling off are
+// still tried last, so a request is attempted even when all recently failed.
+func (sp *serverPool) order() []string {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	now := sp.now()
+	var ready, cooling []string
+	for _, addr := range sp.addrs {
+		if now.Before(sp.downUntil[addr]) {
+			cooling = append(cooling, addr)
+		} else {
+			ready = append(ready, addr)
+		}
+	}
+	slices.SortStableFunc(cooling, func(a, b string) int {
+		return sp.downUntil[a].Compare(sp.downUntil[b])
+	})
+	return append(ready, cooling...)
+}
+
+// current returns the server that answered last, or "" if none has.
+func (sp *serverPool) current() string {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	return sp.active
+}
+
+// markUp records that addr answered.
+func (sp *serverPool) markUp(addr string) {
+	sp.mu.Lock()
+	delete(sp.downUntil, addr)
+	sp.mu.Unlock()
+	serverUp.WithLabelValues(sp.zone, addr).Set(1)
+}
+
+// markDown puts addr in cool-off after it failed to answer.
+func (sp *serverPool) markDown(addr string, err error) {
+	sp.mu.Lock()
+	sp.downUntil[addr] = sp.now().Add(sp.cooloff)
+	sp.mu.Unlock()
+	serverUp.WithLabelValues(sp.zone, addr).Set(0)
+	if len(sp.addrs) > 1 {
+		sp.log.Warn("dns server unavailable, cooling off", "zone", sp.zone, "server", addr, "cooloff", sp.cooloff, "err", err)
+	}
+}
+
+// use makes addr the active server, logging when it changes.
+func (sp *serverPool) use(addr string) {
+	sp.mu.Lock()
+	prev := sp.active
+	sp.active = addr
+	sp.mu.Unlock()
+	if prev == addr {
+		return
+	}
+	if prev != "" {
+		serverActive.WithLabelValues(sp.zone, prev).Set(0)
+		sp.log.Info("switched dns server", "zone", sp.zone, "server", addr, "previous", prev)
+	}
+	serverActive.WithLabelValues(sp.zone, addr).Set(1)
+}
+
+// try calls op with each server in failover order until one answers, i.e.
+// op returns anything but an *unavailableError, and returns op's result.
+// Servers that fail to answer are put in cool-off.
+func (sp *serverPool) try(ctx context.Context, op func(addr string) error) error {
+	addrs := sp.order()
+	var last error
+	for _, addr := range addrs {
+		err := op(addr)
+		if err != nil && ctx.Err() != nil {
+			return err // cancelled, not the server's fault
+		}
+		var ue *unavailableError
+		if !errors.As(err, &ue) {
+			sp.markUp(addr)
+			sp.use(addr)
+			return err
+		}
+		sp.markDown(addr, ue.err)
+		last = ue.err
+	}
+	if len(addrs) == 1 {
+		return last
+	}
+	return fmt.Errorf("all %d servers failed, last: %w", len(addrs), last)
+}
+
+// exchange sends m to the zone's servers in failover order and returns the
+// first response. Any response counts, whatever its rcode; only servers that
+// cannot be reached are skipped. A TSIG-signed m is re-signed with the
+// zone's other keys while the server rejects the key; if it rejects them
+// all, its last (NOTAUTH) response is returned.
+func (p *Provider) exchange(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	var r *dns.Msg
+	err := p.keys.try(m, func() error {
+		return p.servers.try(ctx, func(addr string) error {
+			resp, _, err := p.exchanger.ExchangeContext(ctx, m, addr)
+			if resp != nil && tsigRejected(resp, err) {
+				r = resp
+				return errTSIGRejected
+			}
+			if err != nil {
+				return &unavailableError{err}
+			}
+			r = resp
+			return nil
+		})
+	})
+	if errors.Is(err, errTSIGRejected) {
+		return r, nil // reported by the caller as NOTAUTH
+	}
+	return r, err
+}
@@ -0,0 +1,173 @@
+package rfc2136
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/bkero/external-dns-docker/pkg/endpoint"
+	"github.com/bkero/external-dns-docker/pkg/plan"
+)
+
+// serverExchanger fails every request to the servers in down and answers
+// the rest with success, recording the servers asked in order.
+type serverExchanger struct {
+	down  map[string]bool
+	rcode int
+	asked []string
+}
+
+func (s *serverExchanger) ExchangeContext(_ context.Context, _ *dns.Msg, addr string) (*dns.Msg, time.Duration, error) {
+	s.asked = append(s.asked, addr)
+	if s.down[addr] {
+		return nil, 0, errors.New("connection refused")
+	}
+	r := soaResp(1)
+	r.Rcode = s.rcode
+	return r, 0, nil
+}
+
+// failoverProvider returns a provider for example.com with two servers and
+// a controllable clock.
+func failoverProvider(se *serverExchanger) (*Provider, *time.Time) {
+	p := newWithDeps(Config{Hosts: []string{"ns1.example.com", "ns2.example.com:5353"}, Zone: "example.com"}, nil, nil, se)
+	now := time.Unix(1_000_000, 0)
+	p.servers.now = func() time.Time { return now }
+	return p, &now
+}
+
+func createApp(t *testing.T, p *Provider) error {
+	t.Helper()
+	return p.ApplyChanges(context.Background(), &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.New("app.example.com", []string{"10.0.0.1"}, endpoint.RecordTypeA, 300, nil),
+	}})
+}
+
+func TestServerAddrs_PortsAndFallback(t *testing.T) {
+	got := serverAddrs(Config{Hosts: []string{"ns1.example.com", "ns2.example.com:5353", "2001:db8::1"}, Port: 53})
+	want := []string{"ns1.example.com:53", "ns2.example.com:5353", "[2001:db8::1]:53"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("serverAddrs = %v, want %v", got, want)
+	}
+	if got := serverAddrs(Config{Host: "ns1.example.com", Port: 53}); len(got) != 1 || got[0] != "ns1.example.com:53" {
+		t.Errorf("serverAddrs without Hosts = %v, want [ns1.example.com:53]", got)
+	}
+}
+
+func TestServers_Failover_UsesNextServer(t *testing.T) {
+	se := &serverExchanger{down: map[string]bool{"ns1.example.com:53": true}}
+	p, _ := failoverProvider(se)
+
+	if err := createApp(t, p); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	if strings.Join(se.asked, " ") != "ns1.example.com:53 ns2.example.com:5353" {
+		t.Errorf("servers asked = %v, want ns1 then ns2", se.asked)
+	}
+	if got := p.servers.current(); got != "ns2.example.com:5353" {
+		t.Errorf("active server = %q, want ns2.example.com:5353", got)
+	}
+	if v := testutil.ToFloat64(serverUp.WithLabelValues("example.com.", "ns1.example.com:53")); v != 0 {
+		t.Errorf("server_up for ns1 = %v, want 0", v)
+	}
+	if v := testutil.ToFloat64(serverActive.WithLabelValues("example.com.", "ns2.example.com:5353")); v != 1 {
+		t.Errorf("server_active for ns2 = %v, want 1", v)
+	}
+}
+
+func TestServers_Cooloff_SkipsFailedServerThenReturns(t *testing.T) {
+	se := &serverExchanger{down: map[string]bool{"ns1.example.com:53": true}}
+	p, now := failoverProvider(se)
+	if err := createApp(t, p); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+
+	// ns1 recovers but is still cooling off: the next request skips it.
+	se.down = nil
+	se.asked = nil
+	if err := createApp(t, p); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	if strings.Join(se.asked, " ") != "ns2.example.com:5353" {
+		t.Errorf("servers asked during cool-off = %v, want only ns2", se.asked)
+	}
+
+	*now = now.Add(serverCooloff)
+	se.asked = nil
+	if err := createApp(t, p); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	if strings.Join(se.asked, " ") != "ns1.example.com:53" || p.servers.current() != "ns1.example.com:53" {
+		t.Errorf("servers asked after cool-off = %v, want ns1 again", se.asked)
+	}
+}
+
+func TestServers_AllDown_ReturnsError(t *testing.T) {
+	se := &serverExchanger{down: map[string]bool{"ns1.example.com:53": true, "ns2.example.com:5353": true}}
+	p, _ := failoverProvider(se)
+
+	err := createApp(t, p)
+	if err == nil || !strings.Contains(err.Error(), "all 2 servers failed") {
+		t.Fatalf("error = %v, want all servers failed", err)
+	}
+
+	// Both are cooling off, but requests are still attempted.
+	se.asked = nil
+	_ = createApp(t, p)
+	if len(se.asked) != 2 {
+		t.Errorf("servers asked = %v, want both tried", se.asked)
+	}
+}
+
+func TestServers_ErrorRcode_NoFailover(t *testing.T) {
+	se := &serverExchanger{rcode: dns.RcodeRefused}
+	p, _ := failoverProvider(se)
+
+	if err := createApp(t, p); err == nil {
+		t.Fatal("expected an error for REFUSED")
+	}
+	if len(se.asked) != 1 {
+		t.Errorf("servers asked = %v, want only ns1 (it answered)", se.asked)
+	}
+}
+
+func TestServers_Transfer_FailsOver(t *testing.T) {
+	st := &scriptedTransferer{
+		replies: [][]dns.RR{nil, {soaRR(1), aRR("app.example.com.", "10.0.0.1"), soaRR(1)}},
+		errs:    []error{errors.New("connection refused")},
+	}
+	p, _ := failoverProvider(&serverExchanger{})
+	p.newTransferer = func() dnsTransferer { return st }
+
+	got := targets(t, p)
+	if len(st.reqs) != 2 || !got["app.example.com=10.0.0.1"] {
+		t.Errorf("transfers = %d, records = %v, want the zone from the second server", len(st.reqs), got)
+	}
+}
+
+func TestPreflight_OneServerDown_Passes(t *testing.T) {
+	se := &serverExchanger{down: map[string]bool{"ns2.example.com:5353": true}}
+	p, _ := failoverProvider(se)
+
+	if err := p.Preflight(context.Background()); err != nil {
+		t.Fatalf("Preflight() error = %v", err)
+	}
+	if len(se.asked) != 2 {
+		t.Errorf("servers checked = %v, want both", se.asked)
+	}
+}
+
+func TestPreflight_AllServersDown_Fails(t *testing.T) {
+	se := &serverExchanger{down: map[string]bool{"ns1.example.com:53": true, "ns2.example.com:5353": true}}
+	p, _ := failoverProvider(se)
+
+	err := p.Preflight(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "ns1.example.com:53") || !strings.Contains(err.Error(), "ns2.example.com:5353") {
+		t.Errorf("Preflight() error = %v, want failures for both servers", err)
+	}
+}
@@ -0,0 +1,124 @@
+package rfc2136
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/miekg/dns"
+
+	"github.com/bkero/external-dns-docker/pkg/endpoint"
+	"github.com/bkero/external-dns-docker/pkg/plan"
+)
+
+// writeSIG0Key generates an ECDSA P-256 key for client.example.com. and
+// writes it in BIND format, returning the public KEY and the .private path.
+func writeSIG0Key(t *testing.T) (*dns.KEY, string) {
+	t.Helper()
+	key := &dns.KEY{DNSKEY: dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: "client.example.com.", Rrtype: dns.TypeKEY, Class: dns.ClassINET},
+		Flags:     512,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}}
+	priv, err := key.Generate(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := filepath.Join(t.TempDir(), "Kclient.example.com.+013+00001")
+	pub := "; This is a key for client.example.com.\n" + key.String() + "\n"
+	if err := os.WriteFile(base+".key", []byte(pub), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(base+".private", []byte(key.PrivateKeyString(priv)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return key, base + ".private"
+}
+
+// verifySIG0 checks that m, as it would go on the wire, ends in a valid
+// SIG(0) record made with key.
+func verifySIG0(t *testing.T, m *dns.Msg, key *dns.KEY) {
+	t.Helper()
+	if len(m.Extra) == 0 {
+		t.Fatal("message carries no additional records")
+	}
+	sig, ok := m.Extra[len(m.Extra)-1].(*dns.SIG)
+	if !ok {
+		t.Fatalf("last additional record = %v, want a SIG", m.Extra[len(m.Extra)-1])
+	}
+	buf, err := m.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sig.Verify(key, buf); err != nil {
+		t.Errorf("SIG(0) does not verify: %v", err)
+	}
+}
+
+func TestLoadSIG0Key_BINDFiles(t *testing.T) {
+	_, path := writeSIG0Key(t)
+	k, err := LoadSIG0Key(path)
+	if err != nil {
+		t.Fatalf("LoadSIG0Key() error = %v", err)
+	}
+	if k.Name() != "client.example.com." {
+		t.Errorf("Name() = %q, want client.example.com.", k.Name())
+	}
+}
+
+func TestLoadSIG0Key_NotPrivateFile_ReturnsError(t *testing.T) {
+	if _, err := LoadSIG0Key("/run/secrets/sig0"); err == nil {
+		t.Error("expected an error for a path without .private")
+	}
+}
+
+func TestLoadSIG0Key_MissingPublicKey_ReturnsError(t *testing.T) {
+	_, path := writeSIG0Key(t)
+	if err := os.Remove(path[:len(path)-len(".private")] + ".key"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSIG0Key(path); err == nil {
+		t.Error("expected an error when the .key file is missing")
+	}
+}
+
+func TestApplyChanges_SIG0_SignsUpdate(t *testing.T) {
+	key, path := writeSIG0Key(t)
+	k, err := LoadSIG0Key(path)
+	if err != nil {
+		t.Fatalf("LoadSIG0Key() error = %v", err)
+	}
+	re := &recordingExchanger{}
+	p := newWithDeps(Config{Host: "ns1.example.com", Zone: "example.com", SIG0Key: k}, nil, nil, re)
+
+	err = p.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.New("app.example.com", []string{"10.0.0.1"}, endpoint.RecordTypeA, 300, nil)},
+	})
+	if err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	verifySIG0(t, re.sent[0], key)
+	if len(re.sent[0].Ns) != 1 {
+		t.Errorf("update section = %v, want the insert preserved after signing", re.sent[0].Ns)
+	}
+}
+
+func TestPreflight_SIG0_SignsQuery(t *testing.T) {
+	key, path := writeSIG0Key(t)
+	k, err := LoadSIG0Key(path)
+	if err != nil {
+		t.Fatalf("LoadSIG0Key() error = %v", err)
+	}
+	me := &mockExchanger{resp: successResp()}
+	p := newWithDeps(Config{Host: "ns1.example.com", Zone: "example.com", SIG0Key: k}, nil, nil, me)
+
+	if err := p.Preflight(context.Background()); err != nil {
+		t.Fatalf("Preflight() error = %v", err)
+	}
+	verifySIG0(t, me.sent, key)
+	if me.sent.IsTsig() != nil {
+		t.Error("preflight query carries a TSIG record as well")
+	}
+}
@@ -0,0 +1,126 @@
+package rfc2136
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Transports accepted in Config.Transport.
+const (
+	// TransportTCP sends every message over TCP. It is the default.
+	TransportTCP = "tcp"
+	// TransportUDP sends queries and updates over UDP, retrying over TCP when
+	// the answer is truncated. Messages too large for a plain UDP datagram go
+	// straight to TCP, as do zone transfers.
+	TransportUDP = "udp"
+	// TransportTLS sends every message, zone transfers included, over DNS over
+	// TLS (RFC 7858, RFC 9103).
+	TransportTLS = "tcp-tls"
+)
+
+// defaultTLSPort is the DNS over TLS port, used when Port is unset.
+const defaultTLSPort = 853
+
+// NewTLSConfig returns the client TLS settings for TransportTLS. caFile, if
+// set, replaces the system roots for verifying the server; certFile and
+// keyFile, set together, present a client certificate; serverName, if set,
+// is verified instead of the host the server was dialled by.
+func NewTLSConfig(caFile, certFile, keyFile, serverName string) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+	}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA file %s: no PEM certificates found", caFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("client certificate and key files must be set together")
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// newExchanger returns the client for queries and updates over cfg.Transport.
+func newExchanger(cfg Config, tsig dns.TsigProvider) dnsExchanger {
+	client := func(net string) *dns.Client {
+		return &dns.Client{
+			Net:          net,
+			TsigProvider: tsig,
+			Timeout:      cfg.Timeout,
+			TLSConfig:    cfg.TLS,
+		}
+	}
+	switch cfg.Transport {
+	case TransportUDP:
+		return &udpExchanger{udp: client("udp"), tcp: client("tcp")}
+	case TransportTLS:
+		return client("tcp-tls")
+	default:
+		return client("tcp")
+	}
+}
+
+// newTransferer returns a factory for zone transfer clients over
+// cfg.Transport; transfers over UDP are not possible and use TCP.
+func newTransferer(cfg Config, tsig dns.TsigProvider) func() dnsTransferer {
+	return func() dnsTransferer {
+		t := &dns.Transfer{
+			TsigProvider: tsig,
+			ReadTimeout:  cfg.Timeout,
+		}
+		if cfg.Transport == TransportTLS {
+			t.TLS = cfg.TLS
+		}
+		return t
+	}
+}
+
+// udpExchanger sends messages over UDP and falls back to TCP for messages
+// that do not fit in a plain UDP datagram and for truncated answers.
+type udpExchanger struct {
+	udp, tcp dnsExchanger
+}
+
+func (u *udpExchanger) ExchangeContext(ctx context.Context, m *dns.Msg, addr string) (*dns.Msg, time.Duration, error) {
+	if m.Len() > dns.MinMsgSize {
+		return u.tcp.ExchangeContext(ctx, m, addr)
+	}
+	r, rtt, err := u.udp.ExchangeContext(ctx, m, addr)
+	if err != nil || !r.Truncated {
+		return r, rtt, err
+	}
+	return u.tcp.ExchangeContext(ctx, m, addr)
+}
+
+// isTLSError reports whether err comes from the TLS handshake rather than
+// the network or the DNS exchange.
+func isTLSError(err error) bool {
+	var verr *tls.CertificateVerificationError
+	var rerr tls.RecordHeaderError
+	var aerr tls.AlertError
+	var uerr x509.UnknownAuthorityError
+	var herr x509.HostnameError
+	return errors.As(err, &verr) || errors.As(err, &rerr) || errors.As(err, &aerr) ||
+		errors.As(err, &uerr) || errors.As(err, &herr)
+}
@@ -0,0 +1,200 @@
+package rfc2136
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// tlsTestServer starts a DNS over TLS server for example.com on 127.0.0.1
+// with a self-signed certificate for ns1.example.com and 127.0.0.1. It
+// answers SOA queries and AXFRs. It returns the server address and the path
+// of the certificate in PEM form, for use as the CA file.
+func tlsTestServer(t *testing.T) (addr, caFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ns1.example.com"},
+		DNSNames:              []string{"ns1.example.com"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile = filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	srv := &dns.Server{
+		Listener:          ln,
+		Net:               "tcp-tls",
+		NotifyStartedFunc: func() { close(started) },
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetReply(r)
+			switch r.Question[0].Qtype {
+			case dns.TypeSOA:
+				m.Answer = []dns.RR{soaRR(1)}
+			case dns.TypeAXFR:
+				m.Answer = []dns.RR{soaRR(1), aRR("app.example.com.", "10.0.0.1"), soaRR(1)}
+			}
+			_ = w.WriteMsg(m)
+		}),
+	}
+	go func() { _ = srv.ActivateAndServe() }()
+	t.Cleanup(func() { _ = srv.Shutdown() })
+	<-started
+	return ln.Addr().String(), caFile
+}
+
+func tlsProvider(t *testing.T, addr string, tlsCfg *tls.Config) *Provider {
+	t.Helper()
+	return New(Config{Hosts: []string{addr}, Zone: "example.com", Transport: TransportTLS, TLS: tlsCfg, Timeout: 5 * time.Second}, nil)
+}
+
+func TestPreflight_TLS_TrustedCA_Passes(t *testing.T) {
+	addr, caFile := tlsTestServer(t)
+	tlsCfg, err := NewTLSConfig(caFile, "", "", "")
+	if err != nil {
+		t.Fatalf("NewTLSConfig() error = %v", err)
+	}
+
+	if err := tlsProvider(t, addr, tlsCfg).Preflight(context.Background()); err != nil {
+		t.Fatalf("Preflight() error = %v", err)
+	}
+}
+
+func TestPreflight_TLS_UntrustedServer_ReportsHandshake(t *testing.T) {
+	addr, _ := tlsTestServer(t)
+
+	err := tlsProvider(t, addr, nil).Preflight(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "TLS handshake") {
+		t.Errorf("Preflight() error = %v, want a TLS handshake failure", err)
+	}
+}
+
+func TestPreflight_TLS_ServerNameMismatch_ReportsHandshake(t *testing.T) {
+	addr, caFile := tlsTestServer(t)
+	tlsCfg, err := NewTLSConfig(caFile, "", "", "ns2.example.com")
+	if err != nil {
+		t.Fatalf("NewTLSConfig() error = %v", err)
+	}
+
+	err = tlsProvider(t, addr, tlsCfg).Preflight(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "TLS handshake") {
+		t.Errorf("Preflight() error = %v, want a TLS handshake failure", err)
+	}
+}
+
+func TestRecords_TLS_TransfersOverTLS(t *testing.T) {
+	addr, caFile := tlsTestServer(t)
+	tlsCfg, err := NewTLSConfig(caFile, "", "", "ns1.example.com")
+	if err != nil {
+		t.Fatalf("NewTLSConfig() error = %v", err)
+	}
+
+	got := targets(t, tlsProvider(t, addr, tlsCfg))
+	if !got["app.example.com=10.0.0.1"] {
+		t.Errorf("records = %v, want app.example.com from the AXFR over TLS", got)
+	}
+}
+
+func TestNewTLSConfig_CertWithoutKey_ReturnsError(t *testing.T) {
+	if _, err := NewTLSConfig("", "client.pem", "", ""); err == nil {
+		t.Error("expected an error for a client certificate without a key")
+	}
+}
+
+func TestNewTLSConfig_CAFileWithoutPEM_ReturnsError(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(f, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewTLSConfig(f, "", "", ""); err == nil {
+		t.Error("expected an error for a CA file without certificates")
+	}
+}
+
+func TestNew_TLSTransport_DefaultPort853(t *testing.T) {
+	p := New(Config{Host: "ns1.example.com", Zone: "example.com", Transport: TransportTLS}, nil)
+	if got := p.servers.String(); got != "ns1.example.com:853" {
+		t.Errorf("servers = %q, want ns1.example.com:853", got)
+	}
+}
+
+// netExchanger answers with resp and records the messages it was sent.
+type netExchanger struct {
+	resp *dns.Msg
+	sent int
+}
+
+func (n *netExchanger) ExchangeContext(_ context.Context, _ *dns.Msg, _ string) (*dns.Msg, time.Duration, error) {
+	n.sent++
+	return n.resp, 0, nil
+}
+
+func TestUDPExchanger_Truncated_RetriesOverTCP(t *testing.T) {
+	truncated := successResp()
+	truncated.Truncated = true
+	udp, tcp := &netExchanger{resp: truncated}, &netExchanger{resp: successResp()}
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeSOA)
+
+	r, _, err := (&udpExchanger{udp: udp, tcp: tcp}).ExchangeContext(context.Background(), m, "ns1.example.com:53")
+	if err != nil || r.Truncated {
+		t.Fatalf("ExchangeContext() = %v, %v; want the untruncated TCP answer", r, err)
+	}
+	if udp.sent != 1 || tcp.sent != 1 {
+		t.Errorf("sent udp=%d tcp=%d, want 1 each", udp.sent, tcp.sent)
+	}
+}
+
+func TestUDPExchanger_LargeMessage_UsesTCP(t *testing.T) {
+	udp, tcp := &netExchanger{resp: successResp()}, &netExchanger{resp: successResp()}
+	m := new(dns.Msg)
+	m.SetUpdate("example.com.")
+	m.Insert([]dns.RR{&dns.TXT{
+		Hdr: dns.RR_Header{Name: "big.example.com.", Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 300},
+		Txt: []string{strings.Repeat("x", 255), strings.Repeat("y", 255), strings.Repeat("z", 255)},
+	}})
+
+	if _, _, err := (&udpExchanger{udp: udp, tcp: tcp}).ExchangeContext(context.Background(), m, "ns1.example.com:53"); err != nil {
+		t.Fatalf("ExchangeContext() error = %v", err)
+	}
+	if udp.sent != 0 || tcp.sent != 1 {
+		t.Errorf("sent udp=%d tcp=%d, want the %d-byte message over TCP only", udp.sent, tcp.sent, m.Len())
+	}
+}
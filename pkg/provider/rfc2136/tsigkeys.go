@@ -0,0 +1,310 @@
+package rfc2136
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// tsigKeyActive reports the TSIG key each zone signs with.
+var tsigKeyActive = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "external_dns_docker_tsig_key_active",
+	Help: "1 for the TSIG key the zone's messages are signed with, 0 for its other keys.",
+}, []string{"zone", "key"})
+
+// errTSIGRejected reports a NOTAUTH reply to a signed request: the server
+// does not know the TSIG key or could not verify the signature.
+var errTSIGRejected = errors.New("tsig key rejected by server")
+
+// tsigRejected reports whether r, err, the outcome of an exchange, means the
+// server rejected the request's TSIG key. The client returns dns.ErrAuth for
+// a NOTAUTH reply carrying a TSIG record, such as BIND's BADKEY and BADSIG
+// replies.
+func tsigRejected(r *dns.Msg, err error) bool {
+	return errors.Is(err, dns.ErrAuth) || (err == nil && r.Rcode == dns.RcodeNotAuth)
+}
+
+// TSIGKey is one of the TSIG keys a zone's messages can be signed with.
+type TSIGKey struct {
+	Name   string
+	Secret string // base64-encoded
+	// SecretFile, if set, holds the base64-encoded secret. It is re-read
+	// whenever it changes, so the secret can be rotated without a restart;
+	// Secret is used until the file has been read.
+	SecretFile string
+	Alg        string // e.g. "hmac-sha256" (trailing dot optional); empty uses hmac-sha256
+}
+
+// tsigKeyring holds a zone's TSIG keys, the first being the primary. It
+// signs and verifies messages for the DNS clients (dns.TsigProvider) and
+// remembers which key the server last accepted.
+//
+// Keys may share a name, as when a secret is rotated under the same key
+// name, so the key a message is signed with is pinned by message ID while
+// it is in flight rather than looked up by name.
+type tsigKeyring struct {
+	zone string // dns.Fqdn-normalised, used as the metric label
+	log  *slog.Logger
+
+	mu     sync.Mutex
+	keys   []*ringKey
+	active int            // index of the key the server last accepted
+	pinned map[uint16]int // message ID -> index of the key it is signed with
+}
+
+// ringKey is a TSIGKey with its names normalised and the state of its
+// secret file.
+type ringKey struct {
+	name    string // FQDN
+	alg     string // normalised, with trailing dot
+	secret  string
+	file    string
+	modTime time.Time
+	size    int64
+}
+
+// newTSIGKeyring returns a keyring for keys, or nil if there are none.
+func newTSIGKeyring(zone string, keys []TSIGKey, log *slog.Logger) *tsigKeyring {
+	if len(keys) == 0 {
+		return nil
+	}
+	k := &tsigKeyring{zone: dns.Fqdn(zone), log: log, pinned: make(map[uint16]int)}
+	for _, key := range keys {
+		k.keys = append(k.keys, &ringKey{
+			name:   dns.Fqdn(key.Name),
+			alg:    normaliseTSIGAlg(key.Alg),
+			secret: key.Secret,
+			file:   key.SecretFile,
+		})
+	}
+	tsigKeyActive.WithLabelValues(k.zone, k.keys[0].name).Set(1)
+	return k
+}
+
+// tsigKeys returns cfg's TSIG keys: TSIGKeyName's first, then TSIGKeys.
+// SIG(0) replaces them all.
+func tsigKeys(cfg Config) []TSIGKey {
+	if cfg.SIG0Key != nil {
+		return nil
+	}
+	var keys []TSIGKey
+	if cfg.TSIGKeyName != "" {
+		keys = append(keys, TSIGKey{
+			Name:       cfg.TSIGKeyName,
+			Secret:     cfg.TSIGSecret,
+			SecretFile: cfg.TSIGSecretFile,
+			Alg:        cfg.TSIGSecretAlg,
+		})
+	}
+	return append(keys, cfg.TSIGKeys...)
+}
+
+// order returns the key indexes to try: the key last accepted first, then
+// the others in configured order.
+func (k *tsigKeyring) order() []int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	order := []int{k.active}
+	for i := range k.keys {
+		if i != k.active {
+			order = append(order, i)
+		}
+	}
+	return order
+}
+
+// sign replaces any TSIG on m with one for key i.
+func (k *tsigKeyring) sign(m *dns.Msg, i int) {
+	if t := m.IsTsig(); t != nil {
+		m.Extra = m.Extra[:len(m.Extra)-1]
+	}
+	k.mu.Lock()
+	key := k.keys[i]
+	k.mu.Unlock()
+	m.SetTsig(key.name, key.alg, 300, time.Now().Unix())
+}
+
+// use records that the server accepted key i, logging when it changes.
+func (k *tsigKeyring) use(i int) {
+	k.mu.Lock()
+	prev := k.active
+	k.active = i
+	k.mu.Unlock()
+	if prev == i {
+		return
+	}
+	tsigKeyActive.WithLabelValues(k.zone, k.keys[prev].name).Set(0)
+	tsigKeyActive.WithLabelValues(k.zone, k.keys[i].name).Set(1)
+	k.log.Info("switched tsig key", "zone", k.zone, "key", k.keys[i].name, "previous", k.keys[prev].name)
+}
+
+// pin records that m is signed with key i until the returned func is
+// called, giving m a fresh ID if another message in flight has its ID.
+func (k *tsigKeyring) pin(m *dns.Msg, i int) func() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for {
+		if _, ok := k.pinned[m.Id]; !ok {
+			break
+		}
+		m.Id = dns.Id()
+	}
+	id := m.Id
+	k.pinned[id] = i
+	return func() {
+		k.mu.Lock()
+		delete(k.pinned, id)
+		k.mu.Unlock()
+	}
+}
+
+// try calls op with m signed by each key in turn, the key last accepted
+// first, until the server does not reject the key, and returns op's result.
+// Messages without TSIG are sent once as they are.
+func (k *tsigKeyring) try(m *dns.Msg, op func() error) error {
+	if k == nil || m.IsTsig() == nil {
+		return op()
+	}
+	order := k.order()
+	var err error
+	for n, i := range order {
+		unpin := k.pin(m, i)
+		k.sign(m, i)
+		err = op()
+		unpin()
+		if !errors.Is(err, errTSIGRejected) {
+			if err == nil {
+				k.use(i)
+			}
+			return err
+		}
+		if n < len(order)-1 {
+			k.log.Warn("tsig key rejected, trying next key", "zone", k.zone, "key", k.keys[i].name)
+		}
+	}
+	return err
+}
+
+// secret returns the current secret of the key t names, re-reading its
+// secret file if the file changed since it was last read. The key pinned to
+// t's message ID is used if it has that name, otherwise the first key with
+// it.
+func (k *tsigKeyring) secret(t *dns.TSIG) (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if i, ok := k.pinned[t.OrigId]; ok && strings.EqualFold(k.keys[i].name, t.Hdr.Name) {
+		k.reload(k.keys[i])
+		return k.keys[i].secret, nil
+	}
+	for _, key := range k.keys {
+		if strings.EqualFold(key.name, t.Hdr.Name) {
+			k.reload(key)
+			return key.secret, nil
+		}
+	}
+	return "", dns.ErrSecret
+}
+
+// reload re-reads key's secret file if it changed. Failures are logged and
+// the previous secret kept, so a file being rewritten does not break
+// signing. The caller must hold k.mu.
+func (k *tsigKeyring) reload(key *ringKey) {
+	if key.file == "" {
+		return
+	}
+	fi, err := os.Stat(key.file)
+	if err != nil {
+		k.log.Warn("cannot read tsig secret file, keeping previous secret", "zone", k.zone, "key", key.name, "path", key.file, "err", err)
+		return
+	}
+	if fi.ModTime().Equal(key.modTime) && fi.Size() == key.size {
+		return
+	}
+	data, err := os.ReadFile(key.file)
+	if err != nil {
+		k.log.Warn("cannot read tsig secret file, keeping previous secret", "zone", k.zone, "key", key.name, "path", key.file, "err", err)
+		return
+	}
+	secret := strings.TrimSpace(string(data))
+	if _, err := base64.StdEncoding.DecodeString(secret); err != nil {
+		k.log.Warn("tsig secret file is not base64, keeping previous secret", "zone", k.zone, "key", key.name, "path", key.file)
+		return
+	}
+	if key.secret != "" && secret != key.secret {
+		k.log.Info("reloaded tsig secret", "zone", k.zone, "key", key.name, "path", key.file)
+	}
+	key.secret, key.modTime, key.size = secret, fi.ModTime(), fi.Size()
+}
+
+// Generate implements dns.TsigProvider.
+func (k *tsigKeyring) Generate(msg []byte, t *dns.TSIG) ([]byte, error) {
+	secret, err := k.secret(t)
+	if err != nil {
+		return nil, err
+	}
+	return tsigMAC(secret, t.Algorithm, msg)
+}
+
+// Verify implements dns.TsigProvider.
+func (k *tsigKeyring) Verify(msg []byte, t *dns.TSIG) error {
+	mac, err := k.Generate(msg, t)
+	if err != nil {
+		return err
+	}
+	want, err := hex.DecodeString(t.MAC)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(mac, want) {
+		return dns.ErrSig
+	}
+	return nil
+}
+
+// tsigMAC returns the HMAC of msg with the base64-encoded secret.
+func tsigMAC(secret, alg string, msg []byte) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("tsig secret: %w", err)
+	}
+	h := tsigHash(alg)
+	if h == nil {
+		return nil, dns.ErrKeyAlg
+	}
+	mac := hmac.New(h, raw)
+	mac.Write(msg)
+	return mac.Sum(nil), nil
+}
+
+// tsigHash returns the hash of the TSIG algorithm alg, or nil if it is not
+// supported.
+func tsigHash(alg string) func() hash.Hash {
+	switch dns.CanonicalName(alg) {
+	case dns.HmacSHA1:
+		return sha1.New
+	case dns.HmacSHA224:
+		return sha256.New224
+	case dns.HmacSHA256:
+		return sha256.New
+	case dns.HmacSHA384:
+		return sha512.New384
+	case dns.HmacSHA512:
+		return sha512.New
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package rfc2136
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const (
+	oldSecret = "b2xkLXNlY3JldA==" // "old-secret"
+	newSecret = "bmV3LXNlY3JldA==" // "new-secret"
+)
+
+// tsigTestServer starts a DNS server for example.com on 127.0.0.1 that only
+// knows the TSIG key new-key. Requests signed with any other key get NOTAUTH
+// with TSIG error BADKEY, as BIND answers them. It returns the server
+// address and the number of requests it rejected so far.
+func tsigTestServer(t *testing.T) (string, *atomic.Int32) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rejected atomic.Int32
+	started := make(chan struct{})
+	srv := &dns.Server{
+		Listener:          ln,
+		Net:               "tcp",
+		TsigSecret:        map[string]string{"new-key.": newSecret},
+		MsgAcceptFunc:     func(dns.Header) dns.MsgAcceptAction { return dns.MsgAccept },
+		NotifyStartedFunc: func() { close(started) },
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetReply(r)
+			tsig := r.IsTsig()
+			if tsig == nil || w.TsigStatus() != nil {
+				rejected.Add(1)
+				m.Rcode = dns.RcodeNotAuth
+				if tsig != nil {
+					m.SetTsig(tsig.Hdr.Name, tsig.Algorithm, 300, time.Now().Unix())
+					m.Extra[0].(*dns.TSIG).Error = dns.RcodeBadKey
+				}
+				_ = w.WriteMsg(m)
+				return
+			}
+			if r.Question[0].Qtype == dns.TypeSOA {
+				m.Answer = []dns.RR{soaRR(1)}
+			}
+			m.SetTsig(tsig.Hdr.Name, tsig.Algorithm, 300, time.Now().Unix())
+			_ = w.WriteMsg(m)
+		}),
+	}
+	go func() { _ = srv.ActivateAndServe() }()
+	t.Cleanup(func() { _ = srv.Shutdown() })
+	<-started
+	return ln.Addr().String(), &rejected
+}
+
+func TestTSIGKeys_RejectedKey_FallsBackAndSticks(t *testing.T) {
+	addr, rejected := tsigTestServer(t)
+	p := New(Config{
+		Hosts:       []string{addr},
+		Zone:        "rotate.example.com",
+		TSIGKeyName: "old-key",
+		TSIGSecret:  oldSecret,
+		TSIGKeys:    []TSIGKey{{Name: "new-key", Secret: newSecret}},
+		Timeout:     5 * time.Second,
+	}, nil)
+
+	if err := createApp(t, p); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	if rejected.Load() != 1 {
+		t.Errorf("rejected requests = %d, want 1 (old key, then new key)", rejected.Load())
+	}
+	if v := testutil.ToFloat64(tsigKeyActive.WithLabelValues("rotate.example.com.", "new-key.")); v != 1 {
+		t.Errorf("tsig_key_active for new-key = %v, want 1", v)
+	}
+	if v := testutil.ToFloat64(tsigKeyActive.WithLabelValues("rotate.example.com.", "old-key.")); v != 0 {
+		t.Errorf("tsig_key_active for old-key = %v, want 0", v)
+	}
+
+	// The accepted key is used first from now on.
+	if err := createApp(t, p); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	if rejected.Load() != 1 {
+		t.Errorf("rejected requests = %d, want no new rejection", rejected.Load())
+	}
+}
+
+func TestTSIGKeys_AllRejected_ReturnsNotAuth(t *testing.T) {
+	addr, rejected := tsigTestServer(t)
+	p := New(Config{
+		Hosts:       []string{addr},
+		Zone:        "example.com",
+		TSIGKeyName: "old-key",
+		TSIGSecret:  oldSecret,
+		TSIGKeys:    []TSIGKey{{Name: "other-key", Secret: oldSecret}},
+		Timeout:     5 * time.Second,
+	}, nil)
+
+	err := createApp(t, p)
+	if err == nil || !strings.Contains(err.Error(), "NOTAUTH") {
+		t.Errorf("ApplyChanges() error = %v, want NOTAUTH", err)
+	}
+	if rejected.Load() != 2 {
+		t.Errorf("rejected requests = %d, want both keys tried", rejected.Load())
+	}
+}
+
+func TestTSIGKeys_Preflight_FallsBack(t *testing.T) {
+	addr, _ := tsigTestServer(t)
+	p := New(Config{
+		Hosts:       []string{addr},
+		Zone:        "example.com",
+		TSIGKeyName: "old-key",
+		TSIGSecret:  oldSecret,
+		TSIGKeys:    []TSIGKey{{Name: "new-key", Secret: newSecret}},
+		Timeout:     5 * time.Second,
+	}, nil)
+
+	if err := p.Preflight(context.Background()); err != nil {
+		t.Fatalf("Preflight() error = %v", err)
+	}
+}
+
+func TestTSIGKeys_SameName_FallsBackToSecondSecret(t *testing.T) {
+	addr, rejected := tsigTestServer(t)
+	p := New(Config{
+		Hosts:       []string{addr},
+		Zone:        "same.example.com",
+		TSIGKeyName: "new-key",
+		TSIGSecret:  oldSecret,
+		TSIGKeys:    []TSIGKey{{Name: "new-key", Secret: newSecret}},
+		Timeout:     5 * time.Second,
+	}, nil)
+
+	if err := createApp(t, p); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	if rejected.Load() != 1 {
+		t.Errorf("rejected requests = %d, want 1 (old secret, then new secret)", rejected.Load())
+	}
+	if err := createApp(t, p); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	if rejected.Load() != 1 {
+		t.Errorf("rejected requests = %d, want the new secret used first", rejected.Load())
+	}
+}
+
+func TestTSIGKeys_SecretFileChanged_Reloaded(t *testing.T) {
+	addr, rejected := tsigTestServer(t)
+	file := filepath.Join(t.TempDir(), "tsig")
+	if err := os.WriteFile(file, []byte(oldSecret+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p := New(Config{
+		Hosts:          []string{addr},
+		Zone:           "example.com",
+		TSIGKeyName:    "new-key",
+		TSIGSecret:     oldSecret,
+		TSIGSecretFile: file,
+		Timeout:        5 * time.Second,
+	}, nil)
+
+	if err := createApp(t, p); err == nil {
+		t.Fatal("expected the stale secret to be rejected")
+	}
+
+	// The secret is rotated on disk; the next request picks it up.
+	if err := os.WriteFile(file, []byte(newSecret+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(file, later, later); err != nil {
+		t.Fatal(err)
+	}
+	before := rejected.Load()
+	if err := createApp(t, p); err != nil {
+		t.Fatalf("ApplyChanges() after rotation error = %v", err)
+	}
+	if rejected.Load() != before {
+		t.Error("request with the reloaded secret was rejected")
+	}
+}
+
+func TestTSIGKeys_UnreadableSecretFile_KeepsSecret(t *testing.T) {
+	k := newTSIGKeyring("example.com", []TSIGKey{{Name: "k", Secret: newSecret, SecretFile: "/nonexistent/tsig"}}, slog.Default())
+	got, err := k.secret(&dns.TSIG{Hdr: dns.RR_Header{Name: "k."}})
+	if err != nil || got != newSecret {
+		t.Errorf("secret = %q, %v; want the configured secret", got, err)
+	}
+}
+
+func TestTSIGKeyring_UnknownKey_ErrSecret(t *testing.T) {
+	k := newTSIGKeyring("example.com", []TSIGKey{{Name: "k", Secret: newSecret}}, slog.Default())
+	if _, err := k.Generate(nil, &dns.TSIG{Hdr: dns.RR_Header{Name: "other."}, Algorithm: dns.HmacSHA256}); err != dns.ErrSecret {
+		t.Errorf("Generate() = %v, want dns.ErrSecret", err)
+	}
+}
@@ -0,0 +1,208 @@
+package rfc2136
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/bkero/external-dns-docker/pkg/provider"
+)
+
+// defaultVerifyTimeout is how long secondaries are polled for applied
+// changes when Config.VerifyTimeout is unset. Polling holds up the
+// reconciliation loop, so it is kept short.
+const defaultVerifyTimeout = 5 * time.Second
+
+// Verification metrics, registered on the default registry.
+var (
+	propagationSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "external_dns_docker_propagation_seconds",
+		Help:    "Time from an update being accepted until a secondary server serves it.",
+		Buckets: prometheus.ExponentialBuckets(0.25, 2, 10), // 0.25s .. 128s
+	}, []string{"zone", "server"})
+
+	unverifiedChanges = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "external_dns_docker_unverified_changes",
+		Help: "Record sets the zone's servers did not serve as applied after the last verified update.",
+	}, []string{"zone"})
+)
+
+// appliedSet is a record set an update created or replaced.
+type appliedSet struct {
+	name   string // FQDN
+	rrtype uint16
+	rrs    []dns.RR
+}
+
+// appliedSets returns the record sets groups insert, in order.
+func appliedSets(groups []*updateGroup) []*appliedSet {
+	type key struct {
+		name   string
+		rrtype uint16
+	}
+	byKey := make(map[key]*appliedSet)
+	var sets []*appliedSet
+	for _, g := range groups {
+		for _, rr := range g.insert {
+			k := key{strings.ToLower(rr.Header().Name), rr.Header().Rrtype}
+			s, ok := byKey[k]
+			if !ok {
+				s = &appliedSet{name: rr.Header().Name, rrtype: k.rrtype}
+				byKey[k] = s
+				sets = append(sets, s)
+			}
+			s.rrs = append(s.rrs, rr)
+		}
+	}
+	return sets
+}
+
+// verify checks that the records groups inserted are served: by the
+// zone's server, which applied the update and must serve them at once, then
+// by each of Config.VerifySecondaries, which are polled until they do or
+// Config.VerifyTimeout passes. The timeout bounds the queries too, so an
+// unresponsive secondary cannot hold up the caller for longer. How long each
+// secondary took is recorded in propagationSeconds. Record sets not served
+// are returned as a *provider.VerifyError.
+func (p *Provider) verify(ctx context.Context, groups []*updateGroup, applied time.Time) error {
+	zone := dns.Fqdn(p.cfg.Zone)
+	sets := appliedSets(groups)
+	unverified := make(map[*appliedSet]bool)
+	var mismatches []provider.Mismatch
+
+	for _, s := range sets {
+		m := s.query(false)
+		if err := p.sign(m); err != nil {
+			return err
+		}
+		r, err := p.exchange(ctx, m)
+		if err != nil {
+			return fmt.Errorf("verify %s %s: %w", s.name, dns.TypeToString[s.rrtype], err)
+		}
+		if r.Rcode != dns.RcodeSuccess && r.Rcode != dns.RcodeNameError {
+			return fmt.Errorf("verify %s %s failed: rcode %s (%d)", s.name, dns.TypeToString[s.rrtype], dns.RcodeToString[r.Rcode], r.Rcode)
+		}
+		if missing := s.missing(r); len(missing) > 0 {
+			mismatches = append(mismatches, s.mismatch(p.servers.current(), missing))
+			unverified[s] = true
+		}
+	}
+
+	// Secondaries are polled only for what the primary serves.
+	type check struct {
+		server string
+		set    *appliedSet
+	}
+	var pending []check
+	if len(p.cfg.VerifySecondaries) > 0 {
+		for _, server := range serverAddrs(Config{Hosts: p.cfg.VerifySecondaries, Port: 53}) {
+			for _, s := range sets {
+				if !unverified[s] {
+					pending = append(pending, check{server, s})
+				}
+			}
+		}
+	}
+	timeout := p.cfg.VerifyTimeout
+	if timeout <= 0 {
+		timeout = defaultVerifyTimeout
+	}
+	poll := min(time.Second, timeout/10)
+	deadline := applied.Add(timeout)
+	pctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+	missing := make(map[check][]dns.RR)
+	for len(pending) > 0 {
+		var next []check
+		for i, c := range pending {
+			if pctx.Err() != nil {
+				next = append(next, pending[i:]...)
+				break
+			}
+			r, _, err := p.secondaries.ExchangeContext(pctx, c.set.query(true), c.server)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				p.log.Debug("verify query to secondary failed", "zone", zone, "server", c.server, "err", err)
+				missing[c] = c.set.rrs
+				next = append(next, c)
+				continue
+			}
+			if missing[c] = c.set.missing(r); len(missing[c]) > 0 {
+				next = append(next, c)
+				continue
+			}
+			propagationSeconds.WithLabelValues(zone, c.server).Observe(time.Since(applied).Seconds())
+		}
+		pending = next
+		if len(pending) == 0 || !time.Now().Add(poll).Before(deadline) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(poll):
+		}
+	}
+	for _, c := range pending {
+		if _, queried := missing[c]; !queried {
+			missing[c] = c.set.rrs
+		}
+		mismatches = append(mismatches, c.set.mismatch(c.server, missing[c]))
+		unverified[c.set] = true
+	}
+
+	unverifiedChanges.WithLabelValues(zone).Set(float64(len(unverified)))
+	if len(mismatches) > 0 {
+		return &provider.VerifyError{Mismatches: mismatches}
+	}
+	p.log.Debug("update verified", "zone", zone, "rrsets", len(sets), "secondaries", len(p.cfg.VerifySecondaries), "elapsed", time.Since(applied))
+	return nil
+}
+
+// query returns a query for s. Queries to secondaries go unsigned, as the
+// secondaries need not know the zone's TSIG key, and over UDP, so they
+// advertise a larger buffer.
+func (s *appliedSet) query(secondary bool) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion(s.name, s.rrtype)
+	m.RecursionDesired = false
+	if secondary {
+		m.SetEdns0(dns.DefaultMsgSize, false)
+	}
+	return m
+}
+
+// missing returns the records of s that the answer r lacks. TTLs are not
+// compared.
+func (s *appliedSet) missing(r *dns.Msg) []dns.RR {
+	var missing []dns.RR
+	for _, want := range s.rrs {
+		found := false
+		for _, got := range r.Answer {
+			if dns.IsDuplicate(want, got) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, want)
+		}
+	}
+	return missing
+}
+
+// mismatch describes the records of s that server does not serve.
+func (s *appliedSet) mismatch(server string, missing []dns.RR) provider.Mismatch {
+	m := provider.Mismatch{Server: server, Name: s.name, Type: dns.TypeToString[s.rrtype]}
+	for _, rr := range missing {
+		m.Missing = append(m.Missing, strings.TrimSpace(strings.TrimPrefix(rr.String(), rr.Header().String())))
+	}
+	return m
+}
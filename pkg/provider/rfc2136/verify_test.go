@@ -0,0 +1,231 @@
+package rfc2136
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/bkero/external-dns-docker/pkg/endpoint"
+	"github.com/bkero/external-dns-docker/pkg/plan"
+	"github.com/bkero/external-dns-docker/pkg/provider"
+)
+
+// servingExchanger accepts every UPDATE and answers queries sent to each
+// server with the records it serves. A server with a lag answers its first
+// lag queries with no records, as a secondary that has not caught up yet.
+type servingExchanger struct {
+	mu      sync.Mutex
+	served  map[string][]string // server → records in presentation format
+	lag     map[string]int
+	queries map[string]int
+	hung    map[string]bool // servers that answer nothing until ctx is done
+}
+
+func (s *servingExchanger) ExchangeContext(ctx context.Context, m *dns.Msg, addr string) (*dns.Msg, time.Duration, error) {
+	if s.hung[addr] {
+		<-ctx.Done()
+		return nil, 0, ctx.Err()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := new(dns.Msg)
+	resp.SetReply(m)
+	if m.Opcode == dns.OpcodeUpdate {
+		return resp, 0, nil
+	}
+	s.queries[addr]++
+	if s.queries[addr] <= s.lag[addr] {
+		return resp, 0, nil
+	}
+	q := m.Question[0]
+	for _, text := range s.served[addr] {
+		rr, err := dns.NewRR(text)
+		if err != nil {
+			return nil, 0, err
+		}
+		if strings.EqualFold(rr.Header().Name, q.Name) && rr.Header().Rrtype == q.Qtype {
+			resp.Answer = append(resp.Answer, rr)
+		}
+	}
+	return resp, 0, nil
+}
+
+// verifyProvider returns a provider for verify.example.com that verifies
+// its updates against ns1 and the given secondaries.
+func verifyProvider(se *servingExchanger, secondaries ...string) *Provider {
+	return newWithDeps(Config{
+		Host:              "ns1.example.com",
+		Zone:              "verify.example.com",
+		Verify:            true,
+		VerifySecondaries: secondaries,
+		VerifyTimeout:     200 * time.Millisecond,
+	}, nil, nil, se)
+}
+
+func createAppWithOwner(p *Provider) error {
+	return p.ApplyChanges(context.Background(), &plan.Changes{
+		Create: createWithOwner("app.verify.example.com", "10.0.0.1"),
+	})
+}
+
+const (
+	appA   = "app.verify.example.com. 300 IN A 10.0.0.1"
+	appTXT = `a-external-dns-docker-owner.app.verify.example.com. 300 IN TXT "heritage=external-dns-docker"`
+)
+
+func TestVerify_AllServed_NoError(t *testing.T) {
+	se := &servingExchanger{served: map[string][]string{"ns1.example.com:53": {appA, appTXT}}, queries: map[string]int{}}
+	p := verifyProvider(se)
+
+	if err := createAppWithOwner(p); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	if se.queries["ns1.example.com:53"] != 2 {
+		t.Errorf("queries to the primary = %d, want one per record set", se.queries["ns1.example.com:53"])
+	}
+	if v := testutil.ToFloat64(unverifiedChanges.WithLabelValues("verify.example.com.")); v != 0 {
+		t.Errorf("unverified_changes = %v, want 0", v)
+	}
+}
+
+func TestVerify_RecordDiscarded_ReturnsVerifyError(t *testing.T) {
+	se := &servingExchanger{served: map[string][]string{"ns1.example.com:53": {appTXT}}, queries: map[string]int{}}
+	p := verifyProvider(se)
+
+	err := createAppWithOwner(p)
+	var verr *provider.VerifyError
+	if !errors.As(err, &verr) {
+		t.Fatalf("ApplyChanges() error = %v, want a *provider.VerifyError", err)
+	}
+	if len(verr.Mismatches) != 1 {
+		t.Fatalf("mismatches = %+v, want the A record only", verr.Mismatches)
+	}
+	m := verr.Mismatches[0]
+	if m.Server != "ns1.example.com:53" || m.Type != "A" || strings.Join(m.Missing, ",") != "10.0.0.1" {
+		t.Errorf("mismatch = %+v, want A 10.0.0.1 missing on ns1", m)
+	}
+	if v := testutil.ToFloat64(unverifiedChanges.WithLabelValues("verify.example.com.")); v != 1 {
+		t.Errorf("unverified_changes = %v, want 1", v)
+	}
+}
+
+func TestVerify_Update_ChecksNewRecords(t *testing.T) {
+	se := &servingExchanger{served: map[string][]string{"ns1.example.com:53": {appA}}, queries: map[string]int{}}
+	p := verifyProvider(se)
+
+	err := p.ApplyChanges(context.Background(), &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{endpoint.New("app.verify.example.com", []string{"10.0.0.1"}, endpoint.RecordTypeA, 300, nil)},
+		UpdateNew: []*endpoint.Endpoint{endpoint.New("app.verify.example.com", []string{"10.0.0.2"}, endpoint.RecordTypeA, 300, nil)},
+	})
+	var verr *provider.VerifyError
+	if !errors.As(err, &verr) || verr.Mismatches[0].Missing[0] != "10.0.0.2" {
+		t.Errorf("ApplyChanges() error = %v, want 10.0.0.2 reported missing", err)
+	}
+}
+
+func TestVerify_SecondaryCatchesUp_ObservesPropagation(t *testing.T) {
+	se := &servingExchanger{
+		served: map[string][]string{
+			"ns1.example.com:53": {appA, appTXT},
+			"192.0.2.2:5353":     {appA, appTXT},
+		},
+		lag:     map[string]int{"192.0.2.2:5353": 3},
+		queries: map[string]int{},
+	}
+	p := verifyProvider(se, "192.0.2.2:5353")
+
+	if err := createAppWithOwner(p); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	if n := se.queries["192.0.2.2:5353"]; n != 5 {
+		t.Errorf("queries to the secondary = %d, want polling until it served both record sets", n)
+	}
+	if n := testutil.CollectAndCount(propagationSeconds); n == 0 {
+		t.Error("no propagation_seconds observed")
+	}
+}
+
+func TestVerify_SecondaryNeverServes_ReturnsVerifyError(t *testing.T) {
+	se := &servingExchanger{
+		served:  map[string][]string{"ns1.example.com:53": {appA, appTXT}},
+		queries: map[string]int{},
+	}
+	p := verifyProvider(se, "ns2.example.com")
+
+	start := time.Now()
+	err := createAppWithOwner(p)
+	var verr *provider.VerifyError
+	if !errors.As(err, &verr) || len(verr.Mismatches) != 2 || verr.Mismatches[0].Server != "ns2.example.com:53" {
+		t.Fatalf("ApplyChanges() error = %v, want both record sets missing on ns2", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("verification took %v, want it bounded by the 200ms timeout", elapsed)
+	}
+	if v := testutil.ToFloat64(unverifiedChanges.WithLabelValues("verify.example.com.")); v != 2 {
+		t.Errorf("unverified_changes = %v, want 2", v)
+	}
+}
+
+func TestVerify_SecondaryHangs_BoundedByTimeout(t *testing.T) {
+	se := &servingExchanger{
+		served:  map[string][]string{"ns1.example.com:53": {appA, appTXT}},
+		queries: map[string]int{},
+		hung:    map[string]bool{"192.0.2.2:53": true},
+	}
+	p := verifyProvider(se, "192.0.2.2")
+
+	start := time.Now()
+	err := createAppWithOwner(p)
+	var verr *provider.VerifyError
+	if !errors.As(err, &verr) || len(verr.Mismatches) != 2 {
+		t.Fatalf("error = %v, want both record sets unverified on the hung secondary", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("ApplyChanges took %v, want it bounded by the 200ms verify timeout", elapsed)
+	}
+	for _, m := range verr.Mismatches {
+		if len(m.Missing) == 0 {
+			t.Errorf("mismatch %+v lists no missing records", m)
+		}
+	}
+}
+
+func TestVerify_Disabled_NoQueries(t *testing.T) {
+	se := &servingExchanger{queries: map[string]int{}}
+	p := newWithDeps(Config{Host: "ns1.example.com", Zone: "verify.example.com"}, nil, nil, se)
+
+	if err := createAppWithOwner(p); err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	if len(se.queries) != 0 {
+		t.Errorf("queries = %v, want none without Verify", se.queries)
+	}
+}
+
+func TestMultiApplyChanges_UnverifiedZone_ReturnsVerifyErrorNotZoneErrors(t *testing.T) {
+	se := &servingExchanger{queries: map[string]int{}}
+	m := &MultiProvider{log: slog.Default(), zones: []zoneEntry{
+		{zone: "verify.example.com.", prov: verifyProvider(se)},
+	}}
+
+	err := m.ApplyChanges(context.Background(), &plan.Changes{
+		Create: createWithOwner("app.verify.example.com", "10.0.0.1"),
+	})
+	var verr *provider.VerifyError
+	if !errors.As(err, &verr) || len(verr.Mismatches) != 2 {
+		t.Fatalf("ApplyChanges() error = %v, want a *provider.VerifyError for both record sets", err)
+	}
+	if errors.As(err, new(*provider.ZoneErrors)) {
+		t.Errorf("ApplyChanges() error = %v, want the zone not reported as failed", err)
+	}
+	if v := testutil.ToFloat64(zoneUp.WithLabelValues("verify.example.com.")); v != 1 {
+		t.Errorf("zone_up = %v, want 1", v)
+	}
+}
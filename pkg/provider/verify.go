@@ -0,0 +1,41 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxMismatchesShown bounds the mismatches VerifyError.Error lists.
+const maxMismatchesShown = 5
+
+// VerifyError is returned by ApplyChanges when the backend accepted the
+// changes but reading them back shows records missing, e.g. because a
+// server policy silently discarded part of an update, or a secondary server
+// did not pick them up in time. The changes were applied, so callers should
+// not treat it as a failed apply; the next reconciliation plans whatever is
+// still missing again.
+type VerifyError struct {
+	// Mismatches lists each record set a server does not serve as applied.
+	Mismatches []Mismatch
+}
+
+// Mismatch is a record set a server does not serve as applied.
+type Mismatch struct {
+	Server  string   // the server queried, "host:port"
+	Name    string   // the record set's name
+	Type    string   // the record set's type, e.g. "A"
+	Missing []string // the records not served, in presentation format
+}
+
+// Error lists the mismatched record sets and the servers lacking them.
+func (e *VerifyError) Error() string {
+	shown := min(len(e.Mismatches), maxMismatchesShown)
+	parts := make([]string, 0, shown+1)
+	for _, m := range e.Mismatches[:shown] {
+		parts = append(parts, fmt.Sprintf("%s %s on %s missing %s", m.Name, m.Type, m.Server, strings.Join(m.Missing, ", ")))
+	}
+	if n := len(e.Mismatches) - shown; n > 0 {
+		parts = append(parts, fmt.Sprintf("and %d more", n))
+	}
+	return "applied records not served: " + strings.Join(parts, "; ")
+}
@@ -0,0 +1,28 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestVerifyError_Error_ListsMismatches(t *testing.T) {
+	e := &VerifyError{Mismatches: []Mismatch{
+		{Server: "ns1.example.com:53", Name: "app.example.com.", Type: "A", Missing: []string{"10.0.0.1", "10.0.0.2"}},
+	}}
+	want := "applied records not served: app.example.com. A on ns1.example.com:53 missing 10.0.0.1, 10.0.0.2"
+	if got := e.Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestVerifyError_Error_TruncatesLongLists(t *testing.T) {
+	e := &VerifyError{}
+	for i := range maxMismatchesShown + 3 {
+		e.Mismatches = append(e.Mismatches, Mismatch{Server: "ns1:53", Name: fmt.Sprintf("n%d.", i), Type: "A", Missing: []string{"10.0.0.1"}})
+	}
+	got := e.Error()
+	if !strings.HasSuffix(got, "; and 3 more") || strings.Contains(got, fmt.Sprintf("n%d.", maxMismatchesShown)) {
+		t.Errorf("Error() = %q, want %d mismatches and a count of the rest", got, maxMismatchesShown)
+	}
+}
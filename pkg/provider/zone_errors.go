@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ZoneErrors is returned by multi-zone providers when some zones failed while
+// others succeeded. The results for the healthy zones are returned alongside
+// it, so callers can keep reconciling those and skip only the failed zones.
+type ZoneErrors struct {
+	// Errors holds each failed zone's error, keyed by zone name.
+	Errors map[string]error
+	zoneOf func(name string) string
+}
+
+// NewZoneErrors returns an empty ZoneErrors. zoneOf maps a DNS name to the
+// zone that holds it, or "" if none does; Contains uses it so that names in
+// a healthy subzone of a failed zone are not reported as failed.
+func NewZoneErrors(zoneOf func(name string) string) *ZoneErrors {
+	return &ZoneErrors{Errors: make(map[string]error), zoneOf: zoneOf}
+}
+
+// Add records err as the failure of zone.
+func (e *ZoneErrors) Add(zone string, err error) {
+	e.Errors[zone] = err
+}
+
+// Len returns the number of failed zones.
+func (e *ZoneErrors) Len() int {
+	return len(e.Errors)
+}
+
+// Zones returns the failed zone names, sorted, or nil if there are none. It
+// is safe to call on a nil *ZoneErrors.
+func (e *ZoneErrors) Zones() []string {
+	if e == nil || len(e.Errors) == 0 {
+		return nil
+	}
+	zones := make([]string, 0, len(e.Errors))
+	for z := range e.Errors {
+		zones = append(zones, z)
+	}
+	sort.Strings(zones)
+	return zones
+}
+
+// Contains reports whether name lies in a failed zone. For a zone that
+// applied only some of its changes (a *PartialApplyError), only the names
+// whose changes were not applied are reported.
+func (e *ZoneErrors) Contains(name string) bool {
+	err, failed := e.Errors[e.zoneOf(name)]
+	var partial *PartialApplyError
+	if failed && errors.As(err, &partial) {
+		return partial.Contains(name)
+	}
+	return failed
+}
+
+// Error lists each failed zone with its error.
+func (e *ZoneErrors) Error() string {
+	parts := make([]string, 0, len(e.Errors))
+	for _, z := range e.Zones() {
+		parts = append(parts, fmt.Sprintf("zone %s: %v", z, e.Errors[z]))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Unwrap returns the per-zone errors, so errors.Is and errors.As see them.
+func (e *ZoneErrors) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, z := range e.Zones() {
+		errs = append(errs, e.Errors[z])
+	}
+	return errs
+}
@@ -0,0 +1,79 @@
+package provider
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// zoneOf maps names to example.com or its subzone sub.example.com.
+func zoneOf(name string) string {
+	switch {
+	case name == "sub.example.com" || strings.HasSuffix(name, ".sub.example.com"):
+		return "sub.example.com."
+	case name == "example.com" || strings.HasSuffix(name, ".example.com"):
+		return "example.com."
+	}
+	return ""
+}
+
+func TestZoneErrors_Contains_RespectsSubzones(t *testing.T) {
+	e := NewZoneErrors(zoneOf)
+	e.Add("example.com.", errors.New("axfr refused"))
+
+	for name, want := range map[string]bool{
+		"app.example.com":     true,
+		"example.com":         true,
+		"app.sub.example.com": false, // healthy subzone
+		"app.example.org":     false,
+	} {
+		if got := e.Contains(name); got != want {
+			t.Errorf("Contains(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestZoneErrors_Contains_PartialApplyOnlyFailedNames(t *testing.T) {
+	e := NewZoneErrors(zoneOf)
+	e.Add("example.com.", &PartialApplyError{
+		Failed: []string{"b.example.com.", "a-external-dns-docker-owner.b.example.com."},
+		Err:    errors.New("update batch 2/3: refused"),
+	})
+
+	for name, want := range map[string]bool{
+		"a.example.com": false, // in a committed batch
+		"B.example.com": true,
+		"a-external-dns-docker-owner.b.example.com": true,
+	} {
+		if got := e.Contains(name); got != want {
+			t.Errorf("Contains(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestZoneErrors_ErrorAndUnwrap(t *testing.T) {
+	sentinel := errors.New("timeout")
+	e := NewZoneErrors(zoneOf)
+	e.Add("sub.example.com.", sentinel)
+	e.Add("example.com.", errors.New("refused"))
+
+	if got, want := e.Error(), "zone example.com.: refused; zone sub.example.com.: timeout"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if !errors.Is(e, sentinel) {
+		t.Error("errors.Is should find a zone's error")
+	}
+	if e.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", e.Len())
+	}
+}
+
+func TestZoneErrors_Zones_NilWhenEmpty(t *testing.T) {
+	var nilErrs *ZoneErrors
+	if nilErrs.Zones() != nil {
+		t.Error("Zones() on nil should be nil")
+	}
+	if NewZoneErrors(zoneOf).Zones() != nil {
+		t.Error("Zones() with no failures should be nil")
+	}
+}
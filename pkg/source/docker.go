@@ -13,6 +13,7 @@ import (
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
 	dockerclient "github.com/docker/docker/client"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -22,7 +23,7 @@ import (
 
 var dockerEventsTotal = promauto.NewCounter(prometheus.CounterOpts{
 	Name: "external_dns_docker_docker_events_total",
-	Help: "Total number of Docker container lifecycle events received.",
+	Help: "Total number of Docker container lifecycle and network events received.",
 })
 
 // labelRE matches a single valid RFC 1123 DNS label (1–63 characters).
@@ -48,6 +49,21 @@ func isValidHostname(name string) bool {
 	return true
 }
 
+// serviceLabelRE matches an RFC 2782 service or protocol label such as "_sip".
+var serviceLabelRE = regexp.MustCompile(`^_[a-zA-Z0-9]([a-zA-Z0-9\-]{0,60}[a-zA-Z0-9])?$`)
+
+// isValidSRVName reports whether name is a valid SRV owner name: a hostname
+// optionally preceded by underscore-prefixed service and protocol labels,
+// e.g. "_sip._tcp.example.com".
+func isValidSRVName(name string) bool {
+	labels := strings.Split(strings.TrimSuffix(name, "."), ".")
+	i := 0
+	for i < len(labels) && serviceLabelRE.MatchString(labels[i]) {
+		i++
+	}
+	return i < len(labels) && isValidHostname(strings.Join(labels[i:], "."))
+}
+
 // isValidTarget reports whether target is a valid A/AAAA/CNAME value.
 // Strings matching the four-octet decimal pattern that are not valid IPv4
 // addresses are explicitly rejected (e.g. "999.999.999.999").
@@ -62,11 +78,18 @@ func isValidTarget(target string) bool {
 }
 
 const (
-	labelPrefix     = "external-dns.io/"
-	labelHostname   = labelPrefix + "hostname"
-	labelTarget     = labelPrefix + "target"
-	labelTTL        = labelPrefix + "ttl"
-	labelRecordType = labelPrefix + "record-type"
+	labelPrefix      = "external-dns.io/"
+	labelHostname    = labelPrefix + "hostname"
+	labelTarget      = labelPrefix + "target"
+	labelTTL         = labelPrefix + "ttl"
+	labelRecordType  = labelPrefix + "record-type"
+	labelPTR         = labelPrefix + "ptr"
+	labelDeleteGrace = labelPrefix + "delete-grace"
+	labelNetwork     = labelPrefix + "network"
+
+	// targetAuto is the target label value that derives targets from the
+	// addresses the labelled object holds at runtime instead of a literal.
+	targetAuto = "auto"
 )
 
 // dockerAPI is the subset of the Docker client used by DockerSource.
@@ -91,6 +114,19 @@ type DockerSource struct {
 // override env-based settings where they conflict (e.g. WithHost overrides
 // DOCKER_HOST).
 func NewDockerSource(log *slog.Logger, extraOpts ...dockerclient.Opt) (*DockerSource, error) {
+	c, err := newClient(extraOpts)
+	if err != nil {
+		return nil, err
+	}
+	if log == nil {
+		log = slog.Default()
+	}
+	return &DockerSource{client: c, log: log, reconnectWait: 5 * time.Second}, nil
+}
+
+// newClient builds a Docker API client from the environment, followed by
+// extraOpts.
+func newClient(extraOpts []dockerclient.Opt) (*dockerclient.Client, error) {
 	opts := []dockerclient.Opt{
 		dockerclient.FromEnv,
 		dockerclient.WithAPIVersionNegotiation(),
@@ -100,10 +136,7 @@ func NewDockerSource(log *slog.Logger, extraOpts ...dockerclient.Opt) (*DockerSo
 	if err != nil {
 		return nil, fmt.Errorf("docker client: %w", err)
 	}
-	if log == nil {
-		log = slog.Default()
-	}
-	return &DockerSource{client: c, log: log, reconnectWait: 5 * time.Second}, nil
+	return c, nil
 }
 
 // newDockerSourceWithClient constructs a DockerSource with an injected client
@@ -120,7 +153,9 @@ func (s *DockerSource) Close() error {
 	return s.client.Close()
 }
 
-// Endpoints lists running containers and extracts DNS endpoints from their labels.
+// Endpoints lists running containers and extracts DNS endpoints from their
+// labels. Records that several containers declare for the same name and type
+// are merged into one multi-target endpoint (see mergeEndpoints).
 func (s *DockerSource) Endpoints(ctx context.Context) ([]*endpoint.Endpoint, error) {
 	containers, err := s.client.ContainerList(ctx, container.ListOptions{})
 	if err != nil {
@@ -133,9 +168,17 @@ func (s *DockerSource) Endpoints(ctx context.Context) ([]*endpoint.Endpoint, err
 		if len(id) > 12 {
 			id = id[:12]
 		}
-		eps = append(eps, s.endpointsFromLabels(id, c.Labels)...)
+		var networks map[string]*network.EndpointSettings
+		if c.NetworkSettings != nil {
+			networks = c.NetworkSettings.Networks
+		}
+		name := id
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		eps = append(eps, markSource(s.endpointsFromLabels(id, c.Labels, networks), "container/"+name)...)
 	}
-	return eps, nil
+	return mergeEndpoints(s.log, eps), nil
 }
 
 // AddEventHandler registers a function called when a relevant Docker event occurs.
@@ -144,8 +187,8 @@ func (s *DockerSource) AddEventHandler(_ context.Context, handler func()) {
 }
 
 // Watch subscribes to Docker Events and calls registered handlers on container
-// lifecycle events. Reconnects automatically on stream errors. Blocks until ctx
-// is cancelled.
+// lifecycle and network connect/disconnect events. Reconnects automatically on
+// stream errors. Blocks until ctx is cancelled.
 func (s *DockerSource) Watch(ctx context.Context) {
 	for {
 		s.runEventLoop(ctx)
@@ -161,10 +204,14 @@ func (s *DockerSource) Watch(ctx context.Context) {
 func (s *DockerSource) runEventLoop(ctx context.Context) {
 	f := filters.NewArgs(
 		filters.Arg("type", "container"),
+		filters.Arg("type", "network"),
 		filters.Arg("event", "start"),
 		filters.Arg("event", "stop"),
 		filters.Arg("event", "die"),
 		filters.Arg("event", "update"),
+		// Network attachments change the addresses used by target=auto.
+		filters.Arg("event", "connect"),
+		filters.Arg("event", "disconnect"),
 	)
 	msgs, errs := s.client.Events(ctx, events.ListOptions{Filters: f})
 	for {
@@ -189,76 +236,321 @@ func (s *DockerSource) notify() {
 	}
 }
 
-// endpointsFromLabels parses DNS labels from a container's label map.
-// containerID is used only for log messages.
-func (s *DockerSource) endpointsFromLabels(containerID string, labels map[string]string) []*endpoint.Endpoint {
-	var eps []*endpoint.Endpoint
+// labelSet holds the raw label values of one record declaration: either the
+// non-indexed labels or a single index of the indexed form.
+type labelSet struct {
+	hostname    string
+	target      string
+	ttl         string
+	recordType  string
+	network     string
+	ptr         string
+	deleteGrace string
+}
+
+// labelSets extracts every record declaration from a label map, the
+// non-indexed form first followed by external-dns.io/hostname-0, -1, … in
+// order. Indexed parsing stops at the first missing hostname-N label.
+func labelSets(labels map[string]string) []labelSet {
+	var sets []labelSet
 
 	// Non-indexed single record.
 	if hostname, ok := labels[labelHostname]; ok {
-		if ep := s.parseSingle(containerID, hostname, labels[labelTarget], labels[labelTTL], labels[labelRecordType]); ep != nil {
-			eps = append(eps, ep)
-		}
+		sets = append(sets, labelSet{
+			hostname:    hostname,
+			target:      labels[labelTarget],
+			ttl:         labels[labelTTL],
+			recordType:  labels[labelRecordType],
+			network:     labels[labelNetwork],
+			ptr:         labels[labelPTR],
+			deleteGrace: labels[labelDeleteGrace],
+		})
 	}
 
 	// Indexed records: external-dns.io/hostname-0, external-dns.io/target-0, …
 	for i := 0; ; i++ {
-		hostnameKey := fmt.Sprintf("%shostname-%d", labelPrefix, i)
-		hostname, ok := labels[hostnameKey]
+		hostname, ok := labels[fmt.Sprintf("%s-%d", labelHostname, i)]
 		if !ok {
 			break
 		}
-		targetKey := fmt.Sprintf("%starget-%d", labelPrefix, i)
-		ttlKey := fmt.Sprintf("%sttl-%d", labelPrefix, i)
-		rtKey := fmt.Sprintf("%srecord-type-%d", labelPrefix, i)
-		if ep := s.parseSingle(containerID, hostname, labels[targetKey], labels[ttlKey], labels[rtKey]); ep != nil {
-			eps = append(eps, ep)
+		sets = append(sets, labelSet{
+			hostname:    hostname,
+			target:      labels[fmt.Sprintf("%s-%d", labelTarget, i)],
+			ttl:         labels[fmt.Sprintf("%s-%d", labelTTL, i)],
+			recordType:  labels[fmt.Sprintf("%s-%d", labelRecordType, i)],
+			network:     labels[fmt.Sprintf("%s-%d", labelNetwork, i)],
+			ptr:         labels[fmt.Sprintf("%s-%d", labelPTR, i)],
+			deleteGrace: labels[fmt.Sprintf("%s-%d", labelDeleteGrace, i)],
+		})
+	}
+
+	return sets
+}
+
+// endpointsFromLabels parses DNS labels from a container's label map.
+// networks holds the container's network attachments, used by records whose
+// target is derived automatically. containerID is used only for log messages.
+func (s *DockerSource) endpointsFromLabels(containerID string, labels map[string]string, networks map[string]*network.EndpointSettings) []*endpoint.Endpoint {
+	log := s.log.With("container", containerID)
+	var eps []*endpoint.Endpoint
+	for _, ls := range labelSets(labels) {
+		if isAutoTarget(ls) {
+			if targets, ok := networkTargets(log, ls, networks); ok {
+				eps = append(eps, annotate(log, ls, parseDerived(log, ls, targets))...)
+			}
+			continue
+		}
+		if ep := parseSingle(log, ls.hostname, ls.target, ls.ttl, ls.recordType); ep != nil {
+			eps = append(eps, annotate(log, ls, []*endpoint.Endpoint{ep})...)
+		}
+	}
+	return eps
+}
+
+// annotate copies per-record options from the declaration's labels onto the
+// endpoint Labels of eps: endpoint.LabelPTR on A and AAAA endpoints when the
+// ptr label is true, and endpoint.LabelDeleteGrace when a delete-grace label
+// is set. Unparsable values are logged and ignored.
+func annotate(log *slog.Logger, ls labelSet, eps []*endpoint.Endpoint) []*endpoint.Endpoint {
+	if raw := strings.TrimSpace(ls.ptr); raw != "" {
+		want, err := strconv.ParseBool(raw)
+		if err != nil {
+			log.Warn("invalid ptr label, ignoring", "hostname", ls.hostname, "ptr", ls.ptr)
+		}
+		for _, ep := range eps {
+			if want && (ep.RecordType == endpoint.RecordTypeA || ep.RecordType == endpoint.RecordTypeAAAA) {
+				ep.Labels[endpoint.LabelPTR] = "true"
+			}
+		}
+	}
+	if raw := strings.TrimSpace(ls.deleteGrace); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			log.Warn("invalid delete-grace label, ignoring", "hostname", ls.hostname, "delete_grace", ls.deleteGrace)
+			return eps
+		}
+		for _, ep := range eps {
+			ep.Labels[endpoint.LabelDeleteGrace] = d.String()
 		}
 	}
+	return eps
+}
 
+// markSource records origin, e.g. "container/web-1", as the
+// endpoint.LabelSource of eps.
+func markSource(eps []*endpoint.Endpoint, origin string) []*endpoint.Endpoint {
+	for _, ep := range eps {
+		ep.Labels[endpoint.LabelSource] = origin
+	}
 	return eps
 }
 
+// isAutoTarget reports whether a record declaration derives its targets at
+// runtime: either target=auto, or a network label with no target label.
+func isAutoTarget(ls labelSet) bool {
+	target := strings.TrimSpace(ls.target)
+	if strings.EqualFold(target, targetAuto) {
+		return true
+	}
+	return target == "" && strings.TrimSpace(ls.network) != ""
+}
+
+// networkTargets returns the IPv4 and IPv6 addresses a container holds on the
+// network named by the declaration's network label. Without a network label
+// the container must be attached to exactly one network. Returns false and
+// logs a warning when the network cannot be determined.
+func networkTargets(log *slog.Logger, ls labelSet, networks map[string]*network.EndpointSettings) ([]string, bool) {
+	name := strings.TrimSpace(ls.network)
+	if name == "" {
+		if len(networks) != 1 {
+			log.Warn("target=auto requires a network label when not attached to exactly one network, skipping",
+				"hostname", ls.hostname, "networks", len(networks))
+			return nil, false
+		}
+		for n := range networks {
+			name = n
+		}
+	}
+	settings, ok := networks[name]
+	if !ok || settings == nil {
+		log.Warn("not attached to the labelled network, skipping",
+			"hostname", ls.hostname, "network", name)
+		return nil, false
+	}
+	var targets []string
+	if settings.IPAddress != "" {
+		targets = append(targets, settings.IPAddress)
+	}
+	if settings.GlobalIPv6Address != "" {
+		targets = append(targets, settings.GlobalIPv6Address)
+	}
+	return targets, true
+}
+
 // parseSingle builds one Endpoint from raw label strings.
 // Returns nil and logs a warning when required labels are absent or invalid.
-func (s *DockerSource) parseSingle(containerID, hostname, target, rawTTL, rawRecordType string) *endpoint.Endpoint {
+// log should already carry the identity of the labelled object.
+func parseSingle(log *slog.Logger, hostname, target, rawTTL, rawRecordType string) *endpoint.Endpoint {
 	hostname = strings.TrimSpace(hostname)
 	if hostname == "" {
 		return nil
 	}
-	if !isValidHostname(hostname) {
-		s.log.Warn("container has invalid hostname label, skipping",
-			"container", containerID, "hostname", hostname)
+
+	recordType := strings.ToUpper(strings.TrimSpace(rawRecordType))
+	if recordType != "" && !supportedRecordTypes[recordType] {
+		log.Warn("unsupported record-type label, skipping", "hostname", hostname, "record_type", rawRecordType)
+		return nil
+	}
+
+	validName := isValidHostname(hostname)
+	if recordType == endpoint.RecordTypeSRV {
+		validName = isValidSRVName(hostname)
+	}
+	if !validName {
+		log.Warn("invalid hostname label, skipping", "hostname", hostname)
 		return nil
 	}
 
 	target = strings.TrimSpace(target)
 	if target == "" {
-		s.log.Warn("container missing target label, skipping",
-			"container", containerID, "hostname", hostname)
+		log.Warn("missing target label, skipping", "hostname", hostname)
 		return nil
 	}
-	if !isValidTarget(target) {
-		s.log.Warn("container has invalid target label, skipping",
-			"container", containerID, "hostname", hostname, "target", target)
+	if endpoint.IsStructuredType(recordType) {
+		normalised, ok := parseStructuredTarget(recordType, target)
+		if !ok {
+			log.Warn("invalid target label, skipping",
+				"hostname", hostname, "record_type", recordType, "target", target)
+			return nil
+		}
+		target = normalised
+	} else if !isValidTarget(target) {
+		log.Warn("invalid target label, skipping", "hostname", hostname, "target", target)
 		return nil
 	}
 
-	ttl := endpoint.DefaultTTL
-	if rawTTL != "" {
-		v, err := strconv.ParseInt(strings.TrimSpace(rawTTL), 10, 64)
-		if err != nil || v < 0 {
-			s.log.Warn("container has invalid TTL, skipping",
-				"container", containerID, "hostname", hostname, "ttl", rawTTL)
-			return nil
-		}
-		ttl = v
+	ttl, ok := parseTTL(log, hostname, rawTTL)
+	if !ok {
+		return nil
 	}
 
-	recordType := strings.TrimSpace(rawRecordType)
 	if recordType == "" {
 		recordType = endpoint.InferRecordType(target)
 	}
 
 	return endpoint.New(hostname, []string{target}, recordType, ttl, nil)
 }
+
+// supportedRecordTypes are the values accepted in record-type labels.
+var supportedRecordTypes = map[string]bool{
+	endpoint.RecordTypeA:     true,
+	endpoint.RecordTypeAAAA:  true,
+	endpoint.RecordTypeCNAME: true,
+	endpoint.RecordTypeTXT:   true,
+	endpoint.RecordTypeMX:    true,
+	endpoint.RecordTypeSRV:   true,
+	endpoint.RecordTypeCAA:   true,
+	endpoint.RecordTypeNS:    true,
+	endpoint.RecordTypePTR:   true,
+}
+
+// parseStructuredTarget validates a target label for an MX, SRV, CAA, NS or
+// PTR record and returns it in canonical form. Hostnames embedded in the
+// target must be valid RFC 1123 names.
+func parseStructuredTarget(recordType, target string) (string, bool) {
+	normalised, err := endpoint.NormaliseTarget(recordType, target)
+	if err != nil {
+		return "", false
+	}
+	var host string
+	switch recordType {
+	case endpoint.RecordTypeMX:
+		t, _ := endpoint.ParseMXTarget(normalised)
+		host = t.Host
+	case endpoint.RecordTypeSRV:
+		t, _ := endpoint.ParseSRVTarget(normalised)
+		host = t.Target
+	case endpoint.RecordTypeNS, endpoint.RecordTypePTR:
+		host = normalised
+	default:
+		return normalised, true
+	}
+	return normalised, isValidHostname(host)
+}
+
+// parseTTL parses a TTL label value, returning endpoint.DefaultTTL when raw is
+// empty. Returns false and logs a warning when the value is not a non-negative
+// integer.
+func parseTTL(log *slog.Logger, hostname, raw string) (int64, bool) {
+	if raw == "" {
+		return endpoint.DefaultTTL, true
+	}
+	v, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+	if err != nil || v < 0 {
+		log.Warn("invalid TTL label, skipping", "hostname", hostname, "ttl", raw)
+		return 0, false
+	}
+	return v, true
+}
+
+// parseDerived builds endpoints for a record declaration whose targets were
+// derived from the runtime rather than given by a target label. Targets are
+// grouped into one A and one AAAA endpoint; an explicit record-type label
+// keeps only the matching family. Returns nil and logs a warning when the
+// labels are invalid or no usable target exists.
+func parseDerived(log *slog.Logger, ls labelSet, targets []string) []*endpoint.Endpoint {
+	hostname := strings.TrimSpace(ls.hostname)
+	if hostname == "" {
+		return nil
+	}
+	if !isValidHostname(hostname) {
+		log.Warn("invalid hostname label, skipping", "hostname", hostname)
+		return nil
+	}
+	ttl, ok := parseTTL(log, hostname, ls.ttl)
+	if !ok {
+		return nil
+	}
+
+	want := strings.ToUpper(strings.TrimSpace(ls.recordType))
+	if want != "" && want != endpoint.RecordTypeA && want != endpoint.RecordTypeAAAA {
+		log.Warn("record type not supported for derived targets, skipping",
+			"hostname", hostname, "record_type", want)
+		return nil
+	}
+
+	byType := map[string][]string{}
+	for _, t := range dedupe(targets) {
+		rt := endpoint.InferRecordType(t)
+		if want != "" && rt != want {
+			continue
+		}
+		byType[rt] = append(byType[rt], t)
+	}
+	if len(byType) == 0 {
+		log.Warn("no addresses available for derived target, skipping", "hostname", hostname)
+		return nil
+	}
+
+	var eps []*endpoint.Endpoint
+	for _, rt := range []string{endpoint.RecordTypeA, endpoint.RecordTypeAAAA} {
+		if ts := byType[rt]; len(ts) > 0 {
+			eps = append(eps, endpoint.New(hostname, ts, rt, ttl, nil))
+		}
+	}
+	return eps
+}
+
+// dedupe returns s with duplicate entries removed, preserving first-seen order.
+func dedupe(s []string) []string {
+	seen := make(map[string]bool, len(s))
+	out := make([]string, 0, len(s))
+	for _, v := range s {
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		out = append(out, v)
+	}
+	return out
+}
@@ -4,19 +4,25 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
 	dockerclient "github.com/docker/docker/client"
+
+	"github.com/bkero/external-dns-docker/pkg/endpoint"
 )
 
 // mockDockerClient implements dockerAPI for tests.
 type mockDockerClient struct {
 	containers []container.Summary
 	listErr    error
+	// lastEventOpts records the options passed to the most recent Events call.
+	lastEventOpts events.ListOptions
 	// eventCh and errCh are returned by Events(). Tests send on them to simulate events.
 	eventCh chan events.Message
 	errCh   chan error
@@ -34,7 +40,8 @@ func (m *mockDockerClient) ContainerList(_ context.Context, _ container.ListOpti
 	return m.containers, m.listErr
 }
 
-func (m *mockDockerClient) Events(_ context.Context, _ events.ListOptions) (<-chan events.Message, <-chan error) {
+func (m *mockDockerClient) Events(_ context.Context, opts events.ListOptions) (<-chan events.Message, <-chan error) {
+	m.lastEventOpts = opts
 	return m.eventCh, m.errCh
 }
 
@@ -641,6 +648,189 @@ func TestIsValidHostname(t *testing.T) {
 	}
 }
 
+func TestDockerSource_PTRLabel_MarksAddressRecords(t *testing.T) {
+	src, _ := newTestSource([]container.Summary{{
+		ID: "abc123",
+		Labels: map[string]string{
+			"external-dns.io/hostname-0": "app.example.com",
+			"external-dns.io/target-0":   "192.0.2.5",
+			"external-dns.io/ptr-0":      "true",
+			"external-dns.io/hostname-1": "www.example.com",
+			"external-dns.io/target-1":   "app.example.com",
+			"external-dns.io/ptr-1":      "true",
+			"external-dns.io/hostname-2": "db.example.com",
+			"external-dns.io/target-2":   "192.0.2.6",
+			"external-dns.io/ptr-2":      "maybe",
+		},
+	}})
+
+	eps, _ := src.Endpoints(context.Background())
+	if len(eps) != 3 {
+		t.Fatalf("got %d endpoints, want 3", len(eps))
+	}
+	for _, ep := range eps {
+		want := ep.DNSName == "app.example.com" // CNAMEs and invalid values are not marked
+		if got := ep.Labels[endpoint.LabelPTR] == "true"; got != want {
+			t.Errorf("%s: ptr label = %v, want %v", ep.DNSName, got, want)
+		}
+	}
+}
+
+func TestDockerSource_DeleteGraceLabel(t *testing.T) {
+	src, _ := newTestSource([]container.Summary{{
+		ID: "abc123",
+		Labels: map[string]string{
+			"external-dns.io/hostname-0":     "app.example.com",
+			"external-dns.io/target-0":       "192.0.2.5",
+			"external-dns.io/delete-grace-0": "90s",
+			"external-dns.io/hostname-1":     "db.example.com",
+			"external-dns.io/target-1":       "192.0.2.6",
+			"external-dns.io/delete-grace-1": "soon",
+			"external-dns.io/hostname-2":     "web.example.com",
+			"external-dns.io/target-2":       "192.0.2.7",
+			"external-dns.io/delete-grace-2": "-1m",
+		},
+	}})
+
+	eps, _ := src.Endpoints(context.Background())
+	if len(eps) != 3 {
+		t.Fatalf("got %d endpoints, want 3", len(eps))
+	}
+	for _, ep := range eps {
+		got, ok := ep.Labels[endpoint.LabelDeleteGrace]
+		switch ep.DNSName {
+		case "app.example.com":
+			if got != "1m30s" {
+				t.Errorf("%s: delete-grace = %q, want 1m30s", ep.DNSName, got)
+			}
+		default: // invalid and negative values are ignored
+			if ok {
+				t.Errorf("%s: delete-grace = %q, want unset", ep.DNSName, got)
+			}
+		}
+	}
+}
+
+func TestDockerSource_SourceLabel(t *testing.T) {
+	src, _ := newTestSource([]container.Summary{
+		{
+			ID:     "abc123def4567890",
+			Names:  []string{"/web-1"},
+			Labels: map[string]string{"external-dns.io/hostname": "app.example.com", "external-dns.io/target": "192.0.2.5"},
+		},
+		{
+			ID:     "fed987cba6543210",
+			Labels: map[string]string{"external-dns.io/hostname": "db.example.com", "external-dns.io/target": "192.0.2.6"},
+		},
+	})
+
+	eps, _ := src.Endpoints(context.Background())
+	want := map[string]string{
+		"app.example.com": "container/web-1",
+		"db.example.com":  "container/fed987cba654",
+	}
+	if len(eps) != len(want) {
+		t.Fatalf("got %d endpoints, want %d", len(eps), len(want))
+	}
+	for _, ep := range eps {
+		if got := ep.Labels[endpoint.LabelSource]; got != want[ep.DNSName] {
+			t.Errorf("%s: source = %q, want %q", ep.DNSName, got, want[ep.DNSName])
+		}
+	}
+}
+
+func TestIsValidSRVName(t *testing.T) {
+	tests := map[string]bool{
+		"_sip._tcp.example.com":  true,
+		"_ldap._tcp.dc.example.": true,
+		"example.com":            true,
+		"_sip._tcp":              false,
+		"sip._tcp.example.com":   false,
+		"_si p._tcp.example.com": false,
+	}
+	for in, want := range tests {
+		if got := isValidSRVName(in); got != want {
+			t.Errorf("isValidSRVName(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestDockerSource_StructuredRecordTypes(t *testing.T) {
+	src, _ := newTestSource([]container.Summary{
+		{
+			ID: "mail01",
+			Labels: map[string]string{
+				"external-dns.io/hostname-0":    "example.com",
+				"external-dns.io/target-0":      "10 Mail.Example.com.",
+				"external-dns.io/record-type-0": "mx",
+				"external-dns.io/hostname-1":    "_submission._tcp.example.com",
+				"external-dns.io/target-1":      "0 5 587 mail.example.com",
+				"external-dns.io/record-type-1": "SRV",
+				"external-dns.io/hostname-2":    "example.com",
+				"external-dns.io/target-2":      "0 issue letsencrypt.org",
+				"external-dns.io/record-type-2": "CAA",
+				"external-dns.io/hostname-3":    "10.2.0.192.in-addr.arpa",
+				"external-dns.io/target-3":      "mail.example.com",
+				"external-dns.io/record-type-3": "PTR",
+				"external-dns.io/hostname-4":    "lab.example.com",
+				"external-dns.io/target-4":      "ns1.lab.example.com",
+				"external-dns.io/record-type-4": "NS",
+			},
+		},
+	})
+
+	eps, err := src.Endpoints(context.Background())
+	if err != nil {
+		t.Fatalf("Endpoints() error = %v", err)
+	}
+	got := make(map[string]string)
+	for _, ep := range eps {
+		got[ep.RecordType] = ep.DNSName + " " + strings.Join(ep.Targets, ",")
+	}
+	want := map[string]string{
+		"MX":  "example.com 10 mail.example.com",
+		"SRV": "_submission._tcp.example.com 0 5 587 mail.example.com",
+		"CAA": `example.com 0 issue "letsencrypt.org"`,
+		"PTR": "10.2.0.192.in-addr.arpa mail.example.com",
+		"NS":  "lab.example.com ns1.lab.example.com",
+	}
+	for rt, w := range want {
+		if got[rt] != w {
+			t.Errorf("%s = %q, want %q", rt, got[rt], w)
+		}
+	}
+}
+
+func TestDockerSource_InvalidStructuredTargets_Skipped(t *testing.T) {
+	cases := []struct {
+		name, recordType, hostname, target string
+	}{
+		{"MX without preference", "MX", "example.com", "mail.example.com"},
+		{"MX invalid host", "MX", "example.com", "10 mail_relay.example.com"},
+		{"SRV missing port", "SRV", "_smtp._tcp.example.com", "0 5 mail.example.com"},
+		{"CAA missing value", "CAA", "example.com", "0 issue"},
+		{"PTR with several hosts", "PTR", "10.2.0.192.in-addr.arpa", "a.example.com b.example.com"},
+		{"unsupported type", "HINFO", "example.com", "x86 linux"},
+		{"service name on A record", "A", "_smtp._tcp.example.com", "10.0.0.1"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			src, _ := newTestSource([]container.Summary{{
+				ID: "abc123",
+				Labels: map[string]string{
+					"external-dns.io/hostname":    tc.hostname,
+					"external-dns.io/target":      tc.target,
+					"external-dns.io/record-type": tc.recordType,
+				},
+			}})
+			eps, _ := src.Endpoints(context.Background())
+			if len(eps) != 0 {
+				t.Errorf("got %v, want no endpoints", eps)
+			}
+		})
+	}
+}
+
 func TestIsValidTarget(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -685,3 +875,196 @@ func TestDockerSource_WhitespaceHostname_Skipped(t *testing.T) {
 		t.Errorf("got %d endpoints, want 0 (whitespace hostname)", len(eps))
 	}
 }
+
+// --- target=auto ---
+
+func withNetworks(c container.Summary, nets map[string]*network.EndpointSettings) container.Summary {
+	c.NetworkSettings = &container.NetworkSettingsSummary{Networks: nets}
+	return c
+}
+
+func TestDockerSource_AutoTarget_NamedNetwork(t *testing.T) {
+	src, _ := newTestSource([]container.Summary{
+		withNetworks(container.Summary{
+			ID: "abc123",
+			Labels: map[string]string{
+				"external-dns.io/hostname": "app.example.com",
+				"external-dns.io/target":   "auto",
+				"external-dns.io/network":  "frontend",
+			},
+		}, map[string]*network.EndpointSettings{
+			"frontend": {IPAddress: "172.20.0.5", GlobalIPv6Address: "fd00:20::5"},
+			"backend":  {IPAddress: "172.21.0.5"},
+		}),
+	})
+
+	eps, err := src.Endpoints(context.Background())
+	if err != nil {
+		t.Fatalf("Endpoints() error = %v", err)
+	}
+	if len(eps) != 2 {
+		t.Fatalf("got %d endpoints, want 2 (A + AAAA)", len(eps))
+	}
+	if eps[0].RecordType != "A" || eps[0].Targets[0] != "172.20.0.5" {
+		t.Errorf("eps[0] = %v, want A 172.20.0.5", eps[0])
+	}
+	if eps[1].RecordType != "AAAA" || eps[1].Targets[0] != "fd00:20::5" {
+		t.Errorf("eps[1] = %v, want AAAA fd00:20::5", eps[1])
+	}
+}
+
+func TestDockerSource_NetworkLabelWithoutTarget_ImpliesAuto(t *testing.T) {
+	src, _ := newTestSource([]container.Summary{
+		withNetworks(container.Summary{
+			ID: "abc123",
+			Labels: map[string]string{
+				"external-dns.io/hostname-0": "app.example.com",
+				"external-dns.io/network-0":  "backend",
+			},
+		}, map[string]*network.EndpointSettings{
+			"frontend": {IPAddress: "172.20.0.5"},
+			"backend":  {IPAddress: "172.21.0.5"},
+		}),
+	})
+
+	eps, _ := src.Endpoints(context.Background())
+	if len(eps) != 1 || eps[0].Targets[0] != "172.21.0.5" {
+		t.Fatalf("got %v, want a single A 172.21.0.5", eps)
+	}
+}
+
+func TestDockerSource_AutoTarget_SingleNetworkNeedsNoLabel(t *testing.T) {
+	src, _ := newTestSource([]container.Summary{
+		withNetworks(container.Summary{
+			ID: "abc123",
+			Labels: map[string]string{
+				"external-dns.io/hostname": "app.example.com",
+				"external-dns.io/target":   "AUTO",
+			},
+		}, map[string]*network.EndpointSettings{
+			"bridge": {IPAddress: "172.17.0.2"},
+		}),
+	})
+
+	eps, _ := src.Endpoints(context.Background())
+	if len(eps) != 1 || eps[0].Targets[0] != "172.17.0.2" {
+		t.Fatalf("got %v, want a single A 172.17.0.2", eps)
+	}
+}
+
+func TestDockerSource_AutoTarget_LowerCaseRecordType_KeepsFamily(t *testing.T) {
+	src, _ := newTestSource([]container.Summary{
+		withNetworks(container.Summary{
+			ID: "abc123",
+			Labels: map[string]string{
+				"external-dns.io/hostname":    "app.example.com",
+				"external-dns.io/target":      "auto",
+				"external-dns.io/record-type": " aaaa ",
+			},
+		}, map[string]*network.EndpointSettings{
+			"bridge": {IPAddress: "172.17.0.2", GlobalIPv6Address: "fd00:17::2"},
+		}),
+	})
+
+	eps, _ := src.Endpoints(context.Background())
+	if len(eps) != 1 || eps[0].RecordType != "AAAA" || eps[0].Targets[0] != "fd00:17::2" {
+		t.Fatalf("got %v, want a single AAAA fd00:17::2", eps)
+	}
+}
+
+func TestDockerSource_AutoTarget_Skipped(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels map[string]string
+		nets   map[string]*network.EndpointSettings
+	}{
+		{
+			name:   "ambiguous networks",
+			labels: map[string]string{"external-dns.io/target": "auto"},
+			nets: map[string]*network.EndpointSettings{
+				"frontend": {IPAddress: "172.20.0.5"},
+				"backend":  {IPAddress: "172.21.0.5"},
+			},
+		},
+		{
+			name:   "unknown network",
+			labels: map[string]string{"external-dns.io/network": "missing"},
+			nets:   map[string]*network.EndpointSettings{"bridge": {IPAddress: "172.17.0.2"}},
+		},
+		{
+			name:   "no address on network",
+			labels: map[string]string{"external-dns.io/network": "host"},
+			nets:   map[string]*network.EndpointSettings{"host": {}},
+		},
+		{
+			name:   "no network settings",
+			labels: map[string]string{"external-dns.io/target": "auto"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.labels["external-dns.io/hostname"] = "app.example.com"
+			c := container.Summary{ID: "abc123", Labels: tt.labels}
+			if tt.nets != nil {
+				c = withNetworks(c, tt.nets)
+			}
+			src, _ := newTestSource([]container.Summary{c})
+			eps, err := src.Endpoints(context.Background())
+			if err != nil {
+				t.Fatalf("Endpoints() error = %v", err)
+			}
+			if len(eps) != 0 {
+				t.Errorf("got %d endpoints, want 0", len(eps))
+			}
+		})
+	}
+}
+
+func TestDockerSource_EventFilter_IncludesNetworkEvents(t *testing.T) {
+	src, mock := newTestSource(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		src.runEventLoop(ctx)
+		close(done)
+	}()
+	mock.eventCh <- events.Message{Type: "network", Action: "connect"}
+	cancel()
+	<-done
+
+	f := mock.lastEventOpts.Filters
+	for _, want := range []string{"connect", "disconnect"} {
+		if !f.ExactMatch("event", want) {
+			t.Errorf("event filter missing event=%s", want)
+		}
+	}
+	if !f.ExactMatch("type", "network") {
+		t.Error("event filter missing type=network")
+	}
+}
+
+// --- Same hostname on several containers ---
+
+func TestDockerSource_SharedHostname_MergedIntoOneRRset(t *testing.T) {
+	src, _ := newTestSource([]container.Summary{
+		{ID: "replica1", Labels: map[string]string{
+			"external-dns.io/hostname": "app.example.com",
+			"external-dns.io/target":   "10.0.0.2",
+		}},
+		{ID: "replica2", Labels: map[string]string{
+			"external-dns.io/hostname": "app.example.com",
+			"external-dns.io/target":   "10.0.0.1",
+		}},
+	})
+
+	eps, err := src.Endpoints(context.Background())
+	if err != nil {
+		t.Fatalf("Endpoints() error = %v", err)
+	}
+	if len(eps) != 1 {
+		t.Fatalf("got %d endpoints, want 1 merged endpoint", len(eps))
+	}
+	if len(eps[0].Targets) != 2 {
+		t.Errorf("Targets = %v, want both replica IPs", eps[0].Targets)
+	}
+}
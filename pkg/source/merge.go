@@ -0,0 +1,111 @@
+package source
+
+import (
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/bkero/external-dns-docker/pkg/endpoint"
+)
+
+// singletonTypes are record types that may hold only one target per name.
+var singletonTypes = map[string]bool{
+	endpoint.RecordTypeCNAME: true,
+}
+
+// mergeEndpoints combines endpoints that share a DNS name and record type into
+// a single endpoint carrying every distinct target, so that replicas labelled
+// with the same hostname produce one round-robin RRset instead of competing
+// for it. Names are compared case-insensitively and without a trailing dot.
+//
+// Conflicts are resolved deterministically, independent of input order:
+//   - a merged endpoint takes the lowest TTL of its members;
+//   - a singleton type (CNAME) with several distinct targets keeps the
+//     lexicographically smallest one;
+//   - a CNAME is dropped when other record types exist at the same name,
+//     since a CNAME cannot coexist with other data (RFC 1034 §3.6.2).
+//
+// Every conflict is logged at WARN. The first-seen order of names is kept and
+// targets are sorted. Labels are combined; for a key set by several members
+// the first-seen value wins, except endpoint.LabelSource, which lists every
+// member's source.
+func mergeEndpoints(log *slog.Logger, eps []*endpoint.Endpoint) []*endpoint.Endpoint {
+	type group struct {
+		first       *endpoint.Endpoint
+		targets     []string
+		ttl         int64
+		ttlConflict bool
+		labels      map[string]string
+		sources     []string
+	}
+	groups := make(map[string]*group, len(eps))
+	var order []string
+	typesByName := make(map[string]map[string]bool)
+
+	for _, ep := range eps {
+		name := normaliseName(ep.DNSName)
+		key := name + "|" + ep.RecordType
+		g, ok := groups[key]
+		if !ok {
+			g = &group{first: ep, ttl: ep.TTL, labels: make(map[string]string)}
+			groups[key] = g
+			order = append(order, key)
+			if typesByName[name] == nil {
+				typesByName[name] = make(map[string]bool)
+			}
+			typesByName[name][ep.RecordType] = true
+		}
+		if ep.TTL != g.first.TTL {
+			g.ttlConflict = true
+		}
+		if ep.TTL < g.ttl {
+			g.ttl = ep.TTL
+		}
+		g.targets = append(g.targets, ep.Targets...)
+		for k, v := range ep.Labels {
+			if k == endpoint.LabelSource {
+				g.sources = append(g.sources, strings.Split(v, ",")...)
+				continue
+			}
+			if _, set := g.labels[k]; !set {
+				g.labels[k] = v
+			}
+		}
+	}
+
+	out := make([]*endpoint.Endpoint, 0, len(order))
+	for _, key := range order {
+		g := groups[key]
+		rt := g.first.RecordType
+		name := normaliseName(g.first.DNSName)
+		if rt == endpoint.RecordTypeCNAME && len(typesByName[name]) > 1 {
+			log.Warn("CNAME conflicts with other records at the same name, dropping CNAME",
+				"hostname", g.first.DNSName, "targets", g.targets)
+			continue
+		}
+		if g.ttlConflict {
+			log.Warn("conflicting TTLs for merged record, using the lowest",
+				"hostname", g.first.DNSName, "record_type", rt, "ttl", g.ttl)
+		}
+		targets := dedupe(g.targets)
+		sort.Strings(targets)
+		if singletonTypes[rt] && len(targets) > 1 {
+			log.Warn("conflicting targets for single-value record, keeping the first in sort order",
+				"hostname", g.first.DNSName, "record_type", rt, "kept", targets[0], "dropped", targets[1:])
+			targets = targets[:1]
+		}
+		if len(g.sources) > 0 {
+			sources := dedupe(g.sources)
+			sort.Strings(sources)
+			g.labels[endpoint.LabelSource] = strings.Join(sources, ",")
+		}
+		out = append(out, endpoint.New(g.first.DNSName, targets, rt, g.ttl, g.labels))
+	}
+	return out
+}
+
+// normaliseName returns name lower-cased and without a trailing dot, for use
+// as a comparison key.
+func normaliseName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
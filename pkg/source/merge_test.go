@@ -0,0 +1,103 @@
+package source
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/bkero/external-dns-docker/pkg/endpoint"
+)
+
+func mergeEP(name, target, rt string, ttl int64) *endpoint.Endpoint {
+	return endpoint.New(name, []string{target}, rt, ttl, nil)
+}
+
+func TestMergeEndpoints_SameNameAndType_Combined(t *testing.T) {
+	got := mergeEndpoints(slog.Default(), []*endpoint.Endpoint{
+		mergeEP("app.example.com", "10.0.0.2", "A", 300),
+		mergeEP("other.example.com", "10.0.0.9", "A", 300),
+		mergeEP("APP.example.com.", "10.0.0.1", "A", 300),
+		mergeEP("app.example.com", "10.0.0.2", "A", 300), // duplicate target
+	})
+	if len(got) != 2 {
+		t.Fatalf("got %d endpoints, want 2", len(got))
+	}
+	if got[0].DNSName != "app.example.com" {
+		t.Errorf("first endpoint = %q, want app.example.com (first-seen order)", got[0].DNSName)
+	}
+	ts := got[0].Targets
+	if len(ts) != 2 || ts[0] != "10.0.0.1" || ts[1] != "10.0.0.2" {
+		t.Errorf("Targets = %v, want [10.0.0.1 10.0.0.2]", ts)
+	}
+}
+
+func TestMergeEndpoints_DifferentTypes_KeptSeparate(t *testing.T) {
+	got := mergeEndpoints(slog.Default(), []*endpoint.Endpoint{
+		mergeEP("app.example.com", "10.0.0.1", "A", 300),
+		mergeEP("app.example.com", "fd00::1", "AAAA", 300),
+	})
+	if len(got) != 2 {
+		t.Fatalf("got %d endpoints, want 2", len(got))
+	}
+}
+
+func TestMergeEndpoints_ConflictingTTL_LowestWins(t *testing.T) {
+	got := mergeEndpoints(slog.Default(), []*endpoint.Endpoint{
+		mergeEP("app.example.com", "10.0.0.1", "A", 300),
+		mergeEP("app.example.com", "10.0.0.2", "A", 60),
+		mergeEP("app.example.com", "10.0.0.3", "A", 600),
+	})
+	if len(got) != 1 || got[0].TTL != 60 {
+		t.Fatalf("got %v, want one endpoint with TTL 60", got)
+	}
+}
+
+func TestMergeEndpoints_CNAMEConflict_DeterministicWinner(t *testing.T) {
+	for _, order := range [][]string{{"b.example.net", "a.example.net"}, {"a.example.net", "b.example.net"}} {
+		got := mergeEndpoints(slog.Default(), []*endpoint.Endpoint{
+			mergeEP("app.example.com", order[0], "CNAME", 300),
+			mergeEP("app.example.com", order[1], "CNAME", 300),
+		})
+		if len(got) != 1 || len(got[0].Targets) != 1 || got[0].Targets[0] != "a.example.net" {
+			t.Errorf("input %v: got %v, want single CNAME → a.example.net", order, got)
+		}
+	}
+}
+
+func TestMergeEndpoints_CNAMEWithOtherTypes_Dropped(t *testing.T) {
+	got := mergeEndpoints(slog.Default(), []*endpoint.Endpoint{
+		mergeEP("app.example.com", "lb.example.net", "CNAME", 300),
+		mergeEP("app.example.com", "10.0.0.1", "A", 300),
+	})
+	if len(got) != 1 || got[0].RecordType != "A" {
+		t.Fatalf("got %v, want only the A record", got)
+	}
+}
+
+func TestMergeEndpoints_DoesNotModifyInput(t *testing.T) {
+	in := []*endpoint.Endpoint{
+		mergeEP("app.example.com", "10.0.0.1", "A", 300),
+		mergeEP("app.example.com", "10.0.0.2", "A", 300),
+	}
+	_ = mergeEndpoints(slog.Default(), in)
+	if len(in[0].Targets) != 1 || len(in[1].Targets) != 1 {
+		t.Errorf("input endpoints were modified: %v", in)
+	}
+}
+
+func TestMergeEndpoints_SourcesCombined(t *testing.T) {
+	a := mergeEP("app.example.com", "10.0.0.2", "A", 300)
+	a.Labels = map[string]string{endpoint.LabelSource: "container/web-2"}
+	b := mergeEP("app.example.com", "10.0.0.1", "A", 300)
+	b.Labels = map[string]string{endpoint.LabelSource: "container/web-1,service/web"}
+	c := mergeEP("app.example.com", "10.0.0.3", "A", 300)
+	c.Labels = map[string]string{endpoint.LabelSource: "container/web-2"}
+
+	got := mergeEndpoints(slog.Default(), []*endpoint.Endpoint{a, b, c})
+	if len(got) != 1 {
+		t.Fatalf("got %d endpoints, want 1", len(got))
+	}
+	want := "container/web-1,container/web-2,service/web"
+	if s := got[0].Labels[endpoint.LabelSource]; s != want {
+		t.Errorf("source = %q, want %q", s, want)
+	}
+}
@@ -0,0 +1,45 @@
+package source
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/bkero/external-dns-docker/pkg/endpoint"
+)
+
+// MultiSource implements Source by combining the endpoints of several
+// sources, e.g. standalone containers and Swarm services on the same host.
+type MultiSource struct {
+	sources []Source
+	log     *slog.Logger
+}
+
+// NewMulti returns a MultiSource over the given sources, queried in order.
+func NewMulti(log *slog.Logger, sources ...Source) *MultiSource {
+	if log == nil {
+		log = slog.Default()
+	}
+	return &MultiSource{sources: sources, log: log}
+}
+
+// Endpoints combines the endpoints of every underlying source, merging records
+// that different sources declare for the same name and type.
+// Returns the first error encountered, if any.
+func (m *MultiSource) Endpoints(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	var all []*endpoint.Endpoint
+	for _, s := range m.sources {
+		eps, err := s.Endpoints(ctx)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, eps...)
+	}
+	return mergeEndpoints(m.log, all), nil
+}
+
+// AddEventHandler registers handler with every underlying source.
+func (m *MultiSource) AddEventHandler(ctx context.Context, handler func()) {
+	for _, s := range m.sources {
+		s.AddEventHandler(ctx, handler)
+	}
+}
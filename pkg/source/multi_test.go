@@ -0,0 +1,52 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bkero/external-dns-docker/pkg/endpoint"
+)
+
+// stubSource is a minimal Source for MultiSource tests.
+type stubSource struct {
+	eps      []*endpoint.Endpoint
+	err      error
+	handlers int
+}
+
+func (s *stubSource) Endpoints(_ context.Context) ([]*endpoint.Endpoint, error) {
+	return s.eps, s.err
+}
+
+func (s *stubSource) AddEventHandler(_ context.Context, _ func()) { s.handlers++ }
+
+func TestMultiSource_ConcatenatesEndpoints(t *testing.T) {
+	a := &stubSource{eps: []*endpoint.Endpoint{endpoint.New("a.example.com", []string{"10.0.0.1"}, "A", 0, nil)}}
+	b := &stubSource{eps: []*endpoint.Endpoint{endpoint.New("b.example.com", []string{"10.0.0.2"}, "A", 0, nil)}}
+
+	eps, err := NewMulti(nil, a, b).Endpoints(context.Background())
+	if err != nil {
+		t.Fatalf("Endpoints() error = %v", err)
+	}
+	if len(eps) != 2 || eps[0].DNSName != "a.example.com" || eps[1].DNSName != "b.example.com" {
+		t.Errorf("got %v, want a.example.com then b.example.com", eps)
+	}
+}
+
+func TestMultiSource_ErrorPropagates(t *testing.T) {
+	a := &stubSource{}
+	b := &stubSource{err: errors.New("boom")}
+
+	if _, err := NewMulti(nil, a, b).Endpoints(context.Background()); err == nil {
+		t.Error("expected error from failing source")
+	}
+}
+
+func TestMultiSource_AddEventHandler_RegistersWithAll(t *testing.T) {
+	a, b := &stubSource{}, &stubSource{}
+	NewMulti(nil, a, b).AddEventHandler(context.Background(), func() {})
+	if a.handlers != 1 || b.handlers != 1 {
+		t.Errorf("handlers registered = %d/%d, want 1/1", a.handlers, b.handlers)
+	}
+}
@@ -0,0 +1,327 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/swarm"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/bkero/external-dns-docker/pkg/endpoint"
+)
+
+var swarmEventsTotal = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "external_dns_docker_swarm_events_total",
+	Help: "Total number of Docker Swarm service and node events received.",
+})
+
+// labelSwarmTarget selects how a service's record targets are derived when no
+// explicit external-dns.io/target label is set. It applies to every record
+// declared on the service.
+const labelSwarmTarget = labelPrefix + "swarm-target"
+
+// Swarm target modes accepted by the external-dns.io/swarm-target label.
+const (
+	// SwarmTargetVIP targets the service's virtual IPs on its non-ingress networks.
+	SwarmTargetVIP = "vip"
+	// SwarmTargetIngress targets the addresses of every ready, active node,
+	// for services published through the ingress routing mesh.
+	SwarmTargetIngress = "ingress"
+	// SwarmTargetTasks targets the addresses of the service's running tasks
+	// on its non-ingress networks.
+	SwarmTargetTasks = "tasks"
+)
+
+// errUnknownSwarmTarget reports a swarm-target label naming no known mode.
+var errUnknownSwarmTarget = errors.New("unknown " + labelSwarmTarget)
+
+// swarmAPI is the subset of the Docker client used by SwarmSource.
+// Defined as an interface so tests can inject a mock.
+type swarmAPI interface {
+	ServiceList(ctx context.Context, options swarm.ServiceListOptions) ([]swarm.Service, error)
+	TaskList(ctx context.Context, options swarm.TaskListOptions) ([]swarm.Task, error)
+	NodeList(ctx context.Context, options swarm.NodeListOptions) ([]swarm.Node, error)
+	NetworkList(ctx context.Context, options network.ListOptions) ([]network.Summary, error)
+	Events(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error)
+	Close() error
+}
+
+// SwarmSource implements Source by reading labels from Docker Swarm service
+// specs (the deploy.labels section of a stack file). It must talk to a
+// manager node.
+type SwarmSource struct {
+	client        swarmAPI
+	log           *slog.Logger
+	handlers      []func()
+	reconnectWait time.Duration // how long to wait between reconnect attempts
+}
+
+// NewSwarmSource returns a SwarmSource that connects via the environment
+// (DOCKER_HOST, DOCKER_TLS_VERIFY, etc.) or the default Unix socket.
+// extraOpts behave as for NewDockerSource.
+func NewSwarmSource(log *slog.Logger, extraOpts ...dockerclient.Opt) (*SwarmSource, error) {
+	c, err := newClient(extraOpts)
+	if err != nil {
+		return nil, err
+	}
+	if log == nil {
+		log = slog.Default()
+	}
+	return &SwarmSource{client: c, log: log, reconnectWait: 5 * time.Second}, nil
+}
+
+// newSwarmSourceWithClient constructs a SwarmSource with an injected client
+// for unit testing.
+func newSwarmSourceWithClient(client swarmAPI, log *slog.Logger) *SwarmSource {
+	if log == nil {
+		log = slog.Default()
+	}
+	return &SwarmSource{client: client, log: log, reconnectWait: 0}
+}
+
+// Close releases resources held by the SwarmSource, including the underlying Docker client connection.
+func (s *SwarmSource) Close() error {
+	return s.client.Close()
+}
+
+// Endpoints lists Swarm services and extracts DNS endpoints from their labels,
+// merging records declared by several services as DockerSource does. A
+// service with an unknown swarm-target mode is logged and skipped. A failed
+// lookup fails the call: skipping the services it affects would plan the
+// deletion of their records.
+func (s *SwarmSource) Endpoints(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	services, err := s.client.ServiceList(ctx, swarm.ServiceListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing services: %w", err)
+	}
+
+	// Ingress network IDs and node addresses are fetched lazily, at most once
+	// per call, because most services need neither.
+	r := &swarmResolver{client: s.client}
+
+	var eps []*endpoint.Endpoint
+services:
+	for _, svc := range services {
+		sets := labelSets(svc.Spec.Labels)
+		if len(sets) == 0 {
+			continue
+		}
+		log := s.log.With("service", svc.Spec.Name)
+		mode := strings.TrimSpace(svc.Spec.Labels[labelSwarmTarget])
+		var svcEps []*endpoint.Endpoint
+		for _, ls := range sets {
+			// Services derive their targets unless given a literal one.
+			if !isAutoTarget(ls) && strings.TrimSpace(ls.target) != "" {
+				if ep := parseSingle(log, ls.hostname, ls.target, ls.ttl, ls.recordType); ep != nil {
+					svcEps = append(svcEps, annotate(log, ls, []*endpoint.Endpoint{ep})...)
+				}
+				continue
+			}
+			targets, terr := r.targets(ctx, svc, mode)
+			if errors.Is(terr, errUnknownSwarmTarget) {
+				log.Warn("cannot derive service targets, skipping", "err", terr)
+				continue services
+			}
+			if terr != nil {
+				return nil, fmt.Errorf("service %s: %w", svc.Spec.Name, terr)
+			}
+			svcEps = append(svcEps, annotate(log, ls, parseDerived(log, ls, targets))...)
+		}
+		eps = append(eps, markSource(svcEps, "service/"+svc.Spec.Name)...)
+	}
+	return mergeEndpoints(s.log, eps), nil
+}
+
+// AddEventHandler registers a function called when a relevant Swarm event occurs.
+func (s *SwarmSource) AddEventHandler(_ context.Context, handler func()) {
+	s.handlers = append(s.handlers, handler)
+}
+
+// Watch subscribes to Docker Events and calls registered handlers on service
+// and node events. Reconnects automatically on stream errors. Blocks until ctx
+// is cancelled.
+//
+// Task rescheduling does not emit service events; records in the "tasks" mode
+// converge on the controller's periodic reconciliation instead.
+func (s *SwarmSource) Watch(ctx context.Context) {
+	for {
+		s.runEventLoop(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.reconnectWait):
+			s.log.Warn("reconnecting to Docker event stream")
+		}
+	}
+}
+
+func (s *SwarmSource) runEventLoop(ctx context.Context) {
+	f := filters.NewArgs(
+		filters.Arg("type", string(events.ServiceEventType)),
+		filters.Arg("type", string(events.NodeEventType)),
+	)
+	msgs, errs := s.client.Events(ctx, events.ListOptions{Filters: f})
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-errs:
+			if err != nil {
+				s.log.Warn("docker event stream error", "err", err)
+			}
+			return
+		case <-msgs:
+			s.notify()
+		}
+	}
+}
+
+func (s *SwarmSource) notify() {
+	swarmEventsTotal.Inc()
+	for _, h := range s.handlers {
+		h()
+	}
+}
+
+// swarmResolver derives record targets for a service according to its
+// swarm-target mode, caching cluster-wide lookups for one Endpoints call.
+type swarmResolver struct {
+	client    swarmAPI
+	ingress   map[string]bool // ingress network IDs; nil until loaded
+	nodeAddrs []string        // nil until loaded
+}
+
+// targets returns the addresses a service's records should point to.
+func (r *swarmResolver) targets(ctx context.Context, svc swarm.Service, mode string) ([]string, error) {
+	switch mode {
+	case "", SwarmTargetVIP:
+		return r.vipTargets(ctx, svc)
+	case SwarmTargetIngress:
+		return r.ingressTargets(ctx)
+	case SwarmTargetTasks:
+		return r.taskTargets(ctx, svc)
+	default:
+		return nil, fmt.Errorf("%w %q (want %s, %s or %s)",
+			errUnknownSwarmTarget, mode, SwarmTargetVIP, SwarmTargetIngress, SwarmTargetTasks)
+	}
+}
+
+// vipTargets returns the service's virtual IPs, excluding the ingress network.
+func (r *swarmResolver) vipTargets(ctx context.Context, svc swarm.Service) ([]string, error) {
+	if err := r.loadIngress(ctx); err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, vip := range svc.Endpoint.VirtualIPs {
+		if r.ingress[vip.NetworkID] {
+			continue
+		}
+		if ip := stripPrefixLen(vip.Addr); ip != "" {
+			out = append(out, ip)
+		}
+	}
+	return out, nil
+}
+
+// ingressTargets returns the address of every ready, active node.
+func (r *swarmResolver) ingressTargets(ctx context.Context) ([]string, error) {
+	if r.nodeAddrs != nil {
+		return r.nodeAddrs, nil
+	}
+	nodes, err := r.client.NodeList(ctx, swarm.NodeListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing nodes: %w", err)
+	}
+	r.nodeAddrs = []string{}
+	for _, n := range nodes {
+		if n.Status.State != swarm.NodeStateReady || n.Spec.Availability != swarm.NodeAvailabilityActive {
+			continue
+		}
+		addr := n.Status.Addr
+		// Managers may report 0.0.0.0 here; their manager address is reliable.
+		if (addr == "" || addr == "0.0.0.0") && n.ManagerStatus != nil {
+			if host, _, err := net.SplitHostPort(n.ManagerStatus.Addr); err == nil {
+				addr = host
+			}
+		}
+		if net.ParseIP(addr) == nil || addr == "0.0.0.0" {
+			continue
+		}
+		r.nodeAddrs = append(r.nodeAddrs, addr)
+	}
+	return r.nodeAddrs, nil
+}
+
+// taskTargets returns the addresses of the service's running tasks on its
+// non-ingress networks.
+func (r *swarmResolver) taskTargets(ctx context.Context, svc swarm.Service) ([]string, error) {
+	tasks, err := r.client.TaskList(ctx, swarm.TaskListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("service", svc.ID),
+			filters.Arg("desired-state", string(swarm.TaskStateRunning)),
+		),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing tasks: %w", err)
+	}
+	var out []string
+	for _, t := range tasks {
+		if t.Status.State != swarm.TaskStateRunning {
+			continue
+		}
+		for _, att := range t.NetworksAttachments {
+			if att.Network.Spec.Ingress {
+				continue
+			}
+			for _, a := range att.Addresses {
+				if ip := stripPrefixLen(a); ip != "" {
+					out = append(out, ip)
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+// loadIngress records the IDs of the cluster's ingress networks.
+func (r *swarmResolver) loadIngress(ctx context.Context) error {
+	if r.ingress != nil {
+		return nil
+	}
+	nets, err := r.client.NetworkList(ctx, network.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("driver", "overlay")),
+	})
+	if err != nil {
+		return fmt.Errorf("listing networks: %w", err)
+	}
+	r.ingress = make(map[string]bool)
+	for _, n := range nets {
+		if n.Ingress {
+			r.ingress[n.ID] = true
+		}
+	}
+	return nil
+}
+
+// stripPrefixLen returns the address part of a CIDR string such as
+// "10.0.1.5/24", or the input itself when it is a bare IP. Returns "" when
+// neither form parses.
+func stripPrefixLen(addr string) string {
+	if ip, _, err := net.ParseCIDR(addr); err == nil {
+		return ip.String()
+	}
+	if ip := net.ParseIP(addr); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
@@ -0,0 +1,347 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/swarm"
+)
+
+// mockSwarmClient implements swarmAPI for tests.
+type mockSwarmClient struct {
+	services []swarm.Service
+	tasks    []swarm.Task
+	nodes    []swarm.Node
+	networks []network.Summary
+	listErr  error
+	taskErr  error
+
+	lastEventOpts events.ListOptions
+	eventCh       chan events.Message
+	errCh         chan error
+}
+
+func newMockSwarmClient(services []swarm.Service) *mockSwarmClient {
+	return &mockSwarmClient{
+		services: services,
+		eventCh:  make(chan events.Message, 10),
+		errCh:    make(chan error, 1),
+	}
+}
+
+func (m *mockSwarmClient) ServiceList(_ context.Context, _ swarm.ServiceListOptions) ([]swarm.Service, error) {
+	return m.services, m.listErr
+}
+
+func (m *mockSwarmClient) TaskList(_ context.Context, opts swarm.TaskListOptions) ([]swarm.Task, error) {
+	if m.taskErr != nil {
+		return nil, m.taskErr
+	}
+	var out []swarm.Task
+	for _, t := range m.tasks {
+		if opts.Filters.ExactMatch("service", t.ServiceID) {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockSwarmClient) NodeList(_ context.Context, _ swarm.NodeListOptions) ([]swarm.Node, error) {
+	return m.nodes, nil
+}
+
+func (m *mockSwarmClient) NetworkList(_ context.Context, _ network.ListOptions) ([]network.Summary, error) {
+	return m.networks, nil
+}
+
+func (m *mockSwarmClient) Events(_ context.Context, opts events.ListOptions) (<-chan events.Message, <-chan error) {
+	m.lastEventOpts = opts
+	return m.eventCh, m.errCh
+}
+
+func (m *mockSwarmClient) Close() error { return nil }
+
+func service(id, name string, labels map[string]string, vips ...swarm.EndpointVirtualIP) swarm.Service {
+	svc := swarm.Service{ID: id}
+	svc.Spec.Name = name
+	svc.Spec.Labels = labels
+	svc.Endpoint.VirtualIPs = vips
+	return svc
+}
+
+func runningTask(serviceID string, attachments ...swarm.NetworkAttachment) swarm.Task {
+	return swarm.Task{
+		ServiceID:           serviceID,
+		Status:              swarm.TaskStatus{State: swarm.TaskStateRunning},
+		NetworksAttachments: attachments,
+	}
+}
+
+func attachment(ingress bool, addrs ...string) swarm.NetworkAttachment {
+	att := swarm.NetworkAttachment{Addresses: addrs}
+	att.Network.Spec.Ingress = ingress
+	return att
+}
+
+func node(state swarm.NodeState, avail swarm.NodeAvailability, addr string) swarm.Node {
+	n := swarm.Node{Status: swarm.NodeStatus{State: state, Addr: addr}}
+	n.Spec.Availability = avail
+	return n
+}
+
+func TestSwarmSource_ExplicitTarget(t *testing.T) {
+	mock := newMockSwarmClient([]swarm.Service{
+		service("s1", "web", map[string]string{
+			"external-dns.io/hostname": "web.example.com",
+			"external-dns.io/target":   "203.0.113.10",
+		}),
+	})
+	src := newSwarmSourceWithClient(mock, slog.Default())
+
+	eps, err := src.Endpoints(context.Background())
+	if err != nil {
+		t.Fatalf("Endpoints() error = %v", err)
+	}
+	if len(eps) != 1 {
+		t.Fatalf("got %d endpoints, want 1", len(eps))
+	}
+	if eps[0].DNSName != "web.example.com" || eps[0].Targets[0] != "203.0.113.10" {
+		t.Errorf("got %v, want web.example.com → 203.0.113.10", eps[0])
+	}
+}
+
+func TestSwarmSource_DefaultModeVIP_SkipsIngressNetwork(t *testing.T) {
+	mock := newMockSwarmClient([]swarm.Service{
+		service("s1", "web", map[string]string{"external-dns.io/hostname": "web.example.com"},
+			swarm.EndpointVirtualIP{NetworkID: "ingress-id", Addr: "10.255.0.5/16"},
+			swarm.EndpointVirtualIP{NetworkID: "app-net", Addr: "10.0.1.2/24"},
+		),
+	})
+	mock.networks = []network.Summary{
+		{ID: "ingress-id", Name: "ingress", Ingress: true},
+		{ID: "app-net", Name: "app"},
+	}
+	src := newSwarmSourceWithClient(mock, slog.Default())
+
+	eps, err := src.Endpoints(context.Background())
+	if err != nil {
+		t.Fatalf("Endpoints() error = %v", err)
+	}
+	if len(eps) != 1 {
+		t.Fatalf("got %d endpoints, want 1", len(eps))
+	}
+	if eps[0].RecordType != "A" || len(eps[0].Targets) != 1 || eps[0].Targets[0] != "10.0.1.2" {
+		t.Errorf("got %v, want A 10.0.1.2", eps[0])
+	}
+}
+
+func TestSwarmSource_IngressMode_ReadyActiveNodes(t *testing.T) {
+	mock := newMockSwarmClient([]swarm.Service{
+		service("s1", "web", map[string]string{
+			"external-dns.io/hostname":     "web.example.com",
+			"external-dns.io/swarm-target": "ingress",
+		}),
+	})
+	manager := node(swarm.NodeStateReady, swarm.NodeAvailabilityActive, "0.0.0.0")
+	manager.ManagerStatus = &swarm.ManagerStatus{Addr: "192.0.2.1:2377"}
+	mock.nodes = []swarm.Node{
+		manager,
+		node(swarm.NodeStateReady, swarm.NodeAvailabilityActive, "192.0.2.2"),
+		node(swarm.NodeStateDown, swarm.NodeAvailabilityActive, "192.0.2.3"),
+		node(swarm.NodeStateReady, swarm.NodeAvailabilityDrain, "192.0.2.4"),
+	}
+	src := newSwarmSourceWithClient(mock, slog.Default())
+
+	eps, err := src.Endpoints(context.Background())
+	if err != nil {
+		t.Fatalf("Endpoints() error = %v", err)
+	}
+	if len(eps) != 1 {
+		t.Fatalf("got %d endpoints, want 1", len(eps))
+	}
+	got := eps[0].Targets
+	if len(got) != 2 || got[0] != "192.0.2.1" || got[1] != "192.0.2.2" {
+		t.Errorf("Targets = %v, want [192.0.2.1 192.0.2.2]", got)
+	}
+}
+
+func TestSwarmSource_TasksMode_SplitsAddressFamilies(t *testing.T) {
+	mock := newMockSwarmClient([]swarm.Service{
+		service("s1", "web", map[string]string{
+			"external-dns.io/hostname":     "web.example.com",
+			"external-dns.io/swarm-target": "tasks",
+			"external-dns.io/ttl":          "60",
+		}),
+	})
+	stopped := runningTask("s1", attachment(false, "10.0.1.9/24"))
+	stopped.Status.State = swarm.TaskStateShutdown
+	mock.tasks = []swarm.Task{
+		runningTask("s1", attachment(true, "10.255.0.7/16"), attachment(false, "10.0.1.3/24", "fd00::3/64")),
+		runningTask("s1", attachment(false, "10.0.1.4/24")),
+		runningTask("other", attachment(false, "10.0.1.5/24")),
+		stopped,
+	}
+	src := newSwarmSourceWithClient(mock, slog.Default())
+
+	eps, err := src.Endpoints(context.Background())
+	if err != nil {
+		t.Fatalf("Endpoints() error = %v", err)
+	}
+	if len(eps) != 2 {
+		t.Fatalf("got %d endpoints, want 2 (A + AAAA)", len(eps))
+	}
+	if eps[0].RecordType != "A" || len(eps[0].Targets) != 2 {
+		t.Errorf("A endpoint = %v, want targets [10.0.1.3 10.0.1.4]", eps[0])
+	}
+	if eps[1].RecordType != "AAAA" || eps[1].Targets[0] != "fd00::3" {
+		t.Errorf("AAAA endpoint = %v, want target fd00::3", eps[1])
+	}
+	if eps[0].TTL != 60 || eps[1].TTL != 60 {
+		t.Errorf("TTLs = %d/%d, want 60", eps[0].TTL, eps[1].TTL)
+	}
+}
+
+func TestSwarmSource_RecordTypeLabel_FiltersFamily(t *testing.T) {
+	mock := newMockSwarmClient([]swarm.Service{
+		service("s1", "web", map[string]string{
+			"external-dns.io/hostname":     "web.example.com",
+			"external-dns.io/swarm-target": "tasks",
+			"external-dns.io/record-type":  "AAAA",
+		}),
+	})
+	mock.tasks = []swarm.Task{runningTask("s1", attachment(false, "10.0.1.3/24", "fd00::3/64"))}
+	src := newSwarmSourceWithClient(mock, slog.Default())
+
+	eps, _ := src.Endpoints(context.Background())
+	if len(eps) != 1 || eps[0].RecordType != "AAAA" {
+		t.Fatalf("got %v, want a single AAAA endpoint", eps)
+	}
+}
+
+func TestSwarmSource_NoAddresses_Skipped(t *testing.T) {
+	mock := newMockSwarmClient([]swarm.Service{
+		service("s1", "web", map[string]string{
+			"external-dns.io/hostname":     "web.example.com",
+			"external-dns.io/swarm-target": "tasks",
+		}),
+	})
+	src := newSwarmSourceWithClient(mock, slog.Default())
+
+	eps, err := src.Endpoints(context.Background())
+	if err != nil {
+		t.Fatalf("Endpoints() error = %v", err)
+	}
+	if len(eps) != 0 {
+		t.Errorf("got %d endpoints, want 0 (no running tasks)", len(eps))
+	}
+}
+
+func TestSwarmSource_UnknownMode_SkippedOthersKept(t *testing.T) {
+	mock := newMockSwarmClient([]swarm.Service{
+		service("s1", "bad", map[string]string{
+			"external-dns.io/hostname":     "bad.example.com",
+			"external-dns.io/swarm-target": "bogus",
+		}),
+		service("s2", "web", map[string]string{
+			"external-dns.io/hostname": "web.example.com",
+			"external-dns.io/target":   "203.0.113.10",
+		}),
+	})
+	src := newSwarmSourceWithClient(mock, slog.Default())
+
+	eps, err := src.Endpoints(context.Background())
+	if err != nil {
+		t.Fatalf("Endpoints() error = %v", err)
+	}
+	if len(eps) != 1 || eps[0].DNSName != "web.example.com" {
+		t.Errorf("got %v, want only web.example.com", eps)
+	}
+}
+
+func TestSwarmSource_LookupError_ReturnsError(t *testing.T) {
+	// A transient API failure must fail the call rather than drop the
+	// service, or the next sync would delete its records.
+	mock := newMockSwarmClient([]swarm.Service{
+		service("s1", "app", map[string]string{
+			"external-dns.io/hostname":     "app.example.com",
+			"external-dns.io/swarm-target": "tasks",
+		}),
+		service("s2", "web", map[string]string{
+			"external-dns.io/hostname": "web.example.com",
+			"external-dns.io/target":   "203.0.113.10",
+		}),
+	})
+	mock.taskErr = errors.New("connection reset")
+	src := newSwarmSourceWithClient(mock, slog.Default())
+
+	if eps, err := src.Endpoints(context.Background()); err == nil {
+		t.Errorf("Endpoints() = %v, nil; want the lookup error", eps)
+	}
+}
+
+func TestSwarmSource_UnlabelledService_Ignored(t *testing.T) {
+	mock := newMockSwarmClient([]swarm.Service{service("s1", "db", map[string]string{"app": "db"})})
+	src := newSwarmSourceWithClient(mock, slog.Default())
+
+	eps, err := src.Endpoints(context.Background())
+	if err != nil {
+		t.Fatalf("Endpoints() error = %v", err)
+	}
+	if len(eps) != 0 {
+		t.Errorf("got %d endpoints, want 0", len(eps))
+	}
+}
+
+func TestSwarmSource_ListError(t *testing.T) {
+	mock := newMockSwarmClient(nil)
+	mock.listErr = errors.New("this node is not a swarm manager")
+	src := newSwarmSourceWithClient(mock, slog.Default())
+
+	if _, err := src.Endpoints(context.Background()); err == nil {
+		t.Error("expected error when ServiceList fails")
+	}
+}
+
+func TestSwarmSource_EventTriggers_Handler(t *testing.T) {
+	mock := newMockSwarmClient(nil)
+	src := newSwarmSourceWithClient(mock, slog.Default())
+
+	called := make(chan struct{}, 1)
+	src.AddEventHandler(context.Background(), func() { called <- struct{}{} })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go src.runEventLoop(ctx)
+
+	mock.eventCh <- events.Message{Type: events.ServiceEventType, Action: "update"}
+
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		t.Fatal("handler not called after service event")
+	}
+
+	f := mock.lastEventOpts.Filters
+	if !f.ExactMatch("type", "service") || !f.ExactMatch("type", "node") {
+		t.Errorf("event filter = %v, want type=service and type=node", f)
+	}
+}
+
+func TestStripPrefixLen(t *testing.T) {
+	cases := map[string]string{
+		"10.0.1.5/24": "10.0.1.5",
+		"10.0.1.5":    "10.0.1.5",
+		"fd00::1/64":  "fd00::1",
+		"garbage":     "",
+	}
+	for in, want := range cases {
+		if got := stripPrefixLen(in); got != want {
+			t.Errorf("stripPrefixLen(%q) = %q, want %q", in, got, want)
+		}
+	}
+}